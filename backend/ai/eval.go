@@ -0,0 +1,106 @@
+package ai
+
+import "chinese-chess-backend/xiangqi"
+
+// 局面评估：子力价值加位置分，单位为分（未过河的兵 = 100），以红方为正
+
+var pieceValue = [...]int{
+	xiangqi.Empty:    0,
+	xiangqi.General:  0,
+	xiangqi.Advisor:  200,
+	xiangqi.Elephant: 200,
+	xiangqi.Horse:    400,
+	xiangqi.Chariot:  900,
+	xiangqi.Cannon:   450,
+	xiangqi.Soldier:  100,
+}
+
+// advance 棋子离己方底线的行数（0-9）
+func advance(p xiangqi.Pos, c xiangqi.Color) int {
+	if c == xiangqi.Red {
+		return 9 - p.Y
+	}
+	return p.Y
+}
+
+// centrality 离中路的接近程度（0-4）
+func centrality(p xiangqi.Pos) int {
+	d := p.X - 4
+	if d < 0 {
+		d = -d
+	}
+	return 4 - d
+}
+
+// positionBonus 棋子所在位置的附加分
+func positionBonus(b *xiangqi.Board, piece xiangqi.Piece, p xiangqi.Pos) int {
+	adv := advance(p, piece.Color)
+	switch piece.Kind {
+	case xiangqi.Soldier:
+		if adv < 5 {
+			return 0
+		}
+		if adv == 9 {
+			// 老兵（沉底）作用有限
+			return 20
+		}
+		return 60 + 10*centrality(p) + 15*min(adv-5, 3)
+	case xiangqi.Horse:
+		bonus := 8*centrality(p) + 6*min(adv, 6)
+		if p.X == 0 || p.X == 8 {
+			bonus -= 20
+		}
+		return bonus
+	case xiangqi.Cannon:
+		if p.X == 4 {
+			return 20
+		}
+		return 4 * centrality(p)
+	case xiangqi.Chariot:
+		return 6 * mobility(b, p)
+	}
+	return 0
+}
+
+// mobility 车沿四个方向可以到达的格数
+func mobility(b *xiangqi.Board, from xiangqi.Pos) int {
+	n := 0
+	for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		for p := (xiangqi.Pos{X: from.X + d[0], Y: from.Y + d[1]}); p.Valid(); p = (xiangqi.Pos{X: p.X + d[0], Y: p.Y + d[1]}) {
+			n++
+			if !b.At(p).IsEmpty() {
+				break
+			}
+		}
+	}
+	return n
+}
+
+// Evaluate 静态评估局面，分数以红方为正
+func Evaluate(b *xiangqi.Board) int {
+	score := 0
+	for y := 0; y <= 9; y++ {
+		for x := 0; x <= 8; x++ {
+			p := xiangqi.Pos{X: x, Y: y}
+			piece := b.At(p)
+			if piece.IsEmpty() {
+				continue
+			}
+			v := pieceValue[piece.Kind] + positionBonus(b, piece, p)
+			if piece.Color == xiangqi.Red {
+				score += v
+			} else {
+				score -= v
+			}
+		}
+	}
+	return score
+}
+
+// evaluateFor 以行棋方视角评估
+func evaluateFor(b *xiangqi.Board) int {
+	if b.Turn == xiangqi.Black {
+		return -Evaluate(b)
+	}
+	return Evaluate(b)
+}
@@ -0,0 +1,29 @@
+package ai
+
+import "time"
+
+// MinLevel、MaxLevel 人机对战难度范围，与前端及 GameRecord.AILevel 一致
+const (
+	MinLevel = 1
+	MaxLevel = 6
+)
+
+// levels 各难度的搜索参数：低难度搜索浅且加入较大的随机扰动，高难度加深搜索并给更多时间
+var levels = [MaxLevel + 1]Options{
+	1: {MaxDepth: 1, Noise: 200},
+	2: {MaxDepth: 2, Noise: 100},
+	3: {MaxDepth: 3, Noise: 40, TimeLimit: time.Second},
+	4: {MaxDepth: 4, Noise: 15, TimeLimit: 2 * time.Second},
+	5: {MaxDepth: 6, TimeLimit: 3 * time.Second},
+	6: {MaxDepth: 8, TimeLimit: 5 * time.Second},
+}
+
+// ValidLevel 难度是否在 1-6 之间
+func ValidLevel(level int) bool {
+	return level >= MinLevel && level <= MaxLevel
+}
+
+// LevelOptions 返回指定难度的搜索参数，超出范围时按最近的难度处理
+func LevelOptions(level int) Options {
+	return levels[min(max(level, MinLevel), MaxLevel)]
+}
@@ -0,0 +1,388 @@
+package ai
+
+import (
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"time"
+
+	"chinese-chess-backend/config"
+	"chinese-chess-backend/xiangqi"
+)
+
+const (
+	// Infinity 搜索窗口的上界
+	Infinity = 30000
+	// MateScore 将死的分值，距将死 n 步（半回合）时为 MateScore-n
+	MateScore = 29000
+	// maxPly 搜索（含静态搜索）的最大深度
+	maxPly = 64
+	// ttSize 置换表条目数，须为 2 的幂
+	ttSize = 1 << 16
+)
+
+var ErrNoMove = errors.New("当前局面没有可走的着法")
+
+// slots 限制同时进行的搜索数量，避免人机对局与局面分析过多时占满 CPU
+var slots = make(chan struct{}, max(config.GetEnvInt("AI_MAX_CONCURRENT", runtime.NumCPU()), 1))
+
+// Options 搜索参数
+type Options struct {
+	MaxDepth  int           // 迭代加深的最大深度（半回合）
+	TimeLimit time.Duration // 搜索时间上限，0 表示不限时，到时后使用已完成的最深一层结果
+	// Noise 根节点各着法评分的随机扰动幅度（分），大于 0 时按扰动后的评分选择着法，用于降低棋力
+	Noise int
+	// History 对局中已出现过的局面哈希，搜索中再次出现按和棋计，避免无谓的循环
+	History []uint64
+}
+
+// Result 搜索结果
+type Result struct {
+	Move  xiangqi.Move   // 选择的着法
+	Score int            // 行棋方视角的评分（分）
+	Depth int            // 完成搜索的深度
+	Nodes int            // 搜索的节点数
+	PV    []xiangqi.Move // 主要变化，从 Move 开始
+}
+
+// IsMate 评分是否表示已搜到杀棋
+func IsMate(score int) bool {
+	return score >= MateScore-maxPly || score <= -MateScore+maxPly
+}
+
+type ttFlag uint8
+
+const (
+	ttExact ttFlag = iota + 1
+	ttLower        // 评分不低于 score（发生剪枝）
+	ttUpper        // 评分不高于 score（所有着法都未超过 alpha）
+)
+
+type ttEntry struct {
+	key   uint64
+	move  xiangqi.Move
+	score int32
+	depth int8
+	flag  ttFlag
+}
+
+type searcher struct {
+	board    *xiangqi.Board
+	hash     uint64
+	tt       []ttEntry
+	killers  [maxPly][2]xiangqi.Move
+	path     []uint64       // 搜索路径上的局面哈希
+	seen     map[uint64]int // 对局中已出现的局面
+	nodes    int
+	deadline time.Time
+	stopped  bool
+}
+
+// Search 在局面 b 上为行棋方搜索一步棋，不修改 b；同时进行的搜索超过 AI_MAX_CONCURRENT 时排队等待
+func Search(b *xiangqi.Board, opts Options) (Result, error) {
+	slots <- struct{}{}
+	defer func() { <-slots }()
+	s := &searcher{
+		board: b.Clone(),
+		hash:  b.Hash(),
+		tt:    make([]ttEntry, ttSize),
+		path:  make([]uint64, 0, maxPly),
+		seen:  make(map[uint64]int, len(opts.History)),
+	}
+	for _, h := range opts.History {
+		s.seen[h]++
+	}
+	if opts.TimeLimit > 0 {
+		s.deadline = time.Now().Add(opts.TimeLimit)
+	}
+	maxDepth := max(opts.MaxDepth, 1)
+
+	root := s.board.LegalMoves()
+	if len(root) == 0 {
+		return Result{}, ErrNoMove
+	}
+	s.order(root, xiangqi.Move{}, 0)
+
+	var best Result
+	for depth := 1; depth <= maxDepth; depth++ {
+		move, score, ok := s.searchRoot(root, depth, opts.Noise)
+		if !ok {
+			break
+		}
+		best = Result{Move: move, Score: score, Depth: depth}
+		// 下一层优先搜索本层的最佳着法
+		i := slices.Index(root, move)
+		root[0], root[i] = root[i], root[0]
+		if IsMate(score) {
+			break
+		}
+	}
+	if best.Depth == 0 {
+		// 第一层都未完成（时间极短），退而选择排序后的第一步
+		best = Result{Move: root[0], Score: evaluateFor(s.board), Depth: 0}
+	}
+	best.Nodes = s.nodes
+	best.PV = s.principalVariation(best.Move, max(best.Depth, 1))
+	return best, nil
+}
+
+// searchRoot 搜索根节点；Noise 为 0 时使用常规的 alpha-beta，否则对每步棋完整搜索后加入扰动
+func (s *searcher) searchRoot(moves []xiangqi.Move, depth, noise int) (xiangqi.Move, int, bool) {
+	alpha := -Infinity
+	bestMove, bestScore, bestPick := moves[0], -Infinity, -Infinity
+	for _, m := range moves {
+		lower := alpha
+		if noise > 0 {
+			lower = -Infinity
+		}
+		captured := s.play(m)
+		score := -s.negamax(depth-1, 1, -Infinity, -lower)
+		s.unplay(m, captured)
+		if s.stopped {
+			return xiangqi.Move{}, 0, false
+		}
+		pick := score
+		if noise > 0 && !IsMate(score) {
+			pick += rand.IntN(2*noise+1) - noise
+		}
+		if pick > bestPick {
+			bestMove, bestScore, bestPick = m, score, pick
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	s.store(depth, bestScore, ttExact, bestMove, 0)
+	return bestMove, bestScore, true
+}
+
+func (s *searcher) negamax(depth, ply, alpha, beta int) int {
+	s.nodes++
+	if s.nodes&2047 == 0 && !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		s.stopped = true
+	}
+	if s.stopped {
+		return 0
+	}
+	if s.repeated() {
+		return 0
+	}
+	inCheck := s.board.InCheck(s.board.Turn)
+	if inCheck && ply < maxPly/2 {
+		// 被将军时延伸一层，避免漏算杀棋
+		depth++
+	}
+	if depth <= 0 || ply >= maxPly-1 {
+		return s.quiesce(ply, alpha, beta)
+	}
+
+	var ttMove xiangqi.Move
+	if e := &s.tt[s.hash&(ttSize-1)]; e.key == s.hash {
+		ttMove = e.move
+		if int(e.depth) >= depth {
+			score := scoreFromTT(int(e.score), ply)
+			switch {
+			case e.flag == ttExact,
+				e.flag == ttLower && score >= beta,
+				e.flag == ttUpper && score <= alpha:
+				return score
+			}
+		}
+	}
+
+	moves := s.board.PseudoMoves(s.board.Turn)
+	s.order(moves, ttMove, ply)
+	origAlpha := alpha
+	best, bestMove, legal := -Infinity, xiangqi.Move{}, 0
+	for _, m := range moves {
+		mover := s.board.Turn
+		captured := s.play(m)
+		if s.board.InCheck(mover) {
+			s.unplay(m, captured)
+			continue
+		}
+		legal++
+		score := -s.negamax(depth-1, ply+1, -beta, -alpha)
+		s.unplay(m, captured)
+		if s.stopped {
+			return 0
+		}
+		if score > best {
+			best, bestMove = score, m
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			if captured.IsEmpty() && s.killers[ply][0] != m {
+				s.killers[ply][1] = s.killers[ply][0]
+				s.killers[ply][0] = m
+			}
+			break
+		}
+	}
+	if legal == 0 {
+		// 被将死或困毙，象棋中都判负
+		return -MateScore + ply
+	}
+
+	flag := ttExact
+	if best <= origAlpha {
+		flag = ttUpper
+	} else if best >= beta {
+		flag = ttLower
+	}
+	s.store(depth, best, flag, bestMove, ply)
+	return best
+}
+
+// quiesce 静态搜索：只继续计算吃子，避免在交换中途评估局面
+func (s *searcher) quiesce(ply, alpha, beta int) int {
+	s.nodes++
+	stand := evaluateFor(s.board)
+	if stand >= beta || ply >= maxPly-1 {
+		return stand
+	}
+	if stand > alpha {
+		alpha = stand
+	}
+	moves := s.board.PseudoMoves(s.board.Turn)
+	captures := moves[:0]
+	for _, m := range moves {
+		if !s.board.At(m.To).IsEmpty() {
+			captures = append(captures, m)
+		}
+	}
+	s.order(captures, xiangqi.Move{}, -1)
+	for _, m := range captures {
+		mover := s.board.Turn
+		captured := s.play(m)
+		if captured.Kind == xiangqi.General {
+			// 吃掉将帅说明上一步送将，直接判胜
+			s.unplay(m, captured)
+			return MateScore - ply
+		}
+		if s.board.InCheck(mover) {
+			s.unplay(m, captured)
+			continue
+		}
+		score := -s.quiesce(ply+1, -beta, -alpha)
+		s.unplay(m, captured)
+		if score >= beta {
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
+
+// order 着法排序：置换表着法、吃子（按被吃子价值高、吃子方价值低优先）、杀手着法、其余
+func (s *searcher) order(moves []xiangqi.Move, ttMove xiangqi.Move, ply int) {
+	weight := func(m xiangqi.Move) int {
+		if m == ttMove {
+			return 1 << 20
+		}
+		if victim := s.board.At(m.To); !victim.IsEmpty() {
+			return 1<<16 + pieceValue[victim.Kind]*16 - pieceValue[s.board.At(m.From).Kind]/16
+		}
+		if ply >= 0 {
+			if m == s.killers[ply][0] {
+				return 1 << 15
+			}
+			if m == s.killers[ply][1] {
+				return 1<<15 - 1
+			}
+		}
+		return 0
+	}
+	slices.SortStableFunc(moves, func(a, b xiangqi.Move) int {
+		return weight(b) - weight(a)
+	})
+}
+
+// play 走一步并增量更新哈希与搜索路径
+func (s *searcher) play(m xiangqi.Move) xiangqi.Piece {
+	piece := s.board.At(m.From)
+	captured := s.board.Play(m)
+	s.path = append(s.path, s.hash)
+	s.hash ^= xiangqi.PieceKey(piece, m.From) ^ xiangqi.PieceKey(piece, m.To) ^ xiangqi.PieceKey(captured, m.To) ^ xiangqi.TurnKey()
+	return captured
+}
+
+func (s *searcher) unplay(m xiangqi.Move, captured xiangqi.Piece) {
+	s.board.Unplay(m, captured)
+	s.hash = s.path[len(s.path)-1]
+	s.path = s.path[:len(s.path)-1]
+}
+
+// repeated 当前局面是否在搜索路径或对局中出现过
+func (s *searcher) repeated() bool {
+	if s.seen[s.hash] > 0 {
+		return true
+	}
+	// 同一方行棋的局面每隔两步出现一次
+	for i := len(s.path) - 2; i >= 0; i -= 2 {
+		if s.path[i] == s.hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *searcher) store(depth, score int, flag ttFlag, move xiangqi.Move, ply int) {
+	e := &s.tt[s.hash&(ttSize-1)]
+	if e.key == s.hash && int(e.depth) > depth {
+		return
+	}
+	*e = ttEntry{key: s.hash, move: move, score: int32(scoreToTT(score, ply)), depth: int8(depth), flag: flag}
+}
+
+// 置换表中的杀棋分数以“距当前节点的步数”保存，取出时换算回距根节点的步数
+func scoreToTT(score, ply int) int {
+	if score >= MateScore-maxPly {
+		return score + ply
+	}
+	if score <= -MateScore+maxPly {
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score, ply int) int {
+	if score >= MateScore-maxPly {
+		return score - ply
+	}
+	if score <= -MateScore+maxPly {
+		return score + ply
+	}
+	return score
+}
+
+// principalVariation 沿置换表还原主要变化
+func (s *searcher) principalVariation(first xiangqi.Move, depth int) []xiangqi.Move {
+	b := s.board.Clone()
+	pv := make([]xiangqi.Move, 0, depth)
+	visited := make(map[uint64]bool)
+	m := first
+	for len(pv) < depth {
+		if b.Validate(m) != nil {
+			break
+		}
+		pv = append(pv, m)
+		b.Play(m)
+		h := b.Hash()
+		if visited[h] {
+			break
+		}
+		visited[h] = true
+		e := s.tt[h&(ttSize-1)]
+		if e.key != h || e.move == (xiangqi.Move{}) {
+			break
+		}
+		m = e.move
+	}
+	return pv
+}
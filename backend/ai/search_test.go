@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+func mustFEN(t *testing.T, fen string) *xiangqi.Board {
+	t.Helper()
+	b, err := xiangqi.ParseFEN(fen)
+	if err != nil {
+		t.Fatalf("ParseFEN(%q): %v", fen, err)
+	}
+	return b
+}
+
+func TestSearchMate(t *testing.T) {
+	tests := []struct {
+		name, fen string
+		depth     int
+		pv        []string // 主要变化的前几步
+		score     int
+	}{
+		{"mate in 1", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 w", 3, []string{"e1d1"}, MateScore - 1},
+		{"mate in 2", "3a1k3/4a4/4N4/9/9/1C7/9/9/4K4/9 w", 5, []string{"b4b8", "f9e9", "b8b9"}, MateScore - 3},
+		// 黑方无论怎么走都会被一步杀
+		{"mated in 1", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 b", 4, nil, -MateScore + 2},
+	}
+	for _, tt := range tests {
+		b := mustFEN(t, tt.fen)
+		fen := b.FEN()
+		res, err := Search(b, Options{MaxDepth: tt.depth})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if res.Score != tt.score || !IsMate(res.Score) {
+			t.Errorf("%s: score = %d, want %d", tt.name, res.Score, tt.score)
+		}
+		var pv []string
+		for _, m := range res.PV {
+			pv = append(pv, notation.ToICCS(m))
+		}
+		if len(pv) < len(tt.pv) || !slices.Equal(pv[:len(tt.pv)], tt.pv) {
+			t.Errorf("%s: PV = %v, want prefix %v", tt.name, pv, tt.pv)
+		}
+		if len(res.PV) == 0 || res.PV[0] != res.Move {
+			t.Errorf("%s: PV %v does not start with move %+v", tt.name, pv, res.Move)
+		}
+		// 搜到杀棋后不再加深
+		if res.Depth > len(tt.pv)+1 && len(tt.pv) > 0 {
+			t.Errorf("%s: searched to depth %d after finding the mate", tt.name, res.Depth)
+		}
+		if b.FEN() != fen {
+			t.Errorf("%s: Search modified the board: %s", tt.name, b.FEN())
+		}
+	}
+}
+
+func TestSearchNoMove(t *testing.T) {
+	b := mustFEN(t, "3k5/9/9/9/9/9/9/9/9/3RK4 b")
+	if _, err := Search(b, Options{MaxDepth: 2}); !errors.Is(err, ErrNoMove) {
+		t.Errorf("Search on a mated position: err = %v, want ErrNoMove", err)
+	}
+}
+
+func TestIsMate(t *testing.T) {
+	tests := []struct {
+		score int
+		want  bool
+	}{
+		{0, false},
+		{1500, false},
+		{MateScore - maxPly - 1, false},
+		{MateScore - maxPly, true},
+		{MateScore - 1, true},
+		{MateScore, true},
+		{-MateScore + maxPly + 1, false},
+		{-MateScore + maxPly, true},
+		{-MateScore + 2, true},
+	}
+	for _, tt := range tests {
+		if got := IsMate(tt.score); got != tt.want {
+			t.Errorf("IsMate(%d) = %v, want %v", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestLevelOptions(t *testing.T) {
+	if !reflect.DeepEqual(LevelOptions(0), LevelOptions(MinLevel)) || !reflect.DeepEqual(LevelOptions(99), LevelOptions(MaxLevel)) {
+		t.Errorf("LevelOptions does not clamp out-of-range levels")
+	}
+	for level := MinLevel; level < MaxLevel; level++ {
+		easy, hard := LevelOptions(level), LevelOptions(level+1)
+		if easy.MaxDepth > hard.MaxDepth || easy.Noise < hard.Noise {
+			t.Errorf("level %d %+v is not weaker than level %d %+v", level, easy, level+1, hard)
+		}
+	}
+	if ValidLevel(MinLevel-1) || ValidLevel(MaxLevel+1) || !ValidLevel(MinLevel) || !ValidLevel(MaxLevel) {
+		t.Errorf("ValidLevel accepts the wrong range")
+	}
+}
+
+// TestLevelNoiseLegal 加入随机扰动后选出的仍是合法着法，包括被将军时
+func TestLevelNoiseLegal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var positions []*xiangqi.Board
+	b := xiangqi.NewBoard()
+	for ply := 0; ply < 60 && b.Status() == xiangqi.Ongoing; ply++ {
+		if ply%6 == 0 || b.InCheck(b.Turn) {
+			positions = append(positions, b.Clone())
+		}
+		legal := b.LegalMoves()
+		b.Play(legal[r.Intn(len(legal))])
+	}
+	// 被将军且只有少数应将着法的局面
+	positions = append(positions, mustFEN(t, "3k5/9/9/9/4r4/9/9/9/9/4K4 w"), mustFEN(t, "3k5/9/9/9/9/9/9/9/4r4/4K4 w"))
+
+	for level := MinLevel; level <= MaxLevel; level++ {
+		opts := LevelOptions(level)
+		opts.MaxDepth = min(opts.MaxDepth, 2)
+		opts.TimeLimit = 200 * time.Millisecond
+		for _, pos := range positions {
+			if pos.Status() != xiangqi.Ongoing {
+				continue
+			}
+			legal := pos.LegalMoves()
+			for i := 0; i < 5; i++ {
+				res, err := Search(pos, opts)
+				if err != nil {
+					t.Fatalf("level %d %s: %v", level, pos.FEN(), err)
+				}
+				if !slices.Contains(legal, res.Move) || pos.Validate(res.Move) != nil {
+					t.Fatalf("level %d %s: illegal move %s", level, pos.FEN(), notation.ToICCS(res.Move))
+				}
+			}
+		}
+	}
+}
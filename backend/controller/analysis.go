@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+	analysisDto "chinese-chess-backend/dto/analysis"
+	"chinese-chess-backend/service"
+)
+
+type AnalysisController struct {
+	analysisService *service.AnalysisService
+}
+
+func NewAnalysisController(s *service.AnalysisService) *AnalysisController {
+	return &AnalysisController{
+		analysisService: s,
+	}
+}
+
+// Analyze 分析棋盘上的局面，返回最佳着法、评分与主要变化
+func (ac *AnalysisController) Analyze(c *gin.Context) {
+	var req analysisDto.AnalyzeRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	resp, err := ac.analysisService.Analyze(c.Request.Context(), &req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// RequestRecordAnalysis 发起对局记录的赛后分析，分析在后台进行，返回当前状态
+func (ac *AnalysisController) RequestRecordAnalysis(c *gin.Context) {
+	req, ok := bindRecordAnalysis(c)
+	if !ok {
+		return
+	}
+	resp, err := ac.analysisService.RequestRecordAnalysis(req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// GetRecordAnalysis 查询对局记录的赛后分析结果：每步的评分、推荐着法与分类，以及双方准确率
+func (ac *AnalysisController) GetRecordAnalysis(c *gin.Context) {
+	req, ok := bindRecordAnalysis(c)
+	if !ok {
+		return
+	}
+	resp, err := ac.analysisService.GetRecordAnalysis(req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+func bindRecordAnalysis(c *gin.Context) (*analysisDto.RecordAnalysisRequest, bool) {
+	userID := c.GetInt("userId")
+	if userID == 0 {
+		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
+		return nil, false
+	}
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("非法的记录ID"))
+		return nil, false
+	}
+	return &analysisDto.RecordAnalysisRequest{UserID: userID, RecordID: uint(id64)}, true
+}
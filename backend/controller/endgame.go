@@ -35,25 +35,104 @@ func (ec *EndgameController) GetProgress(c *gin.Context) {
 	dto.SuccessResponse(c, dto.WithData(resp))
 }
 
-// SaveProgress 保存当前登录用户在某个残局关卡的本次挑战结果
+// endgameDeprecated 旧版客户端提交残局结果时的提示
+const endgameDeprecated = "该接口已废弃：残局挑战请通过 websocket 与服务端 AI 对弈，进度与通关奖励由服务端结算"
+
+// SaveProgress 已废弃：残局挑战改在 websocket 上与服务端 AI 对弈，进度由服务端按对局结果记录
+// 保留路由以便旧版客户端得到明确的提示，而不是 404
 func (ec *EndgameController) SaveProgress(c *gin.Context) {
+	dto.ErrorResponse(c, dto.WithMessage(endgameDeprecated))
+}
+
+// ListScenarios 获取按难度分组的残局关卡目录及当前用户在各关卡的进度
+func (ec *EndgameController) ListScenarios(c *gin.Context) {
 	userID := c.GetInt("userId")
 	if userID == 0 {
 		dto.ErrorResponse(c, dto.WithMessage("未登录"))
 		return
 	}
+	req := endgameDto.ListScenariosRequest{UserID: userID}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
+		return
+	}
+	resp, err := ec.endgameService.ListScenarios(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
 
-	var req endgameDto.SaveEndgameProgressRequest
+// AdminListScenarios 获取全部关卡（含已下架的）
+func (ec *EndgameController) AdminListScenarios(c *gin.Context) {
+	resp, err := ec.endgameService.ListAllScenarios()
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// CreateScenario 新建关卡
+func (ec *EndgameController) CreateScenario(c *gin.Context) {
+	var req endgameDto.SaveScenarioRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	resp, err := ec.endgameService.CreateScenario(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// UpdateScenario 修改关卡，关卡ID取自路径
+func (ec *EndgameController) UpdateScenario(c *gin.Context) {
+	var req endgameDto.SaveScenarioRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
 		return
 	}
-
-	if err := ec.endgameService.SaveProgress(userID, &req); err != nil {
+	req.ID = c.Param("id")
+	if err := req.Examine(); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	resp, err := ec.endgameService.UpdateScenario(&req)
+	if err != nil {
 		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
 		return
 	}
-	dto.SuccessResponse(c, dto.WithMessage("保存成功"))
+	dto.SuccessResponse(c, dto.WithData(resp))
 }
 
+// ReorderScenarios 调整关卡顺序
+func (ec *EndgameController) ReorderScenarios(c *gin.Context) {
+	var req endgameDto.ReorderScenariosRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	if err := ec.endgameService.ReorderScenarios(&req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c)
+}
 
+// RetireScenario 下架或恢复关卡
+func (ec *EndgameController) RetireScenario(c *gin.Context) {
+	var req endgameDto.RetireScenarioRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	if err := ec.endgameService.RetireScenario(c.Param("id"), req.Retired); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+	openingDto "chinese-chess-backend/dto/opening"
+	"chinese-chess-backend/service"
+)
+
+type OpeningController struct {
+	openingService *service.OpeningService
+}
+
+func NewOpeningController(s *service.OpeningService) *OpeningController {
+	return &OpeningController{
+		openingService: s,
+	}
+}
+
+// BookMoves 查询局面所属的开局及开局库中的后续着法
+func (oc *OpeningController) BookMoves(c *gin.Context) {
+	var req openingDto.BookMovesRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	resp, err := oc.openingService.BookMoves(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// GetStats 按开局统计当前用户的战绩
+func (oc *OpeningController) GetStats(c *gin.Context) {
+	userID := c.GetInt("userId")
+	if userID == 0 {
+		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
+		return
+	}
+	req := openingDto.OpeningStatsRequest{UserID: userID}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
+		return
+	}
+	if err := req.Examine(); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	resp, err := oc.openingService.Stats(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+	positionDto "chinese-chess-backend/dto/position"
+	"chinese-chess-backend/service"
+)
+
+type PositionController struct {
+	positionService *service.PositionService
+}
+
+func NewPositionController(s *service.PositionService) *PositionController {
+	return &PositionController{
+		positionService: s,
+	}
+}
+
+// GetRecordFEN 返回对局记录在指定步数之后的 FEN，query 参数 ply 为空时返回终局
+func (pc *PositionController) GetRecordFEN(c *gin.Context) {
+	userID := c.GetInt("userId")
+	if userID == 0 {
+		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
+		return
+	}
+
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("非法的记录ID"))
+		return
+	}
+
+	req := positionDto.GetRecordFENRequest{UserID: userID, RecordID: uint(id64)}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
+		return
+	}
+
+	resp, err := pc.positionService.GetRecordFEN(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// ValidateFEN 校验用户提交的 FEN，返回规范化结果与局面信息
+func (pc *PositionController) ValidateFEN(c *gin.Context) {
+	var req positionDto.ValidateFENRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(pc.positionService.ValidateFEN(&req)))
+}
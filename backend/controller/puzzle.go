@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+	puzzleDto "chinese-chess-backend/dto/puzzle"
+	"chinese-chess-backend/service"
+)
+
+type PuzzleController struct {
+	puzzleService *service.PuzzleService
+}
+
+func NewPuzzleController(s *service.PuzzleService) *PuzzleController {
+	return &PuzzleController{
+		puzzleService: s,
+	}
+}
+
+// Daily 获取今天的每日一题及当前用户的解答进度
+func (pc *PuzzleController) Daily(c *gin.Context) {
+	resp, err := pc.puzzleService.DailyPuzzle(c.GetInt("userId"))
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// Next 获取一道与当前用户习题等级分相近的计分习题
+func (pc *PuzzleController) Next(c *gin.Context) {
+	resp, err := pc.puzzleService.NextPuzzle(c.GetInt("userId"))
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// SubmitMove 提交一步棋，由服务端按解法校验
+func (pc *PuzzleController) SubmitMove(c *gin.Context) {
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("非法的习题ID"))
+		return
+	}
+	var req puzzleDto.SubmitMoveRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	req.PuzzleID = uint(id64)
+	req.UserID = c.GetInt("userId")
+	resp, err := pc.puzzleService.SubmitMove(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// GetRating 获取当前用户的习题等级分
+func (pc *PuzzleController) GetRating(c *gin.Context) {
+	resp, err := pc.puzzleService.GetRating(c.GetInt("userId"))
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// CreatePuzzle 录入习题
+func (pc *PuzzleController) CreatePuzzle(c *gin.Context) {
+	var req puzzleDto.CreatePuzzleRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	resp, err := pc.puzzleService.CreatePuzzle(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// MinePuzzles 从已分析对局的败着中挖掘习题，挖掘在后台进行
+func (pc *PuzzleController) MinePuzzles(c *gin.Context) {
+	var req puzzleDto.MinePuzzlesRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	resp, err := pc.puzzleService.MinePuzzles(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
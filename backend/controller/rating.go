@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+	"chinese-chess-backend/service"
+)
+
+type RatingController struct {
+	ratingService *service.RatingService
+}
+
+func NewRatingController(s *service.RatingService) *RatingController {
+	return &RatingController{
+		ratingService: s,
+	}
+}
+
+// GetRating 获取当前用户的等级分与最近的变化记录
+func (rc *RatingController) GetRating(c *gin.Context) {
+	userID := c.GetInt("userId")
+	if userID == 0 {
+		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
+		return
+	}
+	resp, err := rc.ratingService.GetRating(userID)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
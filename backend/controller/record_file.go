@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+	recordDto "chinese-chess-backend/dto/record"
+	"chinese-chess-backend/service"
+)
+
+// maxRecordFileSize 导入棋谱文件的大小上限
+const maxRecordFileSize int64 = 1 * 1024 * 1024
+
+type RecordFileController struct {
+	recordFileService *service.RecordFileService
+}
+
+func NewRecordFileController(s *service.RecordFileService) *RecordFileController {
+	return &RecordFileController{
+		recordFileService: s,
+	}
+}
+
+// bindExportRequest 解析导出请求中的记录 ID 与 query 参数
+func bindExportRequest(c *gin.Context) (*recordDto.ExportRecordRequest, bool) {
+	userID := c.GetInt("userId")
+	if userID == 0 {
+		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
+		return nil, false
+	}
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("非法的记录ID"))
+		return nil, false
+	}
+	req := &recordDto.ExportRecordRequest{UserID: userID, RecordID: uint(id64)}
+	if err := c.ShouldBindQuery(req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
+		return nil, false
+	}
+	return req, true
+}
+
+func sendRecordFile(c *gin.Context, resp *recordDto.ExportRecordResponse) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", resp.Filename))
+	c.Data(200, resp.ContentType, resp.Data)
+}
+
+// ExportPGN 下载对局记录的 PGN 棋谱，query 参数 notation 指定记谱格式，charset 指定文本编码
+func (rc *RecordFileController) ExportPGN(c *gin.Context) {
+	req, ok := bindExportRequest(c)
+	if !ok {
+		return
+	}
+	resp, err := rc.recordFileService.ExportPGN(req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	sendRecordFile(c, resp)
+}
+
+// ExportXQF 下载对局记录的 XQF 棋谱
+func (rc *RecordFileController) ExportXQF(c *gin.Context) {
+	req, ok := bindExportRequest(c)
+	if !ok {
+		return
+	}
+	resp, err := rc.recordFileService.ExportXQF(req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	sendRecordFile(c, resp)
+}
+
+// readRecordFile 读取表单字段 file 中上传的棋谱
+func readRecordFile(c *gin.Context) ([]byte, bool) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("缺少文件或字段名应为 file"))
+		return nil, false
+	}
+	if file.Size > maxRecordFileSize {
+		dto.ErrorResponse(c, dto.WithMessage("文件过大，最大 1MB"))
+		return nil, false
+	}
+	f, err := file.Open()
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("读取文件失败"))
+		return nil, false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxRecordFileSize))
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("读取文件失败"))
+		return nil, false
+	}
+	return data, true
+}
+
+// ImportPGN 上传 PGN 棋谱并保存为新的对局记录
+func (rc *RecordFileController) ImportPGN(c *gin.Context) {
+	userID := c.GetInt("userId")
+	if userID == 0 {
+		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
+		return
+	}
+	data, ok := readRecordFile(c)
+	if !ok {
+		return
+	}
+	resp, err := rc.recordFileService.ImportPGN(userID, data)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp), dto.WithMessage("导入成功"))
+}
+
+// ImportXQF 上传 XQF 棋谱并保存为新的对局记录
+func (rc *RecordFileController) ImportXQF(c *gin.Context) {
+	userID := c.GetInt("userId")
+	if userID == 0 {
+		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
+		return
+	}
+	data, ok := readRecordFile(c)
+	if !ok {
+		return
+	}
+	resp, err := rc.recordFileService.ImportXQF(userID, data)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp), dto.WithMessage("导入成功"))
+}
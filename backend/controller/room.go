@@ -32,8 +32,13 @@ func (rc *RoomController) GetSpareRooms(c *gin.Context) {
 		return
 	}
 	fmt.Println(infos)
+	// 匹配队列人数为可选信息
+	var queues []room.QueueInfo
+	if q, ok := c.Get("queues"); ok {
+		queues, _ = q.([]room.QueueInfo)
+	}
 	resp, err := rc.roomService.GetSpareRooms(room.GetSpareRoomsRequest{
-		Infos: infos})
+		Infos: infos, Queues: queues})
 	if err != nil {
 		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
 		return
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+	tournamentDto "chinese-chess-backend/dto/tournament"
+	"chinese-chess-backend/service"
+)
+
+type TournamentController struct {
+	tournamentService *service.TournamentService
+}
+
+func NewTournamentController(s *service.TournamentService) *TournamentController {
+	return &TournamentController{
+		tournamentService: s,
+	}
+}
+
+// tournamentID 解析路径中的比赛ID
+func tournamentID(c *gin.Context) (uint, bool) {
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("非法的比赛ID"))
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// Create 创建比赛
+func (tc *TournamentController) Create(c *gin.Context) {
+	var req tournamentDto.CreateTournamentRequest
+	if err := dto.BindData(c, &req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	req.UserID = c.GetInt("userId")
+	resp, err := tc.tournamentService.Create(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// List 获取比赛列表
+func (tc *TournamentController) List(c *gin.Context) {
+	req := tournamentDto.ListTournamentsRequest{UserID: c.GetInt("userId")}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
+		return
+	}
+	resp, err := tc.tournamentService.List(&req)
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// Detail 获取比赛详情：名次表与各轮对阵
+func (tc *TournamentController) Detail(c *gin.Context) {
+	id, ok := tournamentID(c)
+	if !ok {
+		return
+	}
+	resp, err := tc.tournamentService.Detail(id, c.GetInt("userId"))
+	if err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithData(resp))
+}
+
+// Join 报名参赛
+func (tc *TournamentController) Join(c *gin.Context) {
+	tc.action(c, tc.tournamentService.Join, "报名成功")
+}
+
+// Leave 开赛前退出比赛
+func (tc *TournamentController) Leave(c *gin.Context) {
+	tc.action(c, tc.tournamentService.Leave, "已退出比赛")
+}
+
+// Start 创建者手动开赛，对阵房间由对局服务随后创建
+func (tc *TournamentController) Start(c *gin.Context) {
+	tc.action(c, tc.tournamentService.Start, "比赛已开始")
+}
+
+// Cancel 创建者在开赛前取消比赛
+func (tc *TournamentController) Cancel(c *gin.Context) {
+	tc.action(c, tc.tournamentService.Cancel, "比赛已取消")
+}
+
+func (tc *TournamentController) action(c *gin.Context, fn func(id uint, userID int) error, message string) {
+	id, ok := tournamentID(c)
+	if !ok {
+		return
+	}
+	if err := fn(id, c.GetInt("userId")); err != nil {
+		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
+		return
+	}
+	dto.SuccessResponse(c, dto.WithMessage(message))
+}
@@ -2,7 +2,6 @@ package controller
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path"
 	"strings"
@@ -14,8 +13,8 @@ import (
 	"chinese-chess-backend/dto"
 	endgameDto "chinese-chess-backend/dto/endgame"
 	"chinese-chess-backend/dto/user"
-	recordModel "chinese-chess-backend/model/record"
 	userModel "chinese-chess-backend/model/user"
+	"chinese-chess-backend/notation"
 	"chinese-chess-backend/service"
 )
 
@@ -99,6 +98,14 @@ func (uc *UserController) GetGameRecords(c *gin.Context) {
 	}
 
 	req := &user.GetGameRecordsRequest{UserID: userID.(int)}
+	if raw := c.Query("notation"); raw != "" {
+		format, ok := notation.ParseFormat(raw)
+		if !ok {
+			dto.ErrorResponse(c, dto.WithMessage("不支持的记谱格式，可选 chinese、wxf、iccs"))
+			return
+		}
+		req.Notation = string(format)
+	}
 	resp, err := uc.userService.GetGameRecords(req)
 	if err != nil {
 		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
@@ -107,6 +114,18 @@ func (uc *UserController) GetGameRecords(c *gin.Context) {
 	dto.SuccessResponse(c, dto.WithData(resp))
 }
 
+// SaveGameRecord 已废弃：人机对局改在 websocket 上与服务端 AI 对弈（messageCreateAI），对局记录由服务端保存
+// 保留路由以便旧版客户端得到明确的提示，而不是 404
+func (uc *UserController) SaveGameRecord(c *gin.Context) {
+	dto.ErrorResponse(c, dto.WithMessage("该接口已废弃：人机对局请通过 websocket 创建，对局记录由服务端自动保存"))
+}
+
+// EndgameComplete 已废弃：残局通关由服务端按对局结果判定并发放经验奖励
+// 保留路由以便旧版客户端得到明确的提示，而不是 404
+func (uc *UserController) EndgameComplete(c *gin.Context) {
+	dto.ErrorResponse(c, dto.WithMessage(endgameDeprecated))
+}
+
 // EndgameGetProgress 获取当前用户某关卡的尝试次数与最小步数
 func (uc *UserController) EndgameGetProgress(c *gin.Context) {
 	userID := c.GetInt("userId")
@@ -123,197 +142,6 @@ func (uc *UserController) EndgameGetProgress(c *gin.Context) {
 	dto.SuccessResponse(c, dto.WithData(resp))
 }
 
-// EndgameRecordProgress 记录一局完成（胜/负）并更新尝试次数与最小步数
-func (uc *UserController) EndgameRecordProgress(c *gin.Context) {
-	userID := c.GetInt("userId")
-	if userID == 0 {
-		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
-		return
-	}
-	var req endgameDto.SaveEndgameProgressRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
-		return
-	}
-	svc := service.NewEndgameService()
-	err := svc.SaveProgress(userID, &req)
-	if err != nil {
-		dto.ErrorResponse(c, dto.WithMessage(err.Error()))
-		return
-	}
-	dto.SuccessResponse(c, dto.WithMessage("进度已保存"))
-}
-
-// EndgameComplete 残局挑战结算：
-// - 仅在成功时根据难度发放经验（中级+50，高级+100，其它0）
-// - 同一用户同一关卡仅首次通关发放（使用Redis标记）
-func (uc *UserController) EndgameComplete(c *gin.Context) {
-	userID := c.GetInt("userId")
-	if userID == 0 {
-		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
-		return
-	}
-
-	var req user.EndgameCompleteRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
-		return
-	}
-
-	// 失败直接返回（不加经验）
-	if !req.Success {
-		dto.SuccessResponse(c, dto.WithData(user.EndgameCompleteResponse{Awarded: 0, AlreadyAwarded: false}))
-		return
-	}
-
-	// 仅首次通关奖励：使用 Redis 记录
-	key := fmt.Sprintf("endgame:completed:%d:%s", userID, strings.TrimSpace(req.ScenarioID))
-	if val, err := database.GetValue(key); err == nil && val == "1" {
-		dto.SuccessResponse(c, dto.WithData(user.EndgameCompleteResponse{Awarded: 0, AlreadyAwarded: true}))
-		return
-	}
-
-	// 计算奖励
-	var award int
-	switch strings.TrimSpace(req.Difficulty) {
-	case "高级":
-		award = 100
-	case "中级":
-		award = 50
-	default:
-		award = 0
-	}
-
-	if award > 0 {
-		if err := uc.userService.AddUserExp(userID, award); err != nil {
-			log.Printf("add exp failed (endgame): %v", err)
-			dto.ErrorResponse(c, dto.WithMessage("发放经验失败"))
-			return
-		}
-		// 标记已完成（永久，不设过期）
-		_ = database.SetValue(key, "1", 0)
-		dto.SuccessResponse(c, dto.WithData(user.EndgameCompleteResponse{Awarded: award, AlreadyAwarded: false}))
-		return
-	}
-
-	// award 为0（初级或未覆盖的难度）也做完成标记，但不加经验
-	_ = database.SetValue(key, "1", 0)
-	dto.SuccessResponse(c, dto.WithData(user.EndgameCompleteResponse{Awarded: 0, AlreadyAwarded: false}))
-}
-
-// SaveGameRecord 接收前端提交的对局记录并持久化（用于人机对战保存）
-func (uc *UserController) SaveGameRecord(c *gin.Context) {
-	userID := c.GetInt("userId")
-	if userID == 0 {
-		dto.ErrorResponse(c, dto.WithMessage("未获取到用户信息"))
-		return
-	}
-
-	var req user.SaveGameRecordRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		dto.ErrorResponse(c, dto.WithMessage("参数错误"))
-		return
-	}
-
-	// 调试日志：记录接收到的保存请求（部分字段）
-	log.Printf("SaveGameRecord called by user %d: is_red=%v result=%d history_len=%d ai_level=%d", userID, req.IsRed, req.Result, len(req.History), req.AILevel)
-
-	// 构建 GameRecord 数据
-	var redID uint
-	var blackID uint
-	if req.IsRed {
-		redID = uint(userID)
-		blackID = 0 // AI 对手，黑方 ID 为 0
-	} else {
-		blackID = uint(userID)
-		redID = 0 // AI 对手，红方 ID 为 0
-	}
-
-	// 将前端提交的 result（0=胜,1=负,2=和，从提交者视角）转换为后端的存储格式（0=红方胜,1=黑方胜,2=和）
-	var storedResult int
-	if req.Result == 2 {
-		storedResult = 2
-	} else if req.IsRed {
-		// 提交者为红方
-		if req.Result == 0 {
-			storedResult = 0
-		} else {
-			storedResult = 1
-		}
-	} else {
-		// 提交者为黑方
-		if req.Result == 0 {
-			storedResult = 1
-		} else {
-			storedResult = 0
-		}
-	}
-
-	startTime := req.StartTime
-	if startTime.IsZero() {
-		startTime = time.Now()
-	}
-
-	// 默认 AI 难度为 3（如果前端未传）
-	ailevel := req.AILevel
-	if ailevel < 1 || ailevel > 6 {
-		ailevel = 3
-	}
-
-	rec := recordModel.GameRecord{
-		RedID:     redID,
-		BlackID:   blackID,
-		StartTime: startTime,
-		Result:    storedResult,
-		History:   req.History,
-		GameType:  1, // 人机对战
-		AILevel:   ailevel,
-	}
-
-	if err := database.GetMysqlDb().Create(&rec).Error; err != nil {
-		dto.ErrorResponse(c, dto.WithMessage("保存对局记录失败"))
-		return
-	}
-
-	// 经验值结算（人机对战）：
-	// 简单(<=2)：赢+5 输+1；中等(3-4)：赢+20 输+5；困难(>=5)：赢+30 输+10
-	// req.Result 为提交者视角：0=胜，1=负，2=和（AI无和棋，这里忽略2）
-	var expDelta int
-	if req.Result == 0 || req.Result == 1 {
-		if ailevel <= 2 {
-			if req.Result == 0 {
-				expDelta = 5
-			} else {
-				expDelta = 1
-			}
-		} else if ailevel <= 4 {
-			if req.Result == 0 {
-				expDelta = 20
-			} else {
-				expDelta = 5
-			}
-		} else {
-			if req.Result == 0 {
-				expDelta = 30
-			} else {
-				expDelta = 10
-			}
-		}
-		if err := uc.userService.AddUserExp(userID, expDelta); err != nil {
-			log.Printf("add exp failed (AI): %v", err)
-		}
-	}
-
-	// 保存成功后，按规则更新用户统计：
-	// 人机对战只有一侧是用户（另一侧为0），这里仅更新当前用户统计；
-	// UpdateUserStats 会按规则（AI仅胜负计数、随机匹配全计数）重新聚合。
-	if err := uc.userService.UpdateUserStats(userID); err != nil {
-		log.Printf("UpdateUserStats failed after AI record save: %v", err)
-	}
-
-	dto.SuccessResponse(c, dto.WithMessage("保存成功"))
-}
-
 func (uc *UserController) SendVCode(c *gin.Context) {
 	var req user.SendVCodeRequest
 	err := dto.BindData(c, &req)
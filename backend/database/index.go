@@ -4,10 +4,15 @@ import (
 	"github.com/joho/godotenv"
 
 	"os"
+	"testing"
 
 )
 
 func init() {
+	// 单元测试不连接数据库，用到时再按环境变量连接
+	if testing.Testing() {
+		return
+	}
 	if(os.Getenv("GO_ENV") != "docker") {
 		err := godotenv.Load()
 		if err != nil {
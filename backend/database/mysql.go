@@ -95,3 +95,17 @@ func initMysql() {
 	log.Println("Database connection established successfully")
 }
 
+
+// CloseMysql 关闭数据库连接池
+func CloseMysql() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -56,3 +56,59 @@ func DeleteValue(key string) error {
 	}
 	return rdb.Del(ctx, key).Err()
 }
+
+// HashSet sets a field of a Redis hash
+func HashSet(key, field, value string) error {
+	if rdb == nil {
+		initRedis()
+	}
+	return rdb.HSet(ctx, key, field, value).Err()
+}
+
+// HashGetAll retrieves all fields of a Redis hash
+func HashGetAll(key string) (map[string]string, error) {
+	if rdb == nil {
+		initRedis()
+	}
+	return rdb.HGetAll(ctx, key).Result()
+}
+
+// HashDelete removes fields from a Redis hash
+func HashDelete(key string, fields ...string) error {
+	if rdb == nil {
+		initRedis()
+	}
+	return rdb.HDel(ctx, key, fields...).Err()
+}
+
+// Incr atomically increments a counter and returns the new value
+func Incr(key string) (int64, error) {
+	if rdb == nil {
+		initRedis()
+	}
+	return rdb.Incr(ctx, key).Result()
+}
+
+// Publish sends a message to a Redis pub/sub channel
+func Publish(channel, message string) error {
+	if rdb == nil {
+		initRedis()
+	}
+	return rdb.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe subscribes to a Redis pub/sub channel; the returned PubSub reconnects automatically
+func Subscribe(channel string) *redis.PubSub {
+	if rdb == nil {
+		initRedis()
+	}
+	return rdb.Subscribe(ctx, channel)
+}
+
+// CloseRedis closes the Redis client
+func CloseRedis() error {
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Close()
+}
@@ -0,0 +1,76 @@
+package analysis
+
+import "fmt"
+
+const (
+	MaxDepth    = 30
+	MaxMoveTime = 10000 // 毫秒
+	MaxMoves    = 600
+)
+
+// AnalyzeRequest 分析局面：从 fen（为空时为开局）走完 moves（ICCS 坐标）后，为行棋方搜索最佳着法
+// depth 与 movetime（毫秒）都为空时默认思考 1 秒
+type AnalyzeRequest struct {
+	FEN      string   `json:"fen"`
+	Moves    []string `json:"moves"`
+	Depth    int      `json:"depth"`
+	MoveTime int      `json:"movetime"`
+}
+
+func (r *AnalyzeRequest) Examine() error {
+	if r.Depth < 0 || r.Depth > MaxDepth {
+		return fmt.Errorf("搜索深度应在 0-%d 之间", MaxDepth)
+	}
+	if r.MoveTime < 0 || r.MoveTime > MaxMoveTime {
+		return fmt.Errorf("思考时间应在 0-%d 毫秒之间", MaxMoveTime)
+	}
+	if len(r.Moves) > MaxMoves {
+		return fmt.Errorf("着法不能超过 %d 步", MaxMoves)
+	}
+	return nil
+}
+
+type AnalyzeResponse struct {
+	Engine       string   `json:"engine"` // 给出分析的引擎名称
+	FEN          string   `json:"fen"`    // 被分析的局面
+	BestMove     string   `json:"best_move"`
+	BestMoveText string   `json:"best_move_text"` // 中文纵线记谱
+	Ponder       string   `json:"ponder,omitempty"`
+	Score        int      `json:"score"`          // 行棋方视角的分数，100 约为一个兵
+	Mate         int      `json:"mate,omitempty"` // 非 0 表示 n 回合内杀棋，负数表示行棋方被杀
+	Depth        int      `json:"depth"`
+	Nodes        int64    `json:"nodes"`
+	PV           []string `json:"pv"`
+	PVText       []string `json:"pv_text"`
+}
+
+// RecordAnalysisRequest 发起或查询对局记录的赛后分析
+type RecordAnalysisRequest struct {
+	UserID   int  `json:"-"`
+	RecordID uint `json:"-"`
+}
+
+// RecordAnalysisResponse 赛后分析结果，status 为 done 时 moves 才有内容
+type RecordAnalysisResponse struct {
+	RecordID      uint               `json:"record_id"`
+	Status        string             `json:"status"` // pending / running / done / failed
+	Engine        string             `json:"engine,omitempty"`
+	Error         string             `json:"error,omitempty"`
+	RedAccuracy   float64            `json:"red_accuracy"`
+	BlackAccuracy float64            `json:"black_accuracy"`
+	Moves         []MoveAnalysisItem `json:"moves"`
+}
+
+// MoveAnalysisItem 一步棋的分析，分数为红方视角
+type MoveAnalysisItem struct {
+	Ply          int    `json:"ply"`
+	Color        string `json:"color"` // 走子方 red / black
+	Move         string `json:"move"`
+	MoveText     string `json:"move_text"`
+	Score        int    `json:"score"` // 走子后的局面评分
+	BestMove     string `json:"best_move"`
+	BestMoveText string `json:"best_move_text"`
+	BestScore    int    `json:"best_score"` // 按推荐走法的评分
+	Loss         int    `json:"loss"`       // 走子方损失的分数
+	Class        string `json:"class"`      // best / good / inaccuracy / mistake / blunder
+}
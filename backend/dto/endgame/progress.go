@@ -11,6 +11,7 @@ type ScenarioProgress struct {
 	Attempts   int    `json:"attempts"`
 	BestSteps  *int   `json:"best_steps,omitempty"`
 	LastResult string `json:"last_result,omitempty"` // "win"/"lose"
+	Cleared    bool   `json:"cleared"`               // 是否已由服务端裁定通关
 }
 
 // GetEndgameProgressResponse 返回当前用户所有关卡进度
@@ -18,11 +19,11 @@ type GetEndgameProgressResponse struct {
 	Progress []ScenarioProgress `json:"progress"`
 }
 
-// SaveEndgameProgressRequest 用于保存某一局残局挑战结果
-type SaveEndgameProgressRequest struct {
-	ScenarioID string `json:"scenario_id" binding:"required"` // 残局 ID（前端 endgameData.ts 中的 id）
-	Result     string `json:"result" binding:"required"`      // "win" 或 "lose"
-	Steps      *int   `json:"steps,omitempty"`                // 若为 win，可选地传入完成步数
+// EndgameResult 服务端裁定的一次残局挑战结果，随对局结束消息下发
+type EndgameResult struct {
+	ScenarioID string `json:"scenarioId"`
+	Success    bool   `json:"success"`
+	Steps      int    `json:"steps"`               // 本次玩家走的步数
+	BestSteps  *int   `json:"bestSteps,omitempty"` // 通关的最少步数
+	Awarded    int    `json:"awarded"`             // 本次发放的经验（仅首次通关发放）
 }
-
-
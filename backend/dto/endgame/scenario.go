@@ -0,0 +1,118 @@
+package endgame
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// 关卡字段的限制
+const (
+	MaxNameLen   = 32
+	MaxMoveLimit = 100
+	MaxTags      = 8
+	MaxTagLen    = 16
+)
+
+var scenarioIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// SaveScenarioRequest 新建或修改残局关卡（管理员），修改时 ID 取自路径且不可更改
+// 局面由服务端用规则引擎校验：须是合法局面且对局尚未结束
+type SaveScenarioRequest struct {
+	ID         string   `json:"id"` // 小写字母、数字与连字符
+	Name       string   `json:"name"`
+	Difficulty string   `json:"difficulty"`
+	FEN        string   `json:"fen"`
+	Goal       string   `json:"goal"`
+	MoveLimit  int      `json:"move_limit"`
+	Tags       []string `json:"tags"`
+}
+
+func (r *SaveScenarioRequest) Examine() error {
+	if !scenarioIDPattern.MatchString(r.ID) {
+		return fmt.Errorf("关卡ID只能包含小写字母、数字与连字符")
+	}
+	if r.Name == "" || utf8.RuneCountInString(r.Name) > MaxNameLen {
+		return fmt.Errorf("关卡名称不能为空且不能超过 %d 个字", MaxNameLen)
+	}
+	if r.FEN == "" {
+		return fmt.Errorf("局面不能为空")
+	}
+	if r.MoveLimit < 1 || r.MoveLimit > MaxMoveLimit {
+		return fmt.Errorf("限定步数须在 1 到 %d 之间", MaxMoveLimit)
+	}
+	if len(r.Tags) > MaxTags {
+		return fmt.Errorf("标签不能超过 %d 个", MaxTags)
+	}
+	for _, t := range r.Tags {
+		if utf8.RuneCountInString(t) > MaxTagLen {
+			return fmt.Errorf("标签不能超过 %d 个字", MaxTagLen)
+		}
+	}
+	return nil
+}
+
+// ReorderScenariosRequest 调整关卡顺序：按 ids 的先后重排，未列出的关卡顺序不变
+type ReorderScenariosRequest struct {
+	IDs []string `json:"ids"`
+}
+
+func (r *ReorderScenariosRequest) Examine() error {
+	if len(r.IDs) == 0 {
+		return fmt.Errorf("关卡列表不能为空")
+	}
+	seen := make(map[string]bool, len(r.IDs))
+	for _, id := range r.IDs {
+		if seen[id] {
+			return fmt.Errorf("关卡 %s 重复", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// RetireScenarioRequest 下架或恢复关卡
+type RetireScenarioRequest struct {
+	Retired bool `json:"retired"`
+}
+
+func (r *RetireScenarioRequest) Examine() error {
+	return nil
+}
+
+// ListScenariosRequest 查询关卡目录，tag 不为空时只返回带该标签的关卡
+type ListScenariosRequest struct {
+	UserID int    `json:"-"`
+	Tag    string `form:"tag"`
+}
+
+// ScenarioItem 残局关卡：玩家执 FEN 中的行棋方
+type ScenarioItem struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Difficulty string            `json:"difficulty"` // 初级/中级/高级
+	FEN        string            `json:"fen"`
+	Goal       string            `json:"goal"`       // win：限定步数内取胜；draw：走满限定步数不败
+	MoveLimit  int               `json:"move_limit"` // 玩家最多可走的步数
+	Exp        int               `json:"exp"`        // 首次通关发放的经验
+	Tags       []string          `json:"tags"`
+	SortOrder  int               `json:"sort_order"`
+	Retired    bool              `json:"retired,omitempty"`
+	Progress   *ScenarioProgress `json:"progress,omitempty"` // 当前用户在该关卡的进度，未挑战过时为空
+}
+
+// ScenarioGroup 同一难度的关卡
+type ScenarioGroup struct {
+	Difficulty string         `json:"difficulty"`
+	Scenarios  []ScenarioItem `json:"scenarios"`
+}
+
+// ListScenariosResponse 按难度分组的关卡目录（不含已下架的关卡）
+type ListScenariosResponse struct {
+	Groups []ScenarioGroup `json:"groups"`
+}
+
+// AdminListScenariosResponse 管理员查看的全部关卡，含已下架的关卡
+type AdminListScenariosResponse struct {
+	Scenarios []ScenarioItem `json:"scenarios"`
+}
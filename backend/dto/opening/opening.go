@@ -0,0 +1,61 @@
+package opening
+
+import "fmt"
+
+// MaxMoves 查询开局库时最多提交的着法数
+const MaxMoves = 100
+
+// BookMovesRequest 查询开局库：从 fen（为空时为开局）走完 moves（ICCS 坐标）后的局面
+type BookMovesRequest struct {
+	FEN   string   `json:"fen"`
+	Moves []string `json:"moves"`
+}
+
+func (r *BookMovesRequest) Examine() error {
+	if len(r.Moves) > MaxMoves {
+		return fmt.Errorf("着法不能超过 %d 步", MaxMoves)
+	}
+	return nil
+}
+
+type BookMove struct {
+	Move     string `json:"move"`      // ICCS 坐标
+	MoveText string `json:"move_text"` // 中文纵线记谱
+	Ecco     string `json:"ecco"`      // 走后形成的开局
+	Name     string `json:"name"`
+}
+
+type BookMovesResponse struct {
+	FEN string `json:"fen"`
+	// 当前局面所属的开局，不在开局库中时为空
+	Ecco  string     `json:"ecco"`
+	Name  string     `json:"name"`
+	Moves []BookMove `json:"moves"`
+}
+
+// OpeningStatsRequest 按开局统计当前用户的战绩，color 为 red/black 时只统计执该方的对局
+type OpeningStatsRequest struct {
+	UserID int    `json:"-"`
+	Color  string `form:"color"`
+}
+
+func (r *OpeningStatsRequest) Examine() error {
+	if r.Color != "" && r.Color != "red" && r.Color != "black" {
+		return fmt.Errorf("color 只能为 red 或 black")
+	}
+	return nil
+}
+
+type OpeningStat struct {
+	Ecco    string  `json:"ecco"`
+	Name    string  `json:"name"`
+	Games   int     `json:"games"`
+	Wins    int     `json:"wins"`
+	Draws   int     `json:"draws"`
+	Losses  int     `json:"losses"`
+	WinRate float64 `json:"win_rate"` // 胜局占全部对局的百分比
+}
+
+type OpeningStatsResponse struct {
+	Openings []OpeningStat `json:"openings"` // 按对局数从多到少排列
+}
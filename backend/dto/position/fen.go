@@ -0,0 +1,42 @@
+package position
+
+import "fmt"
+
+// GetRecordFENRequest 获取对局记录在某一步之后的局面
+type GetRecordFENRequest struct {
+	UserID   int  `json:"-"`
+	RecordID uint `json:"-"`
+	// Ply 为半回合数，0 表示开局局面；为空时返回终局局面
+	Ply *int `form:"ply"`
+}
+
+type GetRecordFENResponse struct {
+	RecordID   uint   `json:"record_id"`
+	Ply        int    `json:"ply"`
+	TotalPlies int    `json:"total_plies"`
+	FEN        string `json:"fen"`
+	Turn       string `json:"turn"` // "red" / "black"
+}
+
+// ValidateFENRequest 校验用户提交的 FEN
+type ValidateFENRequest struct {
+	FEN string `json:"fen"`
+}
+
+func (r *ValidateFENRequest) Examine() error {
+	if r.FEN == "" {
+		return fmt.Errorf("FEN 不能为空")
+	}
+	return nil
+}
+
+type ValidateFENResponse struct {
+	Valid bool `json:"valid"`
+	// 以下字段仅在格式可解析时返回
+	FEN        string   `json:"fen,omitempty"` // 规范化后的 FEN
+	Turn       string   `json:"turn,omitempty"`
+	InCheck    bool     `json:"in_check"`
+	Status     string   `json:"status,omitempty"` // ongoing / checkmate / stalemate
+	LegalMoves int      `json:"legal_moves"`
+	Errors     []string `json:"errors,omitempty"`
+}
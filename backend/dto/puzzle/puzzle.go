@@ -0,0 +1,102 @@
+package puzzle
+
+import (
+	"fmt"
+
+	ratingDto "chinese-chess-backend/dto/rating"
+)
+
+// MaxSolutionMoves 录入习题时解法的最大步数（半回合），与自动挖掘的上限一致，更长的连杀无法在录入时验证
+const MaxSolutionMoves = 9
+
+// PuzzleResponse 一道习题及当前用户的解答进度
+type PuzzleResponse struct {
+	ID         uint     `json:"id"`
+	FEN        string   `json:"fen"`   // 初始局面
+	Color      string   `json:"color"` // 解题方：red/black
+	Rating     int      `json:"rating"`
+	Plays      int      `json:"plays"`
+	Daily      bool     `json:"daily,omitempty"` // 是否为今天的每日一题
+	Status     string   `json:"status"`          // playing/solved/failed
+	Moves      []string `json:"moves"`           // 已走的着法（ICCS），包括对方的应着
+	CurrentFEN string   `json:"current_fen"`
+	// Solution 完整解法，解答结束后才返回
+	Solution []string `json:"solution,omitempty"`
+}
+
+// SubmitMoveRequest 提交解题方的一步棋，支持 ICCS、WXF 与中文纵线记谱
+type SubmitMoveRequest struct {
+	PuzzleID uint   `json:"-"`
+	UserID   int    `json:"-"`
+	Move     string `json:"move"`
+}
+
+func (r *SubmitMoveRequest) Examine() error {
+	if r.Move == "" {
+		return fmt.Errorf("着法不能为空")
+	}
+	return nil
+}
+
+// SubmitMoveResponse 服务端按解法校验一步棋的结果
+type SubmitMoveResponse struct {
+	Correct bool   `json:"correct"`
+	Status  string `json:"status"` // playing/solved/failed
+	Move    string `json:"move"`   // 解析后的着法（ICCS）
+	// Reply 解答未结束时服务端代走的对方应着
+	Reply     string `json:"reply,omitempty"`
+	ReplyText string `json:"reply_text,omitempty"`
+	// Solution 解答结束后返回完整解法
+	Solution []string `json:"solution,omitempty"`
+	// RatingChange 解答结束后习题等级分的变化
+	RatingChange *ratingDto.RatingChange `json:"rating_change,omitempty"`
+}
+
+// PuzzleRatingResponse 用户的习题等级分
+type PuzzleRatingResponse struct {
+	Rating      int  `json:"rating"`
+	RD          int  `json:"rd"`
+	Attempts    int  `json:"attempts"`
+	Solved      int  `json:"solved"`
+	Provisional bool `json:"provisional"`
+}
+
+// CreatePuzzleRequest 录入习题（管理员）：solution 为 ICCS 着法，以解题方的着法开始和结束
+// rating 为 0 时按解法长度估计初始等级分
+type CreatePuzzleRequest struct {
+	FEN      string   `json:"fen"`
+	Solution []string `json:"solution"`
+	Rating   int      `json:"rating"`
+}
+
+func (r *CreatePuzzleRequest) Examine() error {
+	if r.FEN == "" {
+		return fmt.Errorf("局面不能为空")
+	}
+	if len(r.Solution)%2 == 0 || len(r.Solution) > MaxSolutionMoves {
+		return fmt.Errorf("解法须为不超过 %d 步的奇数步，以解题方的着法开始和结束", MaxSolutionMoves)
+	}
+	if r.Rating < 0 || r.Rating > 3500 {
+		return fmt.Errorf("等级分须在 0 到 3500 之间")
+	}
+	return nil
+}
+
+// MinePuzzlesRequest 从已分析对局的败着中挖掘习题（管理员），最多处理 limit 局
+type MinePuzzlesRequest struct {
+	Limit int `json:"limit"`
+}
+
+func (r *MinePuzzlesRequest) Examine() error {
+	if r.Limit == 0 {
+		r.Limit = 50
+	}
+	if r.Limit < 0 || r.Limit > 500 {
+		return fmt.Errorf("limit 须在 1 到 500 之间")
+	}
+	return nil
+}
+
+type MinePuzzlesResponse struct {
+	Queued int `json:"queued"` // 排队挖掘的对局数
+}
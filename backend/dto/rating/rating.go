@@ -0,0 +1,31 @@
+package rating
+
+import "time"
+
+// RatingChange 一局计分对局后某一方的等级分变化（取整后展示）
+type RatingChange struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
+	Delta  int `json:"delta"`
+}
+
+// RatingHistoryItem 等级分历史中的一条记录
+type RatingHistoryItem struct {
+	GameRecordID uint      `json:"game_record_id"`
+	OpponentID   uint      `json:"opponent_id"`
+	Score        float64   `json:"score"`
+	Before       int       `json:"before"`
+	After        int       `json:"after"`
+	Delta        int       `json:"delta"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GetRatingResponse 当前等级分与最近的变化记录
+type GetRatingResponse struct {
+	Rating int `json:"rating"`
+	RD     int `json:"rd"`
+	Games  int `json:"games"`
+	// Provisional 评分偏差较大（对局数少或长期未下棋）时等级分仅供参考
+	Provisional bool                `json:"provisional"`
+	History     []RatingHistoryItem `json:"history"`
+}
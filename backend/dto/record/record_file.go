@@ -0,0 +1,28 @@
+package record
+
+// ExportRecordRequest 导出对局记录为棋谱文件
+type ExportRecordRequest struct {
+	UserID   int  `json:"-"`
+	RecordID uint `json:"-"`
+	// Notation PGN 中的记谱格式：chinese（默认）/ wxf / iccs，XQF 忽略此参数
+	Notation string `form:"notation"`
+	// Charset PGN 文本编码：utf-8（默认）/ gbk，XQStudio 等旧软件只识别 GBK
+	Charset string `form:"charset"`
+}
+
+// ExportRecordResponse 导出的棋谱文件
+type ExportRecordResponse struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ImportRecordResponse 导入棋谱后生成的对局记录
+type ImportRecordResponse struct {
+	RecordID   uint   `json:"record_id"`
+	Red        string `json:"red"`
+	Black      string `json:"black"`
+	Result     int    `json:"result"` // 0 红胜 1 黑胜 2 和棋
+	TotalSteps int    `json:"total_steps"`
+	EndReason  string `json:"end_reason"`
+}
@@ -1,9 +1,11 @@
 package room
 
 type GetSpareRoomsRequest struct {
-	Infos []RoomInfo `json:"-"`
+	Infos  []RoomInfo  `json:"-"`
+	Queues []QueueInfo `json:"-"`
 }
 
 type GetSpareRoomsResponse struct {
-	Rooms []RoomInfo `json:"rooms"`
-}
\ No newline at end of file
+	Rooms  []RoomInfo  `json:"rooms"`
+	Queues []QueueInfo `json:"queues"`
+}
@@ -4,8 +4,22 @@ import (
 	"chinese-chess-backend/dto/user"
 )
 
+// RoomInfo 大厅中的房间：等待加入的房间只有 Current；进行中的对局 Current 为红方、Next 为黑方
 type RoomInfo struct {
-	Id      int           `json:"id"`
-	Current user.UserInfo `json:"current"`
-	Next    user.UserInfo `json:"next"`
+	Id         int           `json:"id"`
+	Current    user.UserInfo `json:"current"`
+	Next       user.UserInfo `json:"next"`
+	Playing    bool          `json:"playing"`              // 是否为可观战的进行中对局
+	Locked     bool          `json:"locked,omitempty"`     // 加入或观战需要密码
+	Spectators int           `json:"spectators,omitempty"` // 观战人数
+}
+
+// QueueInfo 匹配队列及其当前排队人数，时间单位为秒
+type QueueInfo struct {
+	Speed     string `json:"speed"` // blitz / rapid / classical / custom
+	Mode      string `json:"mode"`  // rated / casual
+	Base      int    `json:"base"`
+	Increment int    `json:"increment,omitempty"`
+	Byoyomi   int    `json:"byoyomi,omitempty"`
+	Players   int    `json:"players"`
 }
@@ -0,0 +1,143 @@
+package tournament
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// 比赛设置的限制
+const (
+	MaxNameLen        = 32
+	MinPlayers        = 2
+	MaxPlayers        = 32
+	DefaultMaxPlayers = 16
+	MaxSwissRounds    = 11
+)
+
+// CreateTournamentRequest 创建比赛
+// format 为 round_robin（单循环，轮数由人数决定）或 swiss（瑞士制，须指定 rounds）
+// start_at 为空时由创建者手动开赛，否则到时自动开赛
+type CreateTournamentRequest struct {
+	UserID        int        `json:"-"`
+	Name          string     `json:"name"`
+	Format        string     `json:"format"`
+	MaxPlayers    int        `json:"max_players"`
+	Rounds        int        `json:"rounds"`
+	Rated         bool       `json:"rated"`
+	TimeBase      int        `json:"time_base"`
+	TimeIncrement int        `json:"time_increment"`
+	TimeByoyomi   int        `json:"time_byoyomi"`
+	StartAt       *time.Time `json:"start_at"`
+}
+
+func (r *CreateTournamentRequest) Examine() error {
+	if r.Name == "" || utf8.RuneCountInString(r.Name) > MaxNameLen {
+		return fmt.Errorf("比赛名称不能为空且不能超过 %d 个字", MaxNameLen)
+	}
+	if r.Format != "round_robin" && r.Format != "swiss" {
+		return fmt.Errorf("赛制只能为 round_robin 或 swiss")
+	}
+	if r.MaxPlayers == 0 {
+		r.MaxPlayers = DefaultMaxPlayers
+	}
+	if r.MaxPlayers < MinPlayers || r.MaxPlayers > MaxPlayers {
+		return fmt.Errorf("人数上限须在 %d 到 %d 之间", MinPlayers, MaxPlayers)
+	}
+	if r.Format == "swiss" && (r.Rounds < 1 || r.Rounds > MaxSwissRounds) {
+		return fmt.Errorf("瑞士制轮数须在 1 到 %d 之间", MaxSwissRounds)
+	}
+	// 与对局房间的时间控制规则一致
+	switch {
+	case r.TimeBase < 0 || r.TimeIncrement < 0 || r.TimeByoyomi < 0:
+		return fmt.Errorf("时间设置不能为负数")
+	case r.TimeBase > 3*3600:
+		return fmt.Errorf("基础用时不能超过 3 小时")
+	case r.TimeIncrement > 60:
+		return fmt.Errorf("每步加秒不能超过 60 秒")
+	case r.TimeByoyomi > 600:
+		return fmt.Errorf("读秒不能超过 600 秒")
+	case r.TimeIncrement > 0 && r.TimeByoyomi > 0:
+		return fmt.Errorf("加秒与读秒只能选择一种")
+	case r.TimeIncrement > 0 && r.TimeBase == 0:
+		return fmt.Errorf("加秒制需要设置基础用时")
+	}
+	return nil
+}
+
+// ListTournamentsRequest 查询比赛列表，status 为空时返回全部状态，mine 为 true 时只返回已报名的比赛
+type ListTournamentsRequest struct {
+	UserID int    `json:"-"`
+	Status string `form:"status"`
+	Mine   bool   `form:"mine"`
+}
+
+// TournamentItem 比赛概要
+type TournamentItem struct {
+	ID            uint       `json:"id"`
+	Name          string     `json:"name"`
+	Format        string     `json:"format"` // round_robin/swiss
+	Status        string     `json:"status"` // open/running/finished/cancelled
+	CreatorID     uint       `json:"creator_id"`
+	CreatorName   string     `json:"creator_name"`
+	Players       int        `json:"players"`
+	MaxPlayers    int        `json:"max_players"`
+	Rounds        int        `json:"rounds"` // 单循环开赛前为 0
+	CurrentRound  int        `json:"current_round"`
+	Rated         bool       `json:"rated"`
+	TimeBase      int        `json:"time_base"`
+	TimeIncrement int        `json:"time_increment"`
+	TimeByoyomi   int        `json:"time_byoyomi"`
+	StartAt       *time.Time `json:"start_at"`
+	Joined        bool       `json:"joined"` // 当前用户是否已报名
+}
+
+type ListTournamentsResponse struct {
+	Tournaments []TournamentItem `json:"tournaments"`
+}
+
+// StandingItem 名次表中的一行，依次按积分、布赫霍尔茨分、索内本-伯格分排列
+type StandingItem struct {
+	Rank            int     `json:"rank"`
+	UserID          uint    `json:"user_id"`
+	Name            string  `json:"name"`
+	Seed            int     `json:"seed"`
+	Rating          int     `json:"rating"` // 报名时的等级分
+	Score           float64 `json:"score"`
+	Buchholz        float64 `json:"buchholz"`
+	SonnebornBerger float64 `json:"sonneborn_berger"`
+	Played          int     `json:"played"`
+	Wins            int     `json:"wins"`
+	Draws           int     `json:"draws"`
+	Losses          int     `json:"losses"`
+}
+
+// PairingItem 一台对阵；bye 为 true 时红方轮空，forfeit 为 true 时表示有一方（或双方）未到场
+type PairingItem struct {
+	ID           uint       `json:"id"`
+	Board        int        `json:"board"`
+	RedID        uint       `json:"red_id"`
+	RedName      string     `json:"red_name"`
+	BlackID      uint       `json:"black_id"`
+	BlackName    string     `json:"black_name"`
+	Status       string     `json:"status"` // pending/ready/playing/finished
+	RoomID       int        `json:"room_id"`
+	Deadline     *time.Time `json:"deadline"`
+	RedScore     float64    `json:"red_score"`
+	BlackScore   float64    `json:"black_score"`
+	Bye          bool       `json:"bye"`
+	Forfeit      bool       `json:"forfeit"`
+	GameRecordID *uint      `json:"game_record_id"`
+}
+
+type RoundItem struct {
+	Round    int           `json:"round"`
+	Pairings []PairingItem `json:"pairings"`
+}
+
+// TournamentDetail 比赛详情：概要、名次表（开赛前按种子顺序排列）与各轮对阵
+type TournamentDetail struct {
+	Tournament TournamentItem `json:"tournament"`
+	Standings  []StandingItem `json:"standings"`
+	Rounds     []RoundItem    `json:"rounds"`
+}
@@ -7,6 +7,8 @@ import (
 
 type GetGameRecordsRequest struct {
 	UserID int `json:"user_id"`
+	// Notation 可选的记谱格式：chinese / wxf / iccs，为空时不返回 moves
+	Notation string `form:"notation"`
 }
 
 func (r *GetGameRecordsRequest) Examine() error {
@@ -22,7 +24,7 @@ type GameRecordItem struct {
 	OpponentName string `json:"opponent_name"`
 	// Result: 0=win, 1=lose, 2=draw
 	Result int `json:"result"`
-	// GameType: 0=随机匹配, 1=人机对战, 2=好友对战
+	// GameType: 0=随机匹配, 1=人机对战, 2=好友对战, 5=比赛对局
 	GameType int `json:"game_type"`
 	// IsRed: true=红方, false=黑方
 	IsRed      bool      `json:"is_red"`
@@ -31,6 +33,13 @@ type GameRecordItem struct {
 	StartTime  time.Time `json:"start_time"`
 	// AI难度: 1-6，仅在 game_type=1 时有效
 	AILevel int `json:"ai_level"`
+	// 开局分类（ECCO 编码与名称），没有着法的对局为空
+	Ecco        string `json:"ecco"`
+	OpeningName string `json:"opening_name"`
+	// 比赛对局所属的比赛，其它对局为 0
+	TournamentID uint `json:"tournament_id,omitempty"`
+	// Moves 按请求的记谱格式渲染的走法，未指定格式或棋谱无法复盘时为空
+	Moves []string `json:"moves,omitempty"`
 }
 
 type GetGameRecordsResponse struct {
@@ -0,0 +1,387 @@
+// Package engine 通过 UCI/UCCI 协议驱动外部象棋引擎（如 Pikafish、ElephantEye）
+// 引擎以子进程运行，经标准输入输出交换文本命令；走法使用 ICCS 坐标（如 h2e2），局面使用 FEN
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocol 引擎使用的通信协议
+type Protocol string
+
+const (
+	UCI  Protocol = "uci"  // Pikafish 等
+	UCCI Protocol = "ucci" // ElephantEye 等
+)
+
+const (
+	// handshakeTimeout 启动引擎后等待握手完成的最长时间
+	handshakeTimeout = 10 * time.Second
+	// stopGrace 发送 stop 后等待引擎给出着法的时间，超时则结束进程
+	stopGrace = 2 * time.Second
+)
+
+var (
+	ErrNoBestMove = errors.New("引擎没有给出着法")
+	ErrClosed     = errors.New("引擎进程已退出")
+)
+
+// Limit 单次搜索的限制，Depth 与 MoveTime 都为 0 时引擎会一直搜索，需由 ctx 取消
+type Limit struct {
+	Depth    int
+	MoveTime time.Duration
+}
+
+// Info 引擎在搜索过程中输出的 info 行
+type Info struct {
+	Depth int
+	Score int // 行棋方视角的分数（100 约为一个兵）
+	Mate  int // 非 0 表示 n 回合内杀棋，负数表示行棋方被杀（仅 UCI 引擎提供）
+	Nodes int64
+	Time  time.Duration
+	PV    []string // 主要变化，ICCS 坐标
+}
+
+// Result 一次搜索的结果
+type Result struct {
+	BestMove string
+	Ponder   string
+	Info     Info // 最后一条带主要变化的 info
+}
+
+// Engine 一个引擎子进程，同一时刻只能进行一次搜索
+type Engine struct {
+	protocol Protocol
+	name     string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	lines    chan string   // 引擎的输出行，进程退出后关闭
+	exited   chan struct{} // 进程退出后关闭
+	mu       sync.Mutex
+	broken   bool // 进程已退出或协议状态未知，不应再放回池中
+	drain    sync.Once
+}
+
+// Start 启动引擎并完成握手：发送 uci/ucci 等待 uciok/ucciok，设置选项后等待 readyok
+func Start(path string, args []string, protocol Protocol, options map[string]string) (*Engine, error) {
+	if protocol != UCI && protocol != UCCI {
+		return nil, fmt.Errorf("不支持的引擎协议 %q", protocol)
+	}
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动引擎失败: %w", err)
+	}
+	e := &Engine{
+		protocol: protocol,
+		cmd:      cmd,
+		stdin:    stdin,
+		lines:    make(chan string, 256),
+		exited:   make(chan struct{}),
+	}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			e.lines <- strings.TrimSpace(scanner.Text())
+		}
+		close(e.lines)
+		cmd.Wait()
+		close(e.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	if err := e.handshake(ctx, options); err != nil {
+		e.kill()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Engine) handshake(ctx context.Context, options map[string]string) error {
+	ok := "uciok"
+	if e.protocol == UCCI {
+		ok = "ucciok"
+	}
+	if err := e.send(string(e.protocol)); err != nil {
+		return err
+	}
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return fmt.Errorf("等待引擎握手失败: %w", err)
+		}
+		if name, found := strings.CutPrefix(line, "id name "); found {
+			e.name = strings.TrimSpace(name)
+		}
+		if line == ok {
+			break
+		}
+	}
+	for name, value := range options {
+		cmd := "setoption name " + name + " value " + value
+		if e.protocol == UCCI {
+			cmd = "setoption " + name + " " + value
+		}
+		if err := e.send(cmd); err != nil {
+			return err
+		}
+	}
+	return e.waitReady(ctx)
+}
+
+// waitReady 发送 isready 并等待 readyok
+func (e *Engine) waitReady(ctx context.Context) error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return fmt.Errorf("等待引擎就绪失败: %w", err)
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+// Name 引擎在握手时报告的名称，未报告时为空
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// Broken 引擎是否已不可用
+func (e *Engine) Broken() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.broken
+}
+
+// Go 在 fen 局面执行 moves（ICCS）后搜索，ctx 取消时发送 stop 并使用引擎已有的结果
+func (e *Engine) Go(ctx context.Context, fen string, moves []string, limit Limit) (Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.broken {
+		return Result{}, ErrClosed
+	}
+	res, err := e.search(ctx, fen, moves, limit)
+	if err != nil && !errors.Is(err, ErrNoBestMove) {
+		// 输出流中可能残留本次搜索的内容，不再复用该进程
+		e.broken = true
+		e.kill()
+	}
+	return res, err
+}
+
+func (e *Engine) search(ctx context.Context, fen string, moves []string, limit Limit) (Result, error) {
+	position := "position fen " + fen
+	if len(moves) > 0 {
+		position += " moves " + strings.Join(moves, " ")
+	}
+	if err := e.send(position); err != nil {
+		return Result{}, err
+	}
+	if err := e.send(e.goCommand(limit)); err != nil {
+		return Result{}, err
+	}
+
+	var res Result
+	stopped := false
+	waitCtx := ctx
+	for {
+		line, err := e.readLine(waitCtx)
+		if err != nil {
+			if errors.Is(err, ErrClosed) || stopped {
+				return res, err
+			}
+			// 时间到：要求引擎立即给出当前最佳着法
+			if err := e.send("stop"); err != nil {
+				return res, err
+			}
+			stopped = true
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(context.Background(), stopGrace)
+			defer cancel()
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "info "):
+			// 只带分数的 info（如 UCI 的 currmove 行）不覆盖已有的主要变化
+			if info, ok := ParseInfo(line); ok && (len(info.PV) > 0 || len(res.Info.PV) == 0) {
+				res.Info = info
+			}
+		case line == "nobestmove" || strings.HasPrefix(line, "bestmove (none)") || line == "bestmove":
+			return res, ErrNoBestMove
+		case strings.HasPrefix(line, "bestmove "):
+			fields := strings.Fields(line)
+			res.BestMove = fields[1]
+			if len(fields) >= 4 && fields[2] == "ponder" {
+				res.Ponder = fields[3]
+			}
+			return res, nil
+		}
+	}
+}
+
+// goCommand 按协议构造 go 命令；UCCI 没有固定每步用时的参数，用“剩余时间 + 只剩一步”表示
+func (e *Engine) goCommand(limit Limit) string {
+	ms := limit.MoveTime.Milliseconds()
+	if e.protocol == UCCI {
+		if ms > 0 {
+			return "go time " + strconv.FormatInt(ms, 10) + " movestogo 1"
+		}
+		if limit.Depth > 0 {
+			return "go depth " + strconv.Itoa(limit.Depth)
+		}
+		return "go depth infinite"
+	}
+	cmd := "go"
+	if limit.Depth > 0 {
+		cmd += " depth " + strconv.Itoa(limit.Depth)
+	}
+	if ms > 0 {
+		cmd += " movetime " + strconv.FormatInt(ms, 10)
+	}
+	if cmd == "go" {
+		cmd += " infinite"
+	}
+	return cmd
+}
+
+// NewGame 通知引擎开始新的对局，清除置换表等与上一局相关的状态
+func (e *Engine) NewGame() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.protocol == UCI {
+		if err := e.send("ucinewgame"); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	return e.waitReady(ctx)
+}
+
+// Close 请求引擎退出，未及时退出时结束进程
+func (e *Engine) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.broken = true
+	e.discardOutput()
+	e.send("quit")
+	select {
+	case <-e.exited:
+	case <-time.After(stopGrace):
+		e.kill()
+	}
+}
+
+func (e *Engine) kill() {
+	e.discardOutput()
+	if e.cmd.Process != nil {
+		e.cmd.Process.Kill()
+	}
+	e.stdin.Close()
+}
+
+// discardOutput 不再读取引擎输出后丢弃剩余的行，避免读取协程阻塞而无法回收进程
+func (e *Engine) discardOutput() {
+	e.drain.Do(func() {
+		go func() {
+			for range e.lines {
+			}
+		}()
+	})
+}
+
+func (e *Engine) send(cmd string) error {
+	if _, err := io.WriteString(e.stdin, cmd+"\n"); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+func (e *Engine) readLine(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-e.lines:
+		if !ok {
+			return "", ErrClosed
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// ParseInfo 解析 info 行，兼容 UCI 的 "score cp 35"/"score mate 3" 与 UCCI 的 "score 35"
+// 只有深度或分数的行才返回 ok，"info string" 等说明文字忽略
+func ParseInfo(line string) (Info, bool) {
+	fields := strings.Fields(line)
+	var info Info
+	ok := false
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "string":
+			return Info{}, false
+		case "depth":
+			if i+1 < len(fields) {
+				info.Depth, _ = strconv.Atoi(fields[i+1])
+				ok = true
+				i++
+			}
+		case "nodes":
+			if i+1 < len(fields) {
+				info.Nodes, _ = strconv.ParseInt(fields[i+1], 10, 64)
+				i++
+			}
+		case "time":
+			if i+1 < len(fields) {
+				ms, _ := strconv.ParseInt(fields[i+1], 10, 64)
+				info.Time = time.Duration(ms) * time.Millisecond
+				i++
+			}
+		case "score":
+			if i+1 >= len(fields) {
+				break
+			}
+			switch fields[i+1] {
+			case "cp":
+				if i+2 < len(fields) {
+					info.Score, _ = strconv.Atoi(fields[i+2])
+					i += 2
+				}
+			case "mate":
+				if i+2 < len(fields) {
+					info.Mate, _ = strconv.Atoi(fields[i+2])
+					i += 2
+				}
+			default:
+				info.Score, _ = strconv.Atoi(fields[i+1])
+				i++
+			}
+			ok = true
+		case "pv":
+			info.PV = append([]string(nil), fields[i+1:]...)
+			i = len(fields)
+		}
+	}
+	return info, ok
+}
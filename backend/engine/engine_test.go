@@ -0,0 +1,369 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试用的假引擎：测试二进制设置 FAKE_ENGINE_MODE 后以自身作为引擎子进程启动，
+// 按模式回应 UCI/UCCI 命令，收到的每条命令追加写入 FAKE_ENGINE_LOG
+const (
+	fakeModeEnv = "FAKE_ENGINE_MODE"
+	fakeLogEnv  = "FAKE_ENGINE_LOG"
+)
+
+const testFEN = "rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9/RNBAKABNR w - - 0 1"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeModeEnv); mode != "" {
+		runFakeEngine(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeEngine 假引擎的命令循环，各模式只在收到 go 之后的行为不同：
+//   - normal：输出 UCI 格式的 info 后给出 bestmove
+//   - mate：报告被杀
+//   - ucci：输出 UCCI 格式的分数
+//   - hang：一直搜索，收到 stop 后才给出着法
+//   - mute：收到 stop 也不回应
+//   - crash：收到 go 后进程退出
+//   - nomove：没有可走的着法
+//   - dead：启动后立即退出
+func runFakeEngine(mode string) {
+	if mode == "dead" {
+		os.Exit(1)
+	}
+	var transcript *os.File
+	if path := os.Getenv(fakeLogEnv); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err == nil {
+			transcript = f
+			defer f.Close()
+		}
+	}
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		cmd := strings.TrimSpace(scanner.Text())
+		if transcript != nil {
+			fmt.Fprintln(transcript, cmd)
+		}
+		switch {
+		case cmd == "uci":
+			reply("id name FakeFish 1.0", "id author test", "option name Threads type spin default 1 min 1 max 8", "uciok")
+		case cmd == "ucci":
+			reply("id name FakeEye", "ucciok")
+		case cmd == "isready":
+			reply("readyok")
+		case cmd == "quit":
+			return
+		case cmd == "stop" && mode == "hang":
+			reply("info depth 20 score cp 5 pv b2e2 b9c7", "bestmove b2e2")
+		case strings.HasPrefix(cmd, "go"):
+			switch mode {
+			case "normal":
+				reply(
+					"info string NNUE evaluation enabled",
+					"info depth 1 score cp 12 nodes 100 pv h2e2",
+					"info depth 8 seldepth 11 score cp 35 nodes 52000 time 120 pv h2e2 h9g7 h0g2",
+					"info depth 9 currmove b0c2 currmovenumber 3",
+					"bestmove h2e2 ponder h9g7",
+				)
+			case "mate":
+				reply("info depth 5 score mate -3 nodes 800 pv a0a1 e9e8 a1a9", "bestmove a0a1 ponder e9e8")
+			case "ucci":
+				reply("info depth 6 score 35 pv h2e2 h9g7", "bestmove h2e2 ponder h9g7")
+			case "crash":
+				os.Exit(3)
+			case "nomove":
+				reply("nobestmove")
+			}
+		}
+	}
+}
+
+// fakeEngine 以 mode 模式运行假引擎，返回读取假引擎收到的命令的函数
+func fakeEngine(t *testing.T, mode string) (path string, received func() []string) {
+	t.Helper()
+	logPath := filepath.Join(t.TempDir(), "engine.log")
+	t.Setenv(fakeModeEnv, mode)
+	t.Setenv(fakeLogEnv, logPath)
+	return os.Args[0], func() []string {
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("read engine log: %v", err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func startFake(t *testing.T, mode string, protocol Protocol, options map[string]string) (*Engine, func() []string) {
+	t.Helper()
+	path, received := fakeEngine(t, mode)
+	e, err := Start(path, nil, protocol, options)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(e.Close)
+	return e, received
+}
+
+func TestUCISearch(t *testing.T) {
+	e, received := startFake(t, "normal", UCI, map[string]string{"Threads": "2"})
+	if e.Name() != "FakeFish 1.0" {
+		t.Errorf("Name = %q, want FakeFish 1.0", e.Name())
+	}
+	if err := e.NewGame(); err != nil {
+		t.Fatalf("NewGame: %v", err)
+	}
+	res, err := e.Go(context.Background(), testFEN, []string{"h2e2", "h9g7"}, Limit{MoveTime: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Go: %v", err)
+	}
+	want := Result{
+		BestMove: "h2e2",
+		Ponder:   "h9g7",
+		// 最后一条 currmove 行没有主要变化，不覆盖之前的结果
+		Info: Info{Depth: 8, Score: 35, Nodes: 52000, Time: 120 * time.Millisecond, PV: []string{"h2e2", "h9g7", "h0g2"}},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Go = %+v, want %+v", res, want)
+	}
+	e.Close()
+
+	wantCmds := []string{
+		"uci",
+		"setoption name Threads value 2",
+		"isready",
+		"ucinewgame",
+		"isready",
+		"position fen " + testFEN + " moves h2e2 h9g7",
+		"go movetime 500",
+		"quit",
+	}
+	if got := received(); !reflect.DeepEqual(got, wantCmds) {
+		t.Errorf("engine received:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(wantCmds, "\n"))
+	}
+}
+
+func TestUCCISearch(t *testing.T) {
+	e, received := startFake(t, "ucci", UCCI, map[string]string{"hashsize": "16"})
+	if e.Name() != "FakeEye" {
+		t.Errorf("Name = %q, want FakeEye", e.Name())
+	}
+	if err := e.NewGame(); err != nil {
+		t.Fatalf("NewGame: %v", err)
+	}
+	res, err := e.Go(context.Background(), testFEN, nil, Limit{MoveTime: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Go: %v", err)
+	}
+	if res.BestMove != "h2e2" || res.Info.Score != 35 || res.Info.Mate != 0 || res.Info.Depth != 6 {
+		t.Errorf("Go = %+v, want bestmove h2e2 with score 35 at depth 6", res)
+	}
+	e.Close()
+
+	// UCCI 没有 ucinewgame，局面后不带 moves
+	wantCmds := []string{
+		"ucci",
+		"setoption hashsize 16",
+		"isready",
+		"isready",
+		"position fen " + testFEN,
+		"go time 1500 movestogo 1",
+		"quit",
+	}
+	if got := received(); !reflect.DeepEqual(got, wantCmds) {
+		t.Errorf("engine received:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(wantCmds, "\n"))
+	}
+}
+
+func TestMateScore(t *testing.T) {
+	e, _ := startFake(t, "mate", UCI, nil)
+	res, err := e.Go(context.Background(), testFEN, nil, Limit{Depth: 5})
+	if err != nil {
+		t.Fatalf("Go: %v", err)
+	}
+	if res.BestMove != "a0a1" || res.Info.Mate != -3 || res.Info.Score != 0 {
+		t.Errorf("Go = %+v, want bestmove a0a1 with mate -3", res)
+	}
+}
+
+func TestGoCommand(t *testing.T) {
+	tests := []struct {
+		protocol Protocol
+		limit    Limit
+		want     string
+	}{
+		{UCI, Limit{MoveTime: time.Second}, "go movetime 1000"},
+		{UCI, Limit{Depth: 12}, "go depth 12"},
+		{UCI, Limit{Depth: 12, MoveTime: 250 * time.Millisecond}, "go depth 12 movetime 250"},
+		{UCI, Limit{}, "go infinite"},
+		{UCCI, Limit{MoveTime: time.Second}, "go time 1000 movestogo 1"},
+		{UCCI, Limit{Depth: 12}, "go depth 12"},
+		{UCCI, Limit{}, "go depth infinite"},
+	}
+	for _, tt := range tests {
+		e := &Engine{protocol: tt.protocol}
+		if got := e.goCommand(tt.limit); got != tt.want {
+			t.Errorf("%s goCommand(%+v) = %q, want %q", tt.protocol, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		line string
+		want Info
+		ok   bool
+	}{
+		{
+			"info depth 12 seldepth 18 multipv 1 score cp -47 nodes 123456 nps 900000 time 137 pv b0c2 h9g7",
+			Info{Depth: 12, Score: -47, Nodes: 123456, Time: 137 * time.Millisecond, PV: []string{"b0c2", "h9g7"}},
+			true,
+		},
+		{"info depth 7 score mate 2 pv h0g2 e9e8", Info{Depth: 7, Mate: 2, PV: []string{"h0g2", "e9e8"}}, true},
+		{"info depth 9 score -120 pv h7e7", Info{Depth: 9, Score: -120, PV: []string{"h7e7"}}, true},
+		{"info score cp 30", Info{Score: 30}, true},
+		{"info currmove h2e2 currmovenumber 1", Info{}, false},
+		{"info string depth 3 score cp 20", Info{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseInfo(tt.line)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseInfo(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStopOnTimeout(t *testing.T) {
+	e, received := startFake(t, "hang", UCI, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	res, err := e.Go(ctx, testFEN, nil, Limit{})
+	if err != nil {
+		t.Fatalf("Go: %v", err)
+	}
+	if res.BestMove != "b2e2" || res.Info.Depth != 20 {
+		t.Errorf("Go = %+v, want bestmove b2e2 given after stop", res)
+	}
+	if e.Broken() {
+		t.Error("engine broken after a stopped search, want reusable")
+	}
+	got := received()
+	if want := []string{"go infinite", "stop"}; !reflect.DeepEqual(got[len(got)-2:], want) {
+		t.Errorf("last commands = %q, want %q", got[len(got)-2:], want)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	e, _ := startFake(t, "nomove", UCI, nil)
+	if _, err := e.Go(context.Background(), testFEN, nil, Limit{Depth: 1}); !errors.Is(err, ErrNoBestMove) {
+		t.Errorf("nobestmove: err = %v, want ErrNoBestMove", err)
+	}
+	if e.Broken() {
+		t.Error("engine broken after nobestmove, want reusable")
+	}
+
+	e, _ = startFake(t, "crash", UCI, nil)
+	if _, err := e.Go(context.Background(), testFEN, nil, Limit{Depth: 1}); !errors.Is(err, ErrClosed) {
+		t.Errorf("crash: err = %v, want ErrClosed", err)
+	}
+	if !e.Broken() {
+		t.Error("engine not broken after crash")
+	}
+
+	path, _ := fakeEngine(t, "dead")
+	if _, err := Start(path, nil, UCI, nil); err == nil {
+		t.Error("Start succeeded with an engine that exits immediately")
+	}
+	if _, err := Start(path, nil, "xboard", nil); err == nil {
+		t.Error("Start succeeded with an unsupported protocol")
+	}
+}
+
+// fallbackMove 代替内置 AI 的后备搜索
+func fallbackMove(called *bool) func() (Result, error) {
+	return func() (Result, error) {
+		*called = true
+		return Result{BestMove: "b2e2"}, nil
+	}
+}
+
+func TestSearchOrFallback(t *testing.T) {
+	tests := []struct {
+		mode    string
+		timeout time.Duration
+	}{
+		{"crash", time.Second},
+		{"nomove", time.Second},
+		{"dead", time.Second},
+		// 超时后 stop 也没有回应，等待 stopGrace 后结束进程
+		{"mute", 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			path, _ := fakeEngine(t, tt.mode)
+			pool := NewPool(Config{Path: path, Protocol: UCI, Size: 1})
+			defer pool.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			var called bool
+			res, fromEngine, err := SearchOr(ctx, pool, testFEN, nil, Limit{}, fallbackMove(&called))
+			if err != nil || fromEngine || !called || res.BestMove != "b2e2" {
+				t.Errorf("SearchOr = %+v, %v, %v; want fallback result", res, fromEngine, err)
+			}
+		})
+	}
+
+	var called bool
+	res, fromEngine, err := SearchOr(context.Background(), nil, testFEN, nil, Limit{}, fallbackMove(&called))
+	if err != nil || fromEngine || !called || res.BestMove != "b2e2" {
+		t.Errorf("SearchOr without pool = %+v, %v, %v; want fallback result", res, fromEngine, err)
+	}
+
+	fallbackErr := errors.New("built-in ai failed")
+	_, _, err = SearchOr(context.Background(), nil, testFEN, nil, Limit{}, func() (Result, error) {
+		return Result{}, fallbackErr
+	})
+	if !errors.Is(err, fallbackErr) {
+		t.Errorf("SearchOr err = %v, want the fallback error", err)
+	}
+}
+
+func TestPoolRecoversAfterCrash(t *testing.T) {
+	path, _ := fakeEngine(t, "crash")
+	pool := NewPool(Config{Path: path, Protocol: UCI, Size: 1})
+	defer pool.Close()
+
+	var called bool
+	if _, fromEngine, _ := SearchOr(context.Background(), pool, testFEN, nil, Limit{Depth: 1}, fallbackMove(&called)); fromEngine || !called {
+		t.Fatal("crashed engine did not fall back")
+	}
+	// 崩溃的进程释放名额，下一次搜索启动新进程
+	t.Setenv(fakeModeEnv, "normal")
+	called = false
+	for i := 0; i < 2; i++ {
+		res, fromEngine, err := SearchOr(context.Background(), pool, testFEN, []string{"h2e2"}, Limit{Depth: 8}, fallbackMove(&called))
+		if err != nil || !fromEngine || called || res.BestMove != "h2e2" {
+			t.Fatalf("search %d: SearchOr = %+v, %v, %v; want engine result", i, res, fromEngine, err)
+		}
+	}
+	if pool.Name() != "FakeFish 1.0" {
+		t.Errorf("pool Name = %q, want the name reported by the engine", pool.Name())
+	}
+}
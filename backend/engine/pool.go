@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"chinese-chess-backend/config"
+)
+
+// ErrDisabled 未配置外部引擎
+var ErrDisabled = errors.New("服务器未配置外部引擎")
+
+// Config 外部引擎的启动参数
+type Config struct {
+	Path     string
+	Args     []string
+	Protocol Protocol
+	Size     int               // 最多同时运行的引擎进程数
+	Options  map[string]string // 握手后设置的引擎选项，如 Threads、Hash
+	MoveTime time.Duration     // 作为对局对手时每步的思考时间
+}
+
+// ConfigFromEnv 从环境变量读取引擎配置：ENGINE_PATH 为空表示不启用外部引擎
+// ENGINE_OPTIONS 形如 "Threads=1,Hash=64"
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Path:     os.Getenv("ENGINE_PATH"),
+		Args:     strings.Fields(os.Getenv("ENGINE_ARGS")),
+		Protocol: Protocol(strings.ToLower(os.Getenv("ENGINE_PROTOCOL"))),
+		Size:     max(config.GetEnvInt("ENGINE_POOL_SIZE", 2), 1),
+		Options:  make(map[string]string),
+		MoveTime: time.Duration(config.GetEnvInt("ENGINE_MOVETIME_MS", 1000)) * time.Millisecond,
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = UCI
+	}
+	for _, kv := range strings.Split(os.Getenv("ENGINE_OPTIONS"), ",") {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && strings.TrimSpace(name) != "" {
+			cfg.Options[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return cfg
+}
+
+// Pool 引擎进程池：按需启动进程，用完放回复用，出错的进程直接结束
+type Pool struct {
+	cfg    Config
+	idle   chan *Engine
+	slots  chan struct{} // 已启动的进程数
+	mu     sync.Mutex
+	name   string
+	closed bool
+}
+
+// NewPool 创建进程池，进程在第一次使用时才启动
+func NewPool(cfg Config) *Pool {
+	return &Pool{
+		cfg:   cfg,
+		idle:  make(chan *Engine, cfg.Size),
+		slots: make(chan struct{}, cfg.Size),
+	}
+}
+
+var (
+	defaultPool *Pool
+	defaultOnce sync.Once
+)
+
+// Default 返回按环境变量配置的进程池，未配置 ENGINE_PATH 时返回 nil
+func Default() *Pool {
+	defaultOnce.Do(func() {
+		cfg := ConfigFromEnv()
+		if cfg.Path != "" {
+			defaultPool = NewPool(cfg)
+		}
+	})
+	return defaultPool
+}
+
+// Enabled 是否配置了外部引擎
+func Enabled() bool {
+	return Default() != nil
+}
+
+// Name 引擎报告的名称，尚未启动过进程时使用可执行文件名
+func (p *Pool) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.name != "" {
+		return p.name
+	}
+	name := p.cfg.Path
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// MoveTime 作为对局对手时每步的思考时间
+func (p *Pool) MoveTime() time.Duration {
+	return p.cfg.MoveTime
+}
+
+// Acquire 取出一个空闲引擎，没有空闲且未达上限时启动新进程，否则等待
+func (p *Pool) Acquire(ctx context.Context) (*Engine, error) {
+	select {
+	case e := <-p.idle:
+		return e, nil
+	default:
+	}
+	select {
+	case e := <-p.idle:
+		return e, nil
+	case p.slots <- struct{}{}:
+		e, err := Start(p.cfg.Path, p.cfg.Args, p.cfg.Protocol, p.cfg.Options)
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		p.mu.Lock()
+		if name := e.Name(); name != "" {
+			p.name = name
+		}
+		p.mu.Unlock()
+		return e, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Release 归还引擎，已损坏的进程结束后释放名额
+func (p *Pool) Release(e *Engine) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed || e.Broken() {
+		e.Close()
+		<-p.slots
+		return
+	}
+	p.idle <- e
+}
+
+// Search 取一个引擎完成一次搜索后归还；每次搜索前重置引擎状态，不同对局之间互不影响
+func (p *Pool) Search(ctx context.Context, fen string, moves []string, limit Limit) (Result, error) {
+	e, err := p.Acquire(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer p.Release(e)
+	if err := e.NewGame(); err != nil {
+		log.Printf("engine new game failed: %v", err)
+		e.Close()
+		return Result{}, err
+	}
+	return e.Go(ctx, fen, moves, limit)
+}
+
+// SearchOr 由 pool 搜索，未配置外部引擎（pool 为 nil）或引擎出错（超时、进程崩溃、没有给出着法）时改用 fallback，
+// 通常是内置 AI；第二个返回值表示结果是否来自外部引擎
+func SearchOr(ctx context.Context, pool *Pool, fen string, moves []string, limit Limit, fallback func() (Result, error)) (Result, bool, error) {
+	if pool != nil {
+		res, err := pool.Search(ctx, fen, moves, limit)
+		if err == nil {
+			return res, true, nil
+		}
+		log.Printf("engine search failed, falling back to built-in ai: %v", err)
+	}
+	res, err := fallback()
+	return res, false, err
+}
+
+// Close 结束所有空闲的引擎进程，正在使用的进程归还后不再复用
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	for {
+		select {
+		case e := <-p.idle:
+			e.Close()
+			<-p.slots
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package gamefile 读写桌面象棋软件（XQStudio、象棋桥等）使用的棋谱文件：文本 PGN 与二进制 XQF
+package gamefile
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"chinese-chess-backend/xiangqi"
+)
+
+// 对局结果，取值与 GameRecord.Result 一致
+const (
+	ResultUnknown  = -1
+	ResultRedWin   = 0
+	ResultBlackWin = 1
+	ResultDraw     = 2
+)
+
+// Game 棋谱文件中的一局棋，只保留主线，走法为红方视角坐标
+type Game struct {
+	Event  string
+	Site   string
+	Date   time.Time // 零值表示未知
+	Red    string
+	Black  string
+	Result int
+	Moves  []xiangqi.Move
+	// Extra 额外写入 PGN 的标签，例如对局类型、结束原因
+	Extra map[string]string
+}
+
+// isInitialPosition 判断局面是否为标准开局且红方先行
+func isInitialPosition(b *xiangqi.Board) bool {
+	got := strings.Fields(b.FEN())
+	want := strings.Fields(xiangqi.InitialFEN)
+	return got[0] == want[0] && got[1] == want[1]
+}
+
+// decodeText 棋谱文件多为 GBK 编码，非合法 UTF-8 时按 GBK 解码
+func decodeText(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	if s, err := simplifiedchinese.GBK.NewDecoder().Bytes(b); err == nil {
+		return string(s)
+	}
+	return string(b)
+}
+
+// encodeGBK 将字符串编码为 GBK，最多 limit 字节且不截断半个汉字；无法编码的字符替换为 ?
+func encodeGBK(s string, limit int) []byte {
+	enc := simplifiedchinese.GBK.NewEncoder()
+	out := make([]byte, 0, limit)
+	for _, r := range s {
+		b, err := enc.Bytes([]byte(string(r)))
+		if err != nil {
+			b = []byte{'?'}
+		}
+		if len(out)+len(b) > limit {
+			break
+		}
+		out = append(out, b...)
+	}
+	return out
+}
+
+// parseDate 兼容 2024.05.01、2024-05-01、2024/05/01 与只有年份等写法，无法识别时返回零值
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	s = strings.NewReplacer("-", ".", "/", ".", "年", ".", "月", ".", "日", "").Replace(s)
+	for _, layout := range []string{"2006.01.02", "2006.1.2", "2006.01", "2006"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "????.??.??"
+	}
+	return t.Format("2006.01.02")
+}
@@ -0,0 +1,195 @@
+package gamefile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+var pgnResults = map[int]string{
+	ResultUnknown:  "*",
+	ResultRedWin:   "1-0",
+	ResultBlackWin: "0-1",
+	ResultDraw:     "1/2-1/2",
+}
+
+// pgnFormats PGN 中 Format 标签的取值
+var pgnFormats = map[notation.Format]string{
+	notation.Chinese: "Chinese",
+	notation.WXF:     "WXF",
+	notation.ICCS:    "ICCS",
+}
+
+// WritePGN 将对局写为象棋 PGN，走法按 format 记谱
+func WritePGN(g *Game, format notation.Format) ([]byte, error) {
+	text, err := notation.RenderGame(format, xiangqi.NewBoard(), g.Moves)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := pgnResults[g.Result]
+	if !ok {
+		result = "*"
+	}
+
+	var buf bytes.Buffer
+	tag := func(k, v string) {
+		fmt.Fprintf(&buf, "[%s \"%s\"]\n", k, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v))
+	}
+	tag("Game", "Chinese Chess")
+	tag("Event", g.Event)
+	tag("Site", g.Site)
+	tag("Date", formatDate(g.Date))
+	tag("Red", g.Red)
+	tag("Black", g.Black)
+	tag("Result", result)
+	tag("Format", pgnFormats[format])
+	keys := make([]string, 0, len(g.Extra))
+	for k := range g.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tag(k, g.Extra[k])
+	}
+	buf.WriteByte('\n')
+
+	for i := 0; i < len(text); i += 2 {
+		fmt.Fprintf(&buf, "%d. %s", i/2+1, text[i])
+		if i+1 < len(text) {
+			fmt.Fprintf(&buf, " %s", text[i+1])
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(result)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+var (
+	pgnTagRe     = regexp.MustCompile(`^\[\s*(\w+)\s+"((?:[^"\\]|\\.)*)"\s*\]`)
+	pgnMoveNumRe = regexp.MustCompile(`^\d+\.+`)
+)
+
+// ParsePGN 解析象棋 PGN 的第一局棋，忽略注释与变着；每步都会经规则引擎校验
+// 仅支持从标准开局开始的棋谱
+func ParsePGN(data []byte) (*Game, error) {
+	text := decodeText(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	tags := map[string]string{}
+	var movetext strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if m := pgnTagRe.FindStringSubmatch(line); m != nil {
+			if movetext.Len() > 0 && strings.TrimSpace(movetext.String()) != "" {
+				// 第二局的标签，只取第一局
+				break
+			}
+			tags[m[1]] = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(m[2])
+			continue
+		}
+		movetext.WriteString(line)
+		movetext.WriteByte('\n')
+	}
+
+	if fen, ok := tags["FEN"]; ok {
+		b, err := xiangqi.ParseFEN(fen)
+		if err != nil {
+			return nil, fmt.Errorf("FEN 标签无效：%w", err)
+		}
+		if !isInitialPosition(b) {
+			return nil, errors.New("暂不支持从自定义局面开始的棋谱")
+		}
+	}
+
+	g := &Game{
+		Event:  tags["Event"],
+		Site:   tags["Site"],
+		Date:   parseDate(tags["Date"]),
+		Red:    tags["Red"],
+		Black:  tags["Black"],
+		Result: ResultUnknown,
+	}
+	resultTag := tags["Result"]
+
+	board := xiangqi.NewBoard()
+	for _, tok := range pgnTokens(movetext.String()) {
+		if _, ok := resultOf(tok); ok {
+			if resultTag == "" || resultTag == "*" {
+				resultTag = tok
+			}
+			break
+		}
+		tok = pgnMoveNumRe.ReplaceAllString(tok, "")
+		tok = strings.TrimRight(tok, "!?")
+		if tok == "" || strings.HasPrefix(tok, "$") {
+			continue
+		}
+		m, err := notation.ParseMove(board, tok)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 步：%w", len(g.Moves)+1, err)
+		}
+		if _, err := board.MakeMove(m); err != nil {
+			return nil, fmt.Errorf("第 %d 步 %s 不合法：%w", len(g.Moves)+1, tok, err)
+		}
+		g.Moves = append(g.Moves, m)
+	}
+	if r, ok := resultOf(resultTag); ok {
+		g.Result = r
+	}
+	return g, nil
+}
+
+func resultOf(s string) (int, bool) {
+	for r, text := range pgnResults {
+		if s == text {
+			return r, true
+		}
+	}
+	// 部分软件以 0.5-0.5 记和棋
+	if s == "0.5-0.5" || s == "½-½" {
+		return ResultDraw, true
+	}
+	return 0, false
+}
+
+// pgnTokens 去掉 {} 注释、; 行注释与 () 变着后按空白切分
+func pgnTokens(s string) []string {
+	var sb strings.Builder
+	depth := 0
+	inComment := false
+	inLineComment := false
+	for _, r := range s {
+		switch {
+		case inLineComment:
+			if r == '\n' {
+				inLineComment = false
+				sb.WriteRune(' ')
+			}
+		case inComment:
+			if r == '}' {
+				inComment = false
+				sb.WriteRune(' ')
+			}
+		case r == '{':
+			inComment = true
+		case r == ';':
+			inLineComment = true
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+			sb.WriteRune(' ')
+		case depth > 0:
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Fields(sb.String())
+}
@@ -0,0 +1,121 @@
+package gamefile
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+// randomGame 以固定种子随机走 plies 步合法着法，覆盖吃子、同线多子等记谱情形
+func randomGame(t *testing.T, seed int64, plies int) []xiangqi.Move {
+	t.Helper()
+	r := rand.New(rand.NewSource(seed))
+	b := xiangqi.NewBoard()
+	var moves []xiangqi.Move
+	for len(moves) < plies {
+		legal := b.LegalMoves()
+		if len(legal) == 0 {
+			break
+		}
+		m := legal[r.Intn(len(legal))]
+		if _, err := b.MakeMove(m); err != nil {
+			t.Fatalf("MakeMove(%+v): %v", m, err)
+		}
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+// sampleGame 中炮对屏风马的开局
+func sampleGame(t *testing.T) *Game {
+	t.Helper()
+	b := xiangqi.NewBoard()
+	var moves []xiangqi.Move
+	for _, text := range []string{"炮二平五", "马８进７", "马二进三", "车９平８", "车一平二", "马２进３", "兵七进一", "卒７进１"} {
+		m, err := notation.ParseMove(b, text)
+		if err != nil {
+			t.Fatalf("ParseMove(%s): %v", text, err)
+		}
+		if _, err := b.MakeMove(m); err != nil {
+			t.Fatalf("MakeMove(%s): %v", text, err)
+		}
+		moves = append(moves, m)
+	}
+	return &Game{
+		Event:  "全国象棋个人赛",
+		Site:   "北京",
+		Date:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		Red:    "许银川",
+		Black:  "吕钦",
+		Result: ResultRedWin,
+		Moves:  moves,
+	}
+}
+
+func TestPGNRoundTrip(t *testing.T) {
+	games := []*Game{
+		sampleGame(t),
+		{Red: `say "hi"`, Black: `back\slash`, Result: ResultDraw, Moves: randomGame(t, 1, 120)},
+		{Result: ResultUnknown, Moves: randomGame(t, 7, 200)},
+		{Result: ResultBlackWin},
+	}
+	for _, format := range []notation.Format{notation.Chinese, notation.WXF, notation.ICCS} {
+		for i, g := range games {
+			data, err := WritePGN(g, format)
+			if err != nil {
+				t.Fatalf("%s game %d: WritePGN: %v", format, i, err)
+			}
+			got, err := ParsePGN(data)
+			if err != nil {
+				t.Fatalf("%s game %d: ParsePGN: %v\n%s", format, i, err, data)
+			}
+			if len(got.Moves) != len(g.Moves) || (len(g.Moves) > 0 && !reflect.DeepEqual(got.Moves, g.Moves)) {
+				t.Errorf("%s game %d: moves differ after round trip\n%s", format, i, data)
+			}
+			if got.Red != g.Red || got.Black != g.Black || got.Event != g.Event || got.Site != g.Site ||
+				got.Result != g.Result || !got.Date.Equal(g.Date) {
+				t.Errorf("%s game %d: header = %+v, want %+v", format, i, got, g)
+			}
+		}
+	}
+}
+
+func TestParsePGNCommentsAndVariations(t *testing.T) {
+	pgn := `[Game "Chinese Chess"]
+[Red "红方"]
+[Black "黑方"]
+
+1. 炮二平五 {中炮} 马８进７ (1... 炮８平５ 2. 马二进三) 
+2. 马二进三 ; 行尾注释
+车９平８ $1
+3. 车一平二!? 1/2-1/2
+`
+	g, err := ParsePGN([]byte(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sampleGame(t).Moves[:5]
+	if !reflect.DeepEqual(g.Moves, want) {
+		t.Errorf("moves = %v, want %v", xiangqi.FormatHistory(g.Moves), xiangqi.FormatHistory(want))
+	}
+	if g.Result != ResultDraw {
+		t.Errorf("result = %d, want %d", g.Result, ResultDraw)
+	}
+}
+
+func TestParsePGNErrors(t *testing.T) {
+	for _, pgn := range []string{
+		"1. 车一进三",
+		"1. 马二进四",
+		"1. 炮二平五 马８进６",
+		`[FEN "4k4/9/9/9/9/9/9/9/9/4K4 w - - 0 1"]` + "\n1. 帅五平四",
+	} {
+		if _, err := ParsePGN([]byte(pgn)); err == nil {
+			t.Errorf("ParsePGN(%q) succeeded, want error", pgn)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package gamefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"chinese-chess-backend/xiangqi"
+)
+
+// XQF 是 XQStudio 的二进制棋谱格式：1024 字节文件头 + 按先序遍历存储的着法树
+// 坐标编码为 x*10+y，x 为从红方左侧数起的纵线（0-8），y 为从红方底线数起的横线（0-9）
+
+const (
+	xqfHeaderSize = 1024
+	// xqfWriteVersion 导出时使用的版本号，10 及以下版本不加密，各软件均能读取
+	xqfWriteVersion = 10
+)
+
+// 文件头中各字段的偏移
+const (
+	xqfOffVersion  = 2
+	xqfOffKeyMask  = 3
+	xqfOffKeyOr    = 8  // 4 字节
+	xqfOffKeySum   = 12 // KeysSum, KeyXY, KeyXYf, KeyXYt
+	xqfOffPieces   = 16 // 32 字节
+	xqfOffResult   = 51
+	xqfOffTitle    = 80
+	xqfOffEvent    = 208
+	xqfOffDate     = 272
+	xqfOffSite     = 288
+	xqfOffRed      = 304
+	xqfOffBlack    = 320
+	xqfShortString = 16
+	xqfLongString  = 64
+)
+
+// xqfKeyMask 加密版本的密钥掩码
+var xqfKeyMask = []byte("[(C) Copyright Mr. Dong Shiwei.]")
+
+// xqfPieceOrder 文件头中 32 个棋子槽位的种类，红方在前（0-15）黑方在后（16-31）
+var xqfPieceOrder = [16]xiangqi.Kind{
+	xiangqi.Chariot, xiangqi.Horse, xiangqi.Elephant, xiangqi.Advisor, xiangqi.General,
+	xiangqi.Advisor, xiangqi.Elephant, xiangqi.Horse, xiangqi.Chariot,
+	xiangqi.Cannon, xiangqi.Cannon,
+	xiangqi.Soldier, xiangqi.Soldier, xiangqi.Soldier, xiangqi.Soldier, xiangqi.Soldier,
+}
+
+// xqf 结果字节：0 未知，1 红胜，2 黑胜，3 和棋
+var xqfResults = map[byte]int{1: ResultRedWin, 2: ResultBlackWin, 3: ResultDraw}
+
+// xqfInitialSquares 标准开局时 32 个槽位的坐标
+func xqfInitialSquares() [32]byte {
+	files := [16]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 1, 7, 0, 2, 4, 6, 8}
+	ranks := [16]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 2, 3, 3, 3, 3, 3}
+	var sq [32]byte
+	for i := range 16 {
+		sq[i] = byte(files[i]*10 + ranks[i])
+		sq[16+i] = byte(files[i]*10 + 9 - ranks[i])
+	}
+	return sq
+}
+
+func xqfSquare(p xiangqi.Pos) byte {
+	return byte(p.X*10 + 9 - p.Y)
+}
+
+func xqfPos(sq byte) (xiangqi.Pos, bool) {
+	if sq > 89 {
+		return xiangqi.Pos{}, false
+	}
+	return xiangqi.Pos{X: int(sq) / 10, Y: 9 - int(sq)%10}, true
+}
+
+// WriteXQF 将对局写为未加密的 XQF 文件
+func WriteXQF(g *Game) ([]byte, error) {
+	if _, err := xiangqi.Replay(xiangqi.NewBoard(), g.Moves); err != nil {
+		return nil, err
+	}
+	h := make([]byte, xqfHeaderSize)
+	h[0], h[1], h[xqfOffVersion] = 'X', 'Q', xqfWriteVersion
+
+	squares := xqfInitialSquares()
+	copy(h[xqfOffPieces:], squares[:])
+	for code, r := range xqfResults {
+		if r == g.Result {
+			h[xqfOffResult] = code
+		}
+	}
+	putPascal(h, xqfOffTitle, xqfLongString, g.Red+" vs "+g.Black)
+	putPascal(h, xqfOffEvent, xqfLongString, g.Event)
+	putPascal(h, xqfOffDate, xqfShortString, formatDate(g.Date))
+	putPascal(h, xqfOffSite, xqfShortString, g.Site)
+	putPascal(h, xqfOffRed, xqfShortString, g.Red)
+	putPascal(h, xqfOffBlack, xqfShortString, g.Black)
+
+	var buf bytes.Buffer
+	buf.Write(h)
+	// 旧版本每条记录为 4 字节着法 + 4 字节注释长度，tag 高 4 位非零表示有后续着法
+	writeRecord := func(from, to byte, hasNext bool) {
+		tag := byte(0)
+		if hasNext {
+			tag = 0xf0
+		}
+		buf.Write([]byte{from + 24, to + 32, tag, 0})
+		buf.Write([]byte{0, 0, 0, 0})
+	}
+	writeRecord(0, 0, len(g.Moves) > 0) // 根节点，不对应任何着法
+	for i, m := range g.Moves {
+		writeRecord(xqfSquare(m.From), xqfSquare(m.To), i+1 < len(g.Moves))
+	}
+	return buf.Bytes(), nil
+}
+
+func putPascal(h []byte, off, size int, s string) {
+	b := encodeGBK(s, size-1)
+	h[off] = byte(len(b))
+	copy(h[off+1:], b)
+}
+
+func getPascal(h []byte, off, size int) string {
+	n := int(h[off])
+	if n > size-1 {
+		n = size - 1
+	}
+	return decodeText(h[off+1 : off+1+n])
+}
+
+// square54Plus221 XQF 密钥推导公式，按 8 位无符号数溢出计算
+func square54Plus221(x byte) byte {
+	return x*x*54 + 221
+}
+
+// ParseXQF 解析 XQF 文件（含 11 版及以上的加密格式），只读取主线；每步都会经规则引擎校验
+func ParseXQF(data []byte) (*Game, error) {
+	if len(data) < xqfHeaderSize || data[0] != 'X' || data[1] != 'Q' {
+		return nil, errors.New("不是有效的 XQF 文件")
+	}
+	h := data[:xqfHeaderSize]
+	version := h[xqfOffVersion]
+
+	var pieceOff, srcOff, dstOff byte
+	commentOff := 0
+	var keys [32]byte
+	if version >= 11 {
+		keyXY, keyXYf, keyXYt := h[xqfOffKeySum+1], h[xqfOffKeySum+2], h[xqfOffKeySum+3]
+		pieceOff = square54Plus221(keyXY) * keyXY
+		srcOff = square54Plus221(keyXYf) * pieceOff
+		dstOff = square54Plus221(keyXYt) * srcOff
+		commentOff = (int(h[xqfOffKeySum])*256+int(keyXY))%32000 + 767
+		mask := h[xqfOffKeyMask]
+		var args [4]byte
+		for i := range args {
+			args[i] = h[xqfOffKeyOr+i] | (mask & h[xqfOffKeySum+i])
+		}
+		for i := range keys {
+			keys[i] = args[i%4] & xqfKeyMask[i]
+		}
+	}
+
+	// 摆放初始局面
+	var squares [32]byte
+	for i := range 32 {
+		if version >= 12 {
+			squares[(int(pieceOff)+1+i)%32] = h[xqfOffPieces+i]
+		} else {
+			squares[i] = h[xqfOffPieces+i]
+		}
+	}
+	board := xiangqi.EmptyBoard(xiangqi.Red)
+	for i, sq := range squares {
+		pos, ok := xqfPos(sq - pieceOff)
+		if !ok {
+			continue
+		}
+		color := xiangqi.Red
+		if i >= 16 {
+			color = xiangqi.Black
+		}
+		board.Set(pos, xiangqi.Piece{Kind: xqfPieceOrder[i%16], Color: color})
+	}
+	if !isInitialPosition(board) {
+		return nil, errors.New("暂不支持从自定义局面开始的棋谱")
+	}
+
+	g := &Game{
+		Event:  getPascal(h, xqfOffEvent, xqfLongString),
+		Site:   getPascal(h, xqfOffSite, xqfShortString),
+		Date:   parseDate(getPascal(h, xqfOffDate, xqfShortString)),
+		Red:    getPascal(h, xqfOffRed, xqfShortString),
+		Black:  getPascal(h, xqfOffBlack, xqfShortString),
+		Result: ResultUnknown,
+	}
+	if r, ok := xqfResults[h[xqfOffResult]]; ok {
+		g.Result = r
+	}
+
+	// 着法树按先序存储，主线即从根节点起连续的“第一个子节点”
+	body := data[xqfHeaderSize:]
+	offset := 0
+	read := func(n int) ([]byte, error) {
+		if n < 0 || offset+n > len(body) {
+			return nil, errors.New("XQF 文件不完整")
+		}
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = body[offset+i] - keys[(offset+i)%32]
+		}
+		offset += n
+		return out, nil
+	}
+	for root := true; ; root = false {
+		rec, err := read(4)
+		if err != nil {
+			return nil, err
+		}
+		tag := rec[2]
+		var hasNext bool
+		commentLen := 0
+		if version < 11 {
+			hasNext = tag&0xf0 != 0
+			lb, err := read(4)
+			if err != nil {
+				return nil, err
+			}
+			commentLen = int(int32(binary.LittleEndian.Uint32(lb)))
+		} else {
+			hasNext = tag&0x80 != 0
+			if tag&0x20 != 0 {
+				lb, err := read(4)
+				if err != nil {
+					return nil, err
+				}
+				commentLen = int(int32(binary.LittleEndian.Uint32(lb))) - commentOff
+			}
+		}
+		if _, err := read(commentLen); err != nil {
+			return nil, err
+		}
+		if !root {
+			from, ok1 := xqfPos(rec[0] - 24 - srcOff)
+			to, ok2 := xqfPos(rec[1] - 32 - dstOff)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("第 %d 步坐标超出棋盘", len(g.Moves)+1)
+			}
+			m := xiangqi.Move{From: from, To: to}
+			if _, err := board.MakeMove(m); err != nil {
+				return nil, fmt.Errorf("第 %d 步 %s 不合法：%w", len(g.Moves)+1, xiangqi.FormatHistory([]xiangqi.Move{m}), err)
+			}
+			g.Moves = append(g.Moves, m)
+		}
+		if !hasNext {
+			break
+		}
+	}
+	return g, nil
+}
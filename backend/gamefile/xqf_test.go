@@ -0,0 +1,161 @@
+package gamefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"chinese-chess-backend/xiangqi"
+)
+
+var updateFixtures = flag.Bool("update", false, "重新生成 testdata 中的棋谱文件")
+
+func TestXQFRoundTrip(t *testing.T) {
+	games := []*Game{
+		sampleGame(t),
+		{Red: "一个很长很长很长很长的名字", Result: ResultDraw, Moves: randomGame(t, 3, 150)},
+		{Result: ResultUnknown},
+	}
+	for i, g := range games {
+		data, err := WriteXQF(g)
+		if err != nil {
+			t.Fatalf("game %d: WriteXQF: %v", i, err)
+		}
+		got, err := ParseXQF(data)
+		if err != nil {
+			t.Fatalf("game %d: ParseXQF: %v", i, err)
+		}
+		if len(got.Moves) != len(g.Moves) || (len(g.Moves) > 0 && !reflect.DeepEqual(got.Moves, g.Moves)) {
+			t.Errorf("game %d: moves = %s, want %s", i, xiangqi.FormatHistory(got.Moves), xiangqi.FormatHistory(g.Moves))
+		}
+		if got.Black != g.Black || got.Event != g.Event || got.Site != g.Site || got.Result != g.Result || !got.Date.Equal(g.Date) {
+			t.Errorf("game %d: header = %+v, want %+v", i, got, g)
+		}
+	}
+	// 名字按 GBK 截断到 15 字节，不截断半个汉字
+	data, _ := WriteXQF(games[1])
+	if got, _ := ParseXQF(data); got.Red != "一个很长很长很" {
+		t.Errorf("truncated red name = %q", got.Red)
+	}
+}
+
+func TestParseEncryptedXQF(t *testing.T) {
+	path := filepath.Join("testdata", "encrypted.xqf")
+	g := sampleGame(t)
+	if *updateFixtures {
+		if err := os.WriteFile(path, encryptedXQF(t, g), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseXQF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Moves, g.Moves) {
+		t.Errorf("moves = %s, want %s", xiangqi.FormatHistory(got.Moves), xiangqi.FormatHistory(g.Moves))
+	}
+	if got.Red != g.Red || got.Black != g.Black || got.Event != g.Event || got.Result != g.Result || !got.Date.Equal(g.Date) {
+		t.Errorf("header = %+v, want %+v", got, g)
+	}
+}
+
+func TestParseXQFErrors(t *testing.T) {
+	good, err := WriteXQF(sampleGame(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := good[:len(good)-3]
+	illegal := bytes.Clone(good)
+	// 第一步改为车一进三（吃己方兵）
+	rec := illegal[xqfHeaderSize+8:]
+	rec[0], rec[1] = xqfSquare(xiangqi.Pos{X: 8, Y: 9})+24, xqfSquare(xiangqi.Pos{X: 8, Y: 6})+32
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("PK"), good[2:]...),
+		"truncated": truncated,
+		"illegal":   illegal,
+	} {
+		if _, err := ParseXQF(data); err == nil {
+			t.Errorf("%s: ParseXQF succeeded, want error", name)
+		}
+	}
+}
+
+// encryptedXQF 按 XQStudio 18 版的加密格式写出对局：棋子槽位旋转并加偏移，着法坐标加偏移，
+// 着法区逐字节加密钥；主线第二步带注释并有一个变着，用于确认只读取主线
+func encryptedXQF(t *testing.T, g *Game) []byte {
+	t.Helper()
+	plain, err := WriteXQF(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := bytes.Clone(plain[:xqfHeaderSize])
+	h[xqfOffVersion] = 18
+	h[xqfOffKeyMask] = 0x5f
+	copy(h[xqfOffKeyOr:], []byte{0x12, 0x34, 0x56, 0x78})
+	keyXY, keyXYf, keyXYt := byte(0x3a), byte(0x91), byte(0xc7)
+	h[xqfOffKeySum] = keyXY + keyXYf + keyXYt
+	h[xqfOffKeySum+1], h[xqfOffKeySum+2], h[xqfOffKeySum+3] = keyXY, keyXYf, keyXYt
+
+	pieceOff := square54Plus221(keyXY) * keyXY
+	srcOff := square54Plus221(keyXYf) * pieceOff
+	dstOff := square54Plus221(keyXYt) * srcOff
+	commentOff := (int(h[xqfOffKeySum])*256+int(keyXY))%32000 + 767
+	squares := xqfInitialSquares()
+	for i := range 32 {
+		h[xqfOffPieces+i] = squares[(int(pieceOff)+1+i)%32] + pieceOff
+	}
+
+	var body bytes.Buffer
+	record := func(m *xiangqi.Move, hasNext, hasSibling bool, comment string) {
+		var from, to byte
+		if m != nil {
+			from, to = xqfSquare(m.From), xqfSquare(m.To)
+		}
+		tag := byte(0)
+		if hasNext {
+			tag |= 0x80
+		}
+		if hasSibling {
+			tag |= 0x40
+		}
+		if comment != "" {
+			tag |= 0x20
+		}
+		body.Write([]byte{from + 24 + srcOff, to + 32 + dstOff, tag, 0})
+		if comment != "" {
+			text := encodeGBK(comment, 256)
+			binary.Write(&body, binary.LittleEndian, int32(len(text)+commentOff))
+			body.Write(text)
+		}
+	}
+	record(nil, len(g.Moves) > 0, false, "")
+	for i := range g.Moves {
+		comment := ""
+		if i == 1 {
+			comment = "屏风马应中炮"
+		}
+		record(&g.Moves[i], i+1 < len(g.Moves), i == 1, comment)
+	}
+	// 第二步的变着：炮８平５，按先序存储在主线之后
+	variation := xiangqi.Move{From: xiangqi.Pos{X: 7, Y: 2}, To: xiangqi.Pos{X: 4, Y: 2}}
+	record(&variation, false, false, "")
+
+	var keys [32]byte
+	for i := range keys {
+		keys[i] = (h[xqfOffKeyOr+i%4] | (h[xqfOffKeyMask] & h[xqfOffKeySum+i%4])) & xqfKeyMask[i]
+	}
+	enc := body.Bytes()
+	for i := range enc {
+		enc[i] += keys[i%32]
+	}
+	return append(h, enc...)
+}
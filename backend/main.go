@@ -3,12 +3,23 @@ package main
 import (
 	"chinese-chess-backend/config"
 	"chinese-chess-backend/database"
+	"chinese-chess-backend/engine"
 	userModel "chinese-chess-backend/model/user"
 	"chinese-chess-backend/route"
+	"chinese-chess-backend/service"
+	"chinese-chess-backend/websocket"
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+// shutdownTimeout 停机时在对局宽限期之外，等待记录写入与 HTTP 请求完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	config.InitConfig()
 	// 应用启动时，将所有用户在线状态重置为离线，避免历史脏数据导致无法登录
@@ -19,14 +30,27 @@ func main() {
 			log.Printf("reset online status failed: %v", err)
 		}
 	}()
+	// 残局关卡表与习题表为空时写入内置关卡与习题
+	service.SeedEndgameScenarios()
+	service.SeedPuzzles()
+	// 为加入开局分类之前保存的对局记录补充开局编码
+	go service.BackfillOpenings()
 	r := route.SetupRouter()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// 心跳过期检测：每60秒扫描，超过2分钟未心跳的用户自动置为离线
 	go func() {
 		ticker := time.NewTicker(60 * time.Second)
 		defer ticker.Stop()
 		timeout := 2 * time.Minute
-		for range ticker.C {
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
 			db := database.GetMysqlDb()
 			expireBefore := time.Now().Add(-timeout)
 			if err := db.Model(&userModel.User{}).
@@ -37,5 +61,40 @@ func main() {
 		}
 	}()
 
-	r.Run(":8080")
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("listen failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("shutting down, draining websocket hub")
+
+	// 先停止 hub：宽限期内仍可重连、发起 REST 请求；随后停止 HTTP 服务并关闭数据库连接
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), websocket.ShutdownGrace+shutdownTimeout)
+	defer cancel()
+	if websocket.DefaultHub != nil {
+		if err := websocket.DefaultHub.Shutdown(shutdownCtx); err != nil {
+			log.Printf("hub shutdown: %v", err)
+		}
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http server shutdown: %v", err)
+	}
+	// 赛后分析可能使用外部引擎，先于引擎进程池停止
+	if err := service.StopRecordAnalysis(shutdownCtx); err != nil {
+		log.Printf("record analysis shutdown: %v", err)
+	}
+	if pool := engine.Default(); pool != nil {
+		pool.Close()
+	}
+	if err := database.CloseMysql(); err != nil {
+		log.Printf("close mysql failed: %v", err)
+	}
+	if err := database.CloseRedis(); err != nil {
+		log.Printf("close redis failed: %v", err)
+	}
+	log.Println("server stopped")
 }
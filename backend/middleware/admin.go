@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"chinese-chess-backend/dto"
+)
+
+// AdminMiddleware 只放行 ADMIN_USER_IDS（逗号分隔的用户ID）中的用户，需在 AuthMiddleware 之后使用
+func AdminMiddleware() gin.HandlerFunc {
+	admins := make(map[int]bool)
+	for _, s := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && id > 0 {
+			admins[id] = true
+		}
+	}
+	return func(c *gin.Context) {
+		if !admins[c.GetInt("userId")] {
+			dto.ErrorResponse(c, dto.WithMessage("没有管理员权限"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
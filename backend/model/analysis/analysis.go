@@ -0,0 +1,47 @@
+package analysis
+
+import "time"
+
+// 分析任务状态
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// 着法分类，按损失的分数（100 约为一个兵）从低到高
+const (
+	ClassBest       = "best"
+	ClassGood       = "good"
+	ClassInaccuracy = "inaccuracy"
+	ClassMistake    = "mistake"
+	ClassBlunder    = "blunder"
+)
+
+// GameAnalysis 一局对局记录的赛后分析，一条记录对应一个对局
+type GameAnalysis struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RecordID      uint      `gorm:"column:record_id;uniqueIndex" json:"record_id"`
+	Status        string    `gorm:"column:status;type:varchar(16)" json:"status"`
+	Engine        string    `gorm:"column:engine;type:varchar(64);default:''" json:"engine"`
+	Error         string    `gorm:"column:error;type:varchar(255);default:''" json:"error"`
+	RedAccuracy   float64   `gorm:"column:red_accuracy" json:"red_accuracy"`     // 红方准确率（0-100）
+	BlackAccuracy float64   `gorm:"column:black_accuracy" json:"black_accuracy"` // 黑方准确率（0-100）
+	PuzzlesMined  bool      `gorm:"column:puzzles_mined;default:false" json:"-"` // 败着是否已挖掘过习题，挖不出习题的对局也不再重复挖掘
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// MoveAnalysis 对局中一步棋的分析结果，分数均为红方视角
+type MoveAnalysis struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	RecordID  uint   `gorm:"column:record_id;index:idx_move_analysis_ply,unique" json:"record_id"`
+	Ply       int    `gorm:"column:ply;index:idx_move_analysis_ply,unique" json:"ply"` // 从 0 开始的半回合序号
+	Move      string `gorm:"column:move;type:varchar(8)" json:"move"`                  // 实际走法（ICCS）
+	Score     int    `gorm:"column:score" json:"score"`                                // 走子后的局面评分
+	BestMove  string `gorm:"column:best_move;type:varchar(8)" json:"best_move"`        // 引擎推荐的走法（ICCS）
+	BestScore int    `gorm:"column:best_score" json:"best_score"`                      // 走子前的局面评分，即按推荐走法的评分
+	Loss      int    `gorm:"column:loss" json:"loss"`                                  // 走子方相对推荐走法损失的分数
+	Class     string `gorm:"column:class;type:varchar(16)" json:"class"`
+}
@@ -0,0 +1,32 @@
+package endgame
+
+import "time"
+
+// 残局关卡的目标
+const (
+	GoalWin  = "win"  // 在限定步数内将死或困毙对方
+	GoalDraw = "draw" // 走满限定步数而不被将死（守和）
+)
+
+// Difficulties 关卡难度，按展示顺序排列
+var Difficulties = []string{"初级", "中级", "高级"}
+
+// Scenario 残局关卡：玩家执 FEN 中的行棋方先走，由服务端 AI 执另一方
+// ID 即 EndgameProgress.ScenarioID，创建后不可修改；不再使用的关卡下架而不删除，保留玩家的进度
+type Scenario struct {
+	ID         string    `gorm:"primaryKey;type:varchar(64)" json:"id"`
+	Name       string    `gorm:"column:name;type:varchar(64);not null" json:"name"`
+	Difficulty string    `gorm:"column:difficulty;type:varchar(16);index" json:"difficulty"` // 初级/中级/高级
+	FEN        string    `gorm:"column:fen;type:varchar(128);not null" json:"fen"`
+	Goal       string    `gorm:"column:goal;type:varchar(16)" json:"goal"`
+	MoveLimit  int       `gorm:"column:move_limit" json:"move_limit"`                  // 玩家最多可走的步数
+	SortOrder  int       `gorm:"column:sort_order;default:0" json:"sort_order"`        // 同一难度内的顺序，越小越靠前
+	Tags       string    `gorm:"column:tags;type:varchar(255);default:''" json:"tags"` // 逗号分隔的标签
+	Retired    bool      `gorm:"column:retired;default:false;index" json:"retired"`    // 已下架的关卡不再展示，也不能开始挑战
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (Scenario) TableName() string {
+	return "endgame_scenarios"
+}
@@ -3,12 +3,16 @@ package model
 import (
 	"gorm.io/gorm"
 
+	"chinese-chess-backend/model/analysis"
 	"chinese-chess-backend/model/chat"
 	"chinese-chess-backend/model/endgame"
 	"chinese-chess-backend/model/friend"
 	challenge "chinese-chess-backend/model/friend_challenge"
 	friendrequest "chinese-chess-backend/model/friend_request"
+	"chinese-chess-backend/model/puzzle"
+	"chinese-chess-backend/model/rating"
 	"chinese-chess-backend/model/record"
+	"chinese-chess-backend/model/tournament"
 	"chinese-chess-backend/model/user"
 )
 
@@ -22,6 +26,18 @@ func InitTable(db *gorm.DB) error {
 		&friendrequest.FriendRequest{},
 		&challenge.FriendChallenge{},
 		&endgame.EndgameProgress{},
+		&endgame.Scenario{},
+		&rating.UserRating{},
+		&rating.RatingHistory{},
+		&analysis.GameAnalysis{},
+		&analysis.MoveAnalysis{},
+		&puzzle.Puzzle{},
+		&puzzle.PuzzleRating{},
+		&puzzle.PuzzleAttempt{},
+		&puzzle.DailyPuzzle{},
+		&tournament.Tournament{},
+		&tournament.Player{},
+		&tournament.Pairing{},
 	)
 	if err != nil {
 		return err
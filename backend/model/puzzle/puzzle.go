@@ -0,0 +1,69 @@
+package puzzle
+
+import "time"
+
+// 习题来源
+const (
+	SourceBuiltin = "builtin" // 内置习题
+	SourceManual  = "manual"  // 管理员录入
+	SourceMined   = "mined"   // 从对局记录的败着中挖掘
+)
+
+// 一次解题的状态
+const (
+	AttemptPlaying = "playing"
+	AttemptSolved  = "solved"
+	AttemptFailed  = "failed"
+)
+
+// Puzzle 习题：从 FEN 局面开始，行棋方按 Solution 走出强制的着法序列
+// Solution 为空格分隔的 ICCS 着法，解题方与对方交替，以解题方的着法开始和结束
+type Puzzle struct {
+	ID         uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	FEN        string  `gorm:"column:fen;type:varchar(128);not null" json:"fen"`
+	Solution   string  `gorm:"column:solution;type:varchar(255);not null" json:"solution"`
+	Rating     float64 `gorm:"column:rating;default:1500;index" json:"rating"`
+	RD         float64 `gorm:"column:rd;default:350" json:"rd"`                  // 评分偏差
+	Volatility float64 `gorm:"column:volatility;default:0.06" json:"volatility"` // 波动率
+	Plays      int     `gorm:"column:plays;default:0" json:"plays"`
+	Solves     int     `gorm:"column:solves;default:0" json:"solves"`
+	Source     string  `gorm:"column:source;type:varchar(16)" json:"source"`
+	// SourceRecordID、SourcePly 挖掘出习题的对局记录与败着的半回合序号，同一步败着只生成一道习题
+	SourceRecordID *uint     `gorm:"column:source_record_id;index:idx_puzzle_source,unique" json:"source_record_id,omitempty"`
+	SourcePly      *int      `gorm:"column:source_ply;index:idx_puzzle_source,unique" json:"source_ply,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PuzzleRating 用户的习题等级分（Glicko-2），与对局等级分相互独立
+type PuzzleRating struct {
+	UserID       uint       `gorm:"primaryKey;autoIncrement:false;column:user_id" json:"user_id"`
+	Rating       float64    `gorm:"column:rating;default:1500" json:"rating"`
+	RD           float64    `gorm:"column:rd;default:350" json:"rd"`
+	Volatility   float64    `gorm:"column:volatility;default:0.06" json:"volatility"`
+	Attempts     int        `gorm:"column:attempts;default:0" json:"attempts"`
+	Solved       int        `gorm:"column:solved;default:0" json:"solved"`
+	LastPlayedAt *time.Time `gorm:"column:last_played_at" json:"last_played_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// PuzzleAttempt 用户对一道习题的解答，每人每题只计分一次
+type PuzzleAttempt struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID   uint   `gorm:"column:user_id;index:idx_puzzle_attempt,unique" json:"user_id"`
+	PuzzleID uint   `gorm:"column:puzzle_id;index:idx_puzzle_attempt,unique" json:"puzzle_id"`
+	Status   string `gorm:"column:status;type:varchar(16);index" json:"status"`
+	// Moves 已走的着法（空格分隔的 ICCS），包括服务端代走的对方应着
+	Moves        string    `gorm:"column:moves;type:varchar(255);default:''" json:"moves"`
+	RatingBefore float64   `gorm:"column:rating_before" json:"rating_before"`
+	RatingAfter  float64   `gorm:"column:rating_after" json:"rating_after"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// DailyPuzzle 每日一题，每个日期一道
+type DailyPuzzle struct {
+	Date      string    `gorm:"primaryKey;type:varchar(10)" json:"date"` // 2006-01-02
+	PuzzleID  uint      `gorm:"column:puzzle_id;index" json:"puzzle_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
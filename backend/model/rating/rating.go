@@ -0,0 +1,29 @@
+package rating
+
+import "time"
+
+// UserRating 用户的 Glicko-2 等级分，一个用户一条记录
+type UserRating struct {
+	UserID     uint       `gorm:"primaryKey;autoIncrement:false;column:user_id" json:"user_id"`
+	Rating     float64    `gorm:"column:rating;default:1500" json:"rating"`
+	RD         float64    `gorm:"column:rd;default:350" json:"rd"`                  // 评分偏差
+	Volatility float64    `gorm:"column:volatility;default:0.06" json:"volatility"` // 波动率
+	Games      int        `gorm:"column:games;default:0" json:"games"`              // 计分对局数
+	LastGameAt *time.Time `gorm:"column:last_game_at" json:"last_game_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// RatingHistory 每局计分对局后某一方的等级分变化
+type RatingHistory struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       uint      `gorm:"column:user_id;index" json:"user_id"`
+	GameRecordID uint      `gorm:"column:game_record_id;index" json:"game_record_id"`
+	OpponentID   uint      `gorm:"column:opponent_id" json:"opponent_id"`
+	Score        float64   `gorm:"column:score" json:"score"` // 1 胜 0.5 和 0 负
+	RatingBefore float64   `gorm:"column:rating_before" json:"rating_before"`
+	RatingAfter  float64   `gorm:"column:rating_after" json:"rating_after"`
+	RDBefore     float64   `gorm:"column:rd_before" json:"rd_before"`
+	RDAfter      float64   `gorm:"column:rd_after" json:"rd_after"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -2,6 +2,33 @@ package record
 
 import "time"
 
+// 对局结束原因（GameRecord.EndReason 取值，同时作为 websocket 结束消息中的 reason 下发给客户端）
+const (
+	EndReasonCheckmate  = "checkmate"   // 将死
+	EndReasonStalemate  = "stalemate"   // 困毙（无子可走判负）
+	EndReasonResign     = "resign"      // 认输
+	EndReasonDisconnect = "disconnect"  // 断线超时判负
+	EndReasonDrawAgreed = "draw_agreed" // 双方议和
+	// 循环局面裁决（中国象棋协会规则）
+	EndReasonPerpetualCheck = "perpetual_check" // 长将判负
+	EndReasonPerpetualChase = "perpetual_chase" // 长捉判负
+	EndReasonRepetition     = "repetition"      // 循环不变作和
+	EndReasonMoveLimit      = "move_limit"      // 自然限着（长时间未吃子）判和
+	EndReasonTimeout        = "timeout"         // 超时判负
+	EndReasonShutdown       = "shutdown"        // 服务器停机时判和（不计等级分）
+	EndReasonStepLimit      = "step_limit"      // 残局挑战走满限定步数
+)
+
+// 对局类型（GameRecord.GameType 取值）
+const (
+	GameTypeMatch      = 0 // 随机匹配
+	GameTypeAI         = 1 // 人机对战
+	GameTypeFriend     = 2 // 好友对战
+	GameTypeImported   = 3 // 从 PGN/XQF 导入的外部棋谱
+	GameTypeEndgame    = 4 // 残局挑战（仅用于房间，结果记入残局进度而不保存对局记录）
+	GameTypeTournament = 5 // 比赛对局（循环赛或瑞士制的一台对阵）
+)
+
 // GameRecord 表示一局对局的持久化记录
 type GameRecord struct {
 	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -14,10 +41,29 @@ type GameRecord struct {
 	History   string `gorm:"type:longtext;column:history" json:"history"`
 	RedFlag   bool   `gorm:"column:red_flag" json:"red_flag"`
 	BlackFlag bool   `gorm:"column:black_flag" json:"black_flag"`
-	// 对局类型: 0=随机匹配,1=人机,2=好友
+	// 对局类型: 0=随机匹配,1=人机,2=好友,3=导入,5=比赛
 	GameType int `gorm:"column:game_type" json:"game_type"`
 	// AI难度: 1-6，仅在 game_type=1 时有效
-	AILevel   int `gorm:"column:ai_level;default:3" json:"ai_level"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	AILevel int `gorm:"column:ai_level;default:3" json:"ai_level"`
+	// 外部引擎对手的名称（如 Pikafish），内置 AI 为空
+	AIEngine string `gorm:"column:ai_engine;type:varchar(64);default:''" json:"ai_engine"`
+	// 结束原因，取值见 EndReason* 常量；旧数据为空
+	EndReason string `gorm:"column:end_reason;type:varchar(32);default:''" json:"end_reason"`
+	// 开局分类（ECCO 编码，如 C00），没有着法的对局为空
+	Ecco string `gorm:"column:ecco;type:varchar(8);default:'';index" json:"ecco"`
+	// 是否计入等级分：随机匹配取决于所选队列（rated/casual），好友对战需双方同意
+	Rated bool `gorm:"column:rated;default:false" json:"rated"`
+	// 时间控制（秒）：基础用时、每步加秒、读秒，均为 0 表示不计时
+	TimeBase      int `gorm:"column:time_base;default:0" json:"time_base"`
+	TimeIncrement int `gorm:"column:time_increment;default:0" json:"time_increment"`
+	TimeByoyomi   int `gorm:"column:time_byoyomi;default:0" json:"time_byoyomi"`
+	// 导入棋谱的上传者及棋谱中的双方名称，仅 game_type=3 时有效（此时 RedID、BlackID 为 0）
+	UploaderID uint   `gorm:"column:uploader_id;index" json:"uploader_id"`
+	RedName    string `gorm:"column:red_name;type:varchar(64);default:''" json:"red_name"`
+	BlackName  string `gorm:"column:black_name;type:varchar(64);default:''" json:"black_name"`
+	// 比赛对局所属的比赛与对阵，仅 game_type=5 时有效
+	TournamentID uint `gorm:"column:tournament_id;default:0;index" json:"tournament_id"`
+	PairingID    uint `gorm:"column:pairing_id;default:0" json:"pairing_id"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
@@ -0,0 +1,91 @@
+package tournament
+
+import "time"
+
+// 赛制
+const (
+	FormatRoundRobin = "round_robin" // 单循环
+	FormatSwiss      = "swiss"       // 瑞士制
+)
+
+// 比赛状态
+const (
+	StatusOpen      = "open"      // 报名中
+	StatusRunning   = "running"   // 进行中
+	StatusFinished  = "finished"  // 已结束
+	StatusCancelled = "cancelled" // 已取消（开赛时人数不足或创建者取消）
+)
+
+// 对阵状态
+const (
+	PairingPending  = "pending"  // 已编排，等待创建对局房间
+	PairingReady    = "ready"    // 房间已创建，等待双方入场
+	PairingPlaying  = "playing"  // 对局进行中
+	PairingFinished = "finished" // 已结束（含轮空与弃权）
+)
+
+// Tournament 一场比赛
+type Tournament struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string `gorm:"column:name;type:varchar(64);not null" json:"name"`
+	CreatorID uint   `gorm:"column:creator_id;index" json:"creator_id"`
+	Format    string `gorm:"column:format;type:varchar(16);not null" json:"format"`
+	Status    string `gorm:"column:status;type:varchar(16);index" json:"status"`
+	// MaxPlayers 人数上限
+	MaxPlayers int `gorm:"column:max_players" json:"max_players"`
+	// Rounds 总轮数：瑞士制由创建者指定，单循环在开赛时按人数确定
+	Rounds       int `gorm:"column:rounds;default:0" json:"rounds"`
+	CurrentRound int `gorm:"column:current_round;default:0" json:"current_round"`
+	// Rated 对局是否计入等级分
+	Rated bool `gorm:"column:rated;default:false" json:"rated"`
+	// 时间控制（秒），含义同 GameRecord
+	TimeBase      int `gorm:"column:time_base;default:0" json:"time_base"`
+	TimeIncrement int `gorm:"column:time_increment;default:0" json:"time_increment"`
+	TimeByoyomi   int `gorm:"column:time_byoyomi;default:0" json:"time_byoyomi"`
+	// StartAt 预定的开赛时间，到时自动开赛；为空时由创建者手动开赛
+	StartAt    *time.Time `gorm:"column:start_at;index" json:"start_at"`
+	StartedAt  *time.Time `gorm:"column:started_at" json:"started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Player 参赛棋手
+type Player struct {
+	ID           uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	TournamentID uint `gorm:"column:tournament_id;index:idx_tournament_player,unique" json:"tournament_id"`
+	UserID       uint `gorm:"column:user_id;index:idx_tournament_player,unique;index" json:"user_id"`
+	// Rating 报名时的等级分，开赛时据此排定种子
+	Rating int `gorm:"column:rating" json:"rating"`
+	// Seed 种子号，从 1 开始，开赛前为 0
+	Seed      int       `gorm:"column:seed;default:0" json:"seed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Player) TableName() string {
+	return "tournament_players"
+}
+
+// Pairing 一轮中的一台对阵，BlackID 为 0 表示红方轮空
+// 积分按 RedScore、BlackScore 记录：胜 1、和 0.5、负 0；弃权的一方记 0，双方均未到场时都记 0
+type Pairing struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	TournamentID uint       `gorm:"column:tournament_id;index:idx_tournament_round" json:"tournament_id"`
+	Round        int        `gorm:"column:round;index:idx_tournament_round" json:"round"`
+	Board        int        `gorm:"column:board" json:"board"`
+	RedID        uint       `gorm:"column:red_id" json:"red_id"`
+	BlackID      uint       `gorm:"column:black_id" json:"black_id"`
+	Status       string     `gorm:"column:status;type:varchar(16);index" json:"status"`
+	RoomID       int        `gorm:"column:room_id;default:0" json:"room_id"`
+	Deadline     *time.Time `gorm:"column:deadline" json:"deadline"` // 入场截止时间，过时未到场判负
+	RedScore     float64    `gorm:"column:red_score;default:0" json:"red_score"`
+	BlackScore   float64    `gorm:"column:black_score;default:0" json:"black_score"`
+	Forfeit      bool       `gorm:"column:forfeit;default:false" json:"forfeit"`
+	GameRecordID *uint      `gorm:"column:game_record_id" json:"game_record_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (Pairing) TableName() string {
+	return "tournament_pairings"
+}
@@ -0,0 +1,264 @@
+// Package notation 将棋步渲染为中文纵线记谱（炮二平五）、WXF（C2=5）与 ICCS（h2e2）格式
+package notation
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chinese-chess-backend/xiangqi"
+)
+
+// Format 记谱格式
+type Format string
+
+const (
+	Chinese Format = "chinese" // 中文纵线记谱：炮二平五 / 马8进7
+	WXF     Format = "wxf"     // 世界象棋联合会记谱：C2=5 / H8+7
+	ICCS    Format = "iccs"    // 坐标记谱：h2e2
+)
+
+// ParseFormat 解析记谱格式名称（大小写不敏感）
+func ParseFormat(s string) (Format, bool) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case Chinese, WXF, ICCS:
+		return f, true
+	}
+	return "", false
+}
+
+var (
+	redNames   = map[xiangqi.Kind]string{xiangqi.General: "帅", xiangqi.Advisor: "仕", xiangqi.Elephant: "相", xiangqi.Horse: "马", xiangqi.Chariot: "车", xiangqi.Cannon: "炮", xiangqi.Soldier: "兵"}
+	blackNames = map[xiangqi.Kind]string{xiangqi.General: "将", xiangqi.Advisor: "士", xiangqi.Elephant: "象", xiangqi.Horse: "马", xiangqi.Chariot: "车", xiangqi.Cannon: "炮", xiangqi.Soldier: "卒"}
+	wxfLetters = map[xiangqi.Kind]string{xiangqi.General: "K", xiangqi.Advisor: "A", xiangqi.Elephant: "E", xiangqi.Horse: "H", xiangqi.Chariot: "R", xiangqi.Cannon: "C", xiangqi.Soldier: "P"}
+	cnDigits   = []string{"", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+)
+
+// action 走法方向
+type action int
+
+const (
+	advance  action = iota // 进
+	retreat                // 退
+	traverse               // 平
+)
+
+// description 一步棋在纵线记谱中的各组成部分（与具体文字无关）
+type description struct {
+	piece     xiangqi.Piece
+	file      int  // 起点纵线号（走子方视角，1-9，从右往左数）
+	rank      int  // 同一纵线上有多个同类棋子时，从前往后的序号（0 开始）；否则为 -1
+	tandem    int  // 同一纵线上同类棋子的数量
+	multiFile bool // 不止一条纵线有多个同类棋子（只可能是兵卒），前/中/后之后还要写明纵线号
+	act       action
+	target    int // 直行棋子进退时为步数，其余为落点纵线号
+}
+
+// fileOf 返回走子方视角下的纵线号：红方从右往左 1-9，黑方从其右侧（x=0）开始 1-9
+func fileOf(x int, c xiangqi.Color) int {
+	if c == xiangqi.Red {
+		return 9 - x
+	}
+	return x + 1
+}
+
+// describe 根据走子前的局面拆解一步棋
+func describe(b *xiangqi.Board, m xiangqi.Move) (description, error) {
+	piece := b.At(m.From)
+	if piece.IsEmpty() {
+		return description{}, fmt.Errorf("起点 (%d,%d) 没有棋子", m.From.X, m.From.Y)
+	}
+	d := description{piece: piece, file: fileOf(m.From.X, piece.Color), rank: -1}
+
+	// 前进方向：红方 y 变小为进，黑方 y 变大为进
+	dy := m.From.Y - m.To.Y
+	if piece.Color == xiangqi.Black {
+		dy = -dy
+	}
+	switch {
+	case dy > 0:
+		d.act = advance
+	case dy < 0:
+		d.act = retreat
+	default:
+		d.act = traverse
+	}
+
+	switch piece.Kind {
+	case xiangqi.Horse, xiangqi.Elephant, xiangqi.Advisor:
+		// 斜行棋子总是记落点纵线
+		d.target = fileOf(m.To.X, piece.Color)
+	default:
+		if d.act == traverse {
+			d.target = fileOf(m.To.X, piece.Color)
+		} else {
+			d.target = max(dy, -dy)
+		}
+	}
+
+	// 车马炮兵在同一纵线上有多个时用前/中/后区分（仕相可由进退区分，不需要）
+	switch piece.Kind {
+	case xiangqi.Chariot, xiangqi.Horse, xiangqi.Cannon, xiangqi.Soldier:
+		var ys []int
+		for y := 0; y <= 9; y++ {
+			if b.At(xiangqi.Pos{X: m.From.X, Y: y}) == piece {
+				ys = append(ys, y)
+			}
+		}
+		if len(ys) >= 2 {
+			// 按离对方底线由近到远排序：红方 y 小者在前，黑方 y 大者在前
+			sort.Slice(ys, func(i, j int) bool {
+				if piece.Color == xiangqi.Red {
+					return ys[i] < ys[j]
+				}
+				return ys[i] > ys[j]
+			})
+			d.tandem = len(ys)
+			for i, y := range ys {
+				if y == m.From.Y {
+					d.rank = i
+				}
+			}
+			d.multiFile = tandemFiles(b, piece) > 1
+		}
+	}
+	return d, nil
+}
+
+// tandemFiles 有多个 piece 的纵线数
+func tandemFiles(b *xiangqi.Board, piece xiangqi.Piece) int {
+	files := 0
+	for x := 0; x <= 8; x++ {
+		n := 0
+		for y := 0; y <= 9; y++ {
+			if b.At(xiangqi.Pos{X: x, Y: y}) == piece {
+				n++
+			}
+		}
+		if n >= 2 {
+			files++
+		}
+	}
+	return files
+}
+
+// numberText 红方使用中文数字，黑方使用阿拉伯数字
+func numberText(n int, c xiangqi.Color) string {
+	if c == xiangqi.Red && n >= 1 && n <= 9 {
+		return cnDigits[n]
+	}
+	return strconv.Itoa(n)
+}
+
+// tandemText 同一纵线多子时的中文前缀
+func tandemText(rank, count int) string {
+	switch {
+	case rank == 0:
+		return "前"
+	case rank == count-1:
+		return "后"
+	case count == 3:
+		return "中"
+	default:
+		return cnDigits[rank+1]
+	}
+}
+
+// tandemWXF 同一纵线多子时的 WXF 标记：前为 +，后为 -，中间按序号
+func tandemWXF(rank, count int) string {
+	switch {
+	case rank == 0:
+		return "+"
+	case rank == count-1:
+		return "-"
+	case count == 3:
+		return "."
+	default:
+		return strconv.Itoa(rank + 1)
+	}
+}
+
+// ToChinese 渲染中文纵线记谱，b 为走子前的局面
+func ToChinese(b *xiangqi.Board, m xiangqi.Move) (string, error) {
+	d, err := describe(b, m)
+	if err != nil {
+		return "", err
+	}
+	names := redNames
+	if d.piece.Color == xiangqi.Black {
+		names = blackNames
+	}
+	var sb strings.Builder
+	if d.rank >= 0 {
+		sb.WriteString(tandemText(d.rank, d.tandem))
+		sb.WriteString(names[d.piece.Kind])
+		if d.multiFile {
+			// 如“前兵三进一”
+			sb.WriteString(numberText(d.file, d.piece.Color))
+		}
+	} else {
+		sb.WriteString(names[d.piece.Kind])
+		sb.WriteString(numberText(d.file, d.piece.Color))
+	}
+	sb.WriteString([]string{"进", "退", "平"}[d.act])
+	sb.WriteString(numberText(d.target, d.piece.Color))
+	return sb.String(), nil
+}
+
+// ToWXF 渲染 WXF 记谱，b 为走子前的局面
+func ToWXF(b *xiangqi.Board, m xiangqi.Move) (string, error) {
+	d, err := describe(b, m)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(wxfLetters[d.piece.Kind])
+	if d.rank >= 0 {
+		sb.WriteString(tandemWXF(d.rank, d.tandem))
+		if d.multiFile {
+			// 如 "P+3+1"
+			sb.WriteString(strconv.Itoa(d.file))
+		}
+	} else {
+		sb.WriteString(strconv.Itoa(d.file))
+	}
+	sb.WriteString([]string{"+", "-", "="}[d.act])
+	sb.WriteString(strconv.Itoa(d.target))
+	return sb.String(), nil
+}
+
+// ToICCS 渲染 ICCS 坐标记谱：纵线 a-i 从红方左侧起，横线 0-9 从红方底线起
+func ToICCS(m xiangqi.Move) string {
+	return fmt.Sprintf("%c%d%c%d", 'a'+m.From.X, 9-m.From.Y, 'a'+m.To.X, 9-m.To.Y)
+}
+
+// Render 按指定格式渲染一步棋，b 为走子前的局面
+func Render(f Format, b *xiangqi.Board, m xiangqi.Move) (string, error) {
+	switch f {
+	case Chinese:
+		return ToChinese(b, m)
+	case WXF:
+		return ToWXF(b, m)
+	case ICCS:
+		return ToICCS(m), nil
+	}
+	return "", fmt.Errorf("不支持的记谱格式 %q", f)
+}
+
+// RenderGame 从 start 局面开始依次渲染整盘棋，遇到非法走法时返回错误
+func RenderGame(f Format, start *xiangqi.Board, moves []xiangqi.Move) ([]string, error) {
+	b := start.Clone()
+	out := make([]string, 0, len(moves))
+	for i, m := range moves {
+		s, err := Render(f, b, m)
+		if err != nil {
+			return out, err
+		}
+		if _, err := b.MakeMove(m); err != nil {
+			return out, fmt.Errorf("第 %d 步不合法：%w", i+1, err)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
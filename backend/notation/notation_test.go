@@ -0,0 +1,142 @@
+package notation
+
+import (
+	"math/rand"
+	"testing"
+
+	"chinese-chess-backend/xiangqi"
+)
+
+func mustFEN(t *testing.T, fen string) *xiangqi.Board {
+	t.Helper()
+	b, err := xiangqi.ParseFEN(fen)
+	if err != nil {
+		t.Fatalf("ParseFEN(%q): %v", fen, err)
+	}
+	return b
+}
+
+func mustICCS(t *testing.T, s string) xiangqi.Move {
+	t.Helper()
+	m, err := ParseICCS(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+const (
+	// 红方三兵同在五路
+	threeSoldiersFEN = "4k4/9/4P4/4P4/4P4/9/9/9/9/3K5 w"
+	// 红方三路、七路各有两兵
+	twoFileSoldiersFEN = "4k4/9/9/2P3P2/2P3P2/9/9/9/9/3K5 w"
+	// 黑方双车同在 1 路
+	blackChariotsFEN = "r3k4/9/9/9/r8/9/9/9/9/3K5 b"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		fen          string
+		iccs         string
+		chinese, wxf string
+	}{
+		{xiangqi.InitialFEN, "h2e2", "炮二平五", "C2=5"},
+		{xiangqi.InitialFEN, "b0c2", "马八进七", "H8+7"},
+		{xiangqi.InitialFEN, "e3e4", "兵五进一", "P5+1"},
+		{xiangqi.InitialFEN, "f0e1", "仕四进五", "A4+5"},
+		{xiangqi.InitialFEN, "a0a1", "车九进一", "R9+1"},
+		{"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C2C4/9/RNBAKABNR b", "h9g7", "马8进7", "H8+7"},
+		{"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C2C4/9/RNBAKABNR b", "b7b0", "炮2进7", "C2+7"},
+		// 同一纵线上的前/中/后
+		{threeSoldiersFEN, "e7e8", "前兵进一", "P++1"},
+		{threeSoldiersFEN, "e6d6", "中兵平六", "P.=6"},
+		{threeSoldiersFEN, "e5f5", "后兵平四", "P-=4"},
+		{blackChariotsFEN, "a5a1", "前车进4", "R++4"},
+		{blackChariotsFEN, "a9b9", "后车平2", "R-=2"},
+		// 两条纵线都有多个兵时写明纵线号
+		{twoFileSoldiersFEN, "g6g7", "前兵三进一", "P+3+1"},
+		{twoFileSoldiersFEN, "c6c7", "前兵七进一", "P+7+1"},
+		{twoFileSoldiersFEN, "g5f5", "后兵三平四", "P-3=4"},
+		{twoFileSoldiersFEN, "c5b5", "后兵七平八", "P-7=8"},
+	}
+	for _, tt := range tests {
+		b := mustFEN(t, tt.fen)
+		m := mustICCS(t, tt.iccs)
+		if got, err := ToChinese(b, m); err != nil || got != tt.chinese {
+			t.Errorf("%s %s: ToChinese = %q, %v; want %q", tt.fen, tt.iccs, got, err, tt.chinese)
+		}
+		if got, err := ToWXF(b, m); err != nil || got != tt.wxf {
+			t.Errorf("%s %s: ToWXF = %q, %v; want %q", tt.fen, tt.iccs, got, err, tt.wxf)
+		}
+		if got := ToICCS(m); got != tt.iccs {
+			t.Errorf("ToICCS = %q, want %q", got, tt.iccs)
+		}
+		for _, text := range []string{tt.chinese, tt.wxf, tt.iccs} {
+			if got, err := ParseMove(b, text); err != nil || got != m {
+				t.Errorf("%s: ParseMove(%q) = %+v, %v; want %+v", tt.fen, text, got, err, m)
+			}
+		}
+	}
+}
+
+func TestParseMoveVariants(t *testing.T) {
+	b := xiangqi.NewBoard()
+	want := mustICCS(t, "h2e2")
+	// 繁体、全角数字、其他软件的写法都能识别
+	for _, text := range []string{"炮二平五", "砲二平五", "炮2平5", "C2=5", "c2.5", "H2-E2", " h2e2 "} {
+		if got, err := ParseMove(b, text); err != nil || got != want {
+			t.Errorf("ParseMove(%q) = %+v, %v; want %+v", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"炮二进五", "h2h8", "车一平二", "Z2=5", ""} {
+		if _, err := ParseMove(b, text); err == nil {
+			t.Errorf("ParseMove(%q) succeeded, want error", text)
+		}
+	}
+}
+
+// TestRoundTrip 任意局面下每个合法着法的中文与 WXF 记谱互不相同，并能解析回原着法
+func TestRoundTrip(t *testing.T) {
+	starts := []string{xiangqi.InitialFEN, threeSoldiersFEN, twoFileSoldiersFEN, blackChariotsFEN,
+		// 五兵过河
+		"4k4/9/9/P1P1P1P1P/9/9/9/9/9/3K5 w"}
+	for i, fen := range starts {
+		r := rand.New(rand.NewSource(int64(i)))
+		b := mustFEN(t, fen)
+		for ply := 0; ply < 80; ply++ {
+			legal := b.LegalMoves()
+			if len(legal) == 0 {
+				break
+			}
+			checkPosition(t, b, legal)
+			if _, err := b.MakeMove(legal[r.Intn(len(legal))]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func checkPosition(t *testing.T, b *xiangqi.Board, legal []xiangqi.Move) {
+	t.Helper()
+	seen := make(map[string]xiangqi.Move)
+	for _, m := range legal {
+		for _, f := range []Format{Chinese, WXF, ICCS} {
+			text, err := Render(f, b, m)
+			if err != nil {
+				t.Fatalf("%s: Render(%s, %+v): %v", b.FEN(), f, m, err)
+			}
+			// ParseMove 按统一写法比较中文与 WXF 记谱
+			key := string(f) + ":" + text
+			if f != ICCS {
+				key = string(f) + ":" + canonical(text)
+			}
+			if other, dup := seen[key]; dup {
+				t.Fatalf("%s: %+v and %+v both render as %q", b.FEN(), other, m, text)
+			}
+			seen[key] = m
+			if got, err := ParseMove(b, text); err != nil || got != m {
+				t.Fatalf("%s: ParseMove(%q) = %+v, %v; want %+v", b.FEN(), text, got, err, m)
+			}
+		}
+	}
+}
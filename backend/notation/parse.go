@@ -0,0 +1,73 @@
+package notation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"chinese-chess-backend/xiangqi"
+)
+
+// ParseICCS 解析 ICCS 坐标记谱，兼容 "h2e2" 与 "H2-E2" 两种写法
+func ParseICCS(s string) (xiangqi.Move, error) {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "-", ""))
+	if len(s) != 4 || s[0] < 'a' || s[0] > 'i' || s[2] < 'a' || s[2] > 'i' ||
+		s[1] < '0' || s[1] > '9' || s[3] < '0' || s[3] > '9' {
+		return xiangqi.Move{}, fmt.Errorf("无效的 ICCS 走法 %q", s)
+	}
+	return xiangqi.Move{
+		From: xiangqi.Pos{X: int(s[0] - 'a'), Y: 9 - int(s[1]-'0')},
+		To:   xiangqi.Pos{X: int(s[2] - 'a'), Y: 9 - int(s[3]-'0')},
+	}, nil
+}
+
+// canonicalRunes 统一繁简体、红黑用字、全角数字与中文数字，方便比较不同软件导出的棋谱
+var canonicalRunes = map[rune]rune{
+	'帥': '将', '帅': '将', '將': '将',
+	'仕': '士',
+	'相': '象',
+	'傌': '马', '馬': '马',
+	'俥': '车', '車': '车',
+	'砲': '炮', '包': '炮',
+	'兵': '卒',
+	'進': '进', '後': '后',
+	'一': '1', '二': '2', '三': '3', '四': '4', '五': '5', '六': '6', '七': '7', '八': '8', '九': '9',
+	'１': '1', '２': '2', '３': '3', '４': '4', '５': '5', '６': '6', '７': '7', '８': '8', '９': '9',
+	'＋': '+', '－': '-', '．': '=', '.': '=',
+	// WXF 中部分软件用 B/N 表示相、马
+	'B': 'E', 'N': 'H',
+}
+
+func canonical(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if c, ok := canonicalRunes[r]; ok {
+			r = c
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// ParseMove 在局面 b 下解析一步棋，自动识别 ICCS、WXF 与中文纵线记谱，并校验走法合法
+func ParseMove(b *xiangqi.Board, s string) (xiangqi.Move, error) {
+	if m, err := ParseICCS(s); err == nil {
+		if err := b.Validate(m); err != nil {
+			return xiangqi.Move{}, fmt.Errorf("%s：%w", s, err)
+		}
+		return m, nil
+	}
+	want := canonical(s)
+	for _, m := range b.LegalMoves() {
+		if cn, err := ToChinese(b, m); err == nil && canonical(cn) == want {
+			return m, nil
+		}
+		if wxf, err := ToWXF(b, m); err == nil && canonical(wxf) == want {
+			return m, nil
+		}
+	}
+	return xiangqi.Move{}, fmt.Errorf("无法识别或不合法的走法 %q", s)
+}
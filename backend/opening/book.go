@@ -0,0 +1,216 @@
+// Package opening 开局库：按 ECCO 编码（A00–E99）对局面与对局进行开局分类
+// 内置开局库只区分到开局体系（见 ecco.txt），完整的细分编码需通过 OPENING_BOOK_FILE 加载外部开局库
+// 开局库中每条变着从标准开局起逐步执行，途经的每个局面按 Zobrist 哈希登记，因此不同着法顺序形成的同一局面（移动换序）也能识别
+package opening
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+// Unclassified 开局库中没有的走法归入“非常规开局”
+const Unclassified = "A00"
+
+//go:embed ecco.txt
+var defaultBook string
+
+// Opening 一个开局分类
+type Opening struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// BookMove 开局库中某个局面之后的一步棋及走后形成的开局
+type BookMove struct {
+	Move    xiangqi.Move
+	Opening Opening
+}
+
+// Book 开局库
+type Book struct {
+	positions map[uint64]Opening
+	moves     map[uint64][]xiangqi.Move // 局面之后开局库中的着法
+	names     map[string]string
+	maxPly    int // 最长变着的步数，对局分类时只需执行到这一步
+}
+
+// Load 读取开局库，格式见 ecco.txt
+func Load(r io.Reader) (*Book, error) {
+	bk := &Book{
+		positions: make(map[uint64]Opening),
+		moves:     make(map[uint64][]xiangqi.Move),
+		names:     make(map[string]string),
+	}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("第 %d 行缺少开局名称", lineNo)
+		}
+		code := fields[0]
+		if _, ok := bk.names[code]; !ok {
+			bk.names[code] = fields[1]
+		}
+		// 同一编号出现在多行时统一使用先出现的名称
+		op := Opening{Code: code, Name: bk.names[code]}
+		if len(fields) == 2 {
+			continue
+		}
+		moves, err := parseLine(fields[2:])
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行（%s）：%w", lineNo, op.Code, err)
+		}
+		bk.add(op, moves)
+		bk.add(op, mirror(moves))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return bk, nil
+}
+
+// LoadFile 从文件读取开局库
+func LoadFile(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+var (
+	defaultBk   *Book
+	defaultOnce sync.Once
+)
+
+// Default 返回 OPENING_BOOK_FILE 指定的开局库，未配置或读取失败时使用内置的开局库
+func Default() *Book {
+	defaultOnce.Do(func() {
+		if path := os.Getenv("OPENING_BOOK_FILE"); path != "" {
+			bk, err := LoadFile(path)
+			if err == nil {
+				defaultBk = bk
+				return
+			}
+			log.Printf("load opening book from %s failed, using built-in book: %v", path, err)
+		}
+		bk, err := Load(strings.NewReader(defaultBook))
+		if err != nil {
+			// 内置开局库随代码发布，解析失败属于编码错误
+			panic(fmt.Sprintf("built-in opening book: %v", err))
+		}
+		defaultBk = bk
+	})
+	return defaultBk
+}
+
+// parseLine 从标准开局起逐步解析并校验变着
+func parseLine(tokens []string) ([]xiangqi.Move, error) {
+	b := xiangqi.NewBoard()
+	moves := make([]xiangqi.Move, 0, len(tokens))
+	for i, s := range tokens {
+		m, err := notation.ParseMove(b, s)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 步：%w", i+1, err)
+		}
+		b.Play(m)
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// mirror 左右翻转变着，标准开局左右对称，翻转后仍是合法的变着
+func mirror(moves []xiangqi.Move) []xiangqi.Move {
+	out := make([]xiangqi.Move, len(moves))
+	for i, m := range moves {
+		out[i] = xiangqi.Move{
+			From: xiangqi.Pos{X: 8 - m.From.X, Y: m.From.Y},
+			To:   xiangqi.Pos{X: 8 - m.To.X, Y: m.To.Y},
+		}
+	}
+	return out
+}
+
+// add 登记变着途经的局面：每步之后的局面归入该开局（已登记的局面保持不变），并记录每个局面之后的着法
+func (bk *Book) add(op Opening, moves []xiangqi.Move) {
+	b := xiangqi.NewBoard()
+	for _, m := range moves {
+		h := b.Hash()
+		if !containsMove(bk.moves[h], m) {
+			bk.moves[h] = append(bk.moves[h], m)
+		}
+		b.Play(m)
+		if _, ok := bk.positions[b.Hash()]; !ok {
+			bk.positions[b.Hash()] = op
+		}
+	}
+	bk.maxPly = max(bk.maxPly, len(moves))
+}
+
+func containsMove(moves []xiangqi.Move, m xiangqi.Move) bool {
+	for _, x := range moves {
+		if x == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Name 返回编号对应的开局名称，开局库中没有时返回空串
+func (bk *Book) Name(code string) string {
+	return bk.names[code]
+}
+
+// Lookup 查询局面所属的开局
+func (bk *Book) Lookup(b *xiangqi.Board) (Opening, bool) {
+	op, ok := bk.positions[b.Hash()]
+	return op, ok
+}
+
+// Moves 返回开局库中该局面之后的着法
+func (bk *Book) Moves(b *xiangqi.Board) []BookMove {
+	moves := bk.moves[b.Hash()]
+	out := make([]BookMove, 0, len(moves))
+	for _, m := range moves {
+		next := b.Clone()
+		next.Play(m)
+		op, _ := bk.Lookup(next)
+		out = append(out, BookMove{Move: m, Opening: op})
+	}
+	return out
+}
+
+// Classify 对一局棋进行开局分类：取对局中最后一个在开局库中的局面所属的开局
+// 没有着法时返回 false；第一步就不在开局库中时归入非常规开局
+func (bk *Book) Classify(moves []xiangqi.Move) (Opening, bool) {
+	if len(moves) == 0 {
+		return Opening{}, false
+	}
+	result := Opening{Code: Unclassified, Name: bk.names[Unclassified]}
+	b := xiangqi.NewBoard()
+	for i, m := range moves {
+		if i >= bk.maxPly {
+			break
+		}
+		if _, err := b.MakeMove(m); err != nil {
+			break
+		}
+		if op, ok := bk.Lookup(b); ok {
+			result = op
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,134 @@
+package opening
+
+import (
+	"strings"
+	"testing"
+
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+func mustLoad(t *testing.T, text string) *Book {
+	t.Helper()
+	bk, err := Load(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return bk
+}
+
+// parseGame 从标准开局起解析以空白分隔的着法
+func parseGame(t *testing.T, text string) []xiangqi.Move {
+	t.Helper()
+	b := xiangqi.NewBoard()
+	var moves []xiangqi.Move
+	for _, s := range strings.Fields(text) {
+		m, err := notation.ParseMove(b, s)
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		b.Play(m)
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+func TestClassify(t *testing.T) {
+	bk := mustLoad(t, defaultBook)
+	tests := []struct {
+		name, moves, code string
+	}{
+		{"book move", "炮二平五", "B00"},
+		// 开局库只写了炮二平五，炮八平五由对称生成
+		{"mirrored move", "炮八平五", "B00"},
+		{"deepest match", "炮二平五 马8进7 马二进三 马2进3", "C00"},
+		{"mirrored line", "炮八平五 马2进3 马八进七 马8进7", "C00"},
+		// 换序形成同一局面
+		{"transposition", "炮二平五 马2进3 马二进三 马8进7", "C00"},
+		{"mirrored reply", "炮八平五 炮8平5", "D50"},
+		// 出库之后保持最后一个库内局面的分类
+		{"past the book", "炮二平五 炮8平5 马二进三 马8进7 车一平二 车9平8", "D00"},
+		{"off-book reply", "兵七进一 卒3进1", "E00"},
+		{"off-book first move", "车九进一 车9进1", Unclassified},
+	}
+	for _, tt := range tests {
+		op, ok := bk.Classify(parseGame(t, tt.moves))
+		if !ok || op.Code != tt.code {
+			t.Errorf("%s: Classify(%s) = %+v, %v; want %s", tt.name, tt.moves, op, ok, tt.code)
+		}
+		if op.Name != bk.Name(tt.code) {
+			t.Errorf("%s: name %q, want %q", tt.name, op.Name, bk.Name(tt.code))
+		}
+	}
+	if op, ok := bk.Classify(nil); ok {
+		t.Errorf("Classify(nil) = %+v, want false", op)
+	}
+}
+
+func TestMoves(t *testing.T) {
+	bk := mustLoad(t, defaultBook)
+	got := make(map[string]string)
+	for _, bm := range bk.Moves(xiangqi.NewBoard()) {
+		got[notation.ToICCS(bm.Move)] = bm.Opening.Code
+	}
+	// 开局库中写出的着法及其对称着法
+	for iccs, code := range map[string]string{
+		"h2e2": "B00", "b2e2": "B00",
+		"g0e2": "A10", "c0e2": "A10",
+		"c3c4": "E00", "g3g4": "E00",
+	} {
+		if got[iccs] != code {
+			t.Errorf("Moves: %s -> %q, want %q", iccs, got[iccs], code)
+		}
+	}
+	b := xiangqi.NewBoard()
+	b.Play(parseGame(t, "车九进一")[0])
+	if moves := bk.Moves(b); len(moves) != 0 {
+		t.Errorf("Moves after an off-book move = %v, want none", moves)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	bk := mustLoad(t, `
+# 注释与空行忽略
+X01 甲 炮二平五
+X02 乙 炮二平五 炮8平5
+X02 丙 C2=5 C8=5 h0g2
+X03 仅名称
+`)
+	// 同一局面以先出现的行为准，同一编号的名称也以先出现的为准
+	for moves, code := range map[string]string{
+		"炮二平五":           "X01",
+		"炮二平五 炮8平5":      "X02",
+		"炮二平五 炮8平5 马二进三": "X02",
+	} {
+		op, _ := bk.Classify(parseGame(t, moves))
+		if op.Code != code || op.Name != bk.Name(code) {
+			t.Errorf("Classify(%s) = %+v, want %s", moves, op, code)
+		}
+	}
+	if bk.Name("X02") != "乙" || bk.Name("X03") != "仅名称" || bk.Name("X99") != "" {
+		t.Errorf("Name = %q %q %q", bk.Name("X02"), bk.Name("X03"), bk.Name("X99"))
+	}
+	if bk.maxPly != 3 {
+		t.Errorf("maxPly = %d, want 3", bk.maxPly)
+	}
+
+	for _, text := range []string{
+		"X01",
+		"X01 甲 车一平二",
+		"X01 甲 炮二平五 h0g2",
+	} {
+		if _, err := Load(strings.NewReader(text)); err == nil {
+			t.Errorf("Load(%q) succeeded, want error", text)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	t.Setenv("OPENING_BOOK_FILE", "")
+	bk := Default()
+	if bk == nil || bk.Name(Unclassified) != "非常规开局" {
+		t.Fatalf("Default() has no %s entry", Unclassified)
+	}
+}
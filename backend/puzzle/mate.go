@@ -0,0 +1,149 @@
+// Package puzzle 从局面中寻找可作为习题的连杀：攻方每一步都是唯一的取胜着法，守方按最顽强的应着
+package puzzle
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"chinese-chess-backend/ai"
+	"chinese-chess-backend/xiangqi"
+)
+
+const (
+	// MaxPlies 习题解法的最大步数（半回合），即最多五步杀
+	MaxPlies = 9
+	// searchTime 寻找连杀时每次搜索的时间上限
+	searchTime = 500 * time.Millisecond
+)
+
+// Builtin 内置习题，习题表为空时写入；解法均与 FindMate 的结果一致且可通过 Verify（见 mate_test.go）
+var Builtin = []struct {
+	FEN      string
+	Solution string // 空格分隔的 ICCS 着法
+}{
+	{FEN: "3k5/9/9/9/9/9/9/9/4R4/2R1K4 w - - 0 1", Solution: "e1d1"},
+	{FEN: "5k3/4P4/9/9/9/9/9/9/9/3RK4 w - - 0 1", Solution: "d0d9"},
+	{FEN: "3a1k3/4a4/4N4/9/9/1C7/9/9/4K4/9 w - - 0 1", Solution: "b4b8 f9e9 b8b9"},
+	{FEN: "3a5/3ka4/9/9/2R6/4N4/9/9/9/5K3 w - - 0 1", Solution: "e4f6 e8f7 c5c8"},
+	{FEN: "3k1a3/4a4/9/9/9/9/9/2N6/4C4/4K4 w - - 0 1", Solution: "e1d1 d9d8 c2d4 e8d7 d4c6"},
+	{FEN: "2ba1k3/4a4/4b4/6N2/9/9/9/9/4C4/4K4 w - - 0 1", Solution: "g6h8 f9f8 e1i1 c9a7 i1i8"},
+}
+
+// mateIn 行棋方在 plies 个半回合内能否将死对方
+func mateIn(b *xiangqi.Board, plies int) (ai.Result, bool) {
+	res, err := ai.Search(b, ai.Options{MaxDepth: plies, TimeLimit: searchTime})
+	if err != nil || !ai.IsMate(res.Score) || res.Score < ai.MateScore-plies {
+		return res, false
+	}
+	return res, true
+}
+
+// defended 行棋方（守方）能否在 plies 个半回合内不被将死
+func defended(b *xiangqi.Board, plies int) bool {
+	if b.Status() != xiangqi.Ongoing {
+		return false
+	}
+	res, err := ai.Search(b, ai.Options{MaxDepth: plies, TimeLimit: searchTime})
+	if err != nil {
+		return false
+	}
+	return !ai.IsMate(res.Score) || res.Score > -(ai.MateScore-plies)
+}
+
+// FindMate 寻找行棋方不超过 MaxPlies 个半回合的连杀
+// 返回的着法序列以攻方着法开始和结束，攻方除直接将死外的每一步都是唯一能在剩余步数内将死的着法；找不到时返回 false
+func FindMate(b *xiangqi.Board) ([]xiangqi.Move, bool) {
+	if b.Status() != xiangqi.Ongoing {
+		return nil, false
+	}
+	first, ok := mateIn(b, MaxPlies)
+	if !ok {
+		return nil, false
+	}
+	plies := ai.MateScore - first.Score
+
+	cur := b.Clone()
+	line := make([]xiangqi.Move, 0, plies)
+	for remaining := plies; remaining > 0; remaining-- {
+		attacker := (plies-remaining)%2 == 0
+		var m xiangqi.Move
+		if attacker {
+			res, ok := mateIn(cur, remaining)
+			if !ok {
+				return nil, false
+			}
+			m = res.Move
+			if remaining > 1 && !unique(cur, m, remaining) {
+				return nil, false
+			}
+		} else {
+			// 守方取拖延最久的应着
+			res, err := ai.Search(cur, ai.Options{MaxDepth: remaining, TimeLimit: searchTime})
+			if err != nil {
+				return nil, false
+			}
+			m = res.Move
+		}
+		cur.Play(m)
+		line = append(line, m)
+	}
+	if cur.Status() == xiangqi.Ongoing {
+		return nil, false
+	}
+	return line, true
+}
+
+// unique 除 m 与直接将死的着法外，攻方没有其它能在 remaining 个半回合内将死的着法
+// 直接将死总被视为正确解答，因此不影响唯一性
+func unique(b *xiangqi.Board, m xiangqi.Move, remaining int) bool {
+	for _, alt := range b.LegalMoves() {
+		if alt == m {
+			continue
+		}
+		next := b.Clone()
+		next.Play(alt)
+		if next.Status() != xiangqi.Ongoing {
+			continue
+		}
+		if !defended(next, remaining-1) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify 检查 line 是否为 b 局面下的连杀：攻方每一步之后守方都无法在剩余步数内解杀，
+// 攻方除最后一步外的每一步都是唯一的取胜着法，走完后对方被将死或困毙；守方着法只要求合法
+func Verify(b *xiangqi.Board, line []xiangqi.Move) error {
+	if len(line)%2 == 0 || len(line) > MaxPlies {
+		return fmt.Errorf("解法须为不超过 %d 步的奇数步，以攻方的着法开始和结束", MaxPlies)
+	}
+	cur := b.Clone()
+	for i, m := range line {
+		if err := cur.Validate(m); err != nil {
+			return fmt.Errorf("第 %d 步不合法：%w", i+1, err)
+		}
+		remaining := len(line) - i
+		if i%2 == 1 {
+			cur.Play(m)
+			continue
+		}
+		if remaining > 1 && !unique(cur, m, remaining) {
+			return fmt.Errorf("第 %d 步不是唯一的取胜着法", i+1)
+		}
+		cur.Play(m)
+		if remaining > 1 && defended(cur, remaining-1) {
+			return fmt.Errorf("第 %d 步之后对方可以解杀", i+1)
+		}
+	}
+	if cur.Status() == xiangqi.Ongoing {
+		return errors.New("解法走完后没有将死对方")
+	}
+	return nil
+}
+
+// InitialRating 新习题的初始等级分，按解法长度估计：一步杀 1000 分，每多一步加 300 分
+func InitialRating(plies int) float64 {
+	return 1000 + float64((plies-1)/2)*300
+}
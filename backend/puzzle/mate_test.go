@@ -0,0 +1,107 @@
+package puzzle
+
+import (
+	"strings"
+	"testing"
+
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+func parseLine(t *testing.T, b *xiangqi.Board, s string) []xiangqi.Move {
+	t.Helper()
+	var line []xiangqi.Move
+	for _, f := range strings.Fields(s) {
+		m, err := notation.ParseICCS(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = append(line, m)
+	}
+	return line
+}
+
+func formatLine(line []xiangqi.Move) string {
+	out := make([]string, len(line))
+	for i, m := range line {
+		out[i] = notation.ToICCS(m)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestBuiltinPuzzles(t *testing.T) {
+	for _, p := range Builtin {
+		b, err := xiangqi.ParseFEN(p.FEN)
+		if err != nil {
+			t.Fatalf("%s: %v", p.FEN, err)
+		}
+		line, ok := FindMate(b)
+		if !ok {
+			t.Errorf("%s: FindMate found no unique mate", p.FEN)
+			continue
+		}
+		if got := formatLine(line); got != p.Solution {
+			t.Errorf("%s: FindMate = %q, stored solution %q", p.FEN, got, p.Solution)
+		}
+		if err := Verify(b, parseLine(t, b, p.Solution)); err != nil {
+			t.Errorf("%s: Verify(%q): %v", p.FEN, p.Solution, err)
+		}
+	}
+}
+
+const nonUniqueFEN = "3a1k3/4a4/4N4/9/9/1C7/9/9/4K4/1C7 w"
+
+func TestFindMateRejects(t *testing.T) {
+	for _, tt := range []struct {
+		name, fen string
+	}{
+		// 内置的两步杀多一个炮，b4f4、b4b8、b0f0 都能两步杀：首着不唯一
+		{"non-unique", nonUniqueFEN},
+		// 单车无法将死
+		{"no mate", "4k4/9/9/9/9/9/9/9/9/R3K4 w"},
+		// 已经被将死
+		{"game over", "3k5/9/9/9/9/9/9/9/9/3RK4 b"},
+	} {
+		b, err := xiangqi.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if line, ok := FindMate(b); ok {
+			t.Errorf("%s: FindMate = %q, want none", tt.name, formatLine(line))
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name, fen, line string
+		ok              bool
+	}{
+		{"mate in 1", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 w", "e1d1", true},
+		{"mate in 2", "3a1k3/4a4/4N4/9/9/1C7/9/9/4K4/9 w", "b4b8 f9e9 b8b9", true},
+		{"non-unique first move", nonUniqueFEN, "b4b8 f9e9 b8b9", false},
+		{"not mate at the end", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 w", "e1e2", false},
+		// 将军之后守方可以解杀
+		{"defended", "3k5/9/9/9/9/9/9/9/9/R3K4 w", "a0a9 d9d8 a9a8", false},
+		{"even length", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 w", "e1d1 d9e9", false},
+		{"illegal move", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 w", "e1e9", false},
+	}
+	for _, tt := range tests {
+		b, err := xiangqi.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		err = Verify(b, parseLine(t, b, tt.line))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Verify(%q) = %v, want ok=%v", tt.name, tt.line, err, tt.ok)
+		}
+	}
+}
+
+func TestInitialRating(t *testing.T) {
+	for plies, want := range map[int]float64{1: 1000, 3: 1300, 5: 1600, 9: 2200} {
+		if got := InitialRating(plies); got != want {
+			t.Errorf("InitialRating(%d) = %v, want %v", plies, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package rating 实现 Glicko-2 等级分算法（Mark Glickman, "Example of the Glicko-2 system"）
+package rating
+
+import "math"
+
+const (
+	DefaultRating     = 1500.0
+	DefaultRD         = 350.0
+	DefaultVolatility = 0.06
+	// MinRD 评分偏差下限，避免长期活跃玩家的等级分完全固化
+	MinRD = 30.0
+	// Tau 约束波动率随时间的变化幅度，常用取值 0.3~1.2
+	Tau = 0.5
+
+	scale   = 173.7178
+	epsilon = 0.000001
+)
+
+// Rating 玩家在 Glicko 量纲下的等级分、评分偏差与波动率
+type Rating struct {
+	R     float64
+	RD    float64
+	Sigma float64
+}
+
+// Default 新玩家的初始等级分
+func Default() Rating {
+	return Rating{R: DefaultRating, RD: DefaultRD, Sigma: DefaultVolatility}
+}
+
+// Result 一局对局结果，Score 为 1 胜、0.5 和、0 负
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muj, phij float64) float64 {
+	return 1 / (1 + math.Exp(-g(phij)*(mu-muj)))
+}
+
+// Decay 玩家空闲 periods 个评分周期后评分偏差的增长
+func Decay(r Rating, periods int) Rating {
+	if periods <= 0 {
+		return r
+	}
+	phi := r.RD / scale
+	phi = math.Sqrt(phi*phi + float64(periods)*r.Sigma*r.Sigma)
+	r.RD = math.Min(phi*scale, DefaultRD)
+	return r
+}
+
+// Update 以 results 作为一个评分周期计算新的等级分
+func Update(p Rating, results []Result) Rating {
+	mu := (p.R - DefaultRating) / scale
+	phi := p.RD / scale
+	if len(results) == 0 {
+		return Decay(p, 1)
+	}
+
+	var vInv, deltaSum float64
+	for _, res := range results {
+		muj := (res.Opponent.R - DefaultRating) / scale
+		phij := res.Opponent.RD / scale
+		gj := g(phij)
+		e := expected(mu, muj, phij)
+		vInv += gj * gj * e * (1 - e)
+		deltaSum += gj * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * deltaSum
+
+	sigma := newVolatility(p.Sigma, phi, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*deltaSum
+
+	return Rating{
+		R:     newMu*scale + DefaultRating,
+		RD:    math.Max(math.Min(newPhi*scale, DefaultRD), MinRD),
+		Sigma: sigma,
+	}
+}
+
+// newVolatility 按 Illinois 算法迭代求解新的波动率
+func newVolatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(Tau*Tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*Tau) < 0 {
+			k++
+		}
+		B = a - k*Tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,61 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+// TestUpdateGlickmanExample Glickman 论文 "Example of the Glicko-2 system" 中的算例（τ=0.5）
+func TestUpdateGlickmanExample(t *testing.T) {
+	player := Rating{R: 1500, RD: 200, Sigma: 0.06}
+	results := []Result{
+		{Opponent: Rating{R: 1400, RD: 30, Sigma: 0.06}, Score: 1},
+		{Opponent: Rating{R: 1550, RD: 100, Sigma: 0.06}, Score: 0},
+		{Opponent: Rating{R: 1700, RD: 300, Sigma: 0.06}, Score: 0},
+	}
+	got := Update(player, results)
+	checks := []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"rating", got.R, 1464.06, 0.01},
+		{"RD", got.RD, 151.52, 0.01},
+		{"volatility", got.Sigma, 0.05999, 0.00001},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > c.tolerance {
+			t.Errorf("%s = %.5f, want %.5f", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestUpdateWithoutGames(t *testing.T) {
+	player := Rating{R: 1600, RD: 100, Sigma: 0.06}
+	got := Update(player, nil)
+	if got.R != player.R || got.Sigma != player.Sigma {
+		t.Errorf("Update without games changed rating to %+v", got)
+	}
+	// φ' = sqrt(φ² + σ²)
+	want := math.Sqrt(math.Pow(100/scale, 2)+0.06*0.06) * scale
+	if math.Abs(got.RD-want) > 1e-9 {
+		t.Errorf("RD = %.4f, want %.4f", got.RD, want)
+	}
+}
+
+func TestRDBounds(t *testing.T) {
+	if got := Decay(Rating{R: 1500, RD: 340, Sigma: 0.06}, 1000); got.RD != DefaultRD {
+		t.Errorf("Decay RD = %.2f, want capped at %.0f", got.RD, DefaultRD)
+	}
+	p := Rating{R: 1500, RD: MinRD, Sigma: 0.06}
+	opp := Rating{R: 1500, RD: MinRD, Sigma: 0.06}
+	for i := 0; i < 50; i++ {
+		p = Update(p, []Result{{Opponent: opp, Score: 0.5}})
+	}
+	if p.RD < MinRD {
+		t.Errorf("RD = %.2f, want at least %.0f", p.RD, MinRD)
+	}
+	if math.Abs(p.R-1500) > 1e-6 {
+		t.Errorf("drawing an equal opponent moved the rating to %.4f", p.R)
+	}
+}
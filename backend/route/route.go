@@ -50,6 +50,13 @@ func SetupRouter() *gin.Engine {
 	room := controller.NewRoomController(service.NewRoomService())
 	fc := controller.NewFriendChallengeController()
 	endgame := controller.NewEndgameController(service.NewEndgameService())
+	position := controller.NewPositionController(service.NewPositionService())
+	recordFile := controller.NewRecordFileController(service.NewRecordFileService())
+	rating := controller.NewRatingController(service.NewRatingService())
+	analysis := controller.NewAnalysisController(service.NewAnalysisService())
+	openings := controller.NewOpeningController(service.NewOpeningService())
+	puzzles := controller.NewPuzzleController(service.NewPuzzleService())
+	tournaments := controller.NewTournamentController(service.NewTournamentService())
 	// 设置路由组
 	api := r.Group("/api")
 	// 静态资源：通过 /api/uploads 访问后端本地的 ./uploads 目录
@@ -73,7 +80,7 @@ func SetupRouter() *gin.Engine {
 	userRoute.POST("/delete_account", user.DeleteAccount)
 	userRoute.POST("/logout", user.Logout)
 	userRoute.POST("/heartbeat", user.Heartbeat)
-	// 残局挑战结算（一次性经验奖励）
+	// 已废弃：残局通关奖励由服务端结算，旧版客户端提交时返回提示
 	userRoute.POST("/endgame/complete", user.EndgameComplete)
 	userRoute.PUT("/profile", user.UpdateUserProfile)
 	userRoute.GET("/friends", friend.GetFriends)
@@ -87,9 +94,20 @@ func SetupRouter() *gin.Engine {
 	// 好友挑战（用于初始化加载待处理挑战）
 	userRoute.GET("/friend-challenges", fc.ListIncoming)
 
-	// 残局挑战进度相关
+	// 残局挑战：关卡目录与进度（挑战通过 websocket 与服务端 AI 对弈，结果由服务端记录）
+	userRoute.GET("/endgame/scenarios", endgame.ListScenarios)
 	userRoute.GET("/endgame/progress", endgame.GetProgress)
+	// 已废弃：残局进度由服务端记录，旧版客户端提交时返回提示
 	userRoute.POST("/endgame/progress", endgame.SaveProgress)
+	// 残局关卡维护（仅 ADMIN_USER_IDS 中的用户）
+	adminRoute := api.Group("/admin", middleware.AdminMiddleware())
+	adminRoute.GET("/endgame/scenarios", endgame.AdminListScenarios)
+	adminRoute.POST("/endgame/scenarios", endgame.CreateScenario)
+	adminRoute.PUT("/endgame/scenarios/:id", endgame.UpdateScenario)
+	adminRoute.POST("/endgame/scenarios/reorder", endgame.ReorderScenarios)
+	adminRoute.POST("/endgame/scenarios/:id/retire", endgame.RetireScenario)
+	adminRoute.POST("/puzzles", puzzles.CreatePuzzle)
+	adminRoute.POST("/puzzles/mine", puzzles.MinePuzzles)
 
 	// 聊天相关路由
 	userRoute.GET("/friends/:relationId/messages", chat.GetMessages)
@@ -99,7 +117,38 @@ func SetupRouter() *gin.Engine {
 	hub := websocket.NewChessHub()
 	userRoute.POST("/rooms", hub.GetSpareRooms, room.GetSpareRooms)
 	userRoute.GET("/game-records", user.GetGameRecords)
+	// 已废弃：人机对局记录由服务端保存，旧版客户端提交时返回提示
 	userRoute.POST("/game-records", user.SaveGameRecord)
+	// 局面（FEN）相关
+	userRoute.GET("/game-records/:id/fen", position.GetRecordFEN)
+	userRoute.POST("/fen/validate", position.ValidateFEN)
+	// 棋谱文件导入导出（PGN / XQF）
+	userRoute.GET("/game-records/:id/pgn", recordFile.ExportPGN)
+	userRoute.GET("/game-records/:id/xqf", recordFile.ExportXQF)
+	userRoute.POST("/game-records/import/pgn", recordFile.ImportPGN)
+	userRoute.POST("/game-records/import/xqf", recordFile.ImportXQF)
+	// 等级分
+	userRoute.GET("/rating", rating.GetRating)
+	// 局面分析（外部引擎或内置 AI）
+	userRoute.POST("/analysis", analysis.Analyze)
+	userRoute.POST("/game-records/:id/analysis", analysis.RequestRecordAnalysis)
+	userRoute.GET("/game-records/:id/analysis", analysis.GetRecordAnalysis)
+	// 开局库与按开局的战绩统计
+	userRoute.POST("/openings/book", openings.BookMoves)
+	userRoute.GET("/openings/stats", openings.GetStats)
+	// 习题：每日一题与按习题等级分匹配的计分习题，着法由服务端按解法校验
+	userRoute.GET("/puzzles/daily", puzzles.Daily)
+	userRoute.GET("/puzzles/next", puzzles.Next)
+	userRoute.GET("/puzzles/rating", puzzles.GetRating)
+	userRoute.POST("/puzzles/:id/moves", puzzles.SubmitMove)
+	// 比赛：单循环与瑞士制，开赛后由对局服务自动编排各轮并为每台对阵创建房间
+	userRoute.GET("/tournaments", tournaments.List)
+	userRoute.POST("/tournaments", tournaments.Create)
+	userRoute.GET("/tournaments/:id", tournaments.Detail)
+	userRoute.POST("/tournaments/:id/join", tournaments.Join)
+	userRoute.POST("/tournaments/:id/leave", tournaments.Leave)
+	userRoute.POST("/tournaments/:id/start", tournaments.Start)
+	userRoute.POST("/tournaments/:id/cancel", tournaments.Cancel)
 	r.GET("/ws", hub.HandleConnection)
 	go hub.Run()
 
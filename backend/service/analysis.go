@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"chinese-chess-backend/ai"
+	analysisDto "chinese-chess-backend/dto/analysis"
+	"chinese-chess-backend/engine"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+const (
+	// defaultAnalysisTime 未指定深度与时间时的思考时间
+	defaultAnalysisTime = time.Second
+	// builtinAnalysisTime 只限定深度时的思考时间上限
+	builtinAnalysisTime = 10 * time.Second
+	// builtinEngineName 未配置外部引擎时分析结果中的引擎名称
+	builtinEngineName = "内置引擎"
+)
+
+type AnalysisService struct {
+}
+
+func NewAnalysisService() *AnalysisService {
+	return &AnalysisService{}
+}
+
+// Analyze 分析局面：配置了外部引擎时由引擎搜索，否则（或引擎出错时）使用内置 AI
+func (as *AnalysisService) Analyze(ctx context.Context, req *analysisDto.AnalyzeRequest) (*analysisDto.AnalyzeResponse, error) {
+	start := xiangqi.NewBoard()
+	if req.FEN != "" {
+		b, err := xiangqi.ParseFEN(req.FEN)
+		if err != nil {
+			return nil, err
+		}
+		if errs := b.CheckPosition(); len(errs) > 0 {
+			return nil, fmt.Errorf("局面不合法：%w", errs[0])
+		}
+		start = b
+	}
+	board := start.Clone()
+	for i, s := range req.Moves {
+		m, err := notation.ParseICCS(s)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 步：%w", i+1, err)
+		}
+		if _, err := board.MakeMove(m); err != nil {
+			return nil, fmt.Errorf("第 %d 步 %s 不合法：%w", i+1, s, err)
+		}
+	}
+	if board.Status() != xiangqi.Ongoing {
+		return nil, errors.New("对局已结束，没有可分析的着法")
+	}
+
+	limit := engine.Limit{Depth: req.Depth, MoveTime: time.Duration(req.MoveTime) * time.Millisecond}
+	if limit.Depth == 0 && limit.MoveTime == 0 {
+		limit.MoveTime = defaultAnalysisTime
+	}
+
+	pool := engine.Default()
+	// 只限定深度时由引擎自行结束，外层超时只作为兜底
+	timeout := builtinAnalysisTime
+	if limit.MoveTime > 0 {
+		timeout = limit.MoveTime + 5*time.Second
+	}
+	searchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res, fromEngine, err := engine.SearchOr(searchCtx, pool, start.FEN(), req.Moves, limit, func() (engine.Result, error) {
+		return builtinAnalyze(board, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	pv := res.Info.PV
+	if len(pv) == 0 || pv[0] != res.BestMove {
+		pv = []string{res.BestMove}
+	}
+	resp := &analysisDto.AnalyzeResponse{
+		Engine:   builtinEngineName,
+		BestMove: res.BestMove,
+		Ponder:   res.Ponder,
+		Score:    res.Info.Score,
+		Mate:     res.Info.Mate,
+		Depth:    res.Info.Depth,
+		Nodes:    res.Info.Nodes,
+		PV:       pv,
+	}
+	if fromEngine {
+		resp.Engine = pool.Name()
+	}
+	resp.FEN = board.FEN()
+	resp.PVText = renderPV(board, resp.PV)
+	if len(resp.PVText) > 0 {
+		resp.BestMoveText = resp.PVText[0]
+	} else if m, err := notation.ParseICCS(resp.BestMove); err == nil {
+		resp.BestMoveText, _ = notation.ToChinese(board, m)
+	}
+	return resp, nil
+}
+
+// builtinAnalyze 内置 AI 的分析结果，按外部引擎的输出格式返回
+func builtinAnalyze(board *xiangqi.Board, limit engine.Limit) (engine.Result, error) {
+	opts := ai.Options{MaxDepth: limit.Depth, TimeLimit: limit.MoveTime}
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = analysisDto.MaxDepth
+	}
+	if opts.TimeLimit == 0 {
+		opts.TimeLimit = builtinAnalysisTime
+	}
+	res, err := ai.Search(board, opts)
+	if err != nil {
+		return engine.Result{}, err
+	}
+	info := engine.Info{
+		Depth: res.Depth,
+		Score: res.Score,
+		Nodes: int64(res.Nodes),
+	}
+	if ai.IsMate(res.Score) {
+		// 距杀棋的半回合数换算为回合数
+		plies := ai.MateScore - max(res.Score, -res.Score)
+		info.Mate = (plies + 1) / 2
+		if res.Score < 0 {
+			info.Mate = -info.Mate
+		}
+	}
+	for _, m := range res.PV {
+		info.PV = append(info.PV, notation.ToICCS(m))
+	}
+	return engine.Result{BestMove: notation.ToICCS(res.Move), Info: info}, nil
+}
+
+// renderPV 将主要变化渲染为中文纵线记谱，遇到无法解析的着法时截断
+func renderPV(board *xiangqi.Board, pv []string) []string {
+	moves := make([]xiangqi.Move, 0, len(pv))
+	for _, s := range pv {
+		m, err := notation.ParseICCS(s)
+		if err != nil {
+			break
+		}
+		moves = append(moves, m)
+	}
+	out, _ := notation.RenderGame(notation.Chinese, board, moves)
+	return out
+}
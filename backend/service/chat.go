@@ -7,6 +7,7 @@ import (
 
 	"chinese-chess-backend/database"
 	chatModel "chinese-chess-backend/model/chat"
+	"chinese-chess-backend/utils"
 )
 
 type ChatService struct{}
@@ -22,7 +23,7 @@ func (cs *ChatService) SaveMessage(friendRelationID uint, senderID uint, receive
 		FriendID:   friendRelationID,
 		SenderID:   senderID,
 		ReceiverID: receiverID,
-		Content:    content,
+		Content:    utils.FilterBannedWords(content),
 		IsRead:     false,
 	}
 	if err := db.Create(msg).Error; err != nil {
@@ -6,6 +6,7 @@ import (
 	endgameModel "chinese-chess-backend/model/endgame"
 
 	"errors"
+	"time"
 )
 
 type EndgameService struct {
@@ -36,53 +37,62 @@ func (es *EndgameService) GetProgress(req *endgameDto.GetEndgameProgressRequest)
 			Attempts:   r.Attempts,
 			BestSteps:  r.BestSteps,
 			LastResult: r.LastResult,
+			Cleared:    r.ClearedAt != nil,
 		}
 		resp.Progress = append(resp.Progress, item)
 	}
 	return resp, nil
 }
 
-// SaveProgress 保存某个残局的本次挑战结果（会累加 attempts，并按需刷新 bestSteps）
-func (es *EndgameService) SaveProgress(userID int, req *endgameDto.SaveEndgameProgressRequest) error {
+// RecordResult 记录一局由服务端裁定的残局挑战：累加尝试次数，通关时刷新最少步数，首次通关按难度发放经验
+func (es *EndgameService) RecordResult(userID int, sc *endgameModel.Scenario, success bool, steps int) (*endgameDto.EndgameResult, error) {
 	if userID <= 0 {
-		return errors.New("未登录")
-	}
-	if req.ScenarioID == "" {
-		return errors.New("缺少残局ID")
+		return nil, errors.New("用户ID无效")
 	}
-	if req.Result != "win" && req.Result != "lose" {
-		return errors.New("结果必须为 win 或 lose")
+	result := "lose"
+	if success {
+		result = "win"
 	}
+	resp := &endgameDto.EndgameResult{ScenarioID: sc.ID, Success: success, Steps: steps}
 
 	db := database.GetMysqlDb()
-
 	var record endgameModel.EndgameProgress
-	err := db.Where("user_id = ? AND scenario_id = ?", userID, req.ScenarioID).First(&record).Error
+	err := db.Where("user_id = ? AND scenario_id = ?", userID, sc.ID).First(&record).Error
 	if err != nil {
 		// 若不存在则创建新纪录
 		record = endgameModel.EndgameProgress{
 			UserID:     uint(userID),
-			ScenarioID: req.ScenarioID,
-			Attempts:   1,
-			LastResult: req.Result,
-		}
-		if req.Result == "win" && req.Steps != nil {
-			val := *req.Steps
-			record.BestSteps = &val
+			ScenarioID: sc.ID,
 		}
-		return db.Create(&record).Error
 	}
-
-	// 已存在记录则更新
 	record.Attempts++
-	record.LastResult = req.Result
-	if req.Result == "win" && req.Steps != nil {
-		steps := *req.Steps
-		if record.BestSteps == nil || steps < *record.BestSteps {
-			record.BestSteps = &steps
+	record.LastResult = result
+	if success && (record.BestSteps == nil || steps < *record.BestSteps) {
+		record.BestSteps = &steps
+	}
+	if err := db.Save(&record).Error; err != nil {
+		return nil, err
+	}
+	resp.BestSteps = record.BestSteps
+
+	if !success {
+		return resp, nil
+	}
+	// 以条件更新标记首次通关，并发结算时只有一次能成功，经验不会重复发放
+	now := time.Now()
+	res := db.Model(&endgameModel.EndgameProgress{}).
+		Where("id = ? AND cleared_at IS NULL", record.ID).
+		Update("cleared_at", &now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 1 {
+		if award := EndgameExp(sc.Difficulty); award > 0 {
+			if err := NewUserService().AddUserExp(userID, award); err != nil {
+				return nil, err
+			}
+			resp.Awarded = award
 		}
 	}
-	return db.Save(&record).Error
+	return resp, nil
 }
-
-
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"chinese-chess-backend/database"
+	endgameDto "chinese-chess-backend/dto/endgame"
+	endgameModel "chinese-chess-backend/model/endgame"
+	"chinese-chess-backend/xiangqi"
+)
+
+// builtinScenarios 内置的残局关卡，关卡表为空时写入；胜局关卡均已用内置 AI 验证存在限定步数内的杀法
+var builtinScenarios = []endgameModel.Scenario{
+	{
+		ID:         "single-chariot",
+		Name:       "单车杀将",
+		Difficulty: "初级",
+		FEN:        "4k4/9/9/9/9/9/9/9/R8/5K3 w - - 0 1",
+		Goal:       endgameModel.GoalWin,
+		MoveLimit:  4,
+	},
+	{
+		ID:         "two-pawns",
+		Name:       "炮兵破双士",
+		Difficulty: "初级",
+		FEN:        "3k5/4a4/3a5/3P1P3/9/9/9/9/9/3CK4 w - - 0 1",
+		Goal:       endgameModel.GoalWin,
+		MoveLimit:  5,
+	},
+	{
+		ID:         "horse-cannon",
+		Name:       "马后炮",
+		Difficulty: "中级",
+		FEN:        "3k1a3/4a4/9/9/9/9/9/2N6/4C4/4K4 w - - 0 1",
+		Goal:       endgameModel.GoalWin,
+		MoveLimit:  5,
+	},
+	{
+		ID:         "chariot-cannon",
+		Name:       "车炮破士象",
+		Difficulty: "高级",
+		FEN:        "3ak1b2/4a4/4b4/9/9/9/9/9/4C4/3K1R3 w - - 0 1",
+		Goal:       endgameModel.GoalWin,
+		MoveLimit:  6,
+	},
+	{
+		ID:         "full-defense",
+		Name:       "士象全守和单车",
+		Difficulty: "高级",
+		FEN:        "3akab2/9/4b4/9/9/9/9/9/9/3K1R3 b - - 0 1",
+		Goal:       endgameModel.GoalDraw,
+		MoveLimit:  20,
+	},
+}
+
+// SeedEndgameScenarios 关卡表为空时写入内置关卡，已有关卡（包括管理员修改过的）保持不变
+func SeedEndgameScenarios() {
+	db := database.GetMysqlDb()
+	var count int64
+	if err := db.Model(&endgameModel.Scenario{}).Count(&count).Error; err != nil {
+		log.Printf("seed endgame scenarios failed: %v", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+	scenarios := make([]endgameModel.Scenario, len(builtinScenarios))
+	copy(scenarios, builtinScenarios)
+	for i := range scenarios {
+		scenarios[i].SortOrder = i
+	}
+	if err := db.Create(&scenarios).Error; err != nil {
+		log.Printf("seed endgame scenarios failed: %v", err)
+	}
+}
+
+// GetEndgameScenario 按 ID 查找可挑战的残局关卡，已下架的关卡视为不存在
+func GetEndgameScenario(id string) (*endgameModel.Scenario, error) {
+	var sc endgameModel.Scenario
+	err := database.GetMysqlDb().Where("id = ? AND retired = ?", id, false).First(&sc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("残局关卡不存在")
+	}
+	if err != nil {
+		return nil, errors.New("查询残局关卡失败")
+	}
+	return &sc, nil
+}
+
+// EndgameExp 首次通关残局关卡发放的经验：中级 50，高级 100，其它 0
+func EndgameExp(difficulty string) int {
+	switch difficulty {
+	case "高级":
+		return 100
+	case "中级":
+		return 50
+	}
+	return 0
+}
+
+// ListScenarios 按难度分组返回未下架的关卡，组内按 sort_order 排列，并附上用户在各关卡的进度
+func (es *EndgameService) ListScenarios(req *endgameDto.ListScenariosRequest) (*endgameDto.ListScenariosResponse, error) {
+	var scenarios []endgameModel.Scenario
+	err := database.GetMysqlDb().Where("retired = ?", false).
+		Order("sort_order, id").Find(&scenarios).Error
+	if err != nil {
+		return nil, errors.New("查询残局关卡失败")
+	}
+	progress, err := es.GetProgress(&endgameDto.GetEndgameProgressRequest{UserID: req.UserID})
+	if err != nil {
+		return nil, err
+	}
+	byScenario := make(map[string]*endgameDto.ScenarioProgress, len(progress.Progress))
+	for i := range progress.Progress {
+		byScenario[progress.Progress[i].ScenarioID] = &progress.Progress[i]
+	}
+
+	resp := &endgameDto.ListScenariosResponse{Groups: make([]endgameDto.ScenarioGroup, 0, len(endgameModel.Difficulties))}
+	for _, d := range endgameModel.Difficulties {
+		group := endgameDto.ScenarioGroup{Difficulty: d, Scenarios: []endgameDto.ScenarioItem{}}
+		for i := range scenarios {
+			sc := &scenarios[i]
+			if sc.Difficulty != d || (req.Tag != "" && !hasTag(sc.Tags, req.Tag)) {
+				continue
+			}
+			item := scenarioItem(sc)
+			item.Progress = byScenario[sc.ID]
+			group.Scenarios = append(group.Scenarios, item)
+		}
+		resp.Groups = append(resp.Groups, group)
+	}
+	return resp, nil
+}
+
+// ListAllScenarios 返回全部关卡（含已下架的），供管理员维护
+func (es *EndgameService) ListAllScenarios() (*endgameDto.AdminListScenariosResponse, error) {
+	var scenarios []endgameModel.Scenario
+	if err := database.GetMysqlDb().Order("sort_order, id").Find(&scenarios).Error; err != nil {
+		return nil, errors.New("查询残局关卡失败")
+	}
+	resp := &endgameDto.AdminListScenariosResponse{Scenarios: make([]endgameDto.ScenarioItem, 0, len(scenarios))}
+	for i := range scenarios {
+		resp.Scenarios = append(resp.Scenarios, scenarioItem(&scenarios[i]))
+	}
+	return resp, nil
+}
+
+// CreateScenario 新建关卡，排在同一难度的最后
+func (es *EndgameService) CreateScenario(req *endgameDto.SaveScenarioRequest) (*endgameDto.ScenarioItem, error) {
+	sc, err := buildScenario(req)
+	if err != nil {
+		return nil, err
+	}
+	db := database.GetMysqlDb()
+	var count int64
+	if err := db.Model(&endgameModel.Scenario{}).Where("id = ?", sc.ID).Count(&count).Error; err != nil {
+		return nil, errors.New("查询残局关卡失败")
+	}
+	if count > 0 {
+		return nil, errors.New("关卡ID已存在")
+	}
+	var last struct{ Max *int }
+	db.Model(&endgameModel.Scenario{}).Select("MAX(sort_order) AS max").Scan(&last)
+	if last.Max != nil {
+		sc.SortOrder = *last.Max + 1
+	}
+	if err := db.Create(sc).Error; err != nil {
+		return nil, errors.New("保存残局关卡失败")
+	}
+	item := scenarioItem(sc)
+	return &item, nil
+}
+
+// UpdateScenario 修改关卡，ID、顺序与下架状态不变；玩家已有的进度保留
+func (es *EndgameService) UpdateScenario(req *endgameDto.SaveScenarioRequest) (*endgameDto.ScenarioItem, error) {
+	sc, err := buildScenario(req)
+	if err != nil {
+		return nil, err
+	}
+	db := database.GetMysqlDb()
+	var existing endgameModel.Scenario
+	if err := db.Where("id = ?", sc.ID).First(&existing).Error; err != nil {
+		return nil, errors.New("残局关卡不存在")
+	}
+	existing.Name, existing.Difficulty, existing.FEN = sc.Name, sc.Difficulty, sc.FEN
+	existing.Goal, existing.MoveLimit, existing.Tags = sc.Goal, sc.MoveLimit, sc.Tags
+	err = db.Model(&existing).Select("name", "difficulty", "fen", "goal", "move_limit", "tags").Updates(&existing).Error
+	if err != nil {
+		return nil, errors.New("保存残局关卡失败")
+	}
+	item := scenarioItem(&existing)
+	return &item, nil
+}
+
+// ReorderScenarios 按 ids 的先后重排关卡，排序值取 ids 中的下标
+func (es *EndgameService) ReorderScenarios(req *endgameDto.ReorderScenariosRequest) error {
+	return database.GetMysqlDb().Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&endgameModel.Scenario{}).Where("id IN ?", req.IDs).Count(&count).Error; err != nil {
+			return errors.New("查询残局关卡失败")
+		}
+		if int(count) != len(req.IDs) {
+			return errors.New("关卡列表中包含不存在的关卡")
+		}
+		for i, id := range req.IDs {
+			if err := tx.Model(&endgameModel.Scenario{}).Where("id = ?", id).Update("sort_order", i).Error; err != nil {
+				return errors.New("保存关卡顺序失败")
+			}
+		}
+		return nil
+	})
+}
+
+// RetireScenario 下架或恢复关卡；下架后不再出现在目录中，也不能开始新的挑战，已有进度保留
+func (es *EndgameService) RetireScenario(id string, retired bool) error {
+	res := database.GetMysqlDb().Model(&endgameModel.Scenario{}).Where("id = ?", id).Update("retired", retired)
+	if res.Error != nil {
+		return errors.New("保存残局关卡失败")
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		database.GetMysqlDb().Model(&endgameModel.Scenario{}).Where("id = ?", id).Count(&count)
+		if count == 0 {
+			return errors.New("残局关卡不存在")
+		}
+	}
+	return nil
+}
+
+// buildScenario 校验关卡并规范化局面与标签
+func buildScenario(req *endgameDto.SaveScenarioRequest) (*endgameModel.Scenario, error) {
+	if !containsString(endgameModel.Difficulties, req.Difficulty) {
+		return nil, fmt.Errorf("难度只能为 %s", strings.Join(endgameModel.Difficulties, "、"))
+	}
+	if req.Goal != endgameModel.GoalWin && req.Goal != endgameModel.GoalDraw {
+		return nil, errors.New("目标只能为 win 或 draw")
+	}
+	fen, err := checkScenarioFEN(req.FEN)
+	if err != nil {
+		return nil, err
+	}
+	return &endgameModel.Scenario{
+		ID:         req.ID,
+		Name:       strings.TrimSpace(req.Name),
+		Difficulty: req.Difficulty,
+		FEN:        fen,
+		Goal:       req.Goal,
+		MoveLimit:  req.MoveLimit,
+		Tags:       strings.Join(normalizeTags(req.Tags), ","),
+	}, nil
+}
+
+// checkScenarioFEN 用规则引擎校验关卡局面：须是合法局面，且行棋方（玩家）尚有着法可走
+func checkScenarioFEN(fen string) (string, error) {
+	b, err := xiangqi.ParseFEN(fen)
+	if err != nil {
+		return "", err
+	}
+	if errs := b.CheckPosition(); len(errs) > 0 {
+		return "", fmt.Errorf("局面不合法：%w", errs[0])
+	}
+	if b.Status() != xiangqi.Ongoing {
+		return "", errors.New("局面已分出胜负")
+	}
+	return b.FEN(), nil
+}
+
+// normalizeTags 去掉标签两端空白、空标签与重复标签，标签中的逗号会与分隔符冲突，一并去掉
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(strings.ReplaceAll(t, ",", ""))
+		if t != "" && !containsString(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+func hasTag(tags, tag string) bool {
+	return containsString(splitTags(tags), tag)
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func scenarioItem(sc *endgameModel.Scenario) endgameDto.ScenarioItem {
+	return endgameDto.ScenarioItem{
+		ID:         sc.ID,
+		Name:       sc.Name,
+		Difficulty: sc.Difficulty,
+		FEN:        sc.FEN,
+		Goal:       sc.Goal,
+		MoveLimit:  sc.MoveLimit,
+		Exp:        EndgameExp(sc.Difficulty),
+		Tags:       splitTags(sc.Tags),
+		SortOrder:  sc.SortOrder,
+		Retired:    sc.Retired,
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"gorm.io/gorm"
+
+	"chinese-chess-backend/database"
+	openingDto "chinese-chess-backend/dto/opening"
+	recordModel "chinese-chess-backend/model/record"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/opening"
+	"chinese-chess-backend/xiangqi"
+)
+
+type OpeningService struct {
+}
+
+func NewOpeningService() *OpeningService {
+	return &OpeningService{}
+}
+
+// BookMoves 查询局面所属的开局及开局库中的后续着法
+func (ops *OpeningService) BookMoves(req *openingDto.BookMovesRequest) (*openingDto.BookMovesResponse, error) {
+	board := xiangqi.NewBoard()
+	if req.FEN != "" {
+		b, err := xiangqi.ParseFEN(req.FEN)
+		if err != nil {
+			return nil, err
+		}
+		if errs := b.CheckPosition(); len(errs) > 0 {
+			return nil, fmt.Errorf("局面不合法：%w", errs[0])
+		}
+		board = b
+	}
+	for i, s := range req.Moves {
+		m, err := notation.ParseICCS(s)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 步：%w", i+1, err)
+		}
+		if _, err := board.MakeMove(m); err != nil {
+			return nil, fmt.Errorf("第 %d 步 %s 不合法：%w", i+1, s, err)
+		}
+	}
+
+	book := opening.Default()
+	resp := &openingDto.BookMovesResponse{FEN: board.FEN(), Moves: []openingDto.BookMove{}}
+	if op, ok := book.Lookup(board); ok {
+		resp.Ecco, resp.Name = op.Code, op.Name
+	}
+	for _, bm := range book.Moves(board) {
+		text, _ := notation.ToChinese(board, bm.Move)
+		resp.Moves = append(resp.Moves, openingDto.BookMove{
+			Move:     notation.ToICCS(bm.Move),
+			MoveText: text,
+			Ecco:     bm.Opening.Code,
+			Name:     bm.Opening.Name,
+		})
+	}
+	return resp, nil
+}
+
+// Stats 按开局统计用户在随机匹配、人机、好友对战与比赛中的战绩
+func (ops *OpeningService) Stats(req *openingDto.OpeningStatsRequest) (*openingDto.OpeningStatsResponse, error) {
+	var rows []struct {
+		Ecco  string
+		Games int
+		Wins  int
+		Draws int
+	}
+	q := database.GetMysqlDb().Model(&recordModel.GameRecord{}).
+		Select("ecco, COUNT(*) AS games, "+
+			"SUM(CASE WHEN (red_id = ? AND result = 0) OR (black_id = ? AND result = 1) THEN 1 ELSE 0 END) AS wins, "+
+			"SUM(CASE WHEN result = 2 THEN 1 ELSE 0 END) AS draws", req.UserID, req.UserID).
+		Where("game_type IN ?", []int{recordModel.GameTypeMatch, recordModel.GameTypeAI, recordModel.GameTypeFriend, recordModel.GameTypeTournament}).
+		Where("ecco <> ''")
+	switch req.Color {
+	case "red":
+		q = q.Where("red_id = ?", req.UserID)
+	case "black":
+		q = q.Where("black_id = ?", req.UserID)
+	default:
+		q = q.Where("red_id = ? OR black_id = ?", req.UserID, req.UserID)
+	}
+	if err := q.Group("ecco").Order("games DESC, ecco").Scan(&rows).Error; err != nil {
+		return nil, errors.New("查询开局统计失败")
+	}
+
+	book := opening.Default()
+	resp := &openingDto.OpeningStatsResponse{Openings: make([]openingDto.OpeningStat, 0, len(rows))}
+	for _, r := range rows {
+		name := book.Name(r.Ecco)
+		if name == "" {
+			name = r.Ecco
+		}
+		resp.Openings = append(resp.Openings, openingDto.OpeningStat{
+			Ecco:    r.Ecco,
+			Name:    name,
+			Games:   r.Games,
+			Wins:    r.Wins,
+			Draws:   r.Draws,
+			Losses:  r.Games - r.Wins - r.Draws,
+			WinRate: math.Round(float64(r.Wins)*1000/float64(r.Games)) / 10,
+		})
+	}
+	return resp, nil
+}
+
+// RecordOpening 对局记录的开局编码，没有着法或棋谱无法解析时为空
+func RecordOpening(rec *recordModel.GameRecord) string {
+	if rec.History == "" {
+		return ""
+	}
+	moves, _, _ := replayRecord(rec)
+	op, ok := opening.Default().Classify(moves)
+	if !ok {
+		return ""
+	}
+	return op.Code
+}
+
+// BackfillOpenings 为加入开局分类之前保存的对局记录补充开局编码
+func BackfillOpenings() {
+	db := database.GetMysqlDb()
+	var records []recordModel.GameRecord
+	tagged := 0
+	err := db.Select("id, history, game_type").
+		Where("ecco = '' AND history <> ''").
+		FindInBatches(&records, 200, func(tx *gorm.DB, batch int) error {
+			for i := range records {
+				code := RecordOpening(&records[i])
+				if code == "" {
+					continue
+				}
+				if err := db.Model(&records[i]).UpdateColumn("ecco", code).Error; err != nil {
+					return err
+				}
+				tagged++
+			}
+			return nil
+		}).Error
+	if err != nil {
+		log.Printf("backfill openings failed: %v", err)
+	}
+	if tagged > 0 {
+		log.Printf("backfilled openings for %d game records", tagged)
+	}
+}
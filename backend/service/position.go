@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"chinese-chess-backend/database"
+	positionDto "chinese-chess-backend/dto/position"
+	recordModel "chinese-chess-backend/model/record"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+type PositionService struct {
+}
+
+func NewPositionService() *PositionService {
+	return &PositionService{}
+}
+
+// loadRecord 查询对局记录，仅允许对局双方或导入者查看
+func loadRecord(userID int, recordID uint) (*recordModel.GameRecord, error) {
+	var rec recordModel.GameRecord
+	if err := database.GetMysqlDb().First(&rec, recordID).Error; err != nil {
+		return nil, errors.New("对局记录不存在")
+	}
+	uid := uint(userID)
+	if rec.RedID != uid && rec.BlackID != uid && rec.UploaderID != uid {
+		return nil, errors.New("无权查看该对局记录")
+	}
+	return &rec, nil
+}
+
+// replayRecord 解析并复盘对局记录，返回走法序列与每一步之后的局面（下标 0 为开局）
+// 早期人机对战记录由前端按执子方视角保存，按红方视角复盘失败时会尝试旋转坐标后再复盘
+func replayRecord(rec *recordModel.GameRecord) ([]xiangqi.Move, []*xiangqi.Board, error) {
+	moves, err := xiangqi.ParseHistory(rec.History)
+	if err != nil {
+		return nil, nil, err
+	}
+	boards, err := xiangqi.Replay(xiangqi.NewBoard(), moves)
+	if err == nil || rec.GameType != 1 {
+		return moves, boards, err
+	}
+	flipped := make([]xiangqi.Move, len(moves))
+	for i, m := range moves {
+		flipped[i] = m.Flip()
+	}
+	if flippedBoards, ferr := xiangqi.Replay(xiangqi.NewBoard(), flipped); ferr == nil {
+		return flipped, flippedBoards, nil
+	}
+	return moves, boards, err
+}
+
+// renderRecordMoves 按指定记谱格式渲染对局记录的全部走法，棋谱无法复盘时返回 nil
+func renderRecordMoves(rec *recordModel.GameRecord, format notation.Format) []string {
+	if rec.History == "" {
+		return nil
+	}
+	moves, _, err := replayRecord(rec)
+	if err != nil {
+		log.Printf("对局记录 %d 无法复盘，跳过记谱渲染: %v", rec.ID, err)
+		return nil
+	}
+	out, err := notation.RenderGame(format, xiangqi.NewBoard(), moves)
+	if err != nil {
+		log.Printf("对局记录 %d 记谱渲染失败: %v", rec.ID, err)
+		return nil
+	}
+	return out
+}
+
+// GetRecordFEN 返回对局记录在第 ply 步（半回合）之后的 FEN
+func (ps *PositionService) GetRecordFEN(req *positionDto.GetRecordFENRequest) (*positionDto.GetRecordFENResponse, error) {
+	rec, err := loadRecord(req.UserID, req.RecordID)
+	if err != nil {
+		return nil, err
+	}
+	moves, boards, err := replayRecord(rec)
+	if err != nil {
+		return nil, errors.New("棋谱无法复盘：" + err.Error())
+	}
+
+	ply := len(moves)
+	if req.Ply != nil {
+		ply = *req.Ply
+	}
+	if ply < 0 || ply > len(moves) {
+		return nil, errors.New("步数超出范围")
+	}
+	board := boards[ply]
+	return &positionDto.GetRecordFENResponse{
+		RecordID:   rec.ID,
+		Ply:        ply,
+		TotalPlies: len(moves),
+		FEN:        board.FEN(),
+		Turn:       board.Turn.String(),
+	}, nil
+}
+
+// ValidateFEN 解析并检查用户提交的 FEN，格式错误或局面不合理时 Valid 为 false 并给出原因
+func (ps *PositionService) ValidateFEN(req *positionDto.ValidateFENRequest) *positionDto.ValidateFENResponse {
+	resp := &positionDto.ValidateFENResponse{}
+	board, err := xiangqi.ParseFEN(req.FEN)
+	if err != nil {
+		resp.Errors = []string{err.Error()}
+		return resp
+	}
+	resp.FEN = board.FEN()
+	resp.Turn = board.Turn.String()
+	if errs := board.CheckPosition(); len(errs) > 0 {
+		for _, e := range errs {
+			resp.Errors = append(resp.Errors, e.Error())
+		}
+		return resp
+	}
+
+	resp.Valid = true
+	resp.InCheck = board.InCheck(board.Turn)
+	resp.LegalMoves = len(board.LegalMoves())
+	switch board.Status() {
+	case xiangqi.Checkmate:
+		resp.Status = "checkmate"
+	case xiangqi.Stalemate:
+		resp.Status = "stalemate"
+	default:
+		resp.Status = "ongoing"
+	}
+	return resp
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"chinese-chess-backend/database"
+	puzzleDto "chinese-chess-backend/dto/puzzle"
+	ratingDto "chinese-chess-backend/dto/rating"
+	puzzleModel "chinese-chess-backend/model/puzzle"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/puzzle"
+	"chinese-chess-backend/rating"
+	"chinese-chess-backend/xiangqi"
+)
+
+// puzzleWindows 按等级分挑选习题时依次放宽的范围，都没有时取等级分最接近的习题
+var puzzleWindows = []float64{100, 200, 400}
+
+type PuzzleService struct {
+}
+
+func NewPuzzleService() *PuzzleService {
+	return &PuzzleService{}
+}
+
+// SeedPuzzles 习题表为空时写入内置习题
+func SeedPuzzles() {
+	db := database.GetMysqlDb()
+	var count int64
+	if err := db.Model(&puzzleModel.Puzzle{}).Count(&count).Error; err != nil {
+		log.Printf("seed puzzles failed: %v", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+	puzzles := make([]puzzleModel.Puzzle, len(puzzle.Builtin))
+	def := rating.Default()
+	for i, bp := range puzzle.Builtin {
+		puzzles[i].FEN, puzzles[i].Solution = bp.FEN, bp.Solution
+		puzzles[i].Rating = puzzle.InitialRating(len(splitMoves(puzzles[i].Solution)))
+		puzzles[i].RD, puzzles[i].Volatility = def.RD, def.Sigma
+		puzzles[i].Source = puzzleModel.SourceBuiltin
+	}
+	if err := db.Create(&puzzles).Error; err != nil {
+		log.Printf("seed puzzles failed: %v", err)
+	}
+}
+
+// loadPuzzleRating 读取用户的习题等级分（不存在时返回初始值但不写库），lock 为 true 时加行锁
+func loadPuzzleRating(tx *gorm.DB, userID uint, lock bool) (*puzzleModel.PuzzleRating, error) {
+	var r puzzleModel.PuzzleRating
+	q := tx
+	if lock {
+		q = q.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	err := q.Where("user_id = ?", userID).First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		def := rating.Default()
+		return &puzzleModel.PuzzleRating{UserID: userID, Rating: def.R, RD: def.RD, Volatility: def.Sigma}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// currentPuzzleRating 返回考虑空闲衰减后的习题等级分，评分周期与对局等级分相同
+func currentPuzzleRating(r *puzzleModel.PuzzleRating, now time.Time) rating.Rating {
+	cur := rating.Rating{R: r.Rating, RD: r.RD, Sigma: r.Volatility}
+	if r.LastPlayedAt != nil && RatingPeriodDays > 0 {
+		periods := int(now.Sub(*r.LastPlayedAt).Hours() / 24 / float64(RatingPeriodDays))
+		cur = rating.Decay(cur, periods)
+	}
+	return cur
+}
+
+// DailyPuzzle 返回今天的每日一题，当天第一次请求时选出：优先选没有当过每日一题的习题
+func (ps *PuzzleService) DailyPuzzle(userID int) (*puzzleDto.PuzzleResponse, error) {
+	p, err := dailyPuzzle(time.Now().Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	return ps.startAttempt(userID, p, true)
+}
+
+func dailyPuzzle(date string) (*puzzleModel.Puzzle, error) {
+	db := database.GetMysqlDb()
+	var daily puzzleModel.DailyPuzzle
+	err := db.Where("date = ?", date).First(&daily).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var p puzzleModel.Puzzle
+		err = db.Where("id NOT IN (?)", db.Model(&puzzleModel.DailyPuzzle{}).Select("puzzle_id")).
+			Order("RAND()").First(&p).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = db.Order("RAND()").First(&p).Error
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("暂无习题")
+		}
+		if err != nil {
+			return nil, errors.New("查询习题失败")
+		}
+		// 并发选题时以先写入的为准
+		db.Clauses(clause.OnConflict{DoNothing: true}).Create(&puzzleModel.DailyPuzzle{Date: date, PuzzleID: p.ID})
+		err = db.Where("date = ?", date).First(&daily).Error
+	}
+	if err != nil {
+		return nil, errors.New("查询每日一题失败")
+	}
+	var p puzzleModel.Puzzle
+	if err := db.First(&p, daily.PuzzleID).Error; err != nil {
+		return nil, errors.New("习题不存在")
+	}
+	return &p, nil
+}
+
+// NextPuzzle 返回一道计分习题：有未完成的习题时继续该题，否则挑选一道没做过且难度与用户习题等级分相近的习题
+func (ps *PuzzleService) NextPuzzle(userID int) (*puzzleDto.PuzzleResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("用户ID无效")
+	}
+	db := database.GetMysqlDb()
+	today, _ := dailyPuzzleID(time.Now().Format(time.DateOnly))
+
+	var playing puzzleModel.PuzzleAttempt
+	err := db.Where("user_id = ? AND status = ? AND puzzle_id <> ?", userID, puzzleModel.AttemptPlaying, today).
+		Order("id").First(&playing).Error
+	if err == nil {
+		var p puzzleModel.Puzzle
+		if err := db.First(&p, playing.PuzzleID).Error; err == nil {
+			return ps.puzzleResponse(&p, &playing, false)
+		}
+	}
+
+	r, err := loadPuzzleRating(db, uint(userID), false)
+	if err != nil {
+		return nil, errors.New("查询习题等级分失败")
+	}
+	target := currentPuzzleRating(r, time.Now()).R
+	unplayed := func() *gorm.DB {
+		return db.Where("id NOT IN (?)", db.Model(&puzzleModel.PuzzleAttempt{}).Select("puzzle_id").Where("user_id = ?", userID))
+	}
+	var p puzzleModel.Puzzle
+	err = gorm.ErrRecordNotFound
+	for _, w := range puzzleWindows {
+		err = unplayed().Where("rating BETWEEN ? AND ?", target-w, target+w).Order("RAND()").First(&p).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = unplayed().Order(clause.OrderBy{Expression: clause.Expr{SQL: "ABS(rating - ?)", Vars: []interface{}{target}}}).First(&p).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("已经做完全部习题")
+	}
+	if err != nil {
+		return nil, errors.New("查询习题失败")
+	}
+	return ps.startAttempt(userID, &p, p.ID == today)
+}
+
+// dailyPuzzleID 指定日期的每日一题，尚未选出时返回 0
+func dailyPuzzleID(date string) (uint, error) {
+	var daily puzzleModel.DailyPuzzle
+	if err := database.GetMysqlDb().Where("date = ?", date).First(&daily).Error; err != nil {
+		return 0, err
+	}
+	return daily.PuzzleID, nil
+}
+
+// startAttempt 开始解答一道习题，已经做过时返回已有的解答
+func (ps *PuzzleService) startAttempt(userID int, p *puzzleModel.Puzzle, daily bool) (*puzzleDto.PuzzleResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("用户ID无效")
+	}
+	db := database.GetMysqlDb()
+	var attempt puzzleModel.PuzzleAttempt
+	err := db.Where("user_id = ? AND puzzle_id = ?", userID, p.ID).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		attempt = puzzleModel.PuzzleAttempt{UserID: uint(userID), PuzzleID: p.ID, Status: puzzleModel.AttemptPlaying}
+		if err = db.Create(&attempt).Error; err != nil {
+			// 并发开始同一道题时唯一索引冲突，以已有的解答为准
+			err = db.Where("user_id = ? AND puzzle_id = ?", userID, p.ID).First(&attempt).Error
+		}
+	}
+	if err != nil {
+		return nil, errors.New("查询解答记录失败")
+	}
+	return ps.puzzleResponse(p, &attempt, daily)
+}
+
+func (ps *PuzzleService) puzzleResponse(p *puzzleModel.Puzzle, attempt *puzzleModel.PuzzleAttempt, daily bool) (*puzzleDto.PuzzleResponse, error) {
+	b, err := xiangqi.ParseFEN(p.FEN)
+	if err != nil {
+		return nil, fmt.Errorf("习题局面有误：%w", err)
+	}
+	moves := splitMoves(attempt.Moves)
+	resp := &puzzleDto.PuzzleResponse{
+		ID:     p.ID,
+		FEN:    p.FEN,
+		Color:  b.Turn.String(),
+		Rating: int(math.Round(p.Rating)),
+		Plays:  p.Plays,
+		Daily:  daily,
+		Status: attempt.Status,
+		Moves:  moves,
+	}
+	if err := playMoves(b, moves); err != nil {
+		return nil, err
+	}
+	resp.CurrentFEN = b.FEN()
+	if attempt.Status != puzzleModel.AttemptPlaying {
+		resp.Solution = splitMoves(p.Solution)
+	}
+	return resp, nil
+}
+
+// SubmitMove 按解法校验解题方的一步棋：与解法一致或直接将死对方即为正确，正确且未结束时代走对方的应着
+// 无法识别或不合法的着法只返回错误，不判定解答失败；解答结束时更新用户与习题双方的等级分
+func (ps *PuzzleService) SubmitMove(req *puzzleDto.SubmitMoveRequest) (*puzzleDto.SubmitMoveResponse, error) {
+	if req.UserID <= 0 {
+		return nil, errors.New("用户ID无效")
+	}
+	var resp *puzzleDto.SubmitMoveResponse
+	err := database.GetMysqlDb().Transaction(func(tx *gorm.DB) error {
+		var attempt puzzleModel.PuzzleAttempt
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND puzzle_id = ?", req.UserID, req.PuzzleID).First(&attempt).Error
+		if err != nil {
+			return errors.New("请先开始这道习题")
+		}
+		if attempt.Status != puzzleModel.AttemptPlaying {
+			return errors.New("这道习题已经结束")
+		}
+		var p puzzleModel.Puzzle
+		if err := tx.First(&p, req.PuzzleID).Error; err != nil {
+			return errors.New("习题不存在")
+		}
+		b, err := xiangqi.ParseFEN(p.FEN)
+		if err != nil {
+			return fmt.Errorf("习题局面有误：%w", err)
+		}
+		played := splitMoves(attempt.Moves)
+		if err := playMoves(b, played); err != nil {
+			return err
+		}
+		solution := splitMoves(p.Solution)
+		if len(played) >= len(solution) {
+			return errors.New("习题解法有误")
+		}
+
+		m, err := notation.ParseMove(b, req.Move)
+		if err != nil {
+			return err
+		}
+		resp = &puzzleDto.SubmitMoveResponse{Move: notation.ToICCS(m), Status: puzzleModel.AttemptPlaying}
+		b.Play(m)
+		mated := b.Status() != xiangqi.Ongoing
+		resp.Correct = resp.Move == solution[len(played)] || mated
+		played = append(played, resp.Move)
+		switch {
+		case !resp.Correct:
+			resp.Status = puzzleModel.AttemptFailed
+		case mated || len(played) == len(solution):
+			resp.Status = puzzleModel.AttemptSolved
+		default:
+			reply, err := notation.ParseICCS(solution[len(played)])
+			if err != nil {
+				return errors.New("习题解法有误")
+			}
+			resp.Reply = solution[len(played)]
+			resp.ReplyText, _ = notation.ToChinese(b, reply)
+			played = append(played, resp.Reply)
+		}
+
+		attempt.Moves = strings.Join(played, " ")
+		attempt.Status = resp.Status
+		if resp.Status != puzzleModel.AttemptPlaying {
+			change, err := settlePuzzle(tx, &attempt, &p, resp.Status == puzzleModel.AttemptSolved)
+			if err != nil {
+				return err
+			}
+			resp.RatingChange = change
+			resp.Solution = solution
+		}
+		if err := tx.Save(&attempt).Error; err != nil {
+			return errors.New("保存解答失败")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// settlePuzzle 以用户与习题对局的方式更新双方的习题等级分，解出视为用户胜
+func settlePuzzle(tx *gorm.DB, attempt *puzzleModel.PuzzleAttempt, p *puzzleModel.Puzzle, solved bool) (*ratingDto.RatingChange, error) {
+	user, err := loadPuzzleRating(tx, attempt.UserID, true)
+	if err != nil {
+		return nil, errors.New("查询习题等级分失败")
+	}
+	// 习题可能同时被多人解答，结算时才锁定并重新读取习题的等级分
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(p, p.ID).Error; err != nil {
+		return nil, errors.New("习题不存在")
+	}
+	now := time.Now()
+	score := 0.0
+	if solved {
+		score = 1
+	}
+	userCur := currentPuzzleRating(user, now)
+	puzzleCur := rating.Rating{R: p.Rating, RD: p.RD, Sigma: p.Volatility}
+	userNew := rating.Update(userCur, []rating.Result{{Opponent: puzzleCur, Score: score}})
+	puzzleNew := rating.Update(puzzleCur, []rating.Result{{Opponent: userCur, Score: 1 - score}})
+
+	user.Rating, user.RD, user.Volatility = userNew.R, userNew.RD, userNew.Sigma
+	user.Attempts++
+	if solved {
+		user.Solved++
+	}
+	user.LastPlayedAt = &now
+	if err := tx.Save(user).Error; err != nil {
+		return nil, errors.New("保存习题等级分失败")
+	}
+	p.Rating, p.RD, p.Volatility = puzzleNew.R, puzzleNew.RD, puzzleNew.Sigma
+	p.Plays++
+	if solved {
+		p.Solves++
+	}
+	if err := tx.Save(p).Error; err != nil {
+		return nil, errors.New("保存习题等级分失败")
+	}
+
+	attempt.RatingBefore, attempt.RatingAfter = userCur.R, userNew.R
+	before, after := int(math.Round(userCur.R)), int(math.Round(userNew.R))
+	return &ratingDto.RatingChange{Before: before, After: after, Delta: after - before}, nil
+}
+
+// GetRating 返回用户的习题等级分
+func (ps *PuzzleService) GetRating(userID int) (*puzzleDto.PuzzleRatingResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("用户ID无效")
+	}
+	r, err := loadPuzzleRating(database.GetMysqlDb(), uint(userID), false)
+	if err != nil {
+		return nil, errors.New("查询习题等级分失败")
+	}
+	cur := currentPuzzleRating(r, time.Now())
+	return &puzzleDto.PuzzleRatingResponse{
+		Rating:      int(math.Round(cur.R)),
+		RD:          int(math.Round(cur.RD)),
+		Attempts:    r.Attempts,
+		Solved:      r.Solved,
+		Provisional: cur.RD > provisionalRD,
+	}, nil
+}
+
+// CreatePuzzle 录入习题：局面须合法且未分出胜负，解法须能从局面依次走出，并且是攻方着法唯一的连杀
+func (ps *PuzzleService) CreatePuzzle(req *puzzleDto.CreatePuzzleRequest) (*puzzleDto.PuzzleResponse, error) {
+	fen, err := checkScenarioFEN(req.FEN)
+	if err != nil {
+		return nil, err
+	}
+	start, _ := xiangqi.ParseFEN(fen)
+	b := start.Clone()
+	line := make([]xiangqi.Move, len(req.Solution))
+	solution := make([]string, len(req.Solution))
+	for i, s := range req.Solution {
+		m, err := notation.ParseMove(b, s)
+		if err != nil {
+			return nil, fmt.Errorf("解法第 %d 步：%w", i+1, err)
+		}
+		b.Play(m)
+		line[i] = m
+		solution[i] = notation.ToICCS(m)
+	}
+	if err := puzzle.Verify(start, line); err != nil {
+		return nil, fmt.Errorf("解法有误：%w", err)
+	}
+	def := rating.Default()
+	p := puzzleModel.Puzzle{
+		FEN:        fen,
+		Solution:   strings.Join(solution, " "),
+		Rating:     float64(req.Rating),
+		RD:         def.RD,
+		Volatility: def.Sigma,
+		Source:     puzzleModel.SourceManual,
+	}
+	if req.Rating == 0 {
+		p.Rating = puzzle.InitialRating(len(solution))
+	}
+	if err := database.GetMysqlDb().Create(&p).Error; err != nil {
+		return nil, errors.New("保存习题失败")
+	}
+	// 管理员录入后直接返回包含完整解法的习题
+	return ps.puzzleResponse(&p, &puzzleModel.PuzzleAttempt{Status: puzzleModel.AttemptSolved}, false)
+}
+
+// splitMoves 拆分空格分隔的 ICCS 着法
+func splitMoves(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Fields(s)
+}
+
+// playMoves 在局面 b 上依次走出 ICCS 着法
+func playMoves(b *xiangqi.Board, moves []string) error {
+	for i, s := range moves {
+		m, err := notation.ParseICCS(s)
+		if err != nil {
+			return fmt.Errorf("第 %d 步：%w", i+1, err)
+		}
+		if _, err := b.MakeMove(m); err != nil {
+			return fmt.Errorf("第 %d 步 %s 不合法：%w", i+1, s, err)
+		}
+	}
+	return nil
+}
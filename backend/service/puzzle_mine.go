@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm/clause"
+
+	"chinese-chess-backend/database"
+	puzzleDto "chinese-chess-backend/dto/puzzle"
+	analysisModel "chinese-chess-backend/model/analysis"
+	puzzleModel "chinese-chess-backend/model/puzzle"
+	recordModel "chinese-chess-backend/model/record"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/puzzle"
+	"chinese-chess-backend/rating"
+	"chinese-chess-backend/xiangqi"
+)
+
+// minePuzzles 从一局已分析对局的败着中挖掘习题，返回新增的习题数
+// 只有赛后分析在败着之后已搜到杀棋的局面才进一步寻找唯一解法的连杀，其余败着跳过
+// 挖掘完成后在分析记录上标记，即使没有挖出习题也不再重复挖掘
+func minePuzzles(rec *recordModel.GameRecord, boards []*xiangqi.Board, rows []analysisModel.MoveAnalysis) int {
+	db := database.GetMysqlDb()
+	created := 0
+	failed := false
+	for _, row := range rows {
+		if row.Class != analysisModel.ClassBlunder || max(row.Score, -row.Score) <= mateCp-100 || row.Ply+1 >= len(boards) {
+			continue
+		}
+		b := boards[row.Ply+1]
+		line, ok := puzzle.FindMate(b)
+		if !ok {
+			continue
+		}
+		solution := make([]string, len(line))
+		for i, m := range line {
+			solution[i] = notation.ToICCS(m)
+		}
+		recordID, ply := rec.ID, row.Ply
+		def := rating.Default()
+		p := puzzleModel.Puzzle{
+			FEN:            b.FEN(),
+			Solution:       strings.Join(solution, " "),
+			Rating:         puzzle.InitialRating(len(line)),
+			RD:             def.RD,
+			Volatility:     def.Sigma,
+			Source:         puzzleModel.SourceMined,
+			SourceRecordID: &recordID,
+			SourcePly:      &ply,
+		}
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&p)
+		if res.Error != nil {
+			log.Printf("record %d: save mined puzzle failed: %v", rec.ID, res.Error)
+			failed = true
+			continue
+		}
+		created += int(res.RowsAffected)
+	}
+	// 保存失败时不标记，下次挖掘时重试
+	if !failed {
+		if err := db.Model(&analysisModel.GameAnalysis{}).Where("record_id = ?", rec.ID).Update("puzzles_mined", true).Error; err != nil {
+			log.Printf("record %d: mark puzzles mined failed: %v", rec.ID, err)
+		}
+	}
+	return created
+}
+
+// MinePuzzles 为已完成赛后分析、含有败着且尚未挖掘过的对局排队挖掘习题（新完成的分析会自动挖掘）
+func (ps *PuzzleService) MinePuzzles(req *puzzleDto.MinePuzzlesRequest) (*puzzleDto.MinePuzzlesResponse, error) {
+	db := database.GetMysqlDb()
+	var recordIDs []uint
+	err := db.Model(&analysisModel.MoveAnalysis{}).
+		Distinct("move_analyses.record_id").
+		Joins("JOIN game_analyses ON game_analyses.record_id = move_analyses.record_id").
+		Where("game_analyses.status = ? AND move_analyses.class = ?", analysisModel.StatusDone, analysisModel.ClassBlunder).
+		Where("game_analyses.puzzles_mined = ?", false).
+		Order("move_analyses.record_id DESC").
+		Limit(req.Limit).
+		Pluck("move_analyses.record_id", &recordIDs).Error
+	if err != nil {
+		return nil, errors.New("查询已分析的对局失败")
+	}
+	queued := 0
+	for _, id := range recordIDs {
+		if !analysisPool().TryProcess(func() error {
+			return mineRecordPuzzles(id)
+		}) {
+			// 队列已满，剩余的对局留到下次挖掘
+			break
+		}
+		queued++
+	}
+	if queued == 0 && len(recordIDs) > 0 {
+		return nil, errAnalysisQueueFull
+	}
+	return &puzzleDto.MinePuzzlesResponse{Queued: queued}, nil
+}
+
+func mineRecordPuzzles(recordID uint) error {
+	db := database.GetMysqlDb()
+	var rec recordModel.GameRecord
+	if err := db.First(&rec, recordID).Error; err != nil {
+		return fmt.Errorf("record %d: %w", recordID, err)
+	}
+	_, boards, err := replayRecord(&rec)
+	if err != nil {
+		return fmt.Errorf("record %d: %w", recordID, err)
+	}
+	var rows []analysisModel.MoveAnalysis
+	if err := db.Where("record_id = ? AND class = ?", recordID, analysisModel.ClassBlunder).Order("ply").Find(&rows).Error; err != nil {
+		return fmt.Errorf("record %d: %w", recordID, err)
+	}
+	if n := minePuzzles(&rec, boards, rows); n > 0 {
+		log.Printf("record %d: mined %d puzzles", recordID, n)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"chinese-chess-backend/config"
+	"chinese-chess-backend/database"
+	ratingDto "chinese-chess-backend/dto/rating"
+	ratingModel "chinese-chess-backend/model/rating"
+	"chinese-chess-backend/rating"
+)
+
+var (
+	// RatingPeriodDays 一个评分周期的天数，玩家每空闲一个周期评分偏差增大一次
+	RatingPeriodDays = config.GetEnvInt("RATING_PERIOD_DAYS", 7)
+	// provisionalRD 评分偏差高于该值时等级分标记为暂定
+	provisionalRD = 110.0
+)
+
+type RatingService struct {
+}
+
+func NewRatingService() *RatingService {
+	return &RatingService{}
+}
+
+// loadRating 读取用户等级分（不存在时返回初始值但不写库），lock 为 true 时加行锁
+func loadRating(tx *gorm.DB, userID uint, lock bool) (*ratingModel.UserRating, error) {
+	var r ratingModel.UserRating
+	q := tx
+	if lock {
+		q = q.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	err := q.Where("user_id = ?", userID).First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		def := rating.Default()
+		return &ratingModel.UserRating{UserID: userID, Rating: def.R, RD: def.RD, Volatility: def.Sigma}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// current 返回考虑空闲衰减后的 Glicko 等级分
+func current(r *ratingModel.UserRating, now time.Time) rating.Rating {
+	cur := rating.Rating{R: r.Rating, RD: r.RD, Sigma: r.Volatility}
+	if r.LastGameAt != nil && RatingPeriodDays > 0 {
+		periods := int(now.Sub(*r.LastGameAt).Hours() / 24 / float64(RatingPeriodDays))
+		cur = rating.Decay(cur, periods)
+	}
+	return cur
+}
+
+// ApplyGame 按一局计分对局的结果更新双方等级分并记录历史
+// result 取值与 GameRecord.Result 一致：0 红胜，1 黑胜，2 和棋；返回以用户ID为键的等级分变化
+func (rs *RatingService) ApplyGame(recordID, redID, blackID uint, result int) (map[uint]ratingDto.RatingChange, error) {
+	if redID == 0 || blackID == 0 || redID == blackID {
+		return nil, errors.New("计分对局需要两名不同的玩家")
+	}
+	redScore := 0.5
+	switch result {
+	case 0:
+		redScore = 1
+	case 1:
+		redScore = 0
+	}
+
+	changes := make(map[uint]ratingDto.RatingChange, 2)
+	now := time.Now()
+	err := database.GetMysqlDb().Transaction(func(tx *gorm.DB) error {
+		red, err := loadRating(tx, redID, true)
+		if err != nil {
+			return err
+		}
+		black, err := loadRating(tx, blackID, true)
+		if err != nil {
+			return err
+		}
+		redCur, blackCur := current(red, now), current(black, now)
+		// 双方都以对局前的等级分计算，每局视为一个评分周期
+		redNew := rating.Update(redCur, []rating.Result{{Opponent: blackCur, Score: redScore}})
+		blackNew := rating.Update(blackCur, []rating.Result{{Opponent: redCur, Score: 1 - redScore}})
+
+		for _, side := range []struct {
+			row      *ratingModel.UserRating
+			before   rating.Rating
+			after    rating.Rating
+			opponent uint
+			score    float64
+		}{
+			{red, redCur, redNew, blackID, redScore},
+			{black, blackCur, blackNew, redID, 1 - redScore},
+		} {
+			side.row.Rating = side.after.R
+			side.row.RD = side.after.RD
+			side.row.Volatility = side.after.Sigma
+			side.row.Games++
+			side.row.LastGameAt = &now
+			if err := tx.Save(side.row).Error; err != nil {
+				return err
+			}
+			hist := ratingModel.RatingHistory{
+				UserID:       side.row.UserID,
+				GameRecordID: recordID,
+				OpponentID:   side.opponent,
+				Score:        side.score,
+				RatingBefore: side.before.R,
+				RatingAfter:  side.after.R,
+				RDBefore:     side.before.RD,
+				RDAfter:      side.after.RD,
+			}
+			if err := tx.Create(&hist).Error; err != nil {
+				return err
+			}
+			before, after := int(math.Round(side.before.R)), int(math.Round(side.after.R))
+			changes[side.row.UserID] = ratingDto.RatingChange{Before: before, After: after, Delta: after - before}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// DisplayRating 返回用于展示的整数等级分（未下过计分对局时为初始分）
+func (rs *RatingService) DisplayRating(userID uint) int {
+	r, err := loadRating(database.GetMysqlDb(), userID, false)
+	if err != nil {
+		return int(rating.DefaultRating)
+	}
+	return int(math.Round(r.Rating))
+}
+
+// GetRating 返回用户当前等级分与最近 50 局的变化
+func (rs *RatingService) GetRating(userID int) (*ratingDto.GetRatingResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("用户ID无效")
+	}
+	db := database.GetMysqlDb()
+	r, err := loadRating(db, uint(userID), false)
+	if err != nil {
+		return nil, errors.New("查询等级分失败")
+	}
+	cur := current(r, time.Now())
+
+	var history []ratingModel.RatingHistory
+	if err := db.Where("user_id = ?", userID).Order("id DESC").Limit(50).Find(&history).Error; err != nil {
+		return nil, errors.New("查询等级分历史失败")
+	}
+	resp := &ratingDto.GetRatingResponse{
+		Rating:      int(math.Round(cur.R)),
+		RD:          int(math.Round(cur.RD)),
+		Games:       r.Games,
+		Provisional: cur.RD > provisionalRD,
+		History:     make([]ratingDto.RatingHistoryItem, 0, len(history)),
+	}
+	for _, h := range history {
+		before, after := int(math.Round(h.RatingBefore)), int(math.Round(h.RatingAfter))
+		resp.History = append(resp.History, ratingDto.RatingHistoryItem{
+			GameRecordID: h.GameRecordID,
+			OpponentID:   h.OpponentID,
+			Score:        h.Score,
+			Before:       before,
+			After:        after,
+			Delta:        after - before,
+			CreatedAt:    h.CreatedAt,
+		})
+	}
+	return resp, nil
+}
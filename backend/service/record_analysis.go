@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"chinese-chess-backend/ai"
+	"chinese-chess-backend/config"
+	"chinese-chess-backend/database"
+	analysisDto "chinese-chess-backend/dto/analysis"
+	"chinese-chess-backend/engine"
+	analysisModel "chinese-chess-backend/model/analysis"
+	recordModel "chinese-chess-backend/model/record"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/utils"
+	"chinese-chess-backend/xiangqi"
+)
+
+const (
+	// recordAnalysisDepth 内置 AI 分析每个局面的最大深度
+	recordAnalysisDepth = 6
+	// mateCp 杀棋折算的分数，计算损失与准确率时评分限制在 ±mateCp 之间
+	mateCp = 2000
+	// staleAnalysis 超过该时间仍未完成的分析视为已中断（如服务重启），允许重新发起
+	staleAnalysis = 10 * time.Minute
+	// 按走子方损失的分数分类
+	inaccuracyLoss = 50
+	mistakeLoss    = 100
+	blunderLoss    = 300
+)
+
+// recordAnalysisTime 分析每个局面的思考时间
+var recordAnalysisTime = time.Duration(config.GetEnvInt("ANALYSIS_MOVETIME_MS", 300)) * time.Millisecond
+
+var (
+	analysisWorkers     *utils.WorkerPool
+	analysisWorkersOnce sync.Once
+)
+
+// analysisPool 赛后分析任务使用的工作池，第一次使用时启动
+func analysisPool() *utils.WorkerPool {
+	analysisWorkersOnce.Do(func() {
+		analysisWorkers = utils.NewWorkerPool()
+		analysisWorkers.Start()
+		go func() {
+			for err := range analysisWorkers.ErrChan {
+				log.Printf("record analysis failed: %v", err)
+			}
+		}()
+	})
+	return analysisWorkers
+}
+
+// errAnalysisQueueFull 排队的分析任务已达工作池队列上限
+var errAnalysisQueueFull = errors.New("分析队列已满")
+
+// StopRecordAnalysis 停机时停止接受新的分析任务，等待队列中的任务执行完毕，超时则放弃等待
+// 未完成的分析在 staleAnalysis 之后可以重新发起
+func StopRecordAnalysis(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		analysisPool().Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待分析任务结束超时: %w", ctx.Err())
+	}
+}
+
+// RequestRecordAnalysis 发起对局记录的赛后分析，已完成或正在进行时直接返回当前状态
+func (as *AnalysisService) RequestRecordAnalysis(req *analysisDto.RecordAnalysisRequest) (*analysisDto.RecordAnalysisResponse, error) {
+	rec, err := loadRecord(req.UserID, req.RecordID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.History == "" {
+		return nil, errors.New("对局没有着法，无需分析")
+	}
+
+	db := database.GetMysqlDb()
+	var ga analysisModel.GameAnalysis
+	err = db.Where("record_id = ?", rec.ID).First(&ga).Error
+	switch {
+	case err == nil:
+		inProgress := ga.Status == analysisModel.StatusPending || ga.Status == analysisModel.StatusRunning
+		if ga.Status == analysisModel.StatusDone || (inProgress && time.Since(ga.UpdatedAt) < staleAnalysis) {
+			return as.GetRecordAnalysis(req)
+		}
+		ga.Status = analysisModel.StatusPending
+		ga.Error = ""
+		ga.PuzzlesMined = false
+		if err := db.Save(&ga).Error; err != nil {
+			return nil, errors.New("发起分析失败")
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ga = analysisModel.GameAnalysis{RecordID: rec.ID, Status: analysisModel.StatusPending}
+		if err := db.Create(&ga).Error; err != nil {
+			// 并发发起时唯一索引冲突，以已有的任务为准
+			return as.GetRecordAnalysis(req)
+		}
+	default:
+		return nil, errors.New("查询分析结果失败")
+	}
+
+	if !analysisPool().TryProcess(func() error {
+		return runRecordAnalysis(rec)
+	}) {
+		// 任务没有排上，标记为失败以便稍后直接重新发起
+		db.Model(&ga).Updates(map[string]any{"status": analysisModel.StatusFailed, "error": errAnalysisQueueFull.Error()})
+		return nil, errAnalysisQueueFull
+	}
+	return &analysisDto.RecordAnalysisResponse{RecordID: rec.ID, Status: ga.Status, Moves: []analysisDto.MoveAnalysisItem{}}, nil
+}
+
+// GetRecordAnalysis 查询对局记录的赛后分析结果
+func (as *AnalysisService) GetRecordAnalysis(req *analysisDto.RecordAnalysisRequest) (*analysisDto.RecordAnalysisResponse, error) {
+	rec, err := loadRecord(req.UserID, req.RecordID)
+	if err != nil {
+		return nil, err
+	}
+	db := database.GetMysqlDb()
+	var ga analysisModel.GameAnalysis
+	if err := db.Where("record_id = ?", rec.ID).First(&ga).Error; err != nil {
+		return nil, errors.New("该对局尚未分析")
+	}
+	resp := &analysisDto.RecordAnalysisResponse{
+		RecordID:      rec.ID,
+		Status:        ga.Status,
+		Engine:        ga.Engine,
+		Error:         ga.Error,
+		RedAccuracy:   ga.RedAccuracy,
+		BlackAccuracy: ga.BlackAccuracy,
+		Moves:         []analysisDto.MoveAnalysisItem{},
+	}
+	if ga.Status != analysisModel.StatusDone {
+		return resp, nil
+	}
+
+	var rows []analysisModel.MoveAnalysis
+	if err := db.Where("record_id = ?", rec.ID).Order("ply").Find(&rows).Error; err != nil {
+		return nil, errors.New("查询分析结果失败")
+	}
+	_, boards, err := replayRecord(rec)
+	if err != nil {
+		return nil, errors.New("棋谱无法复盘：" + err.Error())
+	}
+	for _, row := range rows {
+		item := analysisDto.MoveAnalysisItem{
+			Ply:       row.Ply,
+			Move:      row.Move,
+			Score:     row.Score,
+			BestMove:  row.BestMove,
+			BestScore: row.BestScore,
+			Loss:      row.Loss,
+			Class:     row.Class,
+		}
+		if row.Ply < len(boards) {
+			b := boards[row.Ply]
+			item.Color = b.Turn.String()
+			item.MoveText = moveText(b, row.Move)
+			item.BestMoveText = moveText(b, row.BestMove)
+		}
+		resp.Moves = append(resp.Moves, item)
+	}
+	return resp, nil
+}
+
+// moveText 将 ICCS 走法渲染为中文纵线记谱，无法渲染时返回空串
+func moveText(b *xiangqi.Board, iccs string) string {
+	m, err := notation.ParseICCS(iccs)
+	if err != nil {
+		return ""
+	}
+	text, err := notation.ToChinese(b, m)
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// positionEval 一个局面的评估：行棋方视角的分数与推荐着法（无子可走时为零值）
+type positionEval struct {
+	score int
+	best  xiangqi.Move
+}
+
+// runRecordAnalysis 逐个局面搜索评分，比较实际走法与推荐走法的差距并保存结果
+func runRecordAnalysis(rec *recordModel.GameRecord) error {
+	db := database.GetMysqlDb()
+	fail := func(err error) error {
+		db.Model(&analysisModel.GameAnalysis{}).Where("record_id = ?", rec.ID).
+			Updates(map[string]any{"status": analysisModel.StatusFailed, "error": err.Error()})
+		return fmt.Errorf("record %d: %w", rec.ID, err)
+	}
+	db.Model(&analysisModel.GameAnalysis{}).Where("record_id = ?", rec.ID).Update("status", analysisModel.StatusRunning)
+
+	moves, boards, err := replayRecord(rec)
+	if err != nil {
+		return fail(errors.New("棋谱无法复盘：" + err.Error()))
+	}
+
+	pool := engine.Default()
+	engineName := builtinEngineName
+	if pool != nil {
+		engineName = pool.Name()
+	}
+	evals := make([]positionEval, len(boards))
+	for i := range boards {
+		evals[i], err = evaluatePosition(pool, moves[:i], boards[:i+1])
+		if err != nil {
+			return fail(fmt.Errorf("第 %d 步分析失败：%w", i, err))
+		}
+	}
+
+	rows := make([]analysisModel.MoveAnalysis, 0, len(moves))
+	accuracy := map[xiangqi.Color][]float64{}
+	for i, m := range moves {
+		mover := boards[i].Turn
+		best := evals[i].score
+		played := -evals[i+1].score
+		loss := max(best-played, 0)
+		class := classifyMove(loss)
+		if m == evals[i].best {
+			loss, class = 0, analysisModel.ClassBest
+		}
+		rows = append(rows, analysisModel.MoveAnalysis{
+			RecordID:  rec.ID,
+			Ply:       i,
+			Move:      notation.ToICCS(m),
+			Score:     redScore(evals[i+1].score, boards[i+1].Turn),
+			BestMove:  notation.ToICCS(evals[i].best),
+			BestScore: redScore(best, mover),
+			Loss:      loss,
+			Class:     class,
+		})
+		accuracy[mover] = append(accuracy[mover], moveAccuracy(best, best-loss))
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("record_id = ?", rec.ID).Delete(&analysisModel.MoveAnalysis{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 100).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&analysisModel.GameAnalysis{}).Where("record_id = ?", rec.ID).Updates(map[string]any{
+			"status":         analysisModel.StatusDone,
+			"engine":         engineName,
+			"error":          "",
+			"red_accuracy":   average(accuracy[xiangqi.Red]),
+			"black_accuracy": average(accuracy[xiangqi.Black]),
+		}).Error
+	})
+	if err != nil {
+		return fail(errors.New("保存分析结果失败"))
+	}
+	// 从败着中挖掘习题
+	if n := minePuzzles(rec, boards, rows); n > 0 {
+		log.Printf("record %d: mined %d puzzles", rec.ID, n)
+	}
+	return nil
+}
+
+// evaluatePosition 评估 boards 中最后一个局面（moves 为从开局到该局面的着法）
+// 配置了外部引擎时优先使用引擎，出错时改用内置 AI
+func evaluatePosition(pool *engine.Pool, moves []xiangqi.Move, boards []*xiangqi.Board) (positionEval, error) {
+	b := boards[len(boards)-1]
+	if b.Status() != xiangqi.Ongoing {
+		// 将死与困毙在象棋中都判负
+		return positionEval{score: -mateCp}, nil
+	}
+	if pool != nil {
+		iccs := make([]string, len(moves))
+		for i, m := range moves {
+			iccs[i] = notation.ToICCS(m)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), recordAnalysisTime+5*time.Second)
+		res, err := pool.Search(ctx, xiangqi.InitialFEN, iccs, engine.Limit{MoveTime: recordAnalysisTime})
+		cancel()
+		if err == nil {
+			if best, perr := notation.ParseICCS(res.BestMove); perr == nil {
+				return positionEval{score: engineScore(res.Info), best: best}, nil
+			}
+		}
+		log.Printf("engine analysis failed, falling back to built-in ai: %v", err)
+	}
+
+	history := make([]uint64, 0, len(boards)-1)
+	for _, prev := range boards[:len(boards)-1] {
+		history = append(history, prev.Hash())
+	}
+	res, err := ai.Search(b, ai.Options{MaxDepth: recordAnalysisDepth, TimeLimit: recordAnalysisTime, History: history})
+	if err != nil {
+		return positionEval{}, err
+	}
+	return positionEval{score: searchScore(res.Score), best: res.Move}, nil
+}
+
+// engineScore 外部引擎的评分；引擎的 mate n 以回合计，行棋方杀棋需走 2n-1 步（半回合），被杀则对方需走 2n 步
+func engineScore(info engine.Info) int {
+	switch {
+	case info.Mate > 0:
+		return mateCp - (2*info.Mate - 1)
+	case info.Mate < 0:
+		return -mateCp + 2*-info.Mate
+	}
+	return clampScore(info.Score)
+}
+
+// searchScore 内置 AI 的评分；杀棋分按距将死的步数（半回合）折算，与 engineScore 一致
+func searchScore(score int) int {
+	if !ai.IsMate(score) {
+		return clampScore(score)
+	}
+	plies := ai.MateScore - max(score, -score)
+	if score > 0 {
+		return mateCp - plies
+	}
+	return -mateCp + plies
+}
+
+func clampScore(score int) int {
+	return min(max(score, -mateCp+100), mateCp-100)
+}
+
+// redScore 将行棋方视角的分数转换为红方视角
+func redScore(score int, turn xiangqi.Color) int {
+	if turn == xiangqi.Black {
+		return -score
+	}
+	return score
+}
+
+// classifyMove 按走子方损失的分数分类
+func classifyMove(loss int) string {
+	switch {
+	case loss >= blunderLoss:
+		return analysisModel.ClassBlunder
+	case loss >= mistakeLoss:
+		return analysisModel.ClassMistake
+	case loss >= inaccuracyLoss:
+		return analysisModel.ClassInaccuracy
+	}
+	return analysisModel.ClassGood
+}
+
+// winChance 将分数换算为胜率（0-100）
+func winChance(score int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(score)))-1)
+}
+
+// moveAccuracy 按走子前后胜率的下降计算一步棋的准确率（0-100），胜率不变时约为 100
+func moveAccuracy(before, after int) float64 {
+	drop := max(winChance(before)-winChance(after), 0)
+	return min(max(103.1668*math.Exp(-0.04354*drop)-3.1669, 0), 100)
+}
+
+func average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return math.Round(sum/float64(len(values))*10) / 10
+}
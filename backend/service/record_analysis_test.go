@@ -0,0 +1,146 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"chinese-chess-backend/ai"
+	"chinese-chess-backend/engine"
+	analysisModel "chinese-chess-backend/model/analysis"
+	"chinese-chess-backend/xiangqi"
+)
+
+func TestEngineScore(t *testing.T) {
+	tests := []struct {
+		info engine.Info
+		want int
+	}{
+		{engine.Info{Score: 35}, 35},
+		{engine.Info{Score: -35}, -35},
+		{engine.Info{Score: 5000}, mateCp - 100},
+		{engine.Info{Score: -5000}, -mateCp + 100},
+		// mate 1：行棋方一步杀
+		{engine.Info{Mate: 1}, mateCp - 1},
+		{engine.Info{Mate: 3}, mateCp - 5},
+		// mate -1：对方下一步杀，中间隔行棋方一步
+		{engine.Info{Mate: -1}, -mateCp + 2},
+		{engine.Info{Mate: -3}, -mateCp + 6},
+	}
+	for _, tt := range tests {
+		if got := engineScore(tt.info); got != tt.want {
+			t.Errorf("engineScore(%+v) = %d, want %d", tt.info, got, tt.want)
+		}
+	}
+}
+
+// TestMateScoresAgree 引擎与内置 AI 对同一杀棋给出相同的评分
+func TestMateScoresAgree(t *testing.T) {
+	for n := 1; n <= 10; n++ {
+		if got, want := searchScore(ai.MateScore-(2*n-1)), engineScore(engine.Info{Mate: n}); got != want {
+			t.Errorf("mate in %d: built-in %d, engine %d", n, got, want)
+		}
+		if got, want := searchScore(-ai.MateScore+2*n), engineScore(engine.Info{Mate: -n}); got != want {
+			t.Errorf("mated in %d: built-in %d, engine %d", n, got, want)
+		}
+	}
+	if got := searchScore(120); got != 120 {
+		t.Errorf("searchScore(120) = %d, want 120", got)
+	}
+}
+
+func TestEvaluatePosition(t *testing.T) {
+	tests := []struct {
+		name, fen string
+		want      int
+	}{
+		{"mate in 1", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 w", mateCp - 1},
+		// 黑方走任何一步红方都能一步杀
+		{"mated in 1", "3k5/9/9/9/9/9/9/9/4R4/2R1K4 b", -mateCp + 2},
+		{"checkmated", "3k5/9/9/9/9/9/9/9/9/3RK4 b", -mateCp},
+	}
+	for _, tt := range tests {
+		b, err := xiangqi.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := evaluatePosition(nil, nil, []*xiangqi.Board{b})
+		if err != nil || got.score != tt.want {
+			t.Errorf("%s: score = %d, %v; want %d", tt.name, got.score, err, tt.want)
+		}
+	}
+}
+
+func TestRedScore(t *testing.T) {
+	if got := redScore(120, xiangqi.Red); got != 120 {
+		t.Errorf("redScore(120, Red) = %d", got)
+	}
+	if got := redScore(120, xiangqi.Black); got != -120 {
+		t.Errorf("redScore(120, Black) = %d", got)
+	}
+}
+
+func TestClassifyMove(t *testing.T) {
+	tests := []struct {
+		loss int
+		want string
+	}{
+		{-30, analysisModel.ClassGood},
+		{0, analysisModel.ClassGood},
+		{inaccuracyLoss - 1, analysisModel.ClassGood},
+		{inaccuracyLoss, analysisModel.ClassInaccuracy},
+		{mistakeLoss - 1, analysisModel.ClassInaccuracy},
+		{mistakeLoss, analysisModel.ClassMistake},
+		{blunderLoss - 1, analysisModel.ClassMistake},
+		{blunderLoss, analysisModel.ClassBlunder},
+		{2 * mateCp, analysisModel.ClassBlunder},
+	}
+	for _, tt := range tests {
+		if got := classifyMove(tt.loss); got != tt.want {
+			t.Errorf("classifyMove(%d) = %q, want %q", tt.loss, got, tt.want)
+		}
+	}
+}
+
+func TestWinChance(t *testing.T) {
+	if got := winChance(0); got != 50 {
+		t.Errorf("winChance(0) = %v, want 50", got)
+	}
+	prev := winChance(-mateCp)
+	for score := -mateCp + 50; score <= mateCp; score += 50 {
+		got := winChance(score)
+		if got <= prev || got < 0 || got > 100 {
+			t.Fatalf("winChance(%d) = %v after %v", score, got, prev)
+		}
+		if sum := got + winChance(-score); math.Abs(sum-100) > 1e-9 {
+			t.Errorf("winChance(%d) + winChance(%d) = %v, want 100", score, -score, sum)
+		}
+		prev = got
+	}
+	if winChance(mateCp) < 99 {
+		t.Errorf("winChance(mateCp) = %v, want nearly 100", winChance(mateCp))
+	}
+}
+
+func TestMoveAccuracy(t *testing.T) {
+	tests := []struct {
+		before, after int
+		min, max      float64
+	}{
+		{0, 0, 99.99, 100},
+		// 胜率上升不扣分
+		{0, 300, 99.99, 100},
+		{300, 250, 80, 95},
+		{0, -300, 30, 60},
+		{mateCp, -mateCp, 0, 1},
+	}
+	for _, tt := range tests {
+		got := moveAccuracy(tt.before, tt.after)
+		if got < tt.min || got > tt.max {
+			t.Errorf("moveAccuracy(%d, %d) = %v, want [%v, %v]", tt.before, tt.after, got, tt.min, tt.max)
+		}
+	}
+	// 同样的分数损失，均势时比大优时扣分更多
+	if even, winning := moveAccuracy(0, -100), moveAccuracy(1500, 1400); even >= winning {
+		t.Errorf("moveAccuracy: even position %v, winning position %v", even, winning)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"chinese-chess-backend/database"
+	recordDto "chinese-chess-backend/dto/record"
+	"chinese-chess-backend/gamefile"
+	recordModel "chinese-chess-backend/model/record"
+	userModel "chinese-chess-backend/model/user"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+type RecordFileService struct {
+}
+
+func NewRecordFileService() *RecordFileService {
+	return &RecordFileService{}
+}
+
+// gameTypeLabels 对局类型在棋谱 Event 标签中的名称
+var gameTypeLabels = map[int]string{
+	recordModel.GameTypeMatch:      "随机匹配",
+	recordModel.GameTypeAI:         "人机对战",
+	recordModel.GameTypeFriend:     "好友对战",
+	recordModel.GameTypeImported:   "导入棋谱",
+	recordModel.GameTypeTournament: "比赛对局",
+}
+
+// playerName 返回对局一方的显示名称
+func playerName(rec *recordModel.GameRecord, id uint, importedName string) string {
+	if rec.GameType == recordModel.GameTypeImported {
+		return importedName
+	}
+	if id == 0 {
+		if rec.GameType == recordModel.GameTypeAI {
+			if rec.AIEngine != "" {
+				return "AI (" + rec.AIEngine + ")"
+			}
+			return fmt.Sprintf("AI (难度%d)", rec.AILevel)
+		}
+		return "未知玩家"
+	}
+	var u userModel.User
+	if err := database.GetMysqlDb().Select("id, name").First(&u, id).Error; err != nil || u.Name == "" {
+		return "未知玩家"
+	}
+	return u.Name
+}
+
+// recordGame 将对局记录转换为棋谱文件中的一局棋
+func recordGame(rec *recordModel.GameRecord) (*gamefile.Game, error) {
+	moves, _, err := replayRecord(rec)
+	if err != nil {
+		return nil, errors.New("棋谱无法复盘：" + err.Error())
+	}
+	g := &gamefile.Game{
+		Event:  gameTypeLabels[rec.GameType],
+		Date:   rec.StartTime,
+		Red:    playerName(rec, rec.RedID, rec.RedName),
+		Black:  playerName(rec, rec.BlackID, rec.BlackName),
+		Result: rec.Result,
+		Moves:  moves,
+		Extra:  map[string]string{"GameType": gameTypeLabels[rec.GameType]},
+	}
+	if rec.EndReason != "" {
+		g.Extra["Termination"] = rec.EndReason
+	}
+	return g, nil
+}
+
+// ExportPGN 将对局记录导出为象棋 PGN
+func (rs *RecordFileService) ExportPGN(req *recordDto.ExportRecordRequest) (*recordDto.ExportRecordResponse, error) {
+	format := notation.Chinese
+	if req.Notation != "" {
+		f, ok := notation.ParseFormat(req.Notation)
+		if !ok {
+			return nil, errors.New("不支持的记谱格式，可选 chinese、wxf、iccs")
+		}
+		format = f
+	}
+	rec, err := loadRecord(req.UserID, req.RecordID)
+	if err != nil {
+		return nil, err
+	}
+	g, err := recordGame(rec)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gamefile.WritePGN(g, format)
+	if err != nil {
+		return nil, err
+	}
+	contentType := "application/x-chess-pgn; charset=utf-8"
+	switch strings.ToLower(req.Charset) {
+	case "", "utf-8", "utf8":
+	case "gbk", "gb2312":
+		if data, err = simplifiedchinese.GBK.NewEncoder().Bytes(data); err != nil {
+			return nil, errors.New("棋谱包含无法用 GBK 编码的字符")
+		}
+		contentType = "application/x-chess-pgn; charset=gbk"
+	default:
+		return nil, errors.New("不支持的字符编码，可选 utf-8、gbk")
+	}
+	return &recordDto.ExportRecordResponse{
+		Filename:    fmt.Sprintf("game_%d.pgn", rec.ID),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// ExportXQF 将对局记录导出为 XQF 二进制棋谱
+func (rs *RecordFileService) ExportXQF(req *recordDto.ExportRecordRequest) (*recordDto.ExportRecordResponse, error) {
+	rec, err := loadRecord(req.UserID, req.RecordID)
+	if err != nil {
+		return nil, err
+	}
+	g, err := recordGame(rec)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gamefile.WriteXQF(g)
+	if err != nil {
+		return nil, err
+	}
+	return &recordDto.ExportRecordResponse{
+		Filename:    fmt.Sprintf("game_%d.xqf", rec.ID),
+		ContentType: "application/octet-stream",
+		Data:        data,
+	}, nil
+}
+
+// ImportPGN 解析 PGN 并保存为当前用户导入的对局记录
+func (rs *RecordFileService) ImportPGN(userID int, data []byte) (*recordDto.ImportRecordResponse, error) {
+	g, err := gamefile.ParsePGN(data)
+	if err != nil {
+		return nil, errors.New("PGN 解析失败：" + err.Error())
+	}
+	return saveImportedGame(userID, g)
+}
+
+// ImportXQF 解析 XQF 并保存为当前用户导入的对局记录
+func (rs *RecordFileService) ImportXQF(userID int, data []byte) (*recordDto.ImportRecordResponse, error) {
+	g, err := gamefile.ParseXQF(data)
+	if err != nil {
+		return nil, errors.New("XQF 解析失败：" + err.Error())
+	}
+	return saveImportedGame(userID, g)
+}
+
+// saveImportedGame 保存导入的棋谱；棋谱未注明结果时按终局局面判定，仍无法判定则拒绝导入
+func saveImportedGame(userID int, g *gamefile.Game) (*recordDto.ImportRecordResponse, error) {
+	if len(g.Moves) == 0 {
+		return nil, errors.New("棋谱中没有任何着法")
+	}
+	boards, err := xiangqi.Replay(xiangqi.NewBoard(), g.Moves)
+	if err != nil {
+		return nil, err
+	}
+	final := boards[len(boards)-1]
+
+	endReason := ""
+	switch final.Status() {
+	case xiangqi.Checkmate:
+		endReason = recordModel.EndReasonCheckmate
+	case xiangqi.Stalemate:
+		endReason = recordModel.EndReasonStalemate
+	}
+	result := g.Result
+	if endReason != "" {
+		// 终局已分胜负时以规则判定为准
+		result = gamefile.ResultRedWin
+		if final.Winner() == xiangqi.Black {
+			result = gamefile.ResultBlackWin
+		}
+	}
+	if result == gamefile.ResultUnknown {
+		return nil, errors.New("棋谱未注明对局结果")
+	}
+
+	red, black := strings.TrimSpace(g.Red), strings.TrimSpace(g.Black)
+	if red == "" {
+		red = "红方"
+	}
+	if black == "" {
+		black = "黑方"
+	}
+	start := g.Date
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	rec := recordModel.GameRecord{
+		StartTime:  start,
+		Result:     result,
+		History:    xiangqi.FormatHistory(g.Moves),
+		GameType:   recordModel.GameTypeImported,
+		EndReason:  endReason,
+		UploaderID: uint(userID),
+		RedName:    truncateRunes(red, 64),
+		BlackName:  truncateRunes(black, 64),
+	}
+	rec.Ecco = RecordOpening(&rec)
+	if err := database.GetMysqlDb().Create(&rec).Error; err != nil {
+		return nil, errors.New("保存对局记录失败")
+	}
+	return &recordDto.ImportRecordResponse{
+		RecordID:   rec.ID,
+		Red:        rec.RedName,
+		Black:      rec.BlackName,
+		Result:     rec.Result,
+		TotalSteps: len(g.Moves),
+		EndReason:  rec.EndReason,
+	}, nil
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
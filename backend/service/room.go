@@ -16,6 +16,7 @@ func (rs *RoomService) GetSpareRooms(req room.GetSpareRoomsRequest) (room.GetSpa
     db := database.GetMysqlDb()
 	rooms := req.Infos
 	var resp room.GetSpareRoomsResponse
+	resp.Queues = req.Queues
     
     // Collect all user IDs that need to be fetched
     var userIDs []uint
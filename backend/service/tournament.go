@@ -0,0 +1,657 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"chinese-chess-backend/database"
+	tournamentDto "chinese-chess-backend/dto/tournament"
+	tournamentModel "chinese-chess-backend/model/tournament"
+	userModel "chinese-chess-backend/model/user"
+	"chinese-chess-backend/tournament"
+)
+
+type TournamentService struct {
+}
+
+func NewTournamentService() *TournamentService {
+	return &TournamentService{}
+}
+
+// TournamentGame 已领取、等待创建对局房间的对阵
+type TournamentGame struct {
+	Pairing    tournamentModel.Pairing
+	Tournament tournamentModel.Tournament
+}
+
+// listLimit 比赛列表最多返回的条数
+const listLimit = 50
+
+// Create 创建比赛，创建者不自动报名
+func (ts *TournamentService) Create(req *tournamentDto.CreateTournamentRequest) (*tournamentDto.TournamentItem, error) {
+	if req.StartAt != nil && !req.StartAt.After(time.Now()) {
+		return nil, errors.New("开赛时间须晚于当前时间")
+	}
+	t := tournamentModel.Tournament{
+		Name:          req.Name,
+		CreatorID:     uint(req.UserID),
+		Format:        req.Format,
+		Status:        tournamentModel.StatusOpen,
+		MaxPlayers:    req.MaxPlayers,
+		Rated:         req.Rated,
+		TimeBase:      req.TimeBase,
+		TimeIncrement: req.TimeIncrement,
+		TimeByoyomi:   req.TimeByoyomi,
+		StartAt:       req.StartAt,
+	}
+	if t.Format == tournamentModel.FormatSwiss {
+		t.Rounds = req.Rounds
+	}
+	if err := database.GetMysqlDb().Create(&t).Error; err != nil {
+		return nil, errors.New("创建比赛失败")
+	}
+	items, err := ts.items([]tournamentModel.Tournament{t}, uint(req.UserID))
+	if err != nil {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// List 按创建时间倒序返回比赛
+func (ts *TournamentService) List(req *tournamentDto.ListTournamentsRequest) (*tournamentDto.ListTournamentsResponse, error) {
+	q := database.GetMysqlDb().Model(&tournamentModel.Tournament{})
+	if req.Status != "" {
+		q = q.Where("status = ?", req.Status)
+	}
+	if req.Mine {
+		q = q.Where("id IN (?)", database.GetMysqlDb().Model(&tournamentModel.Player{}).
+			Select("tournament_id").Where("user_id = ?", req.UserID))
+	}
+	var list []tournamentModel.Tournament
+	if err := q.Order("id DESC").Limit(listLimit).Find(&list).Error; err != nil {
+		return nil, errors.New("查询比赛失败")
+	}
+	items, err := ts.items(list, uint(req.UserID))
+	if err != nil {
+		return nil, err
+	}
+	return &tournamentDto.ListTournamentsResponse{Tournaments: items}, nil
+}
+
+// Detail 比赛详情：名次表与各轮对阵
+func (ts *TournamentService) Detail(id uint, userID int) (*tournamentDto.TournamentDetail, error) {
+	db := database.GetMysqlDb()
+	t, err := loadTournament(db, id, false)
+	if err != nil {
+		return nil, err
+	}
+	items, err := ts.items([]tournamentModel.Tournament{*t}, uint(userID))
+	if err != nil {
+		return nil, err
+	}
+	players, err := seededPlayers(db, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	var pairings []tournamentModel.Pairing
+	if err := db.Where("tournament_id = ?", t.ID).Order("round, board").Find(&pairings).Error; err != nil {
+		return nil, errors.New("查询对阵失败")
+	}
+	ids := make([]uint, 0, len(players))
+	for _, p := range players {
+		ids = append(ids, p.UserID)
+	}
+	names, err := userNames(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &tournamentDto.TournamentDetail{
+		Tournament: items[0],
+		Standings:  make([]tournamentDto.StandingItem, 0, len(players)),
+		Rounds:     []tournamentDto.RoundItem{},
+	}
+	byUser := make(map[uint]*tournamentModel.Player, len(players))
+	for i := range players {
+		byUser[players[i].UserID] = &players[i]
+	}
+	for _, s := range tournament.Standings(ids, finishedGames(pairings)) {
+		p := byUser[s.ID]
+		detail.Standings = append(detail.Standings, tournamentDto.StandingItem{
+			Rank:            s.Rank,
+			UserID:          s.ID,
+			Name:            names[s.ID],
+			Seed:            p.Seed,
+			Rating:          p.Rating,
+			Score:           s.Score,
+			Buchholz:        s.Buchholz,
+			SonnebornBerger: s.SonnebornBerger,
+			Played:          s.Played,
+			Wins:            s.Wins,
+			Draws:           s.Draws,
+			Losses:          s.Losses,
+		})
+	}
+	for _, p := range pairings {
+		if n := len(detail.Rounds); n == 0 || detail.Rounds[n-1].Round != p.Round {
+			detail.Rounds = append(detail.Rounds, tournamentDto.RoundItem{Round: p.Round, Pairings: []tournamentDto.PairingItem{}})
+		}
+		round := &detail.Rounds[len(detail.Rounds)-1]
+		round.Pairings = append(round.Pairings, tournamentDto.PairingItem{
+			ID:           p.ID,
+			Board:        p.Board,
+			RedID:        p.RedID,
+			RedName:      names[p.RedID],
+			BlackID:      p.BlackID,
+			BlackName:    names[p.BlackID],
+			Status:       p.Status,
+			RoomID:       p.RoomID,
+			Deadline:     p.Deadline,
+			RedScore:     p.RedScore,
+			BlackScore:   p.BlackScore,
+			Bye:          p.BlackID == 0,
+			Forfeit:      p.Forfeit,
+			GameRecordID: p.GameRecordID,
+		})
+	}
+	return detail, nil
+}
+
+// Join 报名参赛，报名时的等级分用于开赛时排定种子
+func (ts *TournamentService) Join(id uint, userID int) error {
+	rating := NewRatingService().DisplayRating(uint(userID))
+	return database.GetMysqlDb().Transaction(func(tx *gorm.DB) error {
+		t, err := loadTournament(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if t.Status != tournamentModel.StatusOpen {
+			return errors.New("比赛已不在报名阶段")
+		}
+		var count int64
+		if err := tx.Model(&tournamentModel.Player{}).Where("tournament_id = ?", id).Count(&count).Error; err != nil {
+			return errors.New("查询参赛棋手失败")
+		}
+		var joined int64
+		tx.Model(&tournamentModel.Player{}).Where("tournament_id = ? AND user_id = ?", id, userID).Count(&joined)
+		if joined > 0 {
+			return errors.New("已报名该比赛")
+		}
+		if int(count) >= t.MaxPlayers {
+			return errors.New("报名人数已满")
+		}
+		p := tournamentModel.Player{TournamentID: id, UserID: uint(userID), Rating: rating}
+		if err := tx.Create(&p).Error; err != nil {
+			return errors.New("报名失败")
+		}
+		return nil
+	})
+}
+
+// Leave 开赛前退出比赛
+func (ts *TournamentService) Leave(id uint, userID int) error {
+	return database.GetMysqlDb().Transaction(func(tx *gorm.DB) error {
+		t, err := loadTournament(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if t.Status != tournamentModel.StatusOpen {
+			return errors.New("比赛已开始，不能退出")
+		}
+		res := tx.Where("tournament_id = ? AND user_id = ?", id, userID).Delete(&tournamentModel.Player{})
+		if res.Error != nil {
+			return errors.New("退出比赛失败")
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("未报名该比赛")
+		}
+		return nil
+	})
+}
+
+// Start 创建者手动开赛
+func (ts *TournamentService) Start(id uint, userID int) error {
+	t, err := loadTournament(database.GetMysqlDb(), id, false)
+	if err != nil {
+		return err
+	}
+	if t.CreatorID != uint(userID) {
+		return errors.New("只有创建者可以开赛")
+	}
+	if t.Status != tournamentModel.StatusOpen {
+		return errors.New("比赛已不在报名阶段")
+	}
+	var count int64
+	database.GetMysqlDb().Model(&tournamentModel.Player{}).Where("tournament_id = ?", id).Count(&count)
+	if count < tournamentDto.MinPlayers {
+		return errors.New("参赛人数不足，至少需要 2 人")
+	}
+	return startTournament(t)
+}
+
+// Cancel 创建者在开赛前取消比赛
+func (ts *TournamentService) Cancel(id uint, userID int) error {
+	t, err := loadTournament(database.GetMysqlDb(), id, false)
+	if err != nil {
+		return err
+	}
+	if t.CreatorID != uint(userID) {
+		return errors.New("只有创建者可以取消比赛")
+	}
+	res := database.GetMysqlDb().Model(&tournamentModel.Tournament{}).
+		Where("id = ? AND status = ?", id, tournamentModel.StatusOpen).
+		Update("status", tournamentModel.StatusCancelled)
+	if res.Error != nil {
+		return errors.New("取消比赛失败")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("比赛已不在报名阶段")
+	}
+	return nil
+}
+
+// Advance 到达开赛时间的比赛自动开赛，本轮全部结束的比赛编排下一轮或结束比赛
+// 由对局服务定期调用；状态变更均以条件更新完成，多个实例同时调用也只生效一次
+func (ts *TournamentService) Advance(now time.Time) error {
+	db := database.GetMysqlDb()
+	var due []tournamentModel.Tournament
+	if err := db.Where("status = ? AND start_at IS NOT NULL AND start_at <= ?", tournamentModel.StatusOpen, now).
+		Find(&due).Error; err != nil {
+		return err
+	}
+	for i := range due {
+		if err := startTournament(&due[i]); err != nil {
+			log.Printf("start tournament %d failed: %v", due[i].ID, err)
+		}
+	}
+
+	var running []tournamentModel.Tournament
+	if err := db.Where("status = ?", tournamentModel.StatusRunning).Find(&running).Error; err != nil {
+		return err
+	}
+	for i := range running {
+		t := &running[i]
+		var unfinished int64
+		if err := db.Model(&tournamentModel.Pairing{}).
+			Where("tournament_id = ? AND round = ? AND status <> ?", t.ID, t.CurrentRound, tournamentModel.PairingFinished).
+			Count(&unfinished).Error; err != nil {
+			return err
+		}
+		if unfinished > 0 {
+			continue
+		}
+		if err := nextRound(t, now); err != nil {
+			log.Printf("advance tournament %d failed: %v", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// ClaimPairings 领取等待创建房间的对阵，领取后进入待入场状态，deadline 为入场截止时间
+func (ts *TournamentService) ClaimPairings(deadline time.Time) ([]TournamentGame, error) {
+	db := database.GetMysqlDb()
+	var pending []tournamentModel.Pairing
+	if err := db.Where("status = ?", tournamentModel.PairingPending).Order("id").Limit(listLimit).Find(&pending).Error; err != nil {
+		return nil, err
+	}
+	tournaments := make(map[uint]*tournamentModel.Tournament)
+	games := make([]TournamentGame, 0, len(pending))
+	for _, p := range pending {
+		res := db.Model(&tournamentModel.Pairing{}).
+			Where("id = ? AND status = ?", p.ID, tournamentModel.PairingPending).
+			Updates(map[string]any{"status": tournamentModel.PairingReady, "deadline": deadline})
+		if res.Error != nil {
+			return games, res.Error
+		}
+		if res.RowsAffected == 0 {
+			// 已被其他实例领取
+			continue
+		}
+		t, ok := tournaments[p.TournamentID]
+		if !ok {
+			var err error
+			if t, err = loadTournament(db, p.TournamentID, false); err != nil {
+				return games, err
+			}
+			tournaments[p.TournamentID] = t
+		}
+		p.Status, p.Deadline = tournamentModel.PairingReady, &deadline
+		games = append(games, TournamentGame{Pairing: p, Tournament: *t})
+	}
+	return games, nil
+}
+
+// AttachRoom 记录对阵的对局房间，供棋手从比赛详情进入
+func (ts *TournamentService) AttachRoom(pairingID uint, roomID int) error {
+	return database.GetMysqlDb().Model(&tournamentModel.Pairing{}).Where("id = ?", pairingID).
+		Update("room_id", roomID).Error
+}
+
+// PairingStarted 双方入场、对局开始
+func (ts *TournamentService) PairingStarted(pairingID uint) error {
+	return database.GetMysqlDb().Model(&tournamentModel.Pairing{}).
+		Where("id = ? AND status = ?", pairingID, tournamentModel.PairingReady).
+		Update("status", tournamentModel.PairingPlaying).Error
+}
+
+// RecordGame 记录对阵的结果并关联对局记录，result 取值同 GameRecord.Result；recordID 为 0 表示对局记录保存失败
+func (ts *TournamentService) RecordGame(pairingID, recordID uint, result int) error {
+	redScore, blackScore := 0.5, 0.5
+	switch result {
+	case 0:
+		redScore, blackScore = 1, 0
+	case 1:
+		redScore, blackScore = 0, 1
+	}
+	updates := map[string]any{
+		"status":      tournamentModel.PairingFinished,
+		"red_score":   redScore,
+		"black_score": blackScore,
+	}
+	if recordID > 0 {
+		updates["game_record_id"] = recordID
+	}
+	return database.GetMysqlDb().Model(&tournamentModel.Pairing{}).
+		Where("id = ? AND status IN ?", pairingID, []string{tournamentModel.PairingReady, tournamentModel.PairingPlaying}).
+		Updates(updates).Error
+}
+
+// ReplayPairing 对局因停机被判和时不计结果，对阵退回待编排状态，重启后重新创建房间
+func (ts *TournamentService) ReplayPairing(pairingID uint) error {
+	return database.GetMysqlDb().Model(&tournamentModel.Pairing{}).
+		Where("id = ? AND status IN ?", pairingID, []string{tournamentModel.PairingReady, tournamentModel.PairingPlaying}).
+		Updates(map[string]any{"status": tournamentModel.PairingPending, "room_id": 0, "deadline": nil}).Error
+}
+
+// ExpiredPairings 超过入场截止时间仍未开局的对阵
+func (ts *TournamentService) ExpiredPairings(now time.Time) ([]tournamentModel.Pairing, error) {
+	var pairings []tournamentModel.Pairing
+	err := database.GetMysqlDb().Where("status = ? AND deadline < ?", tournamentModel.PairingReady, now).
+		Order("id").Find(&pairings).Error
+	return pairings, err
+}
+
+// ForfeitPairing 按时到场的一方不战而胜，present 为 0 表示双方均未到场，双方都记负
+func (ts *TournamentService) ForfeitPairing(p *tournamentModel.Pairing, present uint) error {
+	var redScore, blackScore float64
+	switch present {
+	case p.RedID:
+		redScore = 1
+	case p.BlackID:
+		blackScore = 1
+	}
+	return database.GetMysqlDb().Model(&tournamentModel.Pairing{}).
+		Where("id = ? AND status = ?", p.ID, tournamentModel.PairingReady).
+		Updates(map[string]any{
+			"status":      tournamentModel.PairingFinished,
+			"red_score":   redScore,
+			"black_score": blackScore,
+			"forfeit":     true,
+		}).Error
+}
+
+// startTournament 开赛：按报名时的等级分排定种子并编排第一轮，人数不足时取消比赛
+func startTournament(t *tournamentModel.Tournament) error {
+	return database.GetMysqlDb().Transaction(func(tx *gorm.DB) error {
+		locked, err := loadTournament(tx, t.ID, true)
+		if err != nil {
+			return err
+		}
+		if locked.Status != tournamentModel.StatusOpen {
+			return nil
+		}
+		var players []tournamentModel.Player
+		if err := tx.Where("tournament_id = ?", t.ID).Order("rating DESC, id").Find(&players).Error; err != nil {
+			return err
+		}
+		if len(players) < tournamentDto.MinPlayers {
+			return tx.Model(locked).Update("status", tournamentModel.StatusCancelled).Error
+		}
+		for i := range players {
+			players[i].Seed = i + 1
+			if err := tx.Model(&players[i]).Update("seed", players[i].Seed).Error; err != nil {
+				return err
+			}
+		}
+		rounds := tournament.RoundRobinRounds(len(players))
+		if locked.Format == tournamentModel.FormatSwiss {
+			// 轮数超过单循环时无法避免重复交手
+			rounds = min(locked.Rounds, rounds)
+		}
+		now := time.Now()
+		locked.Status, locked.Rounds, locked.CurrentRound, locked.StartedAt = tournamentModel.StatusRunning, rounds, 1, &now
+		if err := tx.Model(locked).Select("status", "rounds", "current_round", "started_at").Updates(locked).Error; err != nil {
+			return err
+		}
+		return pairRound(tx, locked, players, nil)
+	})
+}
+
+// nextRound 本轮全部结束后编排下一轮，最后一轮结束时结束比赛
+func nextRound(t *tournamentModel.Tournament, now time.Time) error {
+	return database.GetMysqlDb().Transaction(func(tx *gorm.DB) error {
+		locked, err := loadTournament(tx, t.ID, true)
+		if err != nil {
+			return err
+		}
+		if locked.Status != tournamentModel.StatusRunning || locked.CurrentRound != t.CurrentRound {
+			// 已由其他实例推进
+			return nil
+		}
+		if locked.CurrentRound >= locked.Rounds {
+			return tx.Model(locked).Updates(map[string]any{
+				"status":      tournamentModel.StatusFinished,
+				"finished_at": now,
+			}).Error
+		}
+		locked.CurrentRound++
+		if err := tx.Model(locked).Update("current_round", locked.CurrentRound).Error; err != nil {
+			return err
+		}
+		players, err := seededPlayers(tx, locked.ID)
+		if err != nil {
+			return err
+		}
+		var played []tournamentModel.Pairing
+		if err := tx.Where("tournament_id = ?", locked.ID).Find(&played).Error; err != nil {
+			return err
+		}
+		return pairRound(tx, locked, players, played)
+	})
+}
+
+// pairRound 编排当前轮：单循环按种子查贝格尔轮转表，瑞士制按已有成绩配对
+// 轮空直接记为结束，瑞士制轮空得 1 分，单循环轮空不得分
+func pairRound(tx *gorm.DB, t *tournamentModel.Tournament, players []tournamentModel.Player, played []tournamentModel.Pairing) error {
+	var pairs []tournament.Pair
+	if t.Format == tournamentModel.FormatRoundRobin {
+		ids := make([]uint, 0, len(players))
+		for _, p := range players {
+			ids = append(ids, p.UserID)
+		}
+		pairs = tournament.RoundRobin(ids, t.CurrentRound)
+	} else {
+		pairs = tournament.Swiss(swissPlayers(players, played))
+	}
+	rows := make([]tournamentModel.Pairing, 0, len(pairs))
+	for i, pair := range pairs {
+		p := tournamentModel.Pairing{
+			TournamentID: t.ID,
+			Round:        t.CurrentRound,
+			Board:        i + 1,
+			RedID:        pair.Red,
+			BlackID:      pair.Black,
+			Status:       tournamentModel.PairingPending,
+		}
+		if pair.Black == 0 {
+			p.Status = tournamentModel.PairingFinished
+			if t.Format == tournamentModel.FormatSwiss {
+				p.RedScore = 1
+			}
+		}
+		rows = append(rows, p)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+// swissPlayers 由已结束的对阵统计瑞士制配对所需的积分、对手与先后手
+func swissPlayers(players []tournamentModel.Player, played []tournamentModel.Pairing) []tournament.SwissPlayer {
+	sp := make([]tournament.SwissPlayer, len(players))
+	index := make(map[uint]*tournament.SwissPlayer, len(players))
+	for i, p := range players {
+		sp[i] = tournament.SwissPlayer{ID: p.UserID, Seed: p.Seed, Opponents: map[uint]bool{}}
+		index[p.UserID] = &sp[i]
+	}
+	// 按轮次先后统计，LastRed 取最后一局的先后手
+	ordered := make([]tournamentModel.Pairing, len(played))
+	copy(ordered, played)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Round < ordered[j].Round })
+	for _, p := range ordered {
+		if p.Status != tournamentModel.PairingFinished {
+			continue
+		}
+		red, black := index[p.RedID], index[p.BlackID]
+		if red != nil {
+			red.Score += p.RedScore
+		}
+		if p.BlackID == 0 {
+			if red != nil {
+				red.HadBye = true
+			}
+			continue
+		}
+		if black != nil {
+			black.Score += p.BlackScore
+		}
+		if red == nil || black == nil {
+			continue
+		}
+		red.Opponents[black.ID], black.Opponents[red.ID] = true, true
+		red.ColorDiff++
+		black.ColorDiff--
+		red.LastRed, black.LastRed = true, false
+	}
+	return sp
+}
+
+// finishedGames 已结束的对阵，用于计算名次
+func finishedGames(pairings []tournamentModel.Pairing) []tournament.Game {
+	games := make([]tournament.Game, 0, len(pairings))
+	for _, p := range pairings {
+		if p.Status != tournamentModel.PairingFinished {
+			continue
+		}
+		games = append(games, tournament.Game{Red: p.RedID, Black: p.BlackID, RedScore: p.RedScore, BlackScore: p.BlackScore})
+	}
+	return games
+}
+
+// items 构造比赛概要，附上报名人数、创建者名称与当前用户是否已报名
+func (ts *TournamentService) items(list []tournamentModel.Tournament, userID uint) ([]tournamentDto.TournamentItem, error) {
+	items := make([]tournamentDto.TournamentItem, 0, len(list))
+	if len(list) == 0 {
+		return items, nil
+	}
+	db := database.GetMysqlDb()
+	ids := make([]uint, 0, len(list))
+	creators := make([]uint, 0, len(list))
+	for _, t := range list {
+		ids = append(ids, t.ID)
+		creators = append(creators, t.CreatorID)
+	}
+	var counts []struct {
+		TournamentID uint
+		Players      int
+	}
+	if err := db.Model(&tournamentModel.Player{}).Select("tournament_id, COUNT(*) AS players").
+		Where("tournament_id IN ?", ids).Group("tournament_id").Scan(&counts).Error; err != nil {
+		return nil, errors.New("查询参赛棋手失败")
+	}
+	players := make(map[uint]int, len(counts))
+	for _, c := range counts {
+		players[c.TournamentID] = c.Players
+	}
+	var joinedIDs []uint
+	if err := db.Model(&tournamentModel.Player{}).Where("tournament_id IN ? AND user_id = ?", ids, userID).
+		Pluck("tournament_id", &joinedIDs).Error; err != nil {
+		return nil, errors.New("查询参赛棋手失败")
+	}
+	joined := make(map[uint]bool, len(joinedIDs))
+	for _, id := range joinedIDs {
+		joined[id] = true
+	}
+	names, err := userNames(creators)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range list {
+		items = append(items, tournamentDto.TournamentItem{
+			ID:            t.ID,
+			Name:          t.Name,
+			Format:        t.Format,
+			Status:        t.Status,
+			CreatorID:     t.CreatorID,
+			CreatorName:   names[t.CreatorID],
+			Players:       players[t.ID],
+			MaxPlayers:    t.MaxPlayers,
+			Rounds:        t.Rounds,
+			CurrentRound:  t.CurrentRound,
+			Rated:         t.Rated,
+			TimeBase:      t.TimeBase,
+			TimeIncrement: t.TimeIncrement,
+			TimeByoyomi:   t.TimeByoyomi,
+			StartAt:       t.StartAt,
+			Joined:        joined[t.ID],
+		})
+	}
+	return items, nil
+}
+
+func loadTournament(tx *gorm.DB, id uint, lock bool) (*tournamentModel.Tournament, error) {
+	q := tx
+	if lock {
+		q = q.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var t tournamentModel.Tournament
+	err := q.First(&t, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("比赛不存在")
+	}
+	if err != nil {
+		return nil, errors.New("查询比赛失败")
+	}
+	return &t, nil
+}
+
+// seededPlayers 按种子顺序返回参赛棋手，开赛前按报名时的等级分排列
+func seededPlayers(tx *gorm.DB, tournamentID uint) ([]tournamentModel.Player, error) {
+	var players []tournamentModel.Player
+	if err := tx.Where("tournament_id = ?", tournamentID).Order("seed, rating DESC, id").Find(&players).Error; err != nil {
+		return nil, errors.New("查询参赛棋手失败")
+	}
+	return players, nil
+}
+
+// userNames 批量查询用户名称
+func userNames(ids []uint) (map[uint]string, error) {
+	names := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	var users []userModel.User
+	if err := database.GetMysqlDb().Model(&userModel.User{}).Select("id, name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, errors.New("查询用户信息失败")
+	}
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+	return names, nil
+}
@@ -5,6 +5,8 @@ import (
 	dto "chinese-chess-backend/dto/user"
 	recordModel "chinese-chess-backend/model/record"
 	userModel "chinese-chess-backend/model/user"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/opening"
 	"chinese-chess-backend/utils"
 	"os"
 	"time"
@@ -39,11 +41,32 @@ func (us *UserService) AddUserExp(userID int, delta int) error {
 	return db.Save(&u).Error
 }
 
+// AIGameExp 人机对战的经验值：简单(<=2)：赢+5 输+1；中等(3-4)：赢+20 输+5；困难(>=5)：赢+30 输+10；和棋不加经验
+func AIGameExp(level int, won bool) int {
+	switch {
+	case level <= 2:
+		if won {
+			return 5
+		}
+		return 1
+	case level <= 4:
+		if won {
+			return 20
+		}
+		return 5
+	default:
+		if won {
+			return 30
+		}
+		return 10
+	}
+}
+
 // UpdateUserStats 重新计算并更新用户的总场次与胜率
 // 统计规则：
 // - 本地对战不入库，天然不计入
 // - 人机对战（game_type=1）：仅当分出胜负（result=0或1）才计入场次；和棋不计入场次
-// - 随机匹配（game_type=0）：一局结束即计入场次（含和棋）
+// - 随机匹配（game_type=0）、好友对战（game_type=2）与比赛对局（game_type=5）：一局结束即计入场次（含和棋）
 // - 胜率基于胜/负计算：wins / (wins + losses) * 100，和棋不影响分母
 func (us *UserService) UpdateUserStats(userID int) error {
 	db := database.GetMysqlDb()
@@ -54,33 +77,33 @@ func (us *UserService) UpdateUserStats(userID int) error {
 	var randomFriendDraws int64
 	var aiWinLoss int64
 
-	// 胜场：用户为红且result=0，或用户为黑且result=1；统计随机匹配、好友、人机
+	// 胜场：用户为红且result=0，或用户为黑且result=1；统计随机匹配、好友、人机、比赛
 	if err := db.Model(&recordModel.GameRecord{}).
-		Where("game_type IN ?", []int{0, 1, 2}).
+		Where("game_type IN ?", []int{0, 1, 2, 5}).
 		Where("(red_id = ? AND result = 0) OR (black_id = ? AND result = 1)", userID, userID).
 		Count(&wins).Error; err != nil {
 		return err
 	}
 
-	// 负场：用户为红且result=1，或用户为黑且result=0；统计随机匹配、好友、人机
+	// 负场：用户为红且result=1，或用户为黑且result=0；统计随机匹配、好友、人机、比赛
 	if err := db.Model(&recordModel.GameRecord{}).
-		Where("game_type IN ?", []int{0, 1, 2}).
+		Where("game_type IN ?", []int{0, 1, 2, 5}).
 		Where("(red_id = ? AND result = 1) OR (black_id = ? AND result = 0)", userID, userID).
 		Count(&losses).Error; err != nil {
 		return err
 	}
 
-	// 随机匹配 + 好友对战总局数：包含和棋
+	// 随机匹配 + 好友对战 + 比赛总局数：包含和棋
 	if err := db.Model(&recordModel.GameRecord{}).
-		Where("game_type IN ?", []int{0, 2}).
+		Where("game_type IN ?", []int{0, 2, 5}).
 		Where("red_id = ? OR black_id = ?", userID, userID).
 		Count(&randomFriendAll).Error; err != nil {
 		return err
 	}
 
-	// 随机匹配 + 好友对战的和棋数
+	// 随机匹配 + 好友对战 + 比赛的和棋数
 	if err := db.Model(&recordModel.GameRecord{}).
-		Where("game_type IN ?", []int{0, 2}).
+		Where("game_type IN ?", []int{0, 2, 5}).
 		Where("red_id = ? OR black_id = ?", userID, userID).
 		Where("result = 2").
 		Count(&randomFriendDraws).Error; err != nil {
@@ -395,8 +418,8 @@ func (us *UserService) GetGameRecords(req *dto.GetGameRecordsRequest) (*dto.GetG
 	var records []recordModel.GameRecord
 	var response dto.GetGameRecordsResponse
 
-	// 查询该用户的所有对局记录（作为红方或黑方）
-	if err := db.Where("red_id = ? OR black_id = ?", req.UserID, req.UserID).
+	// 查询该用户的所有对局记录（作为红方或黑方，或自己导入的棋谱）
+	if err := db.Where("red_id = ? OR black_id = ? OR uploader_id = ?", req.UserID, req.UserID, req.UserID).
 		Order("start_time DESC").
 		Find(&records).Error; err != nil {
 		return nil, errors.New("查询对局记录失败")
@@ -476,6 +499,15 @@ func (us *UserService) GetGameRecords(req *dto.GetGameRecordsRequest) (*dto.GetG
 		}
 
 		opponentName := opponentMap[opponentID]
+		if record.GameType == recordModel.GameTypeImported {
+			// 导入的棋谱不区分上传者执哪一方，按红方视角展示
+			isRed = true
+			opponentName = record.RedName + " vs " + record.BlackName
+			result = 2
+			if record.Result == 0 || record.Result == 1 {
+				result = record.Result
+			}
+		}
 		// 人机对战时，对手 ID 为 0，显示 AI 难度
 		if record.GameType == 1 && opponentID == 0 {
 			var levelLabel string
@@ -488,6 +520,9 @@ func (us *UserService) GetGameRecords(req *dto.GetGameRecordsRequest) (*dto.GetG
 				levelLabel = "困难"
 			}
 			opponentName = "AI (" + levelLabel + ")"
+			if record.AIEngine != "" {
+				opponentName = "AI (" + record.AIEngine + ")"
+			}
 		} else if opponentName == "" {
 			opponentName = "未知玩家"
 		}
@@ -503,6 +538,12 @@ func (us *UserService) GetGameRecords(req *dto.GetGameRecordsRequest) (*dto.GetG
 			History:      record.History,
 			StartTime:    record.StartTime,
 			AILevel:      record.AILevel,
+			Ecco:         record.Ecco,
+			OpeningName:  opening.Default().Name(record.Ecco),
+			TournamentID: record.TournamentID,
+		}
+		if req.Notation != "" {
+			item.Moves = renderRecordMoves(&record, notation.Format(req.Notation))
 		}
 
 		response.Records = append(response.Records, item)
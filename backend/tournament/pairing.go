@@ -0,0 +1,151 @@
+// Package tournament 比赛编排：循环赛（贝格尔轮转）与瑞士制配对，以及按布赫霍尔茨、索内本-伯格分排列的名次
+package tournament
+
+import "sort"
+
+// Pair 一台对阵，Black 为 0 表示 Red 轮空
+type Pair struct {
+	Red   uint
+	Black uint
+}
+
+// RoundRobinRounds n 人单循环的轮数，人数为奇数时每轮有一人轮空
+func RoundRobinRounds(n int) int {
+	if n%2 == 1 {
+		return n
+	}
+	return n - 1
+}
+
+// RoundRobin 按贝格尔轮转法返回单循环第 round 轮（从 1 开始）的对阵，ids 按种子顺序排列
+// 人数为奇数时补一个轮空位，与之相遇的棋手本轮轮空
+func RoundRobin(ids []uint, round int) []Pair {
+	m := len(ids)
+	if m%2 == 1 {
+		m++
+	}
+	if round < 1 || round > m-1 {
+		return nil
+	}
+	seat := func(i int) uint {
+		if i < len(ids) {
+			return ids[i]
+		}
+		return 0
+	}
+	// 最后一位固定，其余位置每轮顺时针转动一格
+	order := make([]int, m)
+	for i := 0; i < m-1; i++ {
+		order[i] = (i + round - 1) % (m - 1)
+	}
+	order[m-1] = m - 1
+
+	pairs := make([]Pair, 0, m/2)
+	for i := 0; i < m/2; i++ {
+		a, b := seat(order[i]), seat(order[m-1-i])
+		// 固定位与第一台的棋手逐轮交换先后手，其余各台按台次交替
+		red := (i == 0 && round%2 == 1) || (i > 0 && i%2 == 0)
+		if !red {
+			a, b = b, a
+		}
+		switch {
+		case a == 0:
+			pairs = append(pairs, Pair{Red: b})
+		case b == 0:
+			pairs = append(pairs, Pair{Red: a})
+		default:
+			pairs = append(pairs, Pair{Red: a, Black: b})
+		}
+	}
+	return pairs
+}
+
+// SwissPlayer 瑞士制配对所需的棋手信息
+type SwissPlayer struct {
+	ID        uint
+	Score     float64
+	Seed      int           // 种子号，越小越靠前
+	Opponents map[uint]bool // 已经交手过的对手
+	ColorDiff int           // 执红局数减执黑局数
+	LastRed   bool          // 上一局是否执红
+	HadBye    bool          // 是否已轮空过
+}
+
+// swissBudget 回溯配对的最大尝试次数，超过后允许重复交手
+const swissBudget = 200000
+
+// Swiss 按瑞士制编排一轮：棋手按积分与种子排序后自上而下与尚未交过手的对手配对（Monrad 制），
+// 无法避免重复交手时放宽限制；人数为奇数时由排名最低且未轮空过的棋手轮空
+func Swiss(players []SwissPlayer) []Pair {
+	list := make([]SwissPlayer, len(players))
+	copy(list, players)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].Seed < list[j].Seed
+	})
+
+	var pairs []Pair
+	if len(list)%2 == 1 {
+		byeAt := len(list) - 1
+		for i := len(list) - 1; i >= 0; i-- {
+			if !list[i].HadBye {
+				byeAt = i
+				break
+			}
+		}
+		pairs = append(pairs, Pair{Red: list[byeAt].ID})
+		list = append(list[:byeAt], list[byeAt+1:]...)
+	}
+
+	budget := swissBudget
+	matched, ok := pairSwiss(list, false, &budget)
+	if !ok {
+		budget = swissBudget
+		matched, _ = pairSwiss(list, true, &budget)
+	}
+	// 轮空放在最后一台
+	return append(matched, pairs...)
+}
+
+// pairSwiss 为第一位棋手依次尝试排名靠前的对手，后续无法配完时回溯
+func pairSwiss(list []SwissPlayer, allowRematch bool, budget *int) ([]Pair, bool) {
+	if len(list) == 0 {
+		return []Pair{}, true
+	}
+	first := list[0]
+	for i := 1; i < len(list); i++ {
+		*budget--
+		if *budget < 0 {
+			return nil, false
+		}
+		if !allowRematch && first.Opponents[list[i].ID] {
+			continue
+		}
+		rest := make([]SwissPlayer, 0, len(list)-2)
+		rest = append(rest, list[1:i]...)
+		rest = append(rest, list[i+1:]...)
+		if tail, ok := pairSwiss(rest, allowRematch, budget); ok {
+			return append([]Pair{colors(first, list[i])}, tail...), true
+		}
+	}
+	return nil, false
+}
+
+// colors 分配先后手：执红较少的一方执红，相同时上一局执黑的一方执红，仍相同时排名靠前的一方执红
+func colors(a, b SwissPlayer) Pair {
+	switch {
+	case a.ColorDiff != b.ColorDiff:
+		if a.ColorDiff < b.ColorDiff {
+			return Pair{Red: a.ID, Black: b.ID}
+		}
+		return Pair{Red: b.ID, Black: a.ID}
+	case a.LastRed != b.LastRed:
+		if b.LastRed {
+			return Pair{Red: a.ID, Black: b.ID}
+		}
+		return Pair{Red: b.ID, Black: a.ID}
+	}
+	return Pair{Red: a.ID, Black: b.ID}
+}
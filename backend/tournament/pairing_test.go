@@ -0,0 +1,226 @@
+package tournament
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func seededIDs(n int) []uint {
+	ids := make([]uint, n)
+	for i := range ids {
+		ids[i] = uint(i + 1)
+	}
+	return ids
+}
+
+type pairKey struct{ a, b uint }
+
+func keyOf(a, b uint) pairKey {
+	if a > b {
+		a, b = b, a
+	}
+	return pairKey{a, b}
+}
+
+// checkRound 每名棋手在一轮中恰好出现一次（对阵或轮空），返回本轮轮空的棋手
+func checkRound(t *testing.T, ids []uint, pairs []Pair) (bye uint) {
+	t.Helper()
+	seen := make(map[uint]int)
+	for _, p := range pairs {
+		seen[p.Red]++
+		if p.Black == 0 {
+			if bye != 0 {
+				t.Errorf("two byes in one round: %d and %d", bye, p.Red)
+			}
+			bye = p.Red
+			continue
+		}
+		seen[p.Black]++
+	}
+	for _, id := range ids {
+		if seen[id] != 1 {
+			t.Errorf("player %d appears %d times in %v", id, seen[id], pairs)
+		}
+	}
+	if len(seen) != len(ids) {
+		t.Errorf("round %v has players outside %v", pairs, ids)
+	}
+	return bye
+}
+
+func TestRoundRobin(t *testing.T) {
+	for n := 2; n <= 11; n++ {
+		ids := seededIDs(n)
+		rounds := RoundRobinRounds(n)
+		if want := n - 1 + n%2; rounds != want {
+			t.Errorf("RoundRobinRounds(%d) = %d, want %d", n, rounds, want)
+		}
+		met := make(map[pairKey]int)
+		byes := make(map[uint]int)
+		reds := make(map[uint]int)
+		for round := 1; round <= rounds; round++ {
+			pairs := RoundRobin(ids, round)
+			if bye := checkRound(t, ids, pairs); bye != 0 {
+				byes[bye]++
+			}
+			for _, p := range pairs {
+				if p.Black != 0 {
+					met[keyOf(p.Red, p.Black)]++
+					reds[p.Red]++
+				}
+			}
+		}
+		// 任意两人恰好相遇一次
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				if c := met[keyOf(ids[i], ids[j])]; c != 1 {
+					t.Errorf("n=%d: %d and %d meet %d times", n, ids[i], ids[j], c)
+				}
+			}
+		}
+		// 奇数人数时每人恰好轮空一次
+		for _, id := range ids {
+			want := n % 2
+			if byes[id] != want {
+				t.Errorf("n=%d: player %d has %d byes, want %d", n, id, byes[id], want)
+			}
+			// 先后手尽量均衡
+			games := n - 1
+			if diff := 2*reds[id] - games; diff < -1 || diff > 1 {
+				t.Errorf("n=%d: player %d is red in %d of %d games", n, id, reds[id], games)
+			}
+		}
+		if RoundRobin(ids, 0) != nil || RoundRobin(ids, rounds+1) != nil {
+			t.Errorf("n=%d: rounds outside 1..%d should have no pairings", n, rounds)
+		}
+	}
+}
+
+func TestSwissFirstRound(t *testing.T) {
+	var players []SwissPlayer
+	for _, id := range seededIDs(7) {
+		players = append(players, SwissPlayer{ID: id, Seed: int(id)})
+	}
+	// 自上而下与相邻的棋手配对，排名最低的棋手轮空并放在最后一台
+	want := []Pair{{1, 2}, {3, 4}, {5, 6}, {7, 0}}
+	if got := Swiss(players); !reflect.DeepEqual(got, want) {
+		t.Errorf("Swiss = %v, want %v", got, want)
+	}
+}
+
+func TestSwissBye(t *testing.T) {
+	players := []SwissPlayer{
+		{ID: 1, Seed: 1, Score: 2},
+		{ID: 2, Seed: 2, Score: 1.5},
+		{ID: 3, Seed: 3, Score: 0.5, HadBye: true},
+		{ID: 4, Seed: 4, Score: 1},
+		{ID: 5, Seed: 5, Score: 0.5},
+	}
+	// 积分最低的是 3 与 5，3 已轮空过，5 按种子排在 3 之后
+	if bye := checkRound(t, seededIDs(5), Swiss(players)); bye != 5 {
+		t.Errorf("bye = %d, want 5", bye)
+	}
+	players[4].HadBye = true
+	// 3 与 5 都已轮空过，由其上的 4 轮空
+	if bye := checkRound(t, seededIDs(5), Swiss(players)); bye != 4 {
+		t.Errorf("bye = %d, want 4", bye)
+	}
+	for i := range players {
+		players[i].HadBye = true
+	}
+	// 所有人都轮空过时仍由排名最低的棋手轮空
+	if bye := checkRound(t, seededIDs(5), Swiss(players)); bye != 5 {
+		t.Errorf("bye = %d, want 5", bye)
+	}
+}
+
+func TestSwissColors(t *testing.T) {
+	tests := []struct {
+		a, b SwissPlayer
+		red  uint
+	}{
+		{SwissPlayer{ID: 1, Seed: 1}, SwissPlayer{ID: 2, Seed: 2}, 1},
+		{SwissPlayer{ID: 1, Seed: 1, ColorDiff: 1}, SwissPlayer{ID: 2, Seed: 2}, 2},
+		{SwissPlayer{ID: 1, Seed: 1, ColorDiff: -1}, SwissPlayer{ID: 2, Seed: 2, ColorDiff: 1}, 1},
+		{SwissPlayer{ID: 1, Seed: 1, LastRed: true}, SwissPlayer{ID: 2, Seed: 2}, 2},
+		{SwissPlayer{ID: 1, Seed: 1}, SwissPlayer{ID: 2, Seed: 2, LastRed: true}, 1},
+	}
+	for i, tt := range tests {
+		got := Swiss([]SwissPlayer{tt.a, tt.b})
+		if len(got) != 1 || got[0].Red != tt.red {
+			t.Errorf("case %d: Swiss = %v, want %d red", i, got, tt.red)
+		}
+	}
+}
+
+// perfectMatching 不重复交手时能否将 ids 全部配对
+func perfectMatching(ids []uint, met map[pairKey]bool) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for i := 1; i < len(ids); i++ {
+		if met[keyOf(ids[0], ids[i])] {
+			continue
+		}
+		rest := make([]uint, 0, len(ids)-2)
+		rest = append(rest, ids[1:i]...)
+		rest = append(rest, ids[i+1:]...)
+		if perfectMatching(rest, met) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSwissAvoidsRematches(t *testing.T) {
+	for n := 4; n <= 11; n++ {
+		for seed := int64(1); seed <= 20; seed++ {
+			rng := rand.New(rand.NewSource(seed*100 + int64(n)))
+			players := make([]SwissPlayer, n)
+			index := make(map[uint]int, n)
+			for i, id := range seededIDs(n) {
+				players[i] = SwissPlayer{ID: id, Seed: i + 1, Opponents: make(map[uint]bool)}
+				index[id] = i
+			}
+			met := make(map[pairKey]bool)
+			// 轮数超过单循环时必然重复交手，只验证无法避免时才重复
+			for round := 1; round <= n+1; round++ {
+				pairs := Swiss(players)
+				bye := checkRound(t, seededIDs(n), pairs)
+				var paired []uint
+				for _, id := range seededIDs(n) {
+					if id != bye {
+						paired = append(paired, id)
+					}
+				}
+				avoidable := perfectMatching(paired, met)
+				for _, p := range pairs {
+					if p.Black == 0 {
+						players[index[p.Red]].Score++
+						players[index[p.Red]].HadBye = true
+						continue
+					}
+					if avoidable && met[keyOf(p.Red, p.Black)] {
+						t.Fatalf("n=%d seed=%d round %d: %d and %d meet again in %v", n, seed, round, p.Red, p.Black, pairs)
+					}
+					met[keyOf(p.Red, p.Black)] = true
+					red, black := &players[index[p.Red]], &players[index[p.Black]]
+					red.Opponents[black.ID], black.Opponents[red.ID] = true, true
+					red.ColorDiff++
+					black.ColorDiff--
+					red.LastRed, black.LastRed = true, false
+					switch rng.Intn(3) {
+					case 0:
+						red.Score++
+					case 1:
+						red.Score += 0.5
+						black.Score += 0.5
+					default:
+						black.Score++
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package tournament
+
+import "sort"
+
+// Game 一台已结束的对阵，Black 为 0 表示轮空
+type Game struct {
+	Red        uint
+	Black      uint
+	RedScore   float64
+	BlackScore float64
+}
+
+// Standing 一名棋手的积分与对手分
+type Standing struct {
+	ID uint
+	// Score 积分：胜 1、和 0.5、负 0
+	Score float64
+	// Buchholz 布赫霍尔茨分：所有对手的积分之和
+	Buchholz float64
+	// SonnebornBerger 索内本-伯格分：战胜的对手积分之和加上战和的对手积分的一半
+	SonnebornBerger float64
+	Played          int // 实际交手的局数，不含轮空
+	Wins            int
+	Draws           int
+	Losses          int
+	Rank            int
+}
+
+// Standings 计算名次：依次比较积分、布赫霍尔茨分、索内本-伯格分，仍相同时种子靠前者在前
+// ids 按种子顺序排列；轮空只计积分，不计入对手分
+func Standings(ids []uint, games []Game) []Standing {
+	index := make(map[uint]int, len(ids))
+	rows := make([]Standing, len(ids))
+	for i, id := range ids {
+		index[id] = i
+		rows[i].ID = id
+	}
+	add := func(id uint, score float64, opponent bool) {
+		i, ok := index[id]
+		if !ok {
+			return
+		}
+		rows[i].Score += score
+		if !opponent {
+			return
+		}
+		rows[i].Played++
+		switch score {
+		case 1:
+			rows[i].Wins++
+		case 0.5:
+			rows[i].Draws++
+		default:
+			rows[i].Losses++
+		}
+	}
+	for _, g := range games {
+		add(g.Red, g.RedScore, g.Black != 0)
+		if g.Black != 0 {
+			add(g.Black, g.BlackScore, true)
+		}
+	}
+
+	// 对手分以全部积分计算，须在积分统计完之后进行
+	for _, g := range games {
+		if g.Black == 0 {
+			continue
+		}
+		r, rok := index[g.Red]
+		b, bok := index[g.Black]
+		if !rok || !bok {
+			continue
+		}
+		rows[r].Buchholz += rows[b].Score
+		rows[b].Buchholz += rows[r].Score
+		rows[r].SonnebornBerger += g.RedScore * rows[b].Score
+		rows[b].SonnebornBerger += g.BlackScore * rows[r].Score
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		if a.SonnebornBerger != b.SonnebornBerger {
+			return a.SonnebornBerger > b.SonnebornBerger
+		}
+		return index[a.ID] < index[b.ID]
+	})
+	for i := range rows {
+		rows[i].Rank = i + 1
+		if i > 0 && rows[i].Score == rows[i-1].Score && rows[i].Buchholz == rows[i-1].Buchholz &&
+			rows[i].SonnebornBerger == rows[i-1].SonnebornBerger {
+			rows[i].Rank = rows[i-1].Rank
+		}
+	}
+	return rows
+}
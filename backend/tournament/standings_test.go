@@ -0,0 +1,61 @@
+package tournament
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStandings(t *testing.T) {
+	games := []Game{
+		// 第一轮：1 胜 2，3 和 4，5 轮空
+		{Red: 1, Black: 2, RedScore: 1},
+		{Red: 3, Black: 4, RedScore: 0.5, BlackScore: 0.5},
+		{Red: 5, RedScore: 1},
+		// 第二轮：1 胜 5，2 胜 3，4 轮空
+		{Red: 5, Black: 1, BlackScore: 1},
+		{Red: 2, Black: 3, RedScore: 1},
+		{Red: 4, RedScore: 1},
+		// 第三轮：1 和 3，4 胜 5，2 轮空
+		{Red: 1, Black: 3, RedScore: 0.5, BlackScore: 0.5},
+		{Red: 4, Black: 5, RedScore: 1},
+		{Red: 2, RedScore: 1},
+	}
+	// 积分：1=2.5 2=2 3=1 4=2.5 5=1，轮空不计入对手分
+	// 布赫霍尔茨：1=2+1+1 2=2.5+1 3=2.5+2+2.5 4=1+1 5=2.5+2.5
+	// 索内本-伯格：1=2+1+1/2 2=1 3=2.5/2+2.5/2 4=1/2+1 5=0
+	want := []Standing{
+		{ID: 1, Score: 2.5, Buchholz: 4, SonnebornBerger: 3.5, Played: 3, Wins: 2, Draws: 1, Rank: 1},
+		{ID: 4, Score: 2.5, Buchholz: 2, SonnebornBerger: 1.5, Played: 2, Wins: 1, Draws: 1, Rank: 2},
+		{ID: 2, Score: 2, Buchholz: 3.5, SonnebornBerger: 1, Played: 2, Wins: 1, Losses: 1, Rank: 3},
+		{ID: 3, Score: 1, Buchholz: 7, SonnebornBerger: 2.5, Played: 3, Draws: 2, Losses: 1, Rank: 4},
+		{ID: 5, Score: 1, Buchholz: 5, SonnebornBerger: 0, Played: 2, Losses: 2, Rank: 5},
+	}
+	if got := Standings(seededIDs(5), games); !reflect.DeepEqual(got, want) {
+		t.Errorf("Standings =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestStandingsTies(t *testing.T) {
+	// 积分与对手分全部相同的棋手名次并列，按种子顺序排列；不在名单中的对手不计入对手分
+	ids := []uint{7, 3, 9}
+	games := []Game{
+		{Red: 3, Black: 7, RedScore: 0.5, BlackScore: 0.5},
+		{Red: 9, Black: 42, RedScore: 1},
+	}
+	got := Standings(ids, games)
+	var order []uint
+	var ranks []int
+	for _, s := range got {
+		order = append(order, s.ID)
+		ranks = append(ranks, s.Rank)
+	}
+	if want := []uint{9, 7, 3}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if want := []int{1, 2, 2}; !reflect.DeepEqual(ranks, want) {
+		t.Errorf("ranks = %v, want %v", ranks, want)
+	}
+	if got[0].Score != 1 || got[0].Buchholz != 0 || got[0].SonnebornBerger != 0 {
+		t.Errorf("player 9 = %+v, want 1 point and no tie-break from an unknown opponent", got[0])
+	}
+}
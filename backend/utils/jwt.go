@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"crypto/rand"
+	"errors"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -56,4 +60,67 @@ func ParseToken(tokenStr string) int {
 		return -1
 	}
 	return claims.UserId
-}
\ No newline at end of file
+}
+
+// 房间邀请令牌使用独立密钥签名，可通过 ROOM_INVITE_SECRET 配置（多实例部署时需一致），未配置时每次启动随机生成
+var (
+	inviteKeyOnce sync.Once
+	inviteKey     []byte
+)
+
+func getInviteKey() []byte {
+	inviteKeyOnce.Do(func() {
+		if k := os.Getenv("ROOM_INVITE_SECRET"); k != "" {
+			inviteKey = []byte(k)
+			return
+		}
+		inviteKey = make([]byte, 32)
+		if _, err := rand.Read(inviteKey); err != nil {
+			panic("generate room invite secret: " + err.Error())
+		}
+	})
+	return inviteKey
+}
+
+type roomInviteClaims struct {
+	RoomId int    `json:"roomId"`
+	Nonce  string `json:"nonce"`
+	jwt.StandardClaims
+}
+
+// GenerateRoomInvite 为房间生成带过期时间的邀请令牌，nonce 用于区分重启后复用的房间ID
+func GenerateRoomInvite(roomId int, nonce string, expiry time.Duration) (string, time.Time, error) {
+	exp := time.Now().Add(expiry)
+	claims := roomInviteClaims{
+		RoomId: roomId,
+		Nonce:  nonce,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: exp.Unix(),
+			Subject:   "room_invite",
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(getInviteKey())
+	return token, exp, err
+}
+
+// ParseRoomInvite 校验邀请令牌的签名与有效期，返回房间ID与 nonce
+func ParseRoomInvite(tokenStr string) (int, string, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &roomInviteClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return getInviteKey(), nil
+	})
+	if err != nil {
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorExpired != 0 {
+			return 0, "", errors.New("邀请链接已过期")
+		}
+		return 0, "", errors.New("邀请链接无效")
+	}
+	claims, ok := token.Claims.(*roomInviteClaims)
+	if !ok || !token.Valid || claims.Subject != "room_invite" {
+		return 0, "", errors.New("邀请链接无效")
+	}
+	return claims.RoomId, claims.Nonce, nil
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestRoomInvite(t *testing.T) {
+	token, exp, err := GenerateRoomInvite(42, "a1b2c3", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(exp); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expires in %v, want about an hour", d)
+	}
+	roomId, nonce, err := ParseRoomInvite(token)
+	if err != nil || roomId != 42 || nonce != "a1b2c3" {
+		t.Errorf("ParseRoomInvite = %d, %q, %v; want 42, a1b2c3", roomId, nonce, err)
+	}
+}
+
+func TestRoomInviteRejects(t *testing.T) {
+	expired, _, err := GenerateRoomInvite(42, "a1b2c3", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, _, err := GenerateRoomInvite(42, "a1b2c3", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(claims jwt.Claims, method jwt.SigningMethod, key any) string {
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	future := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name, token, err string
+	}{
+		{"expired", expired, "邀请链接已过期"},
+		{"tampered signature", valid[:len(valid)-2] + "xx", "邀请链接无效"},
+		{"other key", sign(roomInviteClaims{RoomId: 42, StandardClaims: jwt.StandardClaims{ExpiresAt: future, Subject: "room_invite"}}, jwt.SigningMethodHS256, []byte("other")), "邀请链接无效"},
+		// 同一密钥签发的其他用途令牌不能当作邀请
+		{"wrong subject", sign(roomInviteClaims{RoomId: 42, StandardClaims: jwt.StandardClaims{ExpiresAt: future, Subject: "login"}}, jwt.SigningMethodHS256, getInviteKey()), "邀请链接无效"},
+		{"unsigned", sign(roomInviteClaims{RoomId: 42, StandardClaims: jwt.StandardClaims{ExpiresAt: future, Subject: "room_invite"}}, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), "邀请链接无效"},
+		{"garbage", "not-a-token", "邀请链接无效"},
+		{"empty", "", "邀请链接无效"},
+	}
+	for _, tt := range tests {
+		_, _, err := ParseRoomInvite(tt.token)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("象棋123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "象棋123" || !strings.HasPrefix(hash, "$2") {
+		t.Errorf("HashPassword = %q, want a bcrypt hash", hash)
+	}
+	for password, want := range map[string]bool{"象棋123": true, "象棋124": false, "": false} {
+		if got := CheckPassword(hash, password); got != want {
+			t.Errorf("CheckPassword(%q) = %v, want %v", password, got, want)
+		}
+	}
+	if CheckPassword("", "") || CheckPassword("not-a-hash", "not-a-hash") {
+		t.Errorf("CheckPassword accepts an invalid hash")
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 屏蔽词来自两个环境变量，二者可同时使用：
+//   - CHAT_BANNED_WORDS：逗号分隔的屏蔽词
+//   - CHAT_BANNED_WORDS_FILE：屏蔽词文件，每行一个词，# 开头的行为注释
+var (
+	bannedWordsOnce sync.Once
+	bannedWords     [][]rune
+)
+
+func loadBannedWords() {
+	var words []string
+	words = append(words, strings.Split(os.Getenv("CHAT_BANNED_WORDS"), ",")...)
+	if path := os.Getenv("CHAT_BANNED_WORDS_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Printf("load banned words from %s failed: %v", path, err)
+		} else {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				words = append(words, scanner.Text())
+			}
+			f.Close()
+		}
+	}
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" || strings.HasPrefix(w, "#") {
+			continue
+		}
+		bannedWords = append(bannedWords, []rune(strings.ToLower(w)))
+	}
+}
+
+// FilterBannedWords 将文本中的屏蔽词（忽略大小写）替换为等长的 *
+func FilterBannedWords(s string) string {
+	bannedWordsOnce.Do(loadBannedWords)
+	if len(bannedWords) == 0 {
+		return s
+	}
+	text := []rune(s)
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+	changed := false
+	for _, w := range bannedWords {
+		for i := 0; i+len(w) <= len(lower); i++ {
+			if !hasRunePrefix(lower[i:], w) {
+				continue
+			}
+			for j := i; j < i+len(w); j++ {
+				text[j] = '*'
+			}
+			changed = true
+			i += len(w) - 1
+		}
+	}
+	if !changed {
+		return s
+	}
+	return string(text)
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
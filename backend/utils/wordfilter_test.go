@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// useBannedWords 按给定的环境变量重新加载屏蔽词
+func useBannedWords(t *testing.T, words, file string) {
+	t.Helper()
+	t.Setenv("CHAT_BANNED_WORDS", words)
+	t.Setenv("CHAT_BANNED_WORDS_FILE", file)
+	reset := func() {
+		bannedWordsOnce = sync.Once{}
+		bannedWords = nil
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestFilterBannedWords(t *testing.T) {
+	useBannedWords(t, "笨蛋, Noob ,,蛋糕,#注释", "")
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"好棋", "好棋"},
+		{"你这个笨蛋", "你这个**"},
+		{"笨蛋笨蛋", "****"},
+		// 忽略大小写，其余字符保持原样
+		{"NOOB noob NoOb!", "**** **** ****!"},
+		// 重叠的屏蔽词都会被替换
+		{"笨蛋糕", "***"},
+		// # 开头的词按注释忽略
+		{"#注释", "#注释"},
+		{"车马炮", "车马炮"},
+	}
+	for _, tt := range tests {
+		if got := FilterBannedWords(tt.in); got != tt.want {
+			t.Errorf("FilterBannedWords(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFilterBannedWordsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(path, []byte("# 每行一个词\n傻瓜\n\n  Idiot  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	useBannedWords(t, "笨蛋", path)
+	if got, want := FilterBannedWords("傻瓜、笨蛋、IDIOT、每行一个词"), "**、**、*****、每行一个词"; got != want {
+		t.Errorf("FilterBannedWords = %q, want %q", got, want)
+	}
+
+	// 文件读取失败时仍使用环境变量中的屏蔽词
+	useBannedWords(t, "笨蛋", filepath.Join(t.TempDir(), "missing.txt"))
+	if got, want := FilterBannedWords("傻瓜、笨蛋"), "傻瓜、**"; got != want {
+		t.Errorf("FilterBannedWords with a missing file = %q, want %q", got, want)
+	}
+}
+
+func TestFilterBannedWordsDisabled(t *testing.T) {
+	useBannedWords(t, "", "")
+	if got := FilterBannedWords("笨蛋"); got != "笨蛋" {
+		t.Errorf("FilterBannedWords without banned words = %q", got)
+	}
+}
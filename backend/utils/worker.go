@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"fmt"
 	"runtime"
 	"sync"
 )
@@ -17,6 +18,7 @@ type WorkerPool struct {
 	ErrChan     chan error
 	stopCh      chan struct{}
 	once        sync.Once
+	stopOnce    sync.Once
 	wg          sync.WaitGroup
 }
 
@@ -26,6 +28,7 @@ func NewWorkerPool() *WorkerPool {
 	return &WorkerPool{
 		WorkerCount: workerCount,
 		JobQueue:    make(chan Job, queueSize),
+		ErrChan:     make(chan error, queueSize),
 		stopCh:      make(chan struct{}),
 	}
 }
@@ -38,6 +41,21 @@ func (wp *WorkerPool) Process(ctx context.Context, task Task) {
 	}
 }
 
+// TryProcess 不阻塞地提交任务，队列已满或工作池已停止时返回 false
+func (wp *WorkerPool) TryProcess(task Task) bool {
+	select {
+	case <-wp.stopCh:
+		return false
+	default:
+	}
+	select {
+	case wp.JobQueue <- Job{Task: task}:
+		return true
+	default:
+		return false
+	}
+}
+
 func (wp *WorkerPool) Start() {
 	wp.once.Do(func() {
 		wp.wg.Add(wp.WorkerCount)
@@ -50,22 +68,17 @@ func (wp *WorkerPool) Start() {
 						if !ok {
 							return
 						}
-						var err error
-						func() {
-							defer func() {
-								if r := recover(); r != nil {
-									if e, ok := r.(error); ok {
-										err = e
-									}
-								}
-							}()
-							err = job.Task()
-						}()
-						if err != nil {
-							wp.ErrChan <- err
-						}
+						wp.run(job)
 					case <-wp.stopCh:
-						return
+						// 退出前执行完队列中剩余的任务
+						for {
+							select {
+							case job := <-wp.JobQueue:
+								wp.run(job)
+							default:
+								return
+							}
+						}
 					}
 				}
 			}()
@@ -73,7 +86,29 @@ func (wp *WorkerPool) Start() {
 	})
 }
 
+func (wp *WorkerPool) run(job Job) {
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panic: %v", r)
+			}
+		}()
+		err = job.Task()
+	}()
+	if err != nil {
+		select {
+		case wp.ErrChan <- err:
+		default:
+			// 错误来不及处理时丢弃，避免阻塞工作协程
+		}
+	}
+}
+
+// Stop 通知工作协程退出，并等待队列中已有的任务执行完毕；重复调用只等待
 func (wp *WorkerPool) Stop() {
-	close(wp.stopCh)
+	wp.stopOnce.Do(func() {
+		close(wp.stopCh)
+	})
 	wp.wg.Wait()
 }
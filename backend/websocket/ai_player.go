@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"chinese-chess-backend/ai"
+	"chinese-chess-backend/engine"
+	recordModel "chinese-chess-backend/model/record"
+	"chinese-chess-backend/notation"
+	"chinese-chess-backend/xiangqi"
+)
+
+// engineMoveTimeout 外部引擎每步思考时间之外额外等待的时间，超时则改用内置 AI
+const engineMoveTimeout = 5 * time.Second
+
+// aiPlayer 服务端 AI 棋手，作为人机对局中的一方加入房间
+type aiPlayer struct {
+	level  int
+	engine *engine.Pool // 外部引擎对手，为 nil 时使用内置 AI
+	// seq 悔棋时递增，搜索开始时记录，搜索结果返回时不一致则说明局面已变化，丢弃该结果
+	seq atomic.Int64
+}
+
+// createAIMessage 创建人机对局请求，color 为玩家执的颜色（red/black，缺省为红）
+// engine 为 true 时由服务端配置的外部引擎执子，此时忽略 level
+type createAIMessage struct {
+	BaseMessage
+	Level  int    `json:"level"`
+	Color  string `json:"color,omitempty"`
+	Engine bool   `json:"engine,omitempty"`
+}
+
+// newAIClient 创建 AI 棋手对应的客户端，不登记到 Clients，发给它的消息直接丢弃
+func newAIClient(level int, pool *engine.Pool) *Client {
+	username := fmt.Sprintf("AI（等级%d）", level)
+	if pool != nil {
+		// 外部引擎按最高难度记录
+		level = ai.MaxLevel
+		username = fmt.Sprintf("AI（%s）", pool.Name())
+	}
+	return &Client{
+		Id:       0,
+		Status:   userOnline,
+		RoomId:   -1,
+		Role:     roleNone,
+		LastPong: time.Now(),
+		Username: username,
+		ai:       &aiPlayer{level: level, engine: pool},
+	}
+}
+
+// aiClient 返回房间中的 AI 棋手，非人机对局返回 nil
+func (cr *ChessRoom) aiClient() *Client {
+	for _, c := range []*Client{cr.Current, cr.Next} {
+		if c != nil && c.ai != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// startAIGame 为玩家创建人机对局并开始，AI 执红时立即开始思考
+func (ch *ChessHub) startAIGame(client *Client, req createAIMessage) {
+	var pool *engine.Pool
+	if req.Engine {
+		pool = engine.Default()
+	}
+	bot := newAIClient(req.Level, pool)
+	red, black := client, bot
+	if req.Color == "black" {
+		red, black = bot, client
+	}
+	r := NewChessRoom()
+	r.GameType = recordModel.GameTypeAI
+	r.join(red)
+	r.join(black)
+	ch.mu.Lock()
+	ch.addRoom(r)
+	ch.mu.Unlock()
+
+	red.startPlay(roleRed)
+	black.startPlay(roleBlack)
+	ch.stopWatching(client)
+	r.StartTime = time.Now()
+
+	start := startMessage{
+		BaseMessage: BaseMessage{Type: messageStart},
+		Role:        "red",
+		Opponent:    OpponentInfo{Name: bot.Username},
+		AiColor:     "black",
+	}
+	if bot.Role == roleRed {
+		start.Role, start.AiColor = "black", "red"
+	}
+	client.sendMessage(start)
+	ch.scheduleAIMove(r)
+}
+
+// scheduleAIMove 轮到 AI 走棋时在后台搜索，结果作为 AI 的走子命令交给命令循环处理
+func (ch *ChessHub) scheduleAIMove(room *ChessRoom) {
+	bot := room.Current
+	if bot == nil || bot.ai == nil {
+		return
+	}
+	room.mu.Lock()
+	board := room.Board.Clone()
+	history := room.Repetition.Hashes()
+	moves := room.boardMoves()
+	fen := room.StartFEN
+	room.mu.Unlock()
+	if fen == "" {
+		fen = xiangqi.InitialFEN
+	}
+	seq := bot.ai.seq.Load()
+
+	go func() {
+		m, err := aiMove(bot.ai.engine, fen, moves, func() (xiangqi.Move, error) {
+			return builtinMove(board, bot.ai.level, history)
+		})
+		if err != nil {
+			log.Printf("room %d: ai search failed: %v", room.Id, err)
+			return
+		}
+		// 与玩家上报的走法一样使用走子方视角的坐标
+		if bot.Role == roleBlack {
+			m = m.Flip()
+		}
+		move := MoveMessage{
+			BaseMessage: BaseMessage{Type: messageAIMove},
+			From:        Position{X: m.From.X, Y: m.From.Y},
+			To:          Position{X: m.To.X, Y: m.To.Y},
+		}
+		ch.enqueue(hubCommand{
+			commandType: commandMove,
+			client:      bot,
+			payload:     moveRequest{from: bot, move: move, seq: seq},
+		})
+	}()
+}
+
+// builtinMove 使用内置 AI 搜索
+func builtinMove(board *xiangqi.Board, level int, history []uint64) (xiangqi.Move, error) {
+	opts := ai.LevelOptions(level)
+	opts.History = history
+	result, err := ai.Search(board, opts)
+	return result.Move, err
+}
+
+// aiMove 由外部引擎从起始局面按对局着法搜索，引擎据此也能判断重复局面；
+// 未配置引擎或引擎出错时使用 builtin
+func aiMove(pool *engine.Pool, fen string, moves []xiangqi.Move, builtin func() (xiangqi.Move, error)) (xiangqi.Move, error) {
+	iccs := make([]string, len(moves))
+	for i, m := range moves {
+		iccs[i] = notation.ToICCS(m)
+	}
+	ctx := context.Background()
+	var limit engine.Limit
+	if pool != nil {
+		limit.MoveTime = pool.MoveTime()
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limit.MoveTime+engineMoveTimeout)
+		defer cancel()
+	}
+	res, fromEngine, err := engine.SearchOr(ctx, pool, fen, iccs, limit, func() (engine.Result, error) {
+		m, err := builtin()
+		if err != nil {
+			return engine.Result{}, err
+		}
+		return engine.Result{BestMove: notation.ToICCS(m)}, nil
+	})
+	if err != nil {
+		return xiangqi.Move{}, err
+	}
+	m, err := notation.ParseICCS(res.BestMove)
+	if err != nil && fromEngine {
+		log.Printf("engine returned invalid move %q, falling back to built-in ai", res.BestMove)
+		return builtin()
+	}
+	return m, err
+}
+
+// boardMoves 将 History 转换为红方视角的着法序列，调用方需持有 cr.mu
+func (cr *ChessRoom) boardMoves() []xiangqi.Move {
+	moves := make([]xiangqi.Move, 0, len(cr.History)/2)
+	for i := 0; i+1 < len(cr.History); i += 2 {
+		moves = append(moves, boardMove(cr.History[i], cr.History[i+1], cr.moverAt(i/2)))
+	}
+	return moves
+}
+
+// handleAIRegret 人机对局中的悔棋：AI 总是同意，撤回到玩家上一步走棋之前
+// 轮到玩家时撤回两步（AI 的应着与玩家的一步），AI 思考中时只撤回玩家刚走的一步并放弃这次搜索
+func (ch *ChessHub) handleAIRegret(room *ChessRoom, requester, bot *Client) {
+	room.mu.Lock()
+	plies := len(room.History) / 2
+	// 先走的一方走第 0、2、4… 步，后走的一方走第 1、3、5… 步
+	parity := 0
+	if requester.Role != room.moverAt(0) {
+		parity = 1
+	}
+	keep := plies - 1
+	if keep >= 0 && keep%2 != parity {
+		keep--
+	}
+	if keep < 0 {
+		room.mu.Unlock()
+		requester.sendMessage(RegretResponseMessage{
+			BaseMessage: BaseMessage{Type: messageRegretResponse},
+			Accepted:    false,
+		})
+		return
+	}
+	bot.ai.seq.Add(1)
+	room.History = room.History[:keep*2]
+	room.rebuildBoard()
+	room.Current = requester
+	room.Next = bot
+	room.mu.Unlock()
+
+	requester.sendMessage(RegretResponseMessage{
+		BaseMessage: BaseMessage{Type: messageRegretResponse},
+		Accepted:    true,
+	})
+	room.broadcastToSpectators(room.spectatorSync())
+}
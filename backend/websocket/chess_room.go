@@ -1,9 +1,12 @@
 package websocket
 
 import (
+	"chinese-chess-backend/config"
 	"chinese-chess-backend/database"
+	ratingDto "chinese-chess-backend/dto/rating"
 	recordModel "chinese-chess-backend/model/record"
 	"chinese-chess-backend/service"
+	"chinese-chess-backend/xiangqi"
 	"fmt"
 	"log"
 	"strconv"
@@ -17,25 +20,54 @@ var (
 	idLock sync.Mutex
 )
 
+var (
+	// 自然限着：连续未吃子达到 NoCaptureOfferPlies 步（半回合）时系统提议和棋，达到 NoCaptureDrawPlies 步时强制判和
+	NoCaptureOfferPlies = config.GetEnvInt("NO_CAPTURE_OFFER_PLIES", 60)
+	NoCaptureDrawPlies  = config.GetEnvInt("NO_CAPTURE_DRAW_PLIES", 120)
+)
+
 type ChessRoom struct {
 	Id              int
 	Nums            int     // 已有人数
 	Current         *Client // 先进入房间的作为先手，默认为当前玩家
 	Next            *Client // 后进入房间的作为后手，默认为下一个玩家
 	History         []Position
-	StartTime       time.Time  // 记录对局开始时间
-	RegretRequester *Client    // 新增：记录悔棋请求发起方
-	RecordSaved     bool       // 标记对局记录是否已保存，防止重复保存
-	mu              sync.Mutex // 保护History等共享资源
-	GameType        int        // 0=随机匹配,1=人机,2=好友对战
+	StartTime       time.Time                  // 记录对局开始时间
+	RegretRequester *Client                    // 新增：记录悔棋请求发起方
+	DrawRequester   *Client                    // 尚未答复的和棋请求发起方
+	RecordSaved     bool                       // 标记对局记录是否已保存，防止重复保存
+	mu              sync.Mutex                 // 保护History等共享资源
+	GameType        int                        // 0=随机匹配,1=人机,2=好友对战,5=比赛
+	Board           *xiangqi.Board             // 服务端权威棋盘（红方视角），用于校验走子
+	Repetition      *xiangqi.RepetitionTracker // 局面哈希与着法分类，用于长将、长捉裁决
+	NoCaptureCount  int                        // 连续未吃子的步数（半回合）
+	autoDrawOffered bool                       // 是否已发出自然限着的系统和棋提议
+	autoDrawAccepts map[clientRole]bool        // 已同意系统和棋提议的一方
+	Rated           bool                       // 是否计入等级分：随机匹配取决于所选队列，好友对战需双方同意
+	ratedOffer      bool                       // 好友对战中邀请方是否提议计分
+	TimeControl     TimeControl                // 时间控制，零值表示不计时
+	clock           *gameClock                 // 服务端棋钟，不计时对局为 nil
+	Spectators      map[int]*Client            // 观战者，键为用户ID，由 mu 保护
+	chatMuted       map[clientRole]bool        // 已屏蔽对手聊天的一方，由 mu 保护
+	Private         bool                       // 私密房间不在大厅中展示，只能通过邀请链接或密码进入
+	passwordHash    string                     // 房间密码的哈希，空表示无密码
+	inviteNonce     string                     // 写入邀请令牌的随机串，防止房间ID复用后旧链接仍然有效
+	StartFEN        string                     // 起始局面，空表示标准开局；残局挑战从关卡局面开始
+	startTurn       xiangqi.Color              // 起始局面的行棋方，残局可能由黑方先走
+	endgame         *endgameSession            // 残局挑战的关卡与玩家，普通对局为 nil
+	tournament      *tournamentGame            // 比赛对局所属的对阵，其它对局为 nil
 }
 
 func NewChessRoom() *ChessRoom {
-	idLock.Lock()
-	defer idLock.Unlock()
-	nextId++
-	return &ChessRoom{
-		Id:          nextId,
+	id, ok := allocRoomId()
+	if !ok {
+		idLock.Lock()
+		nextId++
+		id = nextId
+		idLock.Unlock()
+	}
+	room := &ChessRoom{
+		Id:          id,
 		Nums:        0,
 		Current:     nil,
 		Next:        nil,
@@ -44,6 +76,226 @@ func NewChessRoom() *ChessRoom {
 		RecordSaved: false,
 		GameType:    0,
 	}
+	room.resetBoard()
+	return room
+}
+
+// boardMove 将客户端上报的走法（移动者视角）转换为统一的红方视角
+// 客户端总是把己方放在棋盘下方，因此黑方上报的坐标需要旋转 180 度
+func boardMove(from, to Position, mover clientRole) xiangqi.Move {
+	m := xiangqi.Move{
+		From: xiangqi.Pos{X: from.X, Y: from.Y},
+		To:   xiangqi.Pos{X: to.X, Y: to.Y},
+	}
+	if mover == roleBlack {
+		m = m.Flip()
+	}
+	return m
+}
+
+// resetBoard 将棋盘与循环记录恢复到起始局面
+func (cr *ChessRoom) resetBoard() {
+	cr.Board = xiangqi.NewBoard()
+	if cr.StartFEN != "" {
+		// 起始局面在创建房间前已校验
+		if b, err := xiangqi.ParseFEN(cr.StartFEN); err == nil {
+			cr.Board = b
+		}
+	}
+	cr.startTurn = cr.Board.Turn
+	cr.Repetition = xiangqi.NewRepetitionTracker(cr.Board)
+	cr.NoCaptureCount = 0
+	cr.clearAutoDrawOffer()
+}
+
+// playMove 校验并执行一步棋（移动者视角坐标），同时记录循环局面，不修改 History，调用方需持有 cr.mu
+func (cr *ChessRoom) playMove(from, to Position, mover clientRole) error {
+	m := boardMove(from, to, mover)
+	if err := cr.Board.Validate(m); err != nil {
+		return err
+	}
+	class := cr.Board.ClassifyMove(m)
+	captured, err := cr.Board.MakeMove(m)
+	if err != nil {
+		return err
+	}
+	cr.Repetition.Push(cr.Board, xiangqi.Color(mover), class)
+	if captured.IsEmpty() {
+		cr.NoCaptureCount++
+	} else {
+		cr.NoCaptureCount = 0
+		cr.clearAutoDrawOffer()
+	}
+	return nil
+}
+
+// clearAutoDrawOffer 撤销系统和棋提议，调用方需持有 cr.mu
+func (cr *ChessRoom) clearAutoDrawOffer() {
+	cr.autoDrawOffered = false
+	cr.autoDrawAccepts = make(map[clientRole]bool)
+}
+
+// setRequester 记录一方发起的悔棋或和棋请求，覆盖此前尚未答复的请求
+func (cr *ChessRoom) setRequester(pending **Client, requester *Client) {
+	cr.mu.Lock()
+	*pending = requester
+	cr.mu.Unlock()
+}
+
+// takeRequester 对方是否有尚未答复的请求，有则取出（答复后失效）
+// 按用户ID比较，请求方断线重连后请求仍然有效
+func (cr *ChessRoom) takeRequester(pending **Client, requester *Client) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if *pending == nil || requester == nil || (*pending).Id != requester.Id {
+		return false
+	}
+	*pending = nil
+	return true
+}
+
+// takeAutoDrawOffer 连续未吃子刚达到提议步数时返回 true（每轮只提议一次）
+func (cr *ChessRoom) takeAutoDrawOffer() bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.autoDrawOffered || NoCaptureOfferPlies <= 0 || cr.NoCaptureCount < NoCaptureOfferPlies {
+		return false
+	}
+	cr.autoDrawOffered = true
+	return true
+}
+
+// acceptAutoDraw 记录一方对系统和棋提议的答复
+// 第一个返回值表示当前是否存在系统提议，第二个返回值表示双方是否均已同意
+func (cr *ChessRoom) acceptAutoDraw(role clientRole, accepted bool) (bool, bool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if !cr.autoDrawOffered {
+		return false, false
+	}
+	if !accepted {
+		// 任意一方拒绝即撤销提议，直到强制判和前不再提议
+		cr.autoDrawAccepts = make(map[clientRole]bool)
+		return true, false
+	}
+	cr.autoDrawAccepts[role] = true
+	return true, cr.autoDrawAccepts[roleRed] && cr.autoDrawAccepts[roleBlack]
+}
+
+// moverAt 返回第 ply 步（从 0 起）的走子方
+func (cr *ChessRoom) moverAt(ply int) clientRole {
+	first := clientRole(cr.startTurn)
+	if ply%2 == 1 {
+		return opponentRole(first)
+	}
+	return first
+}
+
+// rebuildBoard 按 History 从起始局面重新推演棋盘（悔棋后调用），调用方需持有 cr.mu
+func (cr *ChessRoom) rebuildBoard() {
+	cr.resetBoard()
+	for i := 0; i+1 < len(cr.History); i += 2 {
+		if err := cr.playMove(cr.History[i], cr.History[i+1], cr.moverAt(i/2)); err != nil {
+			log.Printf("room %d: rebuild board failed at ply %d: %v", cr.Id, i/2, err)
+			break
+		}
+	}
+}
+
+// adjudicate 由服务端棋盘判断对局是否已结束，返回胜方与结束原因
+// 依次检查：轮到走棋的一方无子可走（将死或困毙）、循环局面的长将长捉裁决
+func (cr *ChessRoom) adjudicate() (clientRole, string, bool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	// xiangqi.Color 与 clientRole 取值一致（1=红，2=黑）
+	switch cr.Board.Status() {
+	case xiangqi.Checkmate:
+		return clientRole(cr.Board.Turn.Opponent()), recordModel.EndReasonCheckmate, true
+	case xiangqi.Stalemate:
+		return clientRole(cr.Board.Turn.Opponent()), recordModel.EndReasonStalemate, true
+	}
+	if verdict, ok := cr.Repetition.Judge(); ok {
+		switch {
+		case verdict.Loser == xiangqi.NoColor:
+			return roleNone, recordModel.EndReasonRepetition, true
+		case verdict.Kind == xiangqi.Check:
+			return clientRole(verdict.Loser.Opponent()), recordModel.EndReasonPerpetualCheck, true
+		default:
+			return clientRole(verdict.Loser.Opponent()), recordModel.EndReasonPerpetualChase, true
+		}
+	}
+	if NoCaptureDrawPlies > 0 && cr.NoCaptureCount >= NoCaptureDrawPlies {
+		return roleNone, recordModel.EndReasonMoveLimit, true
+	}
+	return roleNone, "", false
+}
+
+// endReasonText 返回需要额外告知双方的裁决说明，普通结束原因返回空串
+func endReasonText(winner clientRole, reason string) string {
+	loser := "黑方"
+	if winner == roleBlack {
+		loser = "红方"
+	}
+	switch reason {
+	case recordModel.EndReasonPerpetualCheck:
+		return loser + "长将不变，判负"
+	case recordModel.EndReasonPerpetualChase:
+		return loser + "长捉不变，判负"
+	case recordModel.EndReasonRepetition:
+		return "双方循环不变，判和"
+	case recordModel.EndReasonMoveLimit:
+		return fmt.Sprintf("双方已连续%d步未吃子，按自然限着判和", NoCaptureDrawPlies)
+	case recordModel.EndReasonShutdown:
+		return "服务器维护，本局判和，不计等级分"
+	case recordModel.EndReasonStepLimit:
+		return "已走满残局限定的步数"
+	}
+	return ""
+}
+
+// startClock 对局开始时按时间控制启动棋钟，onFlag 在一方超时时调用
+func (cr *ChessRoom) startClock(onFlag func(loser clientRole)) {
+	if !cr.TimeControl.Enabled() {
+		return
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.clock != nil {
+		cr.clock.stop()
+	}
+	cr.clock = newGameClock(cr.TimeControl, onFlag)
+	cr.clock.start(clientRole(cr.startTurn), time.Now())
+}
+
+// clockSnapshot 返回当前双方剩余时间，不计时对局返回 nil
+func (cr *ChessRoom) clockSnapshot() *ClockSnapshot {
+	if cr.clock == nil {
+		return nil
+	}
+	return cr.clock.snapshot(time.Now())
+}
+
+// syncMessage 构造发给指定角色的房间同步消息（棋步历史、角色与当前轮次）
+func (cr *ChessRoom) syncMessage(role clientRole) SyncMessage {
+	cr.mu.Lock()
+	history := make([]Position, len(cr.History))
+	copy(history, cr.History)
+	cr.mu.Unlock()
+	var roleStr string
+	if role == roleRed {
+		roleStr = "red"
+	} else if role == roleBlack {
+		roleStr = "black"
+	}
+	var currentTurn string
+	if cr.Current != nil {
+		if cr.Current.Role == roleRed {
+			currentTurn = "red"
+		} else if cr.Current.Role == roleBlack {
+			currentTurn = "black"
+		}
+	}
+	return SyncMessage{BaseMessage: BaseMessage{Type: messageSync}, History: history, Role: roleStr, CurrentTurn: currentTurn, Clock: cr.clockSnapshot(), FEN: cr.StartFEN}
 }
 
 // func (cr * ChessRoom) isEmpty() bool {
@@ -62,14 +314,25 @@ func (cr *ChessRoom) exchange() {
 }
 
 func (cr *ChessRoom) clear() {
+	if cr.clock != nil {
+		cr.clock.stop()
+	}
+	cr.mu.Lock()
+	cr.detachSpectators()
+	cr.RegretRequester, cr.DrawRequester = nil, nil
+	cr.mu.Unlock()
+	cr.deleteSnapshot()
+	clearRoomOwner(cr.Id)
 	if cr.Current != nil {
 		cr.Current.RoomId = -1
 		cr.Current.Status = userOnline
+		cr.Current.releaseRemote()
 		cr.Current = nil
 	}
 	if cr.Next != nil {
 		cr.Next.RoomId = -1
 		cr.Next.Status = userOnline
+		cr.Next.releaseRemote()
 		cr.Next = nil
 	}
 	cr.Nums = 0
@@ -80,6 +343,7 @@ func (cr *ChessRoom) join(c *Client) error {
 		return fmt.Errorf("房间满了")
 	}
 	c.RoomId = cr.Id
+	c.attachRemote()
 	if cr.Current == nil {
 		cr.Current = c
 	} else {
@@ -91,6 +355,23 @@ func (cr *ChessRoom) join(c *Client) error {
 	return nil
 }
 
+// setChatMuted 设置一方是否屏蔽对手的聊天消息
+func (cr *ChessRoom) setChatMuted(role clientRole, muted bool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.chatMuted == nil {
+		cr.chatMuted = make(map[clientRole]bool)
+	}
+	cr.chatMuted[role] = muted
+}
+
+// isChatMuted 一方是否已屏蔽对手的聊天消息
+func (cr *ChessRoom) isChatMuted(role clientRole) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return cr.chatMuted[role]
+}
+
 // 处理聊天消息
 func (cr *ChessRoom) handleChat(sender *Client, content string) {
 	// 创建聊天消息
@@ -113,17 +394,17 @@ func (cr *ChessRoom) handleChat(sender *Client, content string) {
 	}
 }
 
-// saveGameRecord 将房间数据持久化到数据库
-func saveGameRecord(room *ChessRoom, winner clientRole) {
+// saveGameRecord 将房间数据持久化到数据库，计分对局返回双方的等级分变化
+func saveGameRecord(room *ChessRoom, winner clientRole, reason string) map[clientRole]*ratingDto.RatingChange {
 	if room == nil {
-		return
+		return nil
 	}
 
 	// 避免重复保存：如果已经保存过则直接返回
 	room.mu.Lock()
 	if room.RecordSaved {
 		room.mu.Unlock()
-		return
+		return nil
 	}
 	// 标记为已保存，防止并发或重复调用导致多次写入
 	room.RecordSaved = true
@@ -158,12 +439,9 @@ func saveGameRecord(room *ChessRoom, winner clientRole) {
 	// 将按移动对对（from,to）处理，假设红方先手
 	var sb strings.Builder
 	for i := 0; i+1 < len(historyCopy); i += 2 {
-		// moveIdx: 0 表示第一手（红方），1 表示第二手（黑方），以此类推
-		moveIdx := i / 2
-		moverIsBlack := (moveIdx%2 == 1)
 		from := historyCopy[i]
 		to := historyCopy[i+1]
-		if moverIsBlack {
+		if room.moverAt(i/2) == roleBlack {
 			from = Position{X: 8 - from.X, Y: 9 - from.Y}
 			to = Position{X: 8 - to.X, Y: 9 - to.Y}
 		}
@@ -193,7 +471,26 @@ func saveGameRecord(room *ChessRoom, winner clientRole) {
 		RedFlag:   false,
 		BlackFlag: false,
 		GameType:  room.GameType,
+		EndReason: reason,
+		// 时间控制
+		TimeBase:      room.TimeControl.Base,
+		TimeIncrement: room.TimeControl.Increment,
+		TimeByoyomi:   room.TimeControl.Byoyomi,
 	}
+	if bot := room.aiClient(); bot != nil {
+		rec.AILevel = bot.ai.level
+		if bot.ai.engine != nil {
+			rec.AIEngine = bot.ai.engine.Name()
+		}
+	}
+	if room.tournament != nil {
+		rec.TournamentID = room.tournament.tournamentID
+		rec.PairingID = room.tournament.pairingID
+	}
+	rec.Ecco = service.RecordOpening(&rec)
+	rec.Rated = countsAsPlayerGame(room.GameType) && room.Rated && redID > 0 && blackID > 0 && redID != blackID
+
+	ratingChanges := make(map[clientRole]*ratingDto.RatingChange)
 
 	if err := database.GetMysqlDb().Create(&rec).Error; err != nil {
 		log.Printf("failed to save game record: %v", err)
@@ -201,9 +498,30 @@ func saveGameRecord(room *ChessRoom, winner clientRole) {
 		// 记录成功后，更新双方（如果存在）的统计
 		// 仅针对玩家ID>0（AI 为0不更新）
 		us := service.NewUserService()
-		// 经验值结算（随机匹配与好友对战一致）：
-		// 赢 +20，和 +10，输 +5
-		if room.GameType == 0 || room.GameType == 2 {
+		if rec.Rated {
+			// 计分对局按 Glicko-2 更新等级分，不再发放固定经验
+			changes, err := service.NewRatingService().ApplyGame(rec.ID, redID, blackID, result)
+			if err != nil {
+				log.Printf("update rating for record %d failed: %v", rec.ID, err)
+			} else {
+				if c, ok := changes[redID]; ok {
+					ratingChanges[roleRed] = &c
+				}
+				if c, ok := changes[blackID]; ok {
+					ratingChanges[roleBlack] = &c
+				}
+			}
+		} else if bot := room.aiClient(); bot != nil {
+			// 人机对战由服务端裁定胜负后按难度发放经验，和棋不加经验
+			humanID, humanRole := redID, roleRed
+			if bot.Role == roleRed {
+				humanID, humanRole = blackID, roleBlack
+			}
+			if humanID > 0 && winner != roleNone {
+				_ = us.AddUserExp(int(humanID), service.AIGameExp(rec.AILevel, winner == humanRole))
+			}
+		} else if countsAsPlayerGame(room.GameType) {
+			// 不计分的对局仍按固定经验结算：赢 +20，和 +10，输 +5
 			// 确定胜负/和
 			if result == 2 {
 				if redID > 0 {
@@ -239,4 +557,9 @@ func saveGameRecord(room *ChessRoom, winner clientRole) {
 			}
 		}
 	}
+	// 对局记录保存失败时仍记录对阵结果，避免比赛停在本轮
+	if room.tournament != nil {
+		recordTournamentGame(room, rec.ID, result, reason)
+	}
+	return ratingChanges
 }
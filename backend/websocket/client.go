@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	recordModel "chinese-chess-backend/model/record"
 )
 
 type clientStatus int
@@ -33,6 +35,15 @@ type Client struct {
 	LastPong time.Time  // 上次收到PONG的时间
 	Username string     // 用户名
 	Send     chan any   // 发送消息的通道
+
+	matchQueue  matchQueue // 匹配时选择的队列
+	watchRoomId int        // 正在观战的房间ID，0 表示未观战
+
+	// 集群模式：代理客户端的连接所在实例；真实连接上的玩家进入其他实例房间时记录该实例
+	connNode string
+	roomNode string
+
+	ai *aiPlayer // 人机对局中的 AI 棋手，玩家为 nil
 }
 
 func NewClient(conn *websocket.Conn, id int, username string) *Client {
@@ -49,6 +60,13 @@ func NewClient(conn *websocket.Conn, id int, username string) *Client {
 }
 
 func (c *Client) sendMessage(message any) error {
+	if c.ai != nil {
+		// AI 棋手只通过命令循环走子，不接收消息
+		return nil
+	}
+	if c.connNode != "" {
+		return deliverToNode(c.connNode, c.Id, message)
+	}
 	if c.Conn == nil {
 		return fmt.Errorf("client connection is nil")
 	}
@@ -64,14 +82,15 @@ func (c *Client) startPlay(role clientRole) {
 	c.Status = userPlaying
 }
 
+// sendMessage 经命令循环向客户端发送消息；通常在工作池任务中调用，另起协程提交，不占用工作协程
 func (ch *ChessHub) sendMessage(client *Client, message any) {
-	ch.commands <- hubCommand{
+	go ch.enqueue(hubCommand{
 		commandType: commandSendMessage,
 		payload: sendMessageRequest{
 			target:  client,
 			message: message,
 		},
-	}
+	})
 }
 
 // 新增：处理悔棋请求（转发给对手）
@@ -101,8 +120,21 @@ func (ch *ChessHub) handleRegretRequest(requester *Client) {
 		})
 		return
 	}
+	if room.endgame != nil {
+		// 残局挑战按实际走的步数裁定，不能悔棋
+		requester.sendMessage(NormalMessage{
+			BaseMessage: BaseMessage{Type: messageError},
+			Message:     "残局挑战不能悔棋",
+		})
+		return
+	}
+	if opponent.ai != nil {
+		ch.handleAIRegret(room, requester, opponent)
+		return
+	}
 
 	// 向对手发送悔棋请求
+	room.setRequester(&room.RegretRequester, requester)
 	opponent.sendMessage(NormalMessage{
 		BaseMessage: BaseMessage{Type: messageRegretRequest},
 		Message:     "对方请求悔棋",
@@ -136,6 +168,14 @@ func (ch *ChessHub) handleRegretResponse(responder *Client, accepted bool) {
 		})
 		return
 	}
+	// 只能答复对方发起且尚未答复的悔棋请求
+	if !room.takeRequester(&room.RegretRequester, requester) {
+		responder.sendMessage(NormalMessage{
+			BaseMessage: BaseMessage{Type: messageError},
+			Message:     "对方没有请求悔棋",
+		})
+		return
+	}
 
 	if accepted {
 		// 同意悔棋：同步双方执行悔棋，更新房间历史记录
@@ -160,11 +200,16 @@ func (ch *ChessHub) handleRegretResponse(responder *Client, accepted bool) {
 		for i := 0; i < toRemove && len(room.History) > 0; i++ {
 			room.History = room.History[:len(room.History)-1]
 		}
+		room.rebuildBoard()
 
 		// 设置新的轮次：悔棋后应轮到请求方走子
 		room.Current = requester
 		room.Next = opponent
 		room.mu.Unlock()
+		// 悔棋后改为请求方计时
+		if room.clock != nil {
+			room.clock.switchTo(requester.Role, time.Now())
+		}
 
 		// 通知请求方执行悔棋（前端会根据本地状态执行相应步数）
 		respMsg := RegretResponseMessage{
@@ -172,10 +217,13 @@ func (ch *ChessHub) handleRegretResponse(responder *Client, accepted bool) {
 			Accepted:    true,
 		}
 		requester.sendMessage(respMsg)
+		// 观战者无法执行悔棋，直接下发悔棋后的完整局面
+		room.broadcastToSpectators(room.spectatorSync())
 		if room.Current == responder {
 			room.Current = requester
 			room.Next = responder
 		}
+		room.saveSnapshot()
 	} else {
 		// 拒绝悔棋：仅通知请求方
 		requester.sendMessage(RegretResponseMessage{
@@ -212,7 +260,29 @@ func (ch *ChessHub) handleDrawRequest(requester *Client) {
 		return
 	}
 
+	// 存在系统和棋提议时，主动请求和棋视为同意该提议
+	if offered, agreed := room.acceptAutoDraw(requester.Role, true); offered {
+		if agreed {
+			go ch.enqueue(hubCommand{
+				commandType: commandEnd,
+				client:      requester,
+				payload:     endRequest{winner: roleNone, reason: recordModel.EndReasonMoveLimit},
+			})
+			return
+		}
+	}
+
+	// AI 不接受玩家的和棋请求（系统和棋提议除外）
+	if opponent.ai != nil {
+		requester.sendMessage(DrawResponseMessage{
+			BaseMessage: BaseMessage{Type: messageDrawResponse},
+			Accepted:    false,
+		})
+		return
+	}
+
 	// 向对手发送和棋请求（使用 NormalMessage 携带类型）
+	room.setRequester(&room.DrawRequester, requester)
 	opponent.sendMessage(NormalMessage{
 		BaseMessage: BaseMessage{Type: messageDrawRequest},
 		Message:     "对方请求和棋",
@@ -247,6 +317,35 @@ func (ch *ChessHub) handleDrawResponse(responder *Client, accepted bool) {
 		return
 	}
 
+	// 系统和棋提议需双方都同意
+	offered, agreed := room.acceptAutoDraw(responder.Role, accepted)
+	if offered {
+		if agreed {
+			go ch.enqueue(hubCommand{
+				commandType: commandEnd,
+				client:      responder,
+				payload:     endRequest{winner: roleNone, reason: recordModel.EndReasonMoveLimit},
+			})
+			return
+		}
+		if accepted {
+			responder.sendMessage(NormalMessage{
+				BaseMessage: BaseMessage{Type: messageNormal},
+				Message:     "已同意和棋，等待对方确认",
+			})
+			return
+		}
+	}
+
+	// 只能答复对方发起且尚未答复的和棋请求；拒绝系统提议时同样通知对方
+	if !room.takeRequester(&room.DrawRequester, requester) && !offered {
+		responder.sendMessage(NormalMessage{
+			BaseMessage: BaseMessage{Type: messageError},
+			Message:     "对方没有请求和棋",
+		})
+		return
+	}
+
 	// 通知请求方和棋响应
 	respMsg := DrawResponseMessage{
 		BaseMessage: BaseMessage{Type: messageDrawResponse},
@@ -256,10 +355,10 @@ func (ch *ChessHub) handleDrawResponse(responder *Client, accepted bool) {
 
 	if accepted {
 		// 若同意，统一交由 commandEnd 处理（负责通知双方、持久化与清理）
-		ch.commands <- hubCommand{
+		go ch.enqueue(hubCommand{
 			commandType: commandEnd,
 			client:      requester,
-			payload:     roleNone,
-		}
+			payload:     endRequest{winner: roleNone, reason: recordModel.EndReasonDrawAgreed},
+		})
 	}
 }
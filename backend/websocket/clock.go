@@ -0,0 +1,230 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// TimeControl 对局时间控制，单位为秒；零值表示不计时
+// Increment（费舍尔加秒）与 Byoyomi（读秒）二选一：
+//   - 加秒：每走完一步为走子方增加 Increment 秒
+//   - 读秒：基础用时耗尽后，每步须在 Byoyomi 秒内走完
+type TimeControl struct {
+	Base      int `json:"base"`
+	Increment int `json:"increment,omitempty"`
+	Byoyomi   int `json:"byoyomi,omitempty"`
+}
+
+// Enabled 是否计时
+func (tc TimeControl) Enabled() bool {
+	return tc.Base > 0 || tc.Byoyomi > 0
+}
+
+// Validate 校验客户端提交的时间控制
+func (tc TimeControl) Validate() error {
+	switch {
+	case tc.Base < 0 || tc.Increment < 0 || tc.Byoyomi < 0:
+		return errors.New("时间设置不能为负数")
+	case tc.Base > 3*3600:
+		return errors.New("基础用时不能超过 3 小时")
+	case tc.Increment > 60:
+		return errors.New("每步加秒不能超过 60 秒")
+	case tc.Byoyomi > 600:
+		return errors.New("读秒不能超过 600 秒")
+	case tc.Increment > 0 && tc.Byoyomi > 0:
+		return errors.New("加秒与读秒只能选择一种")
+	case tc.Increment > 0 && tc.Base == 0:
+		return errors.New("加秒制需要设置基础用时")
+	}
+	return nil
+}
+
+// ClockSnapshot 双方时钟快照，随每步棋与同步消息下发，时间单位为毫秒
+type ClockSnapshot struct {
+	Red   int64  `json:"red"`   // 红方剩余基础用时
+	Black int64  `json:"black"` // 黑方剩余基础用时
+	Turn  string `json:"turn"`  // 正在计时的一方："red" / "black"
+	// ByoyomiLeft 行棋方已进入读秒时本步剩余的读秒时间，未进入读秒时为 0
+	ByoyomiLeft int64 `json:"byoyomiLeft,omitempty"`
+}
+
+// gameClock 服务端棋钟，超时后通过 onFlag 通知（在独立的定时器协程中调用）
+type gameClock struct {
+	mu        sync.Mutex
+	control   TimeControl
+	remaining [3]time.Duration // 以 clientRole 为下标的剩余基础用时
+	turn      clientRole
+	turnStart time.Time
+	running   bool
+	timer     *time.Timer
+	gen       int // 每次重新计时递增，用于丢弃过期的定时器回调
+	onFlag    func(loser clientRole)
+}
+
+func newGameClock(tc TimeControl, onFlag func(loser clientRole)) *gameClock {
+	base := time.Duration(tc.Base) * time.Second
+	gc := &gameClock{control: tc, onFlag: onFlag}
+	gc.remaining[roleRed] = base
+	gc.remaining[roleBlack] = base
+	return gc
+}
+
+func (gc *gameClock) byoyomi() time.Duration {
+	return time.Duration(gc.control.Byoyomi) * time.Second
+}
+
+// start 开始为先走的一方计时，标准开局为红方，残局等自定义局面可能由黑方先走
+func (gc *gameClock) start(first clientRole, now time.Time) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.running = true
+	gc.turn = first
+	gc.turnStart = now
+	gc.arm(now)
+}
+
+// arm 按行棋方的截止时间重设定时器，调用方需持有 gc.mu
+func (gc *gameClock) arm(now time.Time) {
+	if gc.timer != nil {
+		gc.timer.Stop()
+	}
+	gc.gen++
+	gen := gc.gen
+	loser := gc.turn
+	deadline := gc.turnStart.Add(gc.remaining[gc.turn] + gc.byoyomi())
+	gc.timer = time.AfterFunc(deadline.Sub(now), func() {
+		gc.mu.Lock()
+		if gc.gen != gen || !gc.running {
+			gc.mu.Unlock()
+			return
+		}
+		gc.running = false
+		gc.mu.Unlock()
+		gc.onFlag(loser)
+	})
+}
+
+// flagIfExpired 走子到达时定时器可能尚未触发：若 role 在 now 时刻已超时则停止计时并返回 true
+// 与定时器回调互斥，同一次超时只会有一方返回/触发
+func (gc *gameClock) flagIfExpired(role clientRole, now time.Time) bool {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if !gc.running || gc.turn != role {
+		return false
+	}
+	if now.Sub(gc.turnStart) <= gc.remaining[role]+gc.byoyomi() {
+		return false
+	}
+	gc.running = false
+	gc.timer.Stop()
+	return true
+}
+
+// settle 扣除当前行棋方已用时间，进入读秒后基础用时保持为 0，调用方需持有 gc.mu
+func (gc *gameClock) settle(now time.Time) {
+	used := now.Sub(gc.turnStart)
+	if used >= gc.remaining[gc.turn] {
+		gc.remaining[gc.turn] = 0
+	} else {
+		gc.remaining[gc.turn] -= used
+	}
+}
+
+// resume 按快照恢复双方剩余时间并为快照中的行棋方重新计时
+// 服务重启期间不计时，已进入读秒的一方获得完整的读秒时间
+func (gc *gameClock) resume(snap ClockSnapshot, now time.Time) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.remaining[roleRed] = time.Duration(snap.Red) * time.Millisecond
+	gc.remaining[roleBlack] = time.Duration(snap.Black) * time.Millisecond
+	gc.turn = roleRed
+	if snap.Turn == roleName(roleBlack) {
+		gc.turn = roleBlack
+	}
+	gc.running = true
+	gc.turnStart = now
+	gc.arm(now)
+}
+
+// punch 走子方按钟：结算用时、加秒并切换到对方计时
+func (gc *gameClock) punch(mover clientRole, now time.Time) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if !gc.running || gc.turn != mover {
+		return
+	}
+	gc.settle(now)
+	gc.remaining[mover] += time.Duration(gc.control.Increment) * time.Second
+	gc.turn = opponentRole(mover)
+	gc.turnStart = now
+	gc.arm(now)
+}
+
+// switchTo 悔棋等改变行棋方的情况：结算当前行棋方用时（不加秒）后改为 role 计时
+func (gc *gameClock) switchTo(role clientRole, now time.Time) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if !gc.running || gc.turn == role {
+		return
+	}
+	gc.settle(now)
+	gc.turn = role
+	gc.turnStart = now
+	gc.arm(now)
+}
+
+// stop 停止计时（对局结束）
+func (gc *gameClock) stop() {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.running = false
+	if gc.timer != nil {
+		gc.timer.Stop()
+	}
+}
+
+// snapshot 返回 now 时刻双方的剩余时间
+func (gc *gameClock) snapshot(now time.Time) *ClockSnapshot {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	left := gc.remaining
+	var byoyomiLeft time.Duration
+	if gc.running {
+		used := now.Sub(gc.turnStart)
+		if used < left[gc.turn] {
+			left[gc.turn] -= used
+		} else {
+			byoyomiLeft = max(gc.byoyomi()-(used-left[gc.turn]), 0)
+			left[gc.turn] = 0
+		}
+	}
+	return &ClockSnapshot{
+		Red:         left[roleRed].Milliseconds(),
+		Black:       left[roleBlack].Milliseconds(),
+		Turn:        roleName(gc.turn),
+		ByoyomiLeft: byoyomiLeft.Milliseconds(),
+	}
+}
+
+// opponentRole 返回对方角色
+func opponentRole(r clientRole) clientRole {
+	switch r {
+	case roleRed:
+		return roleBlack
+	case roleBlack:
+		return roleRed
+	}
+	return roleNone
+}
+
+// roleName 角色对应的字符串，与 startMessage、SyncMessage 中的取值一致
+func roleName(r clientRole) string {
+	switch r {
+	case roleRed:
+		return "red"
+	case roleBlack:
+		return "black"
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+var clockStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// at 返回开局后 d 时刻，测试中的时间都由参数传入，不依赖真实时钟
+func at(d time.Duration) time.Time {
+	return clockStart.Add(d)
+}
+
+func newTestClock(t *testing.T, tc TimeControl) *gameClock {
+	t.Helper()
+	gc := newGameClock(tc, func(loser clientRole) {
+		t.Errorf("unexpected flag for %s", roleName(loser))
+	})
+	t.Cleanup(gc.stop)
+	return gc
+}
+
+func checkSnapshot(t *testing.T, gc *gameClock, now time.Duration, want ClockSnapshot) {
+	t.Helper()
+	if got := gc.snapshot(at(now)); *got != want {
+		t.Errorf("snapshot at %v = %+v, want %+v", now, *got, want)
+	}
+}
+
+func TestTimeControlValidate(t *testing.T) {
+	tests := []struct {
+		tc TimeControl
+		ok bool
+	}{
+		{TimeControl{}, true},
+		{TimeControl{Base: 600, Increment: 10}, true},
+		{TimeControl{Base: 300, Byoyomi: 30}, true},
+		{TimeControl{Byoyomi: 60}, true},
+		{TimeControl{Base: -1}, false},
+		{TimeControl{Base: 3*3600 + 1}, false},
+		{TimeControl{Base: 600, Increment: 61}, false},
+		{TimeControl{Base: 600, Byoyomi: 601}, false},
+		{TimeControl{Base: 600, Increment: 5, Byoyomi: 30}, false},
+		{TimeControl{Increment: 5}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.tc.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v.Validate() = %v, want ok=%v", tt.tc, err, tt.ok)
+		}
+	}
+	if (TimeControl{}).Enabled() || !(TimeControl{Byoyomi: 30}).Enabled() {
+		t.Errorf("Enabled: only the zero time control is untimed")
+	}
+}
+
+func TestClockIncrement(t *testing.T) {
+	gc := newTestClock(t, TimeControl{Base: 60, Increment: 5})
+	gc.start(roleRed, at(0))
+	checkSnapshot(t, gc, 10*time.Second, ClockSnapshot{Red: 50000, Black: 60000, Turn: "red"})
+
+	// 红方用时 10 秒，加 5 秒后轮到黑方
+	gc.punch(roleRed, at(10*time.Second))
+	checkSnapshot(t, gc, 10*time.Second, ClockSnapshot{Red: 55000, Black: 60000, Turn: "black"})
+	// 不是行棋方按钟不起作用
+	gc.punch(roleRed, at(12*time.Second))
+	checkSnapshot(t, gc, 13*time.Second, ClockSnapshot{Red: 55000, Black: 57000, Turn: "black"})
+
+	gc.punch(roleBlack, at(13*time.Second))
+	checkSnapshot(t, gc, 13*time.Second, ClockSnapshot{Red: 55000, Black: 62000, Turn: "red"})
+
+	// 悔棋切换行棋方：结算用时但不加秒
+	gc.switchTo(roleBlack, at(20*time.Second))
+	checkSnapshot(t, gc, 20*time.Second, ClockSnapshot{Red: 48000, Black: 62000, Turn: "black"})
+
+	// 加秒制没有读秒，用完基础用时即超时
+	if gc.flagIfExpired(roleBlack, at(82*time.Second)) {
+		t.Errorf("black flagged with exactly 0 left")
+	}
+	if gc.flagIfExpired(roleRed, at(90*time.Second)) {
+		t.Errorf("red flagged while black is to move")
+	}
+	if !gc.flagIfExpired(roleBlack, at(82*time.Second+time.Millisecond)) {
+		t.Errorf("black not flagged after running out of time")
+	}
+	// 超时后停止计时，同一次超时只报告一次
+	if gc.flagIfExpired(roleBlack, at(90*time.Second)) {
+		t.Errorf("black flagged twice")
+	}
+	gc.punch(roleBlack, at(90*time.Second))
+	checkSnapshot(t, gc, 100*time.Second, ClockSnapshot{Red: 48000, Black: 62000, Turn: "black"})
+}
+
+func TestClockByoyomi(t *testing.T) {
+	gc := newTestClock(t, TimeControl{Base: 10, Byoyomi: 30})
+	gc.start(roleRed, at(0))
+	// 基础用时耗尽后进入读秒
+	checkSnapshot(t, gc, 25*time.Second, ClockSnapshot{Red: 0, Black: 10000, Turn: "red", ByoyomiLeft: 15000})
+	if gc.flagIfExpired(roleRed, at(39*time.Second)) {
+		t.Errorf("red flagged inside byoyomi")
+	}
+	gc.punch(roleRed, at(39*time.Second))
+	checkSnapshot(t, gc, 39*time.Second, ClockSnapshot{Red: 0, Black: 10000, Turn: "black"})
+
+	gc.punch(roleBlack, at(42*time.Second))
+	// 每步都有完整的读秒时间
+	checkSnapshot(t, gc, 43*time.Second, ClockSnapshot{Red: 0, Black: 7000, Turn: "red", ByoyomiLeft: 29000})
+	checkSnapshot(t, gc, 80*time.Second, ClockSnapshot{Red: 0, Black: 7000, Turn: "red"})
+	if gc.flagIfExpired(roleRed, at(72*time.Second)) {
+		t.Errorf("red flagged with exactly 0 byoyomi left")
+	}
+	if !gc.flagIfExpired(roleRed, at(72*time.Second+time.Millisecond)) {
+		t.Errorf("red not flagged after byoyomi ran out")
+	}
+}
+
+func TestClockResume(t *testing.T) {
+	gc := newTestClock(t, TimeControl{Base: 10, Byoyomi: 30})
+	// 重启前黑方已进入读秒，恢复后重新获得完整的读秒时间
+	gc.resume(ClockSnapshot{Red: 4000, Black: 0, Turn: "black", ByoyomiLeft: 3000}, at(0))
+	checkSnapshot(t, gc, 5*time.Second, ClockSnapshot{Red: 4000, Black: 0, Turn: "black", ByoyomiLeft: 25000})
+	gc.punch(roleBlack, at(5*time.Second))
+	checkSnapshot(t, gc, 6*time.Second, ClockSnapshot{Red: 3000, Black: 0, Turn: "red"})
+}
+
+func TestClockFlagTimer(t *testing.T) {
+	flagged := make(chan clientRole, 2)
+	gc := newGameClock(TimeControl{Base: 60}, func(loser clientRole) { flagged <- loser })
+	defer gc.stop()
+	now := time.Now()
+	// 定时器按剩余时间触发
+	gc.resume(ClockSnapshot{Red: 60000, Black: 20, Turn: "black"}, now)
+	select {
+	case loser := <-flagged:
+		if loser != roleBlack {
+			t.Errorf("flag for %s, want black", roleName(loser))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("clock did not flag")
+	}
+	if gc.flagIfExpired(roleBlack, now.Add(time.Second)) {
+		t.Errorf("flagIfExpired reported the timer flag again")
+	}
+
+	// 按钟后旧的定时器作废
+	gc.resume(ClockSnapshot{Red: 50, Black: 60000, Turn: "red"}, now)
+	gc.punch(roleRed, now)
+	select {
+	case loser := <-flagged:
+		t.Errorf("stale timer flagged %s", roleName(loser))
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRoles(t *testing.T) {
+	if opponentRole(roleRed) != roleBlack || opponentRole(roleBlack) != roleRed || opponentRole(roleNone) != roleNone {
+		t.Errorf("opponentRole mismatch")
+	}
+	if roleName(roleRed) != "red" || roleName(roleBlack) != "black" || roleName(roleNone) != "" {
+		t.Errorf("roleName mismatch")
+	}
+}
@@ -0,0 +1,383 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"chinese-chess-backend/database"
+)
+
+// 多实例部署（CLUSTER_MODE=true）时：
+//   - 在线状态：Redis 中以用户ID为键记录连接所在的实例，带过期时间并由所在实例定期续期
+//   - 房间归属：房间始终由创建它的实例处理，房间ID由 Redis 统一分配，chess:room:owner:<id> 记录所在实例
+//   - 跨实例消息：每个实例订阅 chess:node:<实例ID>，推送给其他实例上的用户、转发给房间所在实例的消息都经此频道
+//
+// 玩家连接在 B、房间在 A 时，A 上以代理客户端（connNode 为 B）代表该玩家参与对局，发给代理客户端的消息
+// 发布到 B 后写入真实连接；B 上的客户端记录 roomNode 为 A，对局相关的消息原样转发给 A 处理。
+// 随机匹配与大厅房间列表仍按实例划分；重连需由负载均衡保持会话粘滞，才能回到原来的实例。
+var (
+	ClusterMode = os.Getenv("CLUSTER_MODE") == "true"
+	// InstanceId 本实例标识，重启后应保持不变（用于恢复本实例的对局快照），默认取主机名
+	InstanceId = instanceId()
+)
+
+const (
+	presenceTTL             = 60 * time.Second // 在线状态的过期时间
+	presenceRefreshInterval = 20 * time.Second // 续期在线状态、清理空闲代理客户端的间隔
+	roomOwnerKeyPrefix      = "chess:room:owner:"
+	roomSeqKey              = "chess:room:seq"
+	nodeChannelPrefix       = "chess:node:"
+)
+
+// 跨实例消息类型
+const (
+	clusterDeliver    = "deliver"    // 推送给连接在目标实例上的用户
+	clusterForward    = "forward"    // 玩家的消息转发给房间所在实例
+	clusterAttach     = "attach"     // 房间所在实例通知：玩家已进入该实例上的房间（对局或观战）
+	clusterDetach     = "detach"     // 房间所在实例通知：玩家已离开该实例上的房间
+	clusterDisconnect = "disconnect" // 连接所在实例通知：玩家连接断开
+	clusterReconnect  = "reconnect"  // 连接所在实例通知：玩家已重连
+)
+
+// clusterEnvelope 实例之间传递的消息
+type clusterEnvelope struct {
+	Kind     string          `json:"kind"`
+	From     string          `json:"from"`
+	UserId   int             `json:"userId"`
+	Username string          `json:"username,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+func instanceId() string {
+	if id := os.Getenv("INSTANCE_ID"); id != "" {
+		return id
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return strconv.Itoa(os.Getpid())
+}
+
+func nodeChannel(node string) string {
+	return nodeChannelPrefix + node
+}
+
+// publishEnvelope 向指定实例发送消息
+func publishEnvelope(node string, env clusterEnvelope) error {
+	env.From = InstanceId
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return database.Publish(nodeChannel(node), string(data))
+}
+
+// deliverToNode 将消息推送给连接在其他实例上的用户
+func deliverToNode(node string, userId int, message any) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return publishEnvelope(node, clusterEnvelope{Kind: clusterDeliver, UserId: userId, Payload: payload})
+}
+
+// setPresence 记录用户连接在本实例；集群模式下带过期时间，由 runCluster 定期续期
+func setPresence(userId int) {
+	var ttl time.Duration
+	if ClusterMode {
+		ttl = presenceTTL
+	}
+	if err := database.SetValue(fmt.Sprint(userId), InstanceId, ttl); err != nil {
+		log.Printf("set presence of user %d failed: %v", userId, err)
+	}
+}
+
+// clearPresence 用户离线；集群模式下用户可能已在其他实例重新连接，只删除本实例写入的记录
+func clearPresence(userId int) {
+	key := fmt.Sprint(userId)
+	if ClusterMode {
+		if node, err := database.GetValue(key); err != nil || node != InstanceId {
+			return
+		}
+	}
+	database.DeleteValue(key)
+}
+
+// presenceNode 查询用户连接所在的实例，仅集群模式下有效
+func presenceNode(userId int) (string, bool) {
+	if !ClusterMode {
+		return "", false
+	}
+	node, err := database.GetValue(fmt.Sprint(userId))
+	if err != nil || node == "" {
+		return "", false
+	}
+	return node, true
+}
+
+// allocRoomId 集群模式下由 Redis 分配全局唯一的房间ID
+func allocRoomId() (int, bool) {
+	if !ClusterMode {
+		return 0, false
+	}
+	id, err := database.Incr(roomSeqKey)
+	if err != nil {
+		log.Printf("allocate room id failed, falling back to local id: %v", err)
+		return 0, false
+	}
+	return int(id), true
+}
+
+// setRoomOwner 记录房间由本实例处理
+func setRoomOwner(roomId int) {
+	if !ClusterMode {
+		return
+	}
+	if err := database.SetValue(roomOwnerKeyPrefix+strconv.Itoa(roomId), InstanceId, 0); err != nil {
+		log.Printf("room %d: set owner failed: %v", roomId, err)
+	}
+}
+
+func clearRoomOwner(roomId int) {
+	if !ClusterMode {
+		return
+	}
+	database.DeleteValue(roomOwnerKeyPrefix + strconv.Itoa(roomId))
+}
+
+// remoteRoomNode 返回房间所在的其他实例；房间在本实例或不存在时返回空串
+func (ch *ChessHub) remoteRoomNode(roomId int) string {
+	if !ClusterMode || roomId <= 0 {
+		return ""
+	}
+	ch.mu.Lock()
+	_, local := ch.Rooms[roomId]
+	ch.mu.Unlock()
+	if local {
+		return ""
+	}
+	node, err := database.GetValue(roomOwnerKeyPrefix + strconv.Itoa(roomId))
+	if err != nil || node == InstanceId {
+		return ""
+	}
+	return node
+}
+
+// isOnline 用户是否在线（集群模式下包括连接在其他实例上的用户）
+func (ch *ChessHub) isOnline(userId int) bool {
+	ch.mu.Lock()
+	c, ok := ch.Clients[userId]
+	ch.mu.Unlock()
+	if ok && c.connNode == "" {
+		return true
+	}
+	_, ok = presenceNode(userId)
+	return ok
+}
+
+// attachRemote 代理客户端进入本实例的房间后通知其连接所在实例
+func (c *Client) attachRemote() {
+	if c.connNode == "" {
+		return
+	}
+	if err := publishEnvelope(c.connNode, clusterEnvelope{Kind: clusterAttach, UserId: c.Id}); err != nil {
+		log.Printf("notify attach of user %d failed: %v", c.Id, err)
+	}
+}
+
+// releaseRemote 代理客户端不再处于本实例的任何房间时通知其连接所在实例
+func (c *Client) releaseRemote() {
+	if c.connNode == "" || c.RoomId != -1 || c.watchRoomId != 0 {
+		return
+	}
+	if err := publishEnvelope(c.connNode, clusterEnvelope{Kind: clusterDetach, UserId: c.Id}); err != nil {
+		log.Printf("notify detach of user %d failed: %v", c.Id, err)
+	}
+}
+
+// forwardToRoomNode 将需要由其他实例处理的消息转发过去，返回 true 表示消息已处理
+func (ch *ChessHub) forwardToRoomNode(client *Client, msgType MessageType, rawMessage []byte) (bool, error) {
+	if !ClusterMode || client.connNode != "" {
+		return false, nil
+	}
+	forward := func(node string, raw []byte) error {
+		return publishEnvelope(node, clusterEnvelope{
+			Kind:     clusterForward,
+			UserId:   client.Id,
+			Username: client.Username,
+			Payload:  raw,
+		})
+	}
+	busy := func() (bool, error) {
+		return true, client.sendMessage(NormalMessage{
+			BaseMessage: BaseMessage{Type: messageNormal},
+			Message:     "您正在其他对局中下棋或观战，请先退出",
+		})
+	}
+
+	switch msgType {
+	case messageJoin, messageWatch, messageFriendChallengeAccept:
+		var target struct {
+			RoomId int    `json:"roomId"`
+			Invite string `json:"invite"`
+		}
+		if err := json.Unmarshal(rawMessage, &target); err != nil {
+			return false, nil
+		}
+		roomId, err := inviteRoomId(target.RoomId, target.Invite)
+		if err != nil {
+			// 令牌无效由本地流程报告
+			return false, nil
+		}
+		node := ch.remoteRoomNode(roomId)
+		if client.roomNode != "" && client.roomNode != node {
+			if msgType != messageWatch {
+				return busy()
+			}
+			// 切换观战：先退出其他实例上的观战
+			unwatch, _ := json.Marshal(BaseMessage{Type: messageUnwatch})
+			if err := forward(client.roomNode, unwatch); err != nil {
+				log.Printf("forward unwatch of user %d failed: %v", client.Id, err)
+			}
+			client.roomNode = ""
+		}
+		if node == "" {
+			return false, nil
+		}
+		return true, forward(node, rawMessage)
+	case messageMatch, messageCreate, messageCreateAI, messageCreateEndgame:
+		if client.roomNode != "" {
+			return busy()
+		}
+	case messageMove, messageEnd, messageGiveUp, messageRegretRequest, messageRegretResponse,
+		messageDrawRequest, messageDrawResponse, messageChatMessage, messageChatMute, messageUnwatch:
+		if client.roomNode != "" {
+			return true, forward(client.roomNode, rawMessage)
+		}
+	}
+	return false, nil
+}
+
+// proxyClient 取得代表连接在其他实例上的玩家的代理客户端
+func (ch *ChessHub) proxyClient(userId int, username, node string) *Client {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if c, ok := ch.Clients[userId]; ok {
+		if c.Conn == nil && c.connNode != node {
+			// 本实例上断线（或重启后恢复）的玩家已在其他实例上重新连接
+			c.connNode = node
+			if t, ok := ch.disconnectTimers[userId]; ok {
+				t.Stop()
+				delete(ch.disconnectTimers, userId)
+			}
+		}
+		return c
+	}
+	c := NewClient(nil, userId, username)
+	c.connNode = node
+	ch.Clients[userId] = c
+	return c
+}
+
+// runCluster 订阅本实例的频道，并定期续期在线状态
+func (ch *ChessHub) runCluster() {
+	sub := database.Subscribe(nodeChannel(InstanceId))
+	go func() {
+		// 按到达顺序逐条处理，保证同一玩家的棋步顺序
+		for msg := range sub.Channel() {
+			var env clusterEnvelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Printf("invalid cluster message: %v", err)
+				continue
+			}
+			ch.handleEnvelope(env)
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(presenceRefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ch.refreshPresence()
+		}
+	}()
+	log.Printf("cluster mode enabled, instance %s", InstanceId)
+}
+
+// refreshPresence 续期本实例上用户的在线状态，并清理已离开房间的代理客户端
+func (ch *ChessHub) refreshPresence() {
+	ch.mu.Lock()
+	local := make([]int, 0, len(ch.Clients))
+	for id, c := range ch.Clients {
+		if c.connNode == "" {
+			local = append(local, id)
+			continue
+		}
+		if _, waiting := ch.disconnectTimers[id]; !waiting && c.RoomId == -1 && c.watchRoomId == 0 {
+			delete(ch.Clients, id)
+		}
+	}
+	ch.mu.Unlock()
+	for _, id := range local {
+		setPresence(id)
+	}
+}
+
+func (ch *ChessHub) handleEnvelope(env clusterEnvelope) {
+	if env.Kind == clusterForward {
+		client := ch.proxyClient(env.UserId, env.Username, env.From)
+		if err := ch.handleMessage(client, env.Payload); err != nil {
+			log.Printf("handle message forwarded from %s for user %d failed: %v", env.From, env.UserId, err)
+		}
+		return
+	}
+
+	ch.mu.Lock()
+	client := ch.Clients[env.UserId]
+	ch.mu.Unlock()
+	if client == nil {
+		return
+	}
+	switch env.Kind {
+	case clusterDeliver:
+		if client.connNode == "" {
+			client.sendMessage(env.Payload)
+		}
+	case clusterAttach:
+		if client.connNode == "" {
+			client.roomNode = env.From
+		}
+	case clusterDetach:
+		if client.roomNode == env.From {
+			client.roomNode = ""
+		}
+	case clusterDisconnect:
+		if client.connNode == env.From {
+			ch.enqueue(hubCommand{commandType: commandDisconnect, client: client})
+		}
+	case clusterReconnect:
+		if client.connNode != env.From {
+			return
+		}
+		ch.mu.Lock()
+		if t, ok := ch.disconnectTimers[client.Id]; ok {
+			t.Stop()
+			delete(ch.disconnectTimers, client.Id)
+		}
+		ch.mu.Unlock()
+		ch.resync(client)
+	}
+}
+
+// notifyRoomNode 连接所在实例将断线、重连告知玩家所在房间的实例
+func (c *Client) notifyRoomNode(kind string) {
+	if c.roomNode == "" {
+		return
+	}
+	if err := publishEnvelope(c.roomNode, clusterEnvelope{Kind: kind, UserId: c.Id}); err != nil {
+		log.Printf("notify %s of user %d failed: %v", kind, c.Id, err)
+	}
+}
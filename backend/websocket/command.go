@@ -25,11 +25,28 @@ const (
 	commandFriendChallengeCancel CommendType = 20
 	commandFriendChallengeAccept CommendType = 21
 	commandFriendChallengeReject CommendType = 22
+	commandMatchSweep            CommendType = 23 // 定期重试匹配
+	commandWatch                 CommendType = 24 // 观战
+	commandUnwatch               CommendType = 25 // 退出观战
+	commandSpectatorChat         CommendType = 26 // 观战区聊天
+	commandChatMute              CommendType = 27 // 棋手开关本局聊天
+	commandShutdown              CommendType = 28 // 停机：命令循环处理完此前的命令后退出
+	commandCreateAI              CommendType = 29 // 创建人机对局
+	commandCreateEndgame         CommendType = 30 // 开始残局挑战
+	commandTournamentTick        CommendType = 31 // 比赛编排：判定未到场、推进轮次并为新对阵创建房间
 )
 
 type moveRequest struct {
 	from *Client
 	move MoveMessage
+	seq  int64 // AI 走子时为搜索开始时的 aiPlayer.seq
+}
+
+// endRequest commandEnd 的 payload
+// reason 为空表示客户端自行上报的结束，需要由服务端棋盘裁定
+type endRequest struct {
+	winner clientRole
+	reason string
 }
 
 type sendMessageRequest struct {
@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"chinese-chess-backend/ai"
+	endgameDto "chinese-chess-backend/dto/endgame"
+	endgameModel "chinese-chess-backend/model/endgame"
+	recordModel "chinese-chess-backend/model/record"
+	"chinese-chess-backend/service"
+)
+
+// createEndgameMessage 开始残局挑战的请求
+type createEndgameMessage struct {
+	BaseMessage
+	ScenarioId string `json:"scenarioId"`
+}
+
+// endgameSession 残局挑战中的关卡与玩家执的颜色
+type endgameSession struct {
+	scenario *endgameModel.Scenario
+	player   clientRole
+}
+
+// startEndgame 为玩家创建残局挑战：从关卡局面开始，玩家执行棋方先走，服务端 AI 以最高难度执另一方
+func (ch *ChessHub) startEndgame(client *Client, sc *endgameModel.Scenario) {
+	bot := newAIClient(ai.MaxLevel, nil)
+	r := NewChessRoom()
+	r.GameType = recordModel.GameTypeEndgame
+	r.StartFEN = sc.FEN
+	r.resetBoard()
+	player := clientRole(r.startTurn)
+	r.endgame = &endgameSession{scenario: sc, player: player}
+	// 先加入的一方为 Current，即先走的玩家
+	r.join(client)
+	r.join(bot)
+	ch.mu.Lock()
+	ch.addRoom(r)
+	ch.mu.Unlock()
+
+	client.startPlay(player)
+	bot.startPlay(opponentRole(player))
+	ch.stopWatching(client)
+	r.StartTime = time.Now()
+
+	client.sendMessage(startMessage{
+		BaseMessage: BaseMessage{Type: messageStart},
+		Role:        roleName(player),
+		Opponent:    OpponentInfo{Name: bot.Username},
+		AiColor:     roleName(bot.Role),
+		ScenarioId:  sc.ID,
+		FEN:         sc.FEN,
+		Goal:        sc.Goal,
+		MoveLimit:   sc.MoveLimit,
+	})
+}
+
+// endgameSteps 玩家已走的步数，调用方需持有 cr.mu
+func (cr *ChessRoom) endgameSteps() int {
+	steps := 0
+	for ply := 0; ply < len(cr.History)/2; ply++ {
+		if cr.moverAt(ply) == cr.endgame.player {
+			steps++
+		}
+	}
+	return steps
+}
+
+// endgameVerdict 玩家走满限定步数仍未分出胜负时结束挑战：取胜关卡判 AI 胜，守和关卡判和
+func (cr *ChessRoom) endgameVerdict(mover clientRole) (clientRole, string, bool) {
+	if cr.endgame == nil || mover != cr.endgame.player {
+		return roleNone, "", false
+	}
+	cr.mu.Lock()
+	steps := cr.endgameSteps()
+	cr.mu.Unlock()
+	if steps < cr.endgame.scenario.MoveLimit {
+		return roleNone, "", false
+	}
+	if cr.endgame.scenario.Goal == endgameModel.GoalDraw {
+		return roleNone, recordModel.EndReasonStepLimit, true
+	}
+	return opponentRole(cr.endgame.player), recordModel.EndReasonStepLimit, true
+}
+
+// saveEndgameResult 按服务端裁定的结果记录残局进度并发放经验，与 saveGameRecord 一样只结算一次
+// 停机判和不是玩家的真实结果，不计入进度
+func saveEndgameResult(room *ChessRoom, winner clientRole, reason string) *endgameDto.EndgameResult {
+	room.mu.Lock()
+	if room.RecordSaved || reason == recordModel.EndReasonShutdown {
+		room.mu.Unlock()
+		return nil
+	}
+	room.RecordSaved = true
+	steps := room.endgameSteps()
+	room.mu.Unlock()
+
+	s := room.endgame
+	success := winner == s.player
+	if s.scenario.Goal == endgameModel.GoalDraw {
+		success = winner != opponentRole(s.player)
+	}
+	var player *Client
+	for _, c := range []*Client{room.Current, room.Next} {
+		if c != nil && c.ai == nil {
+			player = c
+		}
+	}
+	if player == nil {
+		return nil
+	}
+	result, err := service.NewEndgameService().RecordResult(player.Id, s.scenario, success, steps)
+	if err != nil {
+		log.Printf("room %d: save endgame result failed: %v", room.Id, err)
+		return nil
+	}
+	return result
+}
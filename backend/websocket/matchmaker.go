@@ -0,0 +1,345 @@
+package websocket
+
+import (
+	"errors"
+	"time"
+
+	"chinese-chess-backend/dto/room"
+)
+
+// 匹配窗口参数：可接受的等级分差随等待时间线性放宽
+const (
+	MatchWindowBase     = 100              // 刚进入队列时可接受的等级分差
+	MatchWindowStep     = 50               // 每等待一个 MatchWindowInterval 放宽的分差
+	MatchWindowInterval = 5 * time.Second  // 放宽窗口的时间间隔
+	MatchSweepInterval  = 2 * time.Second  // 定期为队列中的玩家重新尝试配对的间隔
+	RematchCooldown     = 30 * time.Second // 刚对局过的两人在对局结束后超过该时间才会再次配对
+	defaultMatchWait    = 30 * time.Second // 没有历史数据时的预计等待时间
+	matchWaitSamples    = 20               // 估算等待时间所用的最近成功配对数
+)
+
+// 匹配队列的用时档位
+const (
+	SpeedBlitz     = "blitz"     // 快棋
+	SpeedRapid     = "rapid"     // 中速棋
+	SpeedClassical = "classical" // 慢棋
+	speedCustom    = "custom"    // 自定义时间控制，兼容只提交 timeControl 的客户端
+)
+
+// speedPresets 各档位对应的时间控制
+var speedPresets = map[string]TimeControl{
+	SpeedBlitz:     {Base: 5 * 60, Increment: 3},
+	SpeedRapid:     {Base: 15 * 60, Increment: 10},
+	SpeedClassical: {Base: 30 * 60, Byoyomi: 60},
+}
+
+// speedOrder 大厅展示队列的顺序
+var speedOrder = []string{SpeedBlitz, SpeedRapid, SpeedClassical}
+
+// 计分模式
+const (
+	modeRated  = "rated"  // 计入等级分
+	modeCasual = "casual" // 娱乐对局，不计分
+)
+
+// matchQueue 匹配队列参数，只有参数完全相同的玩家才会被配对
+type matchQueue struct {
+	Speed       string
+	TimeControl TimeControl
+	Rated       bool
+}
+
+// parseMatchQueue 根据匹配消息确定队列：指定档位时使用预设时间控制，否则使用自定义时间控制；计分模式缺省为计分
+func parseMatchQueue(m matchMessage) (matchQueue, error) {
+	q := matchQueue{Speed: m.Speed, Rated: true}
+	switch m.Mode {
+	case "", modeRated:
+	case modeCasual:
+		q.Rated = false
+	default:
+		return q, errors.New("不支持的对局模式，可选 rated、casual")
+	}
+	if m.Speed == "" {
+		if err := m.TimeControl.Validate(); err != nil {
+			return q, err
+		}
+		q.Speed = speedCustom
+		q.TimeControl = m.TimeControl
+		return q, nil
+	}
+	tc, ok := speedPresets[m.Speed]
+	if !ok {
+		return q, errors.New("不支持的用时档位，可选 blitz、rapid、classical")
+	}
+	q.TimeControl = tc
+	return q, nil
+}
+
+// matchEntry 匹配队列中的一名玩家
+type matchEntry struct {
+	client   *Client
+	queue    matchQueue
+	rating   int
+	joinedAt time.Time
+}
+
+// matchPair 一次成功的配对，先进入队列的一方执红
+type matchPair struct {
+	red, black *Client
+	queue      matchQueue
+}
+
+// lastMatch 玩家上一局匹配到的对手
+type lastMatch struct {
+	opponent int
+	endedAt  time.Time // 对局结束时间，对局进行中时为配对时间
+}
+
+// matchmaker 按队列参数分组、按等级分接近程度配对的匹配器，由 ChessHub.mu 保护
+type matchmaker struct {
+	queues       map[matchQueue][]*matchEntry // 每个队列按进入的先后排列
+	lastOpponent map[int]lastMatch            // 用户ID -> 上一局匹配到的对手
+	recentWaits  map[matchQueue][]time.Duration
+}
+
+func newMatchmaker() *matchmaker {
+	return &matchmaker{
+		queues:       make(map[matchQueue][]*matchEntry),
+		lastOpponent: make(map[int]lastMatch),
+		recentWaits:  make(map[matchQueue][]time.Duration),
+	}
+}
+
+// pending 是否有队列中的玩家数不少于两人（可能配对成功）
+func (mm *matchmaker) pending() bool {
+	for _, q := range mm.queues {
+		if len(q) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// find 返回玩家所在的队列条目
+func (mm *matchmaker) find(id int) *matchEntry {
+	for _, q := range mm.queues {
+		for _, e := range q {
+			if e.client.Id == id {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (mm *matchmaker) contains(id int) bool {
+	return mm.find(id) != nil
+}
+
+// add 将玩家加入队列，已在同一队列中时返回原有条目，在其他队列中时先移出
+func (mm *matchmaker) add(c *Client, q matchQueue, rating int, now time.Time) *matchEntry {
+	if e := mm.find(c.Id); e != nil {
+		if e.queue == q {
+			return e
+		}
+		mm.remove(c.Id)
+	}
+	e := &matchEntry{client: c, queue: q, rating: rating, joinedAt: now}
+	mm.queues[q] = append(mm.queues[q], e)
+	return e
+}
+
+// remove 将玩家移出队列，返回其是否在队列中
+func (mm *matchmaker) remove(id int) bool {
+	for key, q := range mm.queues {
+		for i, e := range q {
+			if e.client.Id != id {
+				continue
+			}
+			if len(q) == 1 {
+				delete(mm.queues, key)
+			} else {
+				mm.queues[key] = append(q[:i], q[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// drain 清空所有队列，返回被移出的玩家
+func (mm *matchmaker) drain() []*Client {
+	clients := make([]*Client, 0)
+	for _, q := range mm.queues {
+		for _, e := range q {
+			clients = append(clients, e.client)
+		}
+	}
+	mm.queues = make(map[matchQueue][]*matchEntry)
+	return clients
+}
+
+// sizes 返回各队列的排队人数：预设档位的队列总是列出，自定义时间控制的队列有人时才列出
+func (mm *matchmaker) sizes() []room.QueueInfo {
+	infos := make([]room.QueueInfo, 0, len(speedOrder)*2)
+	for _, speed := range speedOrder {
+		for _, rated := range []bool{true, false} {
+			q := matchQueue{Speed: speed, TimeControl: speedPresets[speed], Rated: rated}
+			infos = append(infos, queueInfo(q, len(mm.queues[q])))
+		}
+	}
+	for q, entries := range mm.queues {
+		if q.Speed == speedCustom {
+			infos = append(infos, queueInfo(q, len(entries)))
+		}
+	}
+	return infos
+}
+
+func queueInfo(q matchQueue, players int) room.QueueInfo {
+	mode := modeRated
+	if !q.Rated {
+		mode = modeCasual
+	}
+	return room.QueueInfo{
+		Speed:     q.Speed,
+		Mode:      mode,
+		Base:      q.TimeControl.Base,
+		Increment: q.TimeControl.Increment,
+		Byoyomi:   q.TimeControl.Byoyomi,
+		Players:   players,
+	}
+}
+
+// forget 用户下线后清除其上一局对手记录
+func (mm *matchmaker) forget(id int) {
+	delete(mm.lastOpponent, id)
+}
+
+// gameEnded 两人的对局结束，重复配对的冷却期从此时开始计算；不是彼此上一局对手时忽略
+func (mm *matchmaker) gameEnded(a, b int, now time.Time) {
+	for _, ids := range [][2]int{{a, b}, {b, a}} {
+		if last, ok := mm.lastOpponent[ids[0]]; ok && last.opponent == ids[1] {
+			mm.lastOpponent[ids[0]] = lastMatch{opponent: ids[1], endedAt: now}
+		}
+	}
+}
+
+// window 返回玩家在 now 时刻可接受的等级分差
+func (mm *matchmaker) window(e *matchEntry, now time.Time) int {
+	return MatchWindowBase + MatchWindowStep*int(now.Sub(e.joinedAt)/MatchWindowInterval)
+}
+
+// isRematch 两人上一局是否正是彼此，且距离那一局结束仍在冷却期内
+func (mm *matchmaker) isRematch(a, b *matchEntry, now time.Time) bool {
+	for _, ids := range [][2]int{{a.client.Id, b.client.Id}, {b.client.Id, a.client.Id}} {
+		if last, ok := mm.lastOpponent[ids[0]]; ok && last.opponent == ids[1] && now.Sub(last.endedAt) < RematchCooldown {
+			return true
+		}
+	}
+	return false
+}
+
+// compatible 两人能否互相匹配：同一队列且不是冷却期内的重复对局
+func (mm *matchmaker) compatible(a, b *matchEntry, now time.Time) bool {
+	return a.client.Id != b.client.Id && a.queue == b.queue && !mm.isRematch(a, b, now)
+}
+
+// acceptable 等级分差是否落在两人中等待更久一方的窗口内
+func (mm *matchmaker) acceptable(a, b *matchEntry, now time.Time) bool {
+	return mm.compatible(a, b, now) && abs(a.rating-b.rating) <= max(mm.window(a, now), mm.window(b, now))
+}
+
+// pairAll 在每个队列内按等待时间从长到短为每名玩家寻找分差最小的可接受对手，返回本次完成的所有配对
+func (mm *matchmaker) pairAll(now time.Time) []matchPair {
+	var pairs []matchPair
+	for key, queue := range mm.queues {
+		for i := 0; i < len(queue); i++ {
+			a := queue[i]
+			best := -1
+			for j := i + 1; j < len(queue); j++ {
+				b := queue[j]
+				if !mm.acceptable(a, b, now) {
+					continue
+				}
+				if best < 0 || abs(a.rating-b.rating) < abs(a.rating-queue[best].rating) {
+					best = j
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			b := queue[best]
+			queue = append(queue[:best], queue[best+1:]...)
+			queue = append(queue[:i], queue[i+1:]...)
+			i--
+			mm.lastOpponent[a.client.Id] = lastMatch{opponent: b.client.Id, endedAt: now}
+			mm.lastOpponent[b.client.Id] = lastMatch{opponent: a.client.Id, endedAt: now}
+			mm.recordWait(key, now.Sub(a.joinedAt))
+			mm.recordWait(key, now.Sub(b.joinedAt))
+			pairs = append(pairs, matchPair{red: a.client, black: b.client, queue: key})
+		}
+		if len(queue) == 0 {
+			delete(mm.queues, key)
+		} else {
+			mm.queues[key] = queue
+		}
+	}
+	return pairs
+}
+
+func (mm *matchmaker) recordWait(q matchQueue, d time.Duration) {
+	waits := append(mm.recentWaits[q], d)
+	if len(waits) > matchWaitSamples {
+		waits = waits[len(waits)-matchWaitSamples:]
+	}
+	mm.recentWaits[q] = waits
+}
+
+// estimateWait 估算玩家还需等待的时间：
+// 同一队列中已有其他玩家时，按窗口放宽到覆盖最接近的对手所需的时间计算；
+// 否则按最近成功配对的平均等待时间扣除已等待时间
+func (mm *matchmaker) estimateWait(e *matchEntry, now time.Time) time.Duration {
+	waited := now.Sub(e.joinedAt)
+	best := time.Duration(-1)
+	for _, o := range mm.queues[e.queue] {
+		if !mm.compatible(e, o, now) {
+			continue
+		}
+		// 任一方的窗口放宽到覆盖分差即可配对，冷却期内的重复对手不计入
+		need := mm.timeToCover(e, abs(e.rating-o.rating), now)
+		need = min(need, mm.timeToCover(o, abs(e.rating-o.rating), now))
+		if best < 0 || need < best {
+			best = need
+		}
+	}
+	if best >= 0 {
+		return max(best, MatchSweepInterval)
+	}
+
+	avg := defaultMatchWait
+	if waits := mm.recentWaits[e.queue]; len(waits) > 0 {
+		var sum time.Duration
+		for _, d := range waits {
+			sum += d
+		}
+		avg = sum / time.Duration(len(waits))
+	}
+	return max(avg-waited, MatchSweepInterval)
+}
+
+// timeToCover 玩家的窗口从 now 起放宽到 gap 还需的时间
+func (mm *matchmaker) timeToCover(e *matchEntry, gap int, now time.Time) time.Duration {
+	if gap <= MatchWindowBase {
+		return 0
+	}
+	steps := (gap - MatchWindowBase + MatchWindowStep - 1) / MatchWindowStep
+	return max(e.joinedAt.Add(time.Duration(steps)*MatchWindowInterval).Sub(now), 0)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
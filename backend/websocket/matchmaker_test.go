@@ -0,0 +1,275 @@
+package websocket
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+var blitzRated = matchQueue{Speed: SpeedBlitz, TimeControl: speedPresets[SpeedBlitz], Rated: true}
+
+// joiner 进入队列的玩家：在 after 时刻以 rating 进入队列 q（零值为计分快棋）
+type joiner struct {
+	id, rating int
+	after      time.Duration
+	q          matchQueue
+}
+
+func (mm *matchmaker) join(players ...joiner) {
+	for _, p := range players {
+		q := p.q
+		if q == (matchQueue{}) {
+			q = blitzRated
+		}
+		mm.add(&Client{Id: p.id}, q, p.rating, at(p.after))
+	}
+}
+
+// pairIDs 将配对结果写成 "红方-黑方"，按字典序排列
+func pairIDs(pairs []matchPair) []string {
+	out := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		out = append(out, fmt.Sprintf("%d-%d", p.red.Id, p.black.Id))
+	}
+	slices.Sort(out)
+	return out
+}
+
+// queuedIDs 仍在队列中的玩家
+func (mm *matchmaker) queuedIDs() []int {
+	var ids []int
+	for _, q := range mm.queues {
+		for _, e := range q {
+			ids = append(ids, e.client.Id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestPairAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []joiner
+		now     time.Duration
+		pairs   []string
+		left    []int
+	}{
+		{"within base window", []joiner{{id: 1, rating: 1500}, {id: 2, rating: 1600}}, 0, []string{"1-2"}, nil},
+		{"outside base window", []joiner{{id: 1, rating: 1500}, {id: 2, rating: 1601}}, 0, []string{}, []int{1, 2}},
+		{"closest opponent", []joiner{{id: 1, rating: 1500}, {id: 2, rating: 1590}, {id: 3, rating: 1520}}, 0, []string{"1-3"}, []int{2}},
+		// 等待最久的玩家先挑对手并执红
+		{"longest wait first", []joiner{{id: 1, rating: 1500}, {id: 2, rating: 1510, after: time.Second}, {id: 3, rating: 1505, after: 2 * time.Second}}, 2 * time.Second, []string{"1-3"}, []int{2}},
+		{"two pairs", []joiner{{id: 1, rating: 1500}, {id: 2, rating: 2000}, {id: 3, rating: 1550}, {id: 4, rating: 2050}}, 0, []string{"1-3", "2-4"}, nil},
+		// 等待 10 秒后窗口放宽到 200 分
+		{"not yet widened", []joiner{{id: 1, rating: 1500}, {id: 2, rating: 1700, after: 5 * time.Second}}, 10*time.Second - time.Millisecond, []string{}, []int{1, 2}},
+		{"widened window", []joiner{{id: 1, rating: 1500}, {id: 2, rating: 1700, after: 5 * time.Second}}, 10 * time.Second, []string{"1-2"}, nil},
+		// 只要等待更久一方的窗口覆盖分差即可
+		{"one wide window is enough", []joiner{{id: 2, rating: 1500}, {id: 1, rating: 1700, after: 9 * time.Second}}, 10 * time.Second, []string{"2-1"}, nil},
+		{"alone", []joiner{{id: 1, rating: 1500}}, time.Hour, []string{}, []int{1}},
+	}
+	for _, tt := range tests {
+		mm := newMatchmaker()
+		mm.join(tt.players...)
+		if got := pairIDs(mm.pairAll(at(tt.now))); !slices.Equal(got, tt.pairs) {
+			t.Errorf("%s: pairs = %v, want %v", tt.name, got, tt.pairs)
+		}
+		if got := mm.queuedIDs(); !slices.Equal(got, tt.left) {
+			t.Errorf("%s: left in queue = %v, want %v", tt.name, got, tt.left)
+		}
+		if mm.pending() != (len(tt.left) > 1) {
+			t.Errorf("%s: pending = %v with %v queued", tt.name, mm.pending(), tt.left)
+		}
+	}
+}
+
+func TestMatchWindow(t *testing.T) {
+	mm := newMatchmaker()
+	e := &matchEntry{client: &Client{Id: 1}, queue: blitzRated, rating: 1500, joinedAt: at(0)}
+	for waited, want := range map[time.Duration]int{
+		0:                                      MatchWindowBase,
+		MatchWindowInterval - time.Millisecond: MatchWindowBase,
+		MatchWindowInterval:                    MatchWindowBase + MatchWindowStep,
+		12 * MatchWindowInterval:               MatchWindowBase + 12*MatchWindowStep,
+	} {
+		if got := mm.window(e, at(waited)); got != want {
+			t.Errorf("window after %v = %d, want %d", waited, got, want)
+		}
+	}
+}
+
+func TestRematchCooldown(t *testing.T) {
+	mm := newMatchmaker()
+	mm.join(joiner{id: 1, rating: 1500}, joiner{id: 2, rating: 1500})
+	if got := pairIDs(mm.pairAll(at(0))); !slices.Equal(got, []string{"1-2"}) {
+		t.Fatalf("first pairing = %v", got)
+	}
+	// 与别人的对局结束不影响冷却期
+	mm.gameEnded(1, 3, at(time.Minute))
+	if last := mm.lastOpponent[1]; last.opponent != 2 || !last.endedAt.Equal(at(0)) {
+		t.Errorf("gameEnded with another opponent changed %+v", last)
+	}
+	// 冷却期从两人的对局结束时算起
+	ended := 10 * time.Minute
+	mm.gameEnded(2, 1, at(ended))
+	mm.join(joiner{id: 1, rating: 1500, after: ended}, joiner{id: 2, rating: 1500, after: ended})
+	if got := mm.pairAll(at(ended + RematchCooldown - time.Millisecond)); len(got) != 0 {
+		t.Errorf("rematch inside cooldown: %v", pairIDs(got))
+	}
+	// 冷却期内优先与其他玩家配对，即使分差更大
+	mm.join(joiner{id: 3, rating: 1580, after: ended})
+	if got := pairIDs(mm.pairAll(at(ended + time.Second))); !slices.Equal(got, []string{"1-3"}) {
+		t.Errorf("pairs with a third player = %v, want [1-3]", got)
+	}
+	if got := pairIDs(mm.pairAll(at(ended + RematchCooldown - time.Millisecond))); len(got) != 0 {
+		t.Errorf("player 2 paired alone: %v", got)
+	}
+
+	mm = newMatchmaker()
+	mm.join(joiner{id: 1, rating: 1500}, joiner{id: 2, rating: 1500})
+	mm.pairAll(at(0))
+	mm.gameEnded(1, 2, at(ended))
+	mm.join(joiner{id: 1, rating: 1500, after: ended}, joiner{id: 2, rating: 1500, after: ended})
+	if got := pairIDs(mm.pairAll(at(ended + RematchCooldown))); !slices.Equal(got, []string{"1-2"}) {
+		t.Errorf("rematch after cooldown = %v, want [1-2]", got)
+	}
+}
+
+func TestEstimateWait(t *testing.T) {
+	mm := newMatchmaker()
+	mm.join(joiner{id: 1, rating: 1500})
+	e := mm.find(1)
+	if got := mm.estimateWait(e, at(0)); got != defaultMatchWait {
+		t.Errorf("no history: %v, want %v", got, defaultMatchWait)
+	}
+	// 分差 200 需要窗口放宽两次
+	mm.join(joiner{id: 2, rating: 1700, after: 2 * time.Second})
+	if got, want := mm.estimateWait(e, at(3*time.Second)), 2*MatchWindowInterval-3*time.Second; got != want {
+		t.Errorf("opponent 200 away: %v, want %v", got, want)
+	}
+	// 已能配对时不短于一次定期配对的间隔
+	mm.join(joiner{id: 3, rating: 1550, after: 2 * time.Second})
+	if got := mm.estimateWait(e, at(3*time.Second)); got != MatchSweepInterval {
+		t.Errorf("opponent in window: %v, want %v", got, MatchSweepInterval)
+	}
+
+	// 队列中没有其他人时按最近的平均等待时间估算
+	mm = newMatchmaker()
+	mm.recordWait(blitzRated, 20*time.Second)
+	mm.recordWait(blitzRated, 40*time.Second)
+	mm.join(joiner{id: 1, rating: 1500})
+	if got := mm.estimateWait(mm.find(1), at(10*time.Second)); got != 20*time.Second {
+		t.Errorf("average wait: %v, want 20s", got)
+	}
+	for i := 0; i < matchWaitSamples+5; i++ {
+		mm.recordWait(blitzRated, time.Second)
+	}
+	if n := len(mm.recentWaits[blitzRated]); n != matchWaitSamples {
+		t.Errorf("kept %d wait samples, want %d", n, matchWaitSamples)
+	}
+}
+
+func TestPairAllQueues(t *testing.T) {
+	blitzCasual := matchQueue{Speed: SpeedBlitz, TimeControl: speedPresets[SpeedBlitz]}
+	rapidRated := matchQueue{Speed: SpeedRapid, TimeControl: speedPresets[SpeedRapid], Rated: true}
+	custom := matchQueue{Speed: speedCustom, TimeControl: TimeControl{Base: 600}, Rated: true}
+	otherCustom := matchQueue{Speed: speedCustom, TimeControl: TimeControl{Base: 600, Increment: 1}, Rated: true}
+
+	mm := newMatchmaker()
+	mm.join(
+		joiner{id: 1, rating: 1500},
+		joiner{id: 2, rating: 1500, q: blitzCasual},
+		joiner{id: 3, rating: 1500, q: rapidRated},
+		joiner{id: 4, rating: 1500, q: custom},
+		joiner{id: 5, rating: 1500, q: otherCustom},
+		joiner{id: 6, rating: 1510, q: custom},
+		joiner{id: 7, rating: 1900, q: blitzCasual},
+		joiner{id: 8, rating: 1520, q: rapidRated},
+	)
+	// 只在参数完全相同的队列内配对，分差再小也不跨队列
+	pairs := mm.pairAll(at(time.Second))
+	if got := pairIDs(pairs); !slices.Equal(got, []string{"3-8", "4-6"}) {
+		t.Errorf("pairs = %v, want [3-8 4-6]", got)
+	}
+	for _, p := range pairs {
+		if want := map[int]matchQueue{3: rapidRated, 4: custom}[p.red.Id]; p.queue != want {
+			t.Errorf("pair %d-%d in queue %+v, want %+v", p.red.Id, p.black.Id, p.queue, want)
+		}
+	}
+	if got := mm.queuedIDs(); !slices.Equal(got, []int{1, 2, 5, 7}) {
+		t.Errorf("left in queue = %v, want [1 2 5 7]", got)
+	}
+	// 配对完的队列被移除
+	if _, ok := mm.queues[rapidRated]; ok {
+		t.Errorf("empty rapid queue kept")
+	}
+
+	// 换队列时先移出原队列
+	mm.join(joiner{id: 1, rating: 1500, q: blitzCasual, after: 2 * time.Second})
+	if e := mm.find(1); e == nil || e.queue != blitzCasual {
+		t.Fatalf("player 1 not moved to the casual queue: %+v", e)
+	}
+	if _, ok := mm.queues[blitzRated]; ok {
+		t.Errorf("empty rated queue kept after player 1 left")
+	}
+	// 重复加入同一队列保留原来的进入时间
+	mm.join(joiner{id: 2, rating: 1500, q: blitzCasual, after: 3 * time.Second})
+	if e := mm.find(2); !e.joinedAt.Equal(at(0)) {
+		t.Errorf("rejoining reset the wait: joined at %v", e.joinedAt)
+	}
+	if got := pairIDs(mm.pairAll(at(3 * time.Second))); !slices.Equal(got, []string{"2-1"}) {
+		t.Errorf("casual pairs = %v, want [2-1]", got)
+	}
+
+	if !mm.remove(5) || mm.remove(5) || mm.contains(5) {
+		t.Errorf("remove(5) did not take player 5 out exactly once")
+	}
+	if got := mm.drain(); len(got) != 1 || got[0].Id != 7 || len(mm.queues) != 0 {
+		t.Errorf("drain = %v, queues left %v", got, mm.queues)
+	}
+}
+
+func TestParseMatchQueue(t *testing.T) {
+	tests := []struct {
+		msg  matchMessage
+		want matchQueue
+		ok   bool
+	}{
+		{matchMessage{Speed: SpeedBlitz}, blitzRated, true},
+		{matchMessage{Speed: SpeedRapid, Mode: modeCasual}, matchQueue{Speed: SpeedRapid, TimeControl: speedPresets[SpeedRapid]}, true},
+		{matchMessage{Speed: SpeedClassical, Mode: modeRated}, matchQueue{Speed: SpeedClassical, TimeControl: speedPresets[SpeedClassical], Rated: true}, true},
+		// 只提交 timeControl 的客户端进入自定义队列
+		{matchMessage{TimeControl: TimeControl{Base: 600, Byoyomi: 30}}, matchQueue{Speed: speedCustom, TimeControl: TimeControl{Base: 600, Byoyomi: 30}, Rated: true}, true},
+		{matchMessage{}, matchQueue{Speed: speedCustom, Rated: true}, true},
+		{matchMessage{Speed: "bullet"}, matchQueue{}, false},
+		{matchMessage{Speed: SpeedBlitz, Mode: "ranked"}, matchQueue{}, false},
+		{matchMessage{TimeControl: TimeControl{Increment: 5}}, matchQueue{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseMatchQueue(tt.msg)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("parseMatchQueue(%+v) = %+v, %v; want %+v, ok=%v", tt.msg, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestQueueSizes(t *testing.T) {
+	mm := newMatchmaker()
+	custom := matchQueue{Speed: speedCustom, TimeControl: TimeControl{Base: 600}}
+	mm.join(joiner{id: 1, rating: 1500}, joiner{id: 2, rating: 1900}, joiner{id: 3, rating: 1500, q: custom})
+	infos := mm.sizes()
+	// 预设档位的计分与娱乐队列总是列出，自定义队列有人时才列出
+	if len(infos) != 2*len(speedOrder)+1 {
+		t.Fatalf("sizes = %+v", infos)
+	}
+	if info := infos[0]; info.Speed != SpeedBlitz || info.Mode != modeRated || info.Players != 2 || info.Base != 300 || info.Increment != 3 {
+		t.Errorf("blitz rated = %+v", info)
+	}
+	if info := infos[1]; info.Mode != modeCasual || info.Players != 0 {
+		t.Errorf("blitz casual = %+v", info)
+	}
+	if info := infos[len(infos)-1]; info.Speed != speedCustom || info.Mode != modeCasual || info.Base != 600 || info.Players != 1 {
+		t.Errorf("custom = %+v", info)
+	}
+}
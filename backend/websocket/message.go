@@ -1,5 +1,10 @@
 package websocket
 
+import (
+	endgameDto "chinese-chess-backend/dto/endgame"
+	ratingDto "chinese-chess-backend/dto/rating"
+)
+
 type MessageType int
 
 // 信息类型
@@ -27,6 +32,13 @@ const (
 	messageFriendChallengeAccept  MessageType = 20 // 接受挑战（receiver -> sender）
 	messageFriendChallengeReject  MessageType = 21 // 拒绝挑战（receiver -> sender）
 	messageFriendChallengeCreated MessageType = 22 // 挑战已创建（回执发给 sender）
+	messageAIMove                 MessageType = 23 // AI 走棋（AI 视角坐标，与对手走棋一致）
+	messageCreateAI               MessageType = 24 // 创建人机对局：客户端发送难度与执子颜色
+	messageWatch                  MessageType = 25 // 观战：客户端发送 roomId，服务端回复观战者视角的同步消息
+	messageUnwatch                MessageType = 26 // 退出观战
+	messageChatMute               MessageType = 27 // 棋手开关本局聊天：关闭后不再收到对手的聊天消息
+	messageCreateEndgame          MessageType = 28 // 开始残局挑战：客户端发送关卡 ID，由服务端 AI 执另一方
+	messageTournamentPairing      MessageType = 29 // 比赛对阵通知（服务端 -> 棋手）：客户端以其中的 roomId 发送 messageJoin 入场
 )
 
 type BaseMessage struct {
@@ -40,8 +52,9 @@ type Position struct {
 
 type MoveMessage struct {
 	BaseMessage
-	From Position `json:"from"`
-	To   Position `json:"to"`
+	From  Position       `json:"from"`
+	To    Position       `json:"to"`
+	Clock *ClockSnapshot `json:"clock,omitempty"` // 计时对局中由服务端填写的双方剩余时间
 }
 
 type NormalMessage struct {
@@ -55,22 +68,85 @@ type OpponentInfo struct {
 	Exp        int     `json:"exp"`
 	TotalGames int     `json:"totalGames"`
 	WinRate    float64 `json:"winRate"`
+	Rating     int     `json:"rating"` // 等级分
 }
 
 type startMessage struct {
 	BaseMessage
-	Role     string       `json:"role"`
-	Opponent OpponentInfo `json:"opponent"`
+	Role        string       `json:"role"`
+	Opponent    OpponentInfo `json:"opponent"`
+	TimeControl *TimeControl `json:"timeControl,omitempty"` // 不计时对局为空
+	AiColor     string       `json:"aiColor,omitempty"`     // 人机对局中 AI 执的颜色
+	// 残局挑战的关卡、起始局面、目标与玩家限定步数
+	ScenarioId string `json:"scenarioId,omitempty"`
+	FEN        string `json:"fen,omitempty"`
+	Goal       string `json:"goal,omitempty"`
+	MoveLimit  int    `json:"moveLimit,omitempty"`
+	// 比赛对局所属的比赛与轮次
+	TournamentId uint `json:"tournamentId,omitempty"`
+	Round        int  `json:"round,omitempty"`
+}
+
+// matchMessage 匹配请求，只与选择了相同队列参数的玩家匹配
+// speed 为 blitz/rapid/classical 时使用对应的预设时间控制；为空时使用 timeControl（为空表示不计时）
+// mode 为 rated（缺省）或 casual
+type matchMessage struct {
+	BaseMessage
+	Speed       string      `json:"speed,omitempty"`
+	Mode        string      `json:"mode,omitempty"`
+	TimeControl TimeControl `json:"timeControl"`
+}
+
+// matchingMessage 进入匹配队列后的提示，附带预计等待秒数
+type matchingMessage struct {
+	BaseMessage
+	Message       string `json:"message"`
+	EstimatedWait int    `json:"estimatedWait"`
+}
+
+// createMessage 创建房间请求，private 为 true 时不在大厅展示，password 非空时加入需要密码
+type createMessage struct {
+	BaseMessage
+	TimeControl TimeControl `json:"timeControl"`
+	Private     bool        `json:"private,omitempty"`
+	Password    string      `json:"password,omitempty"`
 }
 
+// roomCreatedMessage 创建房间成功的回执，附带可分享的邀请链接
+type roomCreatedMessage struct {
+	BaseMessage
+	RoomId      int    `json:"roomId"`
+	Private     bool   `json:"private"`
+	Locked      bool   `json:"locked"`
+	InviteToken string `json:"inviteToken"`
+	InviteURL   string `json:"inviteUrl"`
+	ExpiresAt   int64  `json:"expiresAt"` // 邀请链接过期时间（Unix 秒）
+}
+
+// joinMessage 加入房间请求；通过邀请链接加入时可只提供 invite
 type joinMessage struct {
 	BaseMessage
-	RoomId int `json:"roomId"`
+	RoomId   int    `json:"roomId"`
+	Password string `json:"password,omitempty"`
+	Invite   string `json:"invite,omitempty"`
+}
+
+// watchMessage 观战请求，私密或设密码的对局同样需要邀请令牌或密码
+type watchMessage struct {
+	BaseMessage
+	RoomId   int    `json:"roomId"`
+	Password string `json:"password,omitempty"`
+	Invite   string `json:"invite,omitempty"`
 }
 
 type endMessage struct {
 	BaseMessage
 	Winner clientRole `json:"winner"`
+	Reason string     `json:"reason,omitempty"` // 结束原因，见 record.EndReason*
+	// 计分对局中接收方本局的等级分变化
+	Rating *ratingDto.RatingChange `json:"rating,omitempty"`
+	// 残局挑战的结算结果
+	Endgame *endgameDto.EndgameResult `json:"endgame,omitempty"`
 }
 
 type RegretResponseMessage struct {
@@ -78,6 +154,12 @@ type RegretResponseMessage struct {
 	Accepted bool `json:"accepted"`
 }
 
+// chatMuteMessage 棋手屏蔽/恢复对手的聊天消息
+type chatMuteMessage struct {
+	BaseMessage
+	Muted bool `json:"muted"`
+}
+
 type DrawResponseMessage struct {
 	BaseMessage
 	Accepted bool `json:"accepted"`
@@ -87,6 +169,7 @@ type ChatMessage struct {
 	BaseMessage
 	Content    string `json:"content"`
 	Sender     string `json:"sender"`
+	Channel    string `json:"channel,omitempty"` // 对局聊天中 "spectator" 表示观战区聊天，棋手看不到
 	RelationId uint   `json:"relationId,omitempty"`
 	SenderId   uint   `json:"senderId,omitempty"`
 	MessageId  uint   `json:"messageId,omitempty"`
@@ -112,12 +195,21 @@ type FriendChallengeMessage struct {
 	ReceiverId  uint   `json:"receiverId,omitempty"`
 	SenderName  string `json:"senderName,omitempty"`
 	RoomId      int    `json:"roomId,omitempty"`
+	// 邀请方选择的时间控制，为空表示不计时
+	TimeControl *TimeControl `json:"timeControl,omitempty"`
+	// 邀请时表示提议计入等级分，接受时表示同意计分
+	Rated bool `json:"rated,omitempty"`
 }
 
 // SyncMessage 用于在玩家重连时将房间当前的棋步历史、玩家角色和当前轮次同步给客户端
+// 发给观战者时 role 为 "spectator"，历史为红方视角坐标，并附带双方昵称
 type SyncMessage struct {
 	BaseMessage
-	History     []Position `json:"history"`
-	Role        string     `json:"role"`
-	CurrentTurn string     `json:"currentTurn"`
+	History     []Position     `json:"history"`
+	Role        string         `json:"role"`
+	CurrentTurn string         `json:"currentTurn"`
+	Clock       *ClockSnapshot `json:"clock,omitempty"`
+	FEN         string         `json:"fen,omitempty"` // 起始局面，标准开局为空
+	Red         string         `json:"red,omitempty"`
+	Black       string         `json:"black,omitempty"`
 }
@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chinese-chess-backend/config"
+	"chinese-chess-backend/utils"
+)
+
+var (
+	// RoomInviteTTL 房间邀请链接的有效期
+	RoomInviteTTL = time.Duration(config.GetEnvInt("ROOM_INVITE_TTL_MINUTES", 60)) * time.Minute
+)
+
+// maxRoomPasswordLen 房间密码的最大长度（字符）
+const maxRoomPasswordLen = 32
+
+// validateRoomPassword 校验创建房间时设置的密码
+func validateRoomPassword(password string) error {
+	if utf8.RuneCountInString(password) > maxRoomPasswordLen {
+		return errors.New("房间密码不能超过 32 个字符")
+	}
+	return nil
+}
+
+// setAccess 设置房间的公开性与密码（空表示不设密码），并重新生成用于邀请令牌的随机 nonce，使之前的邀请链接失效
+func (cr *ChessRoom) setAccess(private bool, password string) error {
+	cr.Private = private
+	cr.passwordHash = ""
+	if password != "" {
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		cr.passwordHash = hash
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	cr.inviteNonce = hex.EncodeToString(nonce)
+	return nil
+}
+
+// locked 是否设置了密码
+func (cr *ChessRoom) locked() bool {
+	return cr.passwordHash != ""
+}
+
+// checkAccess 校验加入或观战房间的凭据：有效的邀请令牌总能进入；
+// 设置了密码的房间需要正确的密码；没有密码的私密房间只能通过邀请链接进入
+func (cr *ChessRoom) checkAccess(password, invite string) error {
+	if invite != "" {
+		roomId, nonce, err := utils.ParseRoomInvite(invite)
+		if err != nil {
+			return err
+		}
+		if roomId != cr.Id || nonce != cr.inviteNonce {
+			return errors.New("邀请链接与房间不匹配")
+		}
+		return nil
+	}
+	if cr.locked() {
+		if password == "" {
+			return errors.New("该房间需要密码")
+		}
+		if !utils.CheckPassword(cr.passwordHash, password) {
+			return errors.New("房间密码错误")
+		}
+		return nil
+	}
+	if cr.Private {
+		return errors.New("私密房间只能通过邀请链接加入")
+	}
+	return nil
+}
+
+// inviteRoomId 通过邀请令牌加入时以令牌中的房间为准，同时提供的房间ID须一致
+func inviteRoomId(roomId int, invite string) (int, error) {
+	if invite == "" {
+		return roomId, nil
+	}
+	id, _, err := utils.ParseRoomInvite(invite)
+	if err != nil {
+		return 0, err
+	}
+	if roomId != 0 && roomId != id {
+		return 0, errors.New("邀请链接与房间不匹配")
+	}
+	return id, nil
+}
+
+// inviteMessage 生成房间的邀请令牌与链接
+func (cr *ChessRoom) inviteMessage() (roomCreatedMessage, error) {
+	token, exp, err := utils.GenerateRoomInvite(cr.Id, cr.inviteNonce, RoomInviteTTL)
+	if err != nil {
+		return roomCreatedMessage{}, err
+	}
+	return roomCreatedMessage{
+		BaseMessage: BaseMessage{Type: messageCreate},
+		RoomId:      cr.Id,
+		Private:     cr.Private,
+		Locked:      cr.locked(),
+		InviteToken: token,
+		InviteURL:   inviteURL(token),
+		ExpiresAt:   exp.Unix(),
+	}, nil
+}
+
+// inviteURL 拼接邀请链接，前端页面地址可通过 ROOM_INVITE_BASE_URL 配置
+func inviteURL(token string) string {
+	base := os.Getenv("ROOM_INVITE_BASE_URL")
+	if base == "" {
+		base = "/game/chess"
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "invite=" + url.QueryEscape(token)
+}
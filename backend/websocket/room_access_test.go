@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"chinese-chess-backend/utils"
+)
+
+func newAccessRoom(t *testing.T, id int, private bool, password string) *ChessRoom {
+	t.Helper()
+	cr := &ChessRoom{Id: id}
+	if err := cr.setAccess(private, password); err != nil {
+		t.Fatal(err)
+	}
+	return cr
+}
+
+func invite(t *testing.T, cr *ChessRoom) string {
+	t.Helper()
+	msg, err := cr.inviteMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.InviteToken
+}
+
+func TestCheckAccess(t *testing.T) {
+	public := newAccessRoom(t, 1, false, "")
+	private := newAccessRoom(t, 2, true, "")
+	locked := newAccessRoom(t, 3, false, "马后炮")
+	lockedPrivate := newAccessRoom(t, 4, true, "马后炮")
+	other := newAccessRoom(t, 5, true, "")
+
+	expired, _, err := utils.GenerateRoomInvite(private.Id, private.inviteNonce, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		room             *ChessRoom
+		password, invite string
+		err              string // 空表示允许进入
+	}{
+		{"public", public, "", "", ""},
+		{"private without invite", private, "", "", "私密房间只能通过邀请链接加入"},
+		{"private with invite", private, "", invite(t, private), ""},
+		{"locked without password", locked, "", "", "该房间需要密码"},
+		{"locked wrong password", locked, "马前炮", "", "房间密码错误"},
+		{"locked right password", locked, "马后炮", "", ""},
+		{"locked private right password", lockedPrivate, "马后炮", "", ""},
+		// 有效的邀请令牌无需密码
+		{"locked with invite", lockedPrivate, "", invite(t, lockedPrivate), ""},
+		{"locked with invite and wrong password", lockedPrivate, "马前炮", invite(t, lockedPrivate), ""},
+		{"invite for another room", private, "", invite(t, other), "邀请链接与房间不匹配"},
+		{"expired invite", private, "", expired, "邀请链接已过期"},
+		{"bad invite", public, "", "not-a-token", "邀请链接无效"},
+	}
+	for _, tt := range tests {
+		err := tt.room.checkAccess(tt.password, tt.invite)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: checkAccess = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// TestAccessReset 重新设置房间访问权限后旧的邀请链接与密码失效
+func TestAccessReset(t *testing.T) {
+	cr := newAccessRoom(t, 7, true, "马后炮")
+	oldInvite := invite(t, cr)
+	oldNonce := cr.inviteNonce
+	if err := cr.setAccess(true, "炮打翻山"); err != nil {
+		t.Fatal(err)
+	}
+	if cr.inviteNonce == oldNonce || len(cr.inviteNonce) != 16 {
+		t.Errorf("nonce %q not regenerated (was %q)", cr.inviteNonce, oldNonce)
+	}
+	if err := cr.checkAccess("", oldInvite); err == nil || !strings.Contains(err.Error(), "不匹配") {
+		t.Errorf("old invite after reset: %v, want mismatch", err)
+	}
+	if err := cr.checkAccess("马后炮", ""); err == nil {
+		t.Errorf("old password still accepted after reset")
+	}
+	if err := cr.checkAccess("炮打翻山", ""); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+	if err := cr.checkAccess("", invite(t, cr)); err != nil {
+		t.Errorf("new invite: %v", err)
+	}
+	// 取消密码后只能通过新的邀请链接进入
+	if err := cr.setAccess(true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if cr.locked() {
+		t.Errorf("room still locked after clearing the password")
+	}
+	if err := cr.checkAccess("炮打翻山", ""); err == nil || !strings.Contains(err.Error(), "私密房间") {
+		t.Errorf("cleared password: %v, want invite required", err)
+	}
+
+	// 服务重启后房间ID被复用，旧房间的邀请链接不能进入新房间
+	reused := newAccessRoom(t, 7, true, "")
+	if err := reused.checkAccess("", oldInvite); err == nil {
+		t.Errorf("invite for a previous room with the same id accepted")
+	}
+}
+
+func TestInviteMessage(t *testing.T) {
+	t.Setenv("ROOM_INVITE_BASE_URL", "")
+	cr := newAccessRoom(t, 9, true, "马后炮")
+	msg, err := cr.inviteMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != messageCreate || msg.RoomId != 9 || !msg.Private || !msg.Locked {
+		t.Errorf("inviteMessage = %+v", msg)
+	}
+	if d := time.Until(time.Unix(msg.ExpiresAt, 0)); d < RoomInviteTTL-time.Minute || d > RoomInviteTTL {
+		t.Errorf("invite expires in %v, want %v", d, RoomInviteTTL)
+	}
+	if want := "/game/chess?invite=" + msg.InviteToken; msg.InviteURL != want {
+		t.Errorf("InviteURL = %q, want %q", msg.InviteURL, want)
+	}
+	roomId, nonce, err := utils.ParseRoomInvite(msg.InviteToken)
+	if err != nil || roomId != 9 || nonce != cr.inviteNonce {
+		t.Errorf("invite token = %d, %q, %v", roomId, nonce, err)
+	}
+	if cr.passwordHash == "马后炮" || !utils.CheckPassword(cr.passwordHash, "马后炮") {
+		t.Errorf("room password not stored as a bcrypt hash")
+	}
+	if newAccessRoom(t, 10, false, "").locked() {
+		t.Errorf("room without password is locked")
+	}
+}
+
+func TestInviteURL(t *testing.T) {
+	for base, want := range map[string]string{
+		"":                                 "/game/chess?invite=a%2Bb",
+		"https://chess.example.com/play":   "https://chess.example.com/play?invite=a%2Bb",
+		"https://chess.example.com/?tab=1": "https://chess.example.com/?tab=1&invite=a%2Bb",
+	} {
+		t.Setenv("ROOM_INVITE_BASE_URL", base)
+		if got := inviteURL("a+b"); got != want {
+			t.Errorf("base %q: inviteURL = %q, want %q", base, got, want)
+		}
+	}
+}
+
+func TestInviteRoomId(t *testing.T) {
+	cr := newAccessRoom(t, 11, true, "")
+	token := invite(t, cr)
+	tests := []struct {
+		roomId int
+		invite string
+		want   int
+		ok     bool
+	}{
+		{5, "", 5, true},
+		{0, token, 11, true},
+		{11, token, 11, true},
+		{12, token, 0, false},
+		{11, "not-a-token", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := inviteRoomId(tt.roomId, tt.invite)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("inviteRoomId(%d, %.10q) = %d, %v; want %d, ok=%v", tt.roomId, tt.invite, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidateRoomPassword(t *testing.T) {
+	if err := validateRoomPassword(strings.Repeat("车", maxRoomPasswordLen)); err != nil {
+		t.Errorf("32-character password: %v", err)
+	}
+	if err := validateRoomPassword(strings.Repeat("车", maxRoomPasswordLen+1)); err == nil {
+		t.Errorf("33-character password accepted")
+	}
+}
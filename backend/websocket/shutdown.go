@@ -0,0 +1,209 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"chinese-chess-backend/config"
+	"chinese-chess-backend/database"
+	recordModel "chinese-chess-backend/model/record"
+	modeluser "chinese-chess-backend/model/user"
+)
+
+var (
+	// ShutdownGrace 停机前留给进行中对局的时间，期间向客户端倒计时提示
+	ShutdownGrace = time.Duration(config.GetEnvInt("SHUTDOWN_GRACE_SECONDS", 30)) * time.Second
+	// 宽限期后仍未结束的对局：默认保存快照，重启后玩家重连继续；SHUTDOWN_MODE=adjudicate 时判和
+	shutdownAdjudicate = os.Getenv("SHUTDOWN_MODE") == "adjudicate"
+)
+
+// shutdownNoticeInterval 停机倒计时提示的间隔
+const shutdownNoticeInterval = 10 * time.Second
+
+// shutdownMessage 停机倒计时提示
+type shutdownMessage struct {
+	BaseMessage
+	Message   string `json:"message"`
+	Countdown int    `json:"countdown"` // 距停机的秒数
+}
+
+// Shutdown 停机：暂停匹配与开局，倒计时提示客户端，处理未结束的对局，
+// 等待命令循环与工作池中的任务（包括对局记录的写入）执行完毕后断开所有连接
+func (ch *ChessHub) Shutdown(ctx context.Context) error {
+	ch.draining.Store(true)
+
+	// 匹配队列中的玩家全部移出
+	ch.mu.Lock()
+	queued := ch.matchmaker.drain()
+	ch.mu.Unlock()
+	for _, c := range queued {
+		c.Status = userOnline
+		c.sendMessage(NormalMessage{
+			BaseMessage: BaseMessage{Type: messageNormal},
+			Message:     "服务器即将维护，已取消匹配",
+		})
+	}
+
+	ch.countdown(ctx)
+
+	// 处理宽限期后仍在进行的对局
+	ch.mu.Lock()
+	rooms := make([]*ChessRoom, 0, len(ch.Rooms))
+	for _, r := range ch.Rooms {
+		if r.isPlaying() {
+			rooms = append(rooms, r)
+		}
+	}
+	// 停止断线定时器，命令循环退出后不再有命令需要处理
+	for id, t := range ch.disconnectTimers {
+		t.Stop()
+		delete(ch.disconnectTimers, id)
+	}
+	ch.mu.Unlock()
+	for _, r := range rooms {
+		if shutdownAdjudicate {
+			r.Rated = false
+			if err := ch.send(ctx, hubCommand{
+				commandType: commandEnd,
+				client:      r.Current,
+				payload:     endRequest{winner: roleNone, reason: recordModel.EndReasonShutdown},
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		r.saveSnapshot()
+		if r.clock != nil {
+			r.clock.stop()
+		}
+		notice := NormalMessage{
+			BaseMessage: BaseMessage{Type: messageNormal},
+			Message:     "服务器维护，本局已保存，重启后重新连接即可继续",
+		}
+		r.Current.sendMessage(notice)
+		r.Next.sendMessage(notice)
+		r.broadcastToSpectators(notice)
+	}
+	ch.releaseTournamentRooms()
+
+	// 命令循环处理完此前的命令后退出，工作池执行完队列中的任务后停止
+	if err := ch.send(ctx, hubCommand{commandType: commandShutdown}); err != nil {
+		return err
+	}
+	stopped := make(chan struct{})
+	go func() {
+		ch.pool.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return fmt.Errorf("等待工作池退出超时: %w", ctx.Err())
+	}
+
+	ch.closeConnections()
+	return nil
+}
+
+// countdown 在宽限期内定期提示停机倒计时，所有对局提前结束时立即返回
+func (ch *ChessHub) countdown(ctx context.Context) {
+	deadline := time.Now().Add(ShutdownGrace)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 || !ch.hasPlayingRooms() {
+			return
+		}
+		seconds := int(remaining.Round(time.Second) / time.Second)
+		ch.broadcast(shutdownMessage{
+			BaseMessage: BaseMessage{Type: messageNormal},
+			Message:     fmt.Sprintf("服务器将在 %d 秒后维护，请尽快结束对局", seconds),
+			Countdown:   seconds,
+		})
+		select {
+		case <-time.After(min(remaining, shutdownNoticeInterval)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ch *ChessHub) hasPlayingRooms() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	for _, r := range ch.Rooms {
+		if r.isPlaying() {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcast 向本实例上连接的所有客户端发送消息
+func (ch *ChessHub) broadcast(message any) {
+	ch.mu.Lock()
+	targets := make([]*Client, 0, len(ch.Clients))
+	for _, c := range ch.Clients {
+		if c.connNode == "" && c.Conn != nil {
+			targets = append(targets, c)
+		}
+	}
+	ch.mu.Unlock()
+	for _, c := range targets {
+		c.sendMessage(message)
+	}
+}
+
+// send 向命令循环发送命令，停机超时则放弃
+func (ch *ChessHub) send(ctx context.Context, cmd hubCommand) error {
+	select {
+	case ch.commands <- cmd:
+		return nil
+	case <-ch.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待命令循环超时: %w", ctx.Err())
+	}
+}
+
+// enqueue 向命令循环发送命令，命令循环已随停机退出时放弃，避免发送方永久阻塞
+// 在命令处理或定时器回调中发送后续命令时以 go ch.enqueue(...) 调用，不占用工作协程
+func (ch *ChessHub) enqueue(cmd hubCommand) {
+	select {
+	case ch.commands <- cmd:
+	case <-ch.done:
+	}
+}
+
+// closeConnections 断开本实例上的所有连接，并将这些用户标记为离线
+func (ch *ChessHub) closeConnections() {
+	ch.mu.Lock()
+	clients := make([]*Client, 0, len(ch.Clients))
+	for _, c := range ch.Clients {
+		if c.connNode == "" {
+			clients = append(clients, c)
+		}
+	}
+	ch.mu.Unlock()
+	ids := make([]int, 0, len(clients))
+	for _, c := range clients {
+		ids = append(ids, c.Id)
+		clearPresence(c.Id)
+		if c.Conn == nil {
+			continue
+		}
+		c.Conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(time.Second))
+		c.Conn.Close()
+	}
+	if len(ids) > 0 {
+		if err := database.GetMysqlDb().Model(&modeluser.User{}).Where("id IN ?", ids).Update("online", false).Error; err != nil {
+			log.Printf("reset online status failed: %v", err)
+		}
+	}
+}
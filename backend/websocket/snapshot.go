@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"chinese-chess-backend/database"
+)
+
+const (
+	// roomSnapshotKey 保存进行中对局快照的 Redis 哈希，字段为房间ID；集群模式下每个实例各用一个哈希
+	roomSnapshotKey = "chess:rooms"
+	// RestoreGrace 服务重启后等待玩家重连的宽限时间
+	RestoreGrace = 60 * time.Second
+)
+
+// playerSnapshot 快照中的一名玩家
+type playerSnapshot struct {
+	Id       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// roomSnapshot 进行中对局的快照，服务重启后据此重建房间
+// 棋盘、循环局面记录与自然限着计数均可由 History 重新推演，不单独保存
+type roomSnapshot struct {
+	Id           int            `json:"id"`
+	GameType     int            `json:"gameType"`
+	Rated        bool           `json:"rated"`
+	TimeControl  TimeControl    `json:"timeControl"`
+	StartTime    time.Time      `json:"startTime"`
+	History      []Position     `json:"history"`
+	Red          playerSnapshot `json:"red"`
+	Black        playerSnapshot `json:"black"`
+	Turn         clientRole     `json:"turn"` // 当前轮到走棋的一方
+	Clock        *ClockSnapshot `json:"clock,omitempty"`
+	Private      bool           `json:"private,omitempty"`
+	PasswordHash string         `json:"passwordHash,omitempty"`
+	InviteNonce  string         `json:"inviteNonce,omitempty"`
+	// Tournament 比赛对局所属的对阵，红黑双方即对阵双方
+	Tournament *tournamentSnapshot `json:"tournament,omitempty"`
+	SavedAt    time.Time           `json:"savedAt"`
+}
+
+func snapshotKey() string {
+	if ClusterMode {
+		return roomSnapshotKey + ":" + InstanceId
+	}
+	return roomSnapshotKey
+}
+
+// saveSnapshot 将进行中的对局写入 Redis，未开始或已结束的房间以及人机对局不保存
+func (cr *ChessRoom) saveSnapshot() {
+	if !cr.isPlaying() || cr.aiClient() != nil {
+		return
+	}
+	red, black := cr.players()
+	if red == nil || black == nil || cr.Current == nil {
+		return
+	}
+	cr.mu.Lock()
+	history := make([]Position, len(cr.History))
+	copy(history, cr.History)
+	cr.mu.Unlock()
+	snap := roomSnapshot{
+		Id:           cr.Id,
+		GameType:     cr.GameType,
+		Rated:        cr.Rated,
+		TimeControl:  cr.TimeControl,
+		StartTime:    cr.StartTime,
+		History:      history,
+		Red:          playerSnapshot{Id: red.Id, Username: red.Username},
+		Black:        playerSnapshot{Id: black.Id, Username: black.Username},
+		Turn:         cr.Current.Role,
+		Clock:        cr.clockSnapshot(),
+		Private:      cr.Private,
+		PasswordHash: cr.passwordHash,
+		InviteNonce:  cr.inviteNonce,
+		Tournament:   cr.tournament.snapshot(),
+		SavedAt:      time.Now(),
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		log.Printf("room %d: marshal snapshot failed: %v", cr.Id, err)
+		return
+	}
+	if err := database.HashSet(snapshotKey(), strconv.Itoa(cr.Id), string(data)); err != nil {
+		log.Printf("room %d: save snapshot failed: %v", cr.Id, err)
+	}
+}
+
+// deleteSnapshot 对局结束或房间清理时删除快照
+func (cr *ChessRoom) deleteSnapshot() {
+	if err := database.HashDelete(snapshotKey(), strconv.Itoa(cr.Id)); err != nil {
+		log.Printf("room %d: delete snapshot failed: %v", cr.Id, err)
+	}
+}
+
+// restoreRooms 启动时按 Redis 中的快照重建进行中的对局
+// 双方玩家以断线状态加入 Clients，随后通过 HandleConnection 的重连流程接回；宽限期内未重连的一方判负
+func (ch *ChessHub) restoreRooms() {
+	all, err := database.HashGetAll(snapshotKey())
+	if err != nil {
+		log.Printf("load room snapshots failed: %v", err)
+		return
+	}
+	for field, data := range all {
+		var snap roomSnapshot
+		if err := json.Unmarshal([]byte(data), &snap); err != nil {
+			log.Printf("room %s: invalid snapshot, discarded: %v", field, err)
+			database.HashDelete(snapshotKey(), field)
+			continue
+		}
+		ch.restoreRoom(snap)
+	}
+}
+
+func (ch *ChessHub) restoreRoom(snap roomSnapshot) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	_, redBusy := ch.Clients[snap.Red.Id]
+	_, blackBusy := ch.Clients[snap.Black.Id]
+	if redBusy || blackBusy || snap.Red.Id == snap.Black.Id {
+		// 同一玩家出现在多个快照中（数据异常），只恢复先读到的对局
+		log.Printf("room %d: player already restored in another room, snapshot discarded", snap.Id)
+		database.HashDelete(snapshotKey(), strconv.Itoa(snap.Id))
+		return
+	}
+
+	r := &ChessRoom{
+		Id:          snap.Id,
+		GameType:    snap.GameType,
+		Rated:       snap.Rated,
+		TimeControl: snap.TimeControl,
+		StartTime:   snap.StartTime,
+		History:     snap.History,
+		Private:     snap.Private,
+	}
+	r.passwordHash = snap.PasswordHash
+	r.inviteNonce = snap.InviteNonce
+	r.tournament = restoreTournament(snap)
+	r.mu.Lock()
+	r.rebuildBoard()
+	r.mu.Unlock()
+
+	red := restoredClient(snap.Red, r.Id, roleRed)
+	black := restoredClient(snap.Black, r.Id, roleBlack)
+	r.Current, r.Next = red, black
+	if snap.Turn == roleBlack {
+		r.Current, r.Next = black, red
+	}
+	r.Nums = 2
+	if snap.Clock != nil && r.TimeControl.Enabled() {
+		r.clock = newGameClock(r.TimeControl, func(loser clientRole) {
+			ch.endByTimeout(r, loser)
+		})
+		r.clock.resume(*snap.Clock, time.Now())
+	}
+
+	ch.addRoom(r)
+	ch.Clients[red.Id] = red
+	ch.Clients[black.Id] = black
+	// 新建房间的ID不能与恢复的房间冲突
+	idLock.Lock()
+	nextId = max(nextId, r.Id)
+	idLock.Unlock()
+
+	ch.startDisconnectTimer(red, RestoreGrace)
+	ch.startDisconnectTimer(black, RestoreGrace)
+	log.Printf("room %d restored: %d vs %d, %d plies", r.Id, red.Id, black.Id, len(r.History)/2)
+}
+
+// restoredClient 为恢复的对局构造尚未连接的玩家
+func restoredClient(p playerSnapshot, roomId int, role clientRole) *Client {
+	c := NewClient(nil, p.Id, p.Username)
+	c.RoomId = roomId
+	c.Role = role
+	c.Status = userPlaying
+	return c
+}
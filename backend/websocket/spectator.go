@@ -0,0 +1,160 @@
+package websocket
+
+import (
+	"chinese-chess-backend/dto/room"
+)
+
+// 观战者看到的棋盘固定为红方在下，棋步与历史均转换为红方视角坐标
+
+// redView 将移动者视角的坐标转换为红方视角
+func redView(p Position, mover clientRole) Position {
+	if mover == roleBlack {
+		return Position{X: 8 - p.X, Y: 9 - p.Y}
+	}
+	return p
+}
+
+// players 返回红方与黑方玩家（对局未开始时可能为 nil）
+func (cr *ChessRoom) players() (red, black *Client) {
+	for _, c := range []*Client{cr.Current, cr.Next} {
+		if c == nil {
+			continue
+		}
+		switch c.Role {
+		case roleRed:
+			red = c
+		case roleBlack:
+			black = c
+		}
+	}
+	return red, black
+}
+
+// isPlaying 对局是否已开始且尚未结束
+func (cr *ChessRoom) isPlaying() bool {
+	return cr.isFull() && !cr.StartTime.IsZero() && !cr.RecordSaved
+}
+
+// addSpectator 加入观战
+func (cr *ChessRoom) addSpectator(c *Client) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.Spectators == nil {
+		cr.Spectators = make(map[int]*Client)
+	}
+	cr.Spectators[c.Id] = c
+	c.watchRoomId = cr.Id
+	c.attachRemote()
+}
+
+// removeSpectator 退出观战，返回其是否在观战
+func (cr *ChessRoom) removeSpectator(c *Client) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if _, ok := cr.Spectators[c.Id]; !ok {
+		return false
+	}
+	delete(cr.Spectators, c.Id)
+	c.watchRoomId = 0
+	c.releaseRemote()
+	return true
+}
+
+// spectatorCount 当前观战人数
+func (cr *ChessRoom) spectatorCount() int {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return len(cr.Spectators)
+}
+
+// broadcastToSpectators 向所有观战者发送消息，断线的观战者会被跳过
+func (cr *ChessRoom) broadcastToSpectators(message any) {
+	cr.broadcastToSpectatorsExcept(message, nil)
+}
+
+// broadcastToSpectatorsExcept 向除 except 以外的观战者发送消息
+func (cr *ChessRoom) broadcastToSpectatorsExcept(message any, except *Client) {
+	cr.mu.Lock()
+	targets := make([]*Client, 0, len(cr.Spectators))
+	for _, c := range cr.Spectators {
+		if c != except {
+			targets = append(targets, c)
+		}
+	}
+	cr.mu.Unlock()
+	for _, c := range targets {
+		c.sendMessage(message)
+	}
+}
+
+// spectatorMove 将玩家走的一步棋转换为发给观战者的消息
+func spectatorMove(move MoveMessage, mover clientRole) MoveMessage {
+	// AI 的走子对观战者来说与普通走子相同
+	move.Type = messageMove
+	move.From = redView(move.From, mover)
+	move.To = redView(move.To, mover)
+	return move
+}
+
+// spectatorSync 构造发给观战者的同步消息：完整的红方视角棋步历史与双方玩家
+func (cr *ChessRoom) spectatorSync() SyncMessage {
+	msg := cr.syncMessage(roleNone)
+	msg.Role = "spectator"
+	for i := 0; i+1 < len(msg.History); i += 2 {
+		mover := cr.moverAt(i / 2)
+		msg.History[i] = redView(msg.History[i], mover)
+		msg.History[i+1] = redView(msg.History[i+1], mover)
+	}
+	red, black := cr.players()
+	if red != nil {
+		msg.Red = red.Username
+	}
+	if black != nil {
+		msg.Black = black.Username
+	}
+	return msg
+}
+
+// detachSpectators 对局结束后让所有观战者离开房间，调用方需持有 cr.mu
+func (cr *ChessRoom) detachSpectators() {
+	for _, c := range cr.Spectators {
+		c.watchRoomId = 0
+		c.releaseRemote()
+	}
+	cr.Spectators = nil
+}
+
+// stopWatching 让客户端离开正在观战的房间，返回其之前是否在观战
+func (ch *ChessHub) stopWatching(c *Client) bool {
+	if c == nil || c.watchRoomId == 0 {
+		return false
+	}
+	ch.mu.Lock()
+	r := ch.Rooms[c.watchRoomId]
+	ch.mu.Unlock()
+	if r == nil {
+		c.watchRoomId = 0
+		return false
+	}
+	return r.removeSpectator(c)
+}
+
+// watchableRooms 返回可观战的进行中对局，玩家信息只填写ID，由 RoomService 补全，调用方需持有 ch.mu
+func (ch *ChessHub) watchableRooms() []room.RoomInfo {
+	infos := make([]room.RoomInfo, 0)
+	for _, r := range ch.Rooms {
+		// 人机对局与残局挑战的 AI 没有用户信息，不在大厅展示
+		if !r.isPlaying() || r.Private || r.aiClient() != nil {
+			continue
+		}
+		red, black := r.players()
+		if red == nil || black == nil {
+			continue
+		}
+		info := room.RoomInfo{Id: r.Id, Playing: true, Locked: r.locked(), Spectators: r.spectatorCount()}
+		info.Current.ID = uint(red.Id)
+		info.Next.ID = uint(black.Id)
+		infos = append(infos, info)
+	}
+	return infos
+}
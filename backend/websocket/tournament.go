@@ -0,0 +1,315 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"chinese-chess-backend/config"
+	recordModel "chinese-chess-backend/model/record"
+	"chinese-chess-backend/service"
+)
+
+var (
+	// TournamentTickInterval 比赛编排的检查间隔：自动开赛、推进轮次、为新对阵创建房间以及判定未到场
+	TournamentTickInterval = time.Duration(config.GetEnvInt("TOURNAMENT_TICK_SECONDS", 10)) * time.Second
+	// TournamentJoinWindow 对阵房间创建后等待双方入场的时间，超时未到场的一方判负
+	TournamentJoinWindow = time.Duration(config.GetEnvInt("TOURNAMENT_JOIN_MINUTES", 5)) * time.Minute
+)
+
+// tournamentStaleGrace 入场截止后房间仍登记在其他实例上的对阵，超过该时间仍未判定时视为房间已随实例丢失
+const tournamentStaleGrace = 5 * time.Minute
+
+// tournamentPairingMessage 比赛对阵通知：房间已创建，棋手发送 messageJoin 携带 roomId 入场
+type tournamentPairingMessage struct {
+	BaseMessage
+	TournamentId uint   `json:"tournamentId"`
+	Name         string `json:"name"`
+	Round        int    `json:"round"`
+	PairingId    uint   `json:"pairingId"`
+	RoomId       int    `json:"roomId"`
+	Color        string `json:"color"` // 本方执的颜色：red/black
+	OpponentId   int    `json:"opponentId"`
+	Deadline     int64  `json:"deadline"` // 入场截止时间（Unix 秒）
+}
+
+// tournamentGame 比赛对局房间所属的对阵：只有对阵双方可以入场，先后手由编排决定而不是入场顺序
+type tournamentGame struct {
+	tournamentID uint
+	pairingID    uint
+	round        int
+	red          int
+	black        int
+}
+
+// tournamentSnapshot 快照中的比赛对阵
+type tournamentSnapshot struct {
+	TournamentId uint `json:"tournamentId"`
+	PairingId    uint `json:"pairingId"`
+	Round        int  `json:"round"`
+}
+
+// seated 用户是否为对阵的一方
+func (tg *tournamentGame) seated(userId int) bool {
+	return userId == tg.red || userId == tg.black
+}
+
+// runTournamentTicker 定期触发比赛编排
+func (ch *ChessHub) runTournamentTicker() {
+	ticker := time.NewTicker(TournamentTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ch.done:
+			return
+		}
+		select {
+		case ch.commands <- hubCommand{commandType: commandTournamentTick}:
+		case <-ch.done:
+			return
+		}
+	}
+}
+
+// scheduleTournamentTick 比赛对局结束后立即检查能否进入下一轮，不等下一次定期检查
+func (ch *ChessHub) scheduleTournamentTick() {
+	go ch.enqueue(hubCommand{commandType: commandTournamentTick})
+}
+
+// tickTournaments 判定超过入场截止时间的对阵，推进比赛，再为新编排的对阵创建房间
+// 同一时刻只执行一次，错过的检查由下一次定期检查补上
+func (ch *ChessHub) tickTournaments() {
+	if !ch.tournamentBusy.CompareAndSwap(false, true) {
+		return
+	}
+	defer ch.tournamentBusy.Store(false)
+
+	ts := service.NewTournamentService()
+	now := time.Now()
+	ch.forfeitAbsent(ts, now)
+	if err := ts.Advance(now); err != nil {
+		log.Printf("advance tournaments failed: %v", err)
+	}
+	// 停机中不再创建房间，对阵留给重启后的实例
+	if ch.draining.Load() {
+		return
+	}
+	games, err := ts.ClaimPairings(now.Add(TournamentJoinWindow))
+	if err != nil {
+		log.Printf("claim tournament pairings failed: %v", err)
+	}
+	for i := range games {
+		ch.openPairingRoom(ts, &games[i])
+	}
+}
+
+// openPairingRoom 为对阵创建房间并通知双方入场；房间不在大厅的空余房间中展示，开局后可以观战
+func (ch *ChessHub) openPairingRoom(ts *service.TournamentService, g *service.TournamentGame) {
+	p, t := &g.Pairing, &g.Tournament
+	r := NewChessRoom()
+	r.GameType = recordModel.GameTypeTournament
+	r.TimeControl = TimeControl{Base: t.TimeBase, Increment: t.TimeIncrement, Byoyomi: t.TimeByoyomi}
+	r.Rated = t.Rated
+	r.tournament = &tournamentGame{
+		tournamentID: t.ID,
+		pairingID:    p.ID,
+		round:        p.Round,
+		red:          int(p.RedID),
+		black:        int(p.BlackID),
+	}
+	ch.mu.Lock()
+	ch.addRoom(r)
+	ch.mu.Unlock()
+	if err := ts.AttachRoom(p.ID, r.Id); err != nil {
+		log.Printf("tournament pairing %d: attach room %d failed: %v", p.ID, r.Id, err)
+	}
+
+	msg := tournamentPairingMessage{
+		BaseMessage:  BaseMessage{Type: messageTournamentPairing},
+		TournamentId: t.ID,
+		Name:         t.Name,
+		Round:        p.Round,
+		PairingId:    p.ID,
+		RoomId:       r.Id,
+		Deadline:     p.Deadline.Unix(),
+	}
+	for _, seat := range []struct {
+		id, opponent int
+		color        string
+	}{{r.tournament.red, r.tournament.black, "red"}, {r.tournament.black, r.tournament.red, "black"}} {
+		msg.Color, msg.OpponentId = seat.color, seat.opponent
+		// 不在线的棋手从比赛详情中查看对阵房间
+		_ = ch.SendToUser(seat.id, msg)
+	}
+}
+
+// forfeitAbsent 入场截止时仍未开局的对阵：已在房间中等待的一方不战而胜，双方都未到场时都记负
+// 房间在其他实例上时由该实例判定
+func (ch *ChessHub) forfeitAbsent(ts *service.TournamentService, now time.Time) {
+	expired, err := ts.ExpiredPairings(now)
+	if err != nil {
+		log.Printf("load expired tournament pairings failed: %v", err)
+		return
+	}
+	for i := range expired {
+		p := &expired[i]
+		var present *Client
+		ch.mu.Lock()
+		r := ch.Rooms[p.RoomID]
+		if r != nil && (r.tournament == nil || r.tournament.pairingID != p.ID) {
+			r = nil
+		}
+		if r != nil && r.isFull() {
+			// 双方已入场，对阵状态随开局更新
+			ch.mu.Unlock()
+			continue
+		}
+		if r != nil {
+			if r.Current != nil && r.Current.RoomId == r.Id {
+				present = r.Current
+			}
+			// 先移出房间表，此后入场的请求会得到“房间不存在”
+			delete(ch.Rooms, r.Id)
+		}
+		ch.mu.Unlock()
+		if r == nil && now.Before(p.Deadline.Add(tournamentStaleGrace)) && ch.remoteRoomNode(p.RoomID) != "" {
+			continue
+		}
+
+		var presentId uint
+		if present != nil {
+			presentId = uint(present.Id)
+		}
+		if r != nil {
+			r.clear()
+		}
+		if err := ts.ForfeitPairing(p, presentId); err != nil {
+			log.Printf("tournament pairing %d: forfeit failed: %v", p.ID, err)
+			continue
+		}
+		if present != nil {
+			present.sendMessage(NormalMessage{
+				BaseMessage: BaseMessage{Type: messageNormal},
+				Message:     "对手未在规定时间内入场，本轮判您获胜",
+			})
+		}
+	}
+}
+
+// joinTournamentRoom 比赛房间的入场校验，调用方需持有 ch.mu；返回 false 时已告知客户端
+// 同一棋手重复入场（例如重连后）时替换原来的连接，不占用对手的位置
+func (ch *ChessHub) joinTournamentRoom(room *ChessRoom, client *Client) bool {
+	if !room.tournament.seated(client.Id) {
+		client.sendMessage(NormalMessage{
+			BaseMessage: BaseMessage{Type: messageError},
+			Message:     "这是比赛对局房间，只有对阵双方可以入场",
+		})
+		return false
+	}
+	if room.isFull() {
+		// 已开局，由 join 报告房间已满
+		return true
+	}
+	// 先到的棋手已经去了其他房间，不再为其保留位置
+	if room.Current != nil && room.Current.RoomId != room.Id {
+		room.Current, room.Nums = nil, 0
+	}
+	if room.Current != nil && room.Current.Id == client.Id {
+		if room.Current != client {
+			room.Current.RoomId = -1
+			room.Current = client
+			client.RoomId = room.Id
+			client.attachRemote()
+		}
+		client.sendMessage(NormalMessage{
+			BaseMessage: BaseMessage{Type: messageNormal},
+			Message:     "已进入比赛房间，等待对手入场",
+		})
+		return false
+	}
+	return true
+}
+
+// vacate 开局前离开比赛房间：房间保留到入场截止，棋手可以重新入场，调用方需持有 ch.mu
+func (cr *ChessRoom) vacate(c *Client) {
+	switch c {
+	case cr.Current:
+		cr.Current, cr.Next = cr.Next, nil
+	case cr.Next:
+		cr.Next = nil
+	default:
+		return
+	}
+	cr.Nums--
+	c.RoomId = -1
+	c.releaseRemote()
+}
+
+// tournamentStarted 比赛对局开始，更新对阵状态
+func (cr *ChessRoom) tournamentStarted() {
+	if err := service.NewTournamentService().PairingStarted(cr.tournament.pairingID); err != nil {
+		log.Printf("tournament pairing %d: mark started failed: %v", cr.tournament.pairingID, err)
+	}
+}
+
+// recordTournamentGame 比赛对局结束后记录对阵结果，recordID 为 0 表示对局记录未能保存
+// 停机判和的对局不计结果，对阵退回待编排状态，重启后重新开局
+func recordTournamentGame(room *ChessRoom, recordID uint, result int, reason string) {
+	ts := service.NewTournamentService()
+	pairingID := room.tournament.pairingID
+	var err error
+	if reason == recordModel.EndReasonShutdown {
+		err = ts.ReplayPairing(pairingID)
+	} else {
+		err = ts.RecordGame(pairingID, recordID, result)
+	}
+	if err != nil {
+		log.Printf("tournament pairing %d: record result failed: %v", pairingID, err)
+	}
+}
+
+// releaseTournamentRooms 停机时尚未开局的比赛房间不会保存快照，对阵退回待编排状态，重启后重新创建房间
+func (ch *ChessHub) releaseTournamentRooms() {
+	ch.mu.Lock()
+	var pairings []uint
+	for _, r := range ch.Rooms {
+		if r.tournament != nil && r.StartTime.IsZero() {
+			pairings = append(pairings, r.tournament.pairingID)
+		}
+	}
+	ch.mu.Unlock()
+	ts := service.NewTournamentService()
+	for _, id := range pairings {
+		if err := ts.ReplayPairing(id); err != nil {
+			log.Printf("tournament pairing %d: release failed: %v", id, err)
+		}
+	}
+}
+
+// snapshot 快照中保存的对阵信息
+func (tg *tournamentGame) snapshot() *tournamentSnapshot {
+	if tg == nil {
+		return nil
+	}
+	return &tournamentSnapshot{TournamentId: tg.tournamentID, PairingId: tg.pairingID, Round: tg.round}
+}
+
+// restoreTournament 由快照恢复对阵，先后手取快照中的红黑双方
+func restoreTournament(snap roomSnapshot) *tournamentGame {
+	if snap.Tournament == nil || snap.GameType != recordModel.GameTypeTournament {
+		return nil
+	}
+	return &tournamentGame{
+		tournamentID: snap.Tournament.TournamentId,
+		pairingID:    snap.Tournament.PairingId,
+		round:        snap.Tournament.Round,
+		red:          snap.Red.Id,
+		black:        snap.Black.Id,
+	}
+}
+
+// countsAsPlayerGame 随机匹配、好友对战与比赛对局计入等级分与固定经验
+func countsAsPlayerGame(gameType int) bool {
+	return gameType == recordModel.GameTypeMatch || gameType == recordModel.GameTypeFriend ||
+		gameType == recordModel.GameTypeTournament
+}
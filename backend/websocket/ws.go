@@ -10,14 +10,21 @@ import (
 	"encoding/json"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"chinese-chess-backend/ai"
 	"chinese-chess-backend/database"
 	"chinese-chess-backend/dto"
+	endgameDto "chinese-chess-backend/dto/endgame"
+	ratingDto "chinese-chess-backend/dto/rating"
 	"chinese-chess-backend/dto/room"
 	dtouser "chinese-chess-backend/dto/user"
+	"chinese-chess-backend/engine"
+	endgameModel "chinese-chess-backend/model/endgame"
+	recordModel "chinese-chess-backend/model/record"
 	modeluser "chinese-chess-backend/model/user"
 	"chinese-chess-backend/service"
 	"chinese-chess-backend/utils"
@@ -46,9 +53,12 @@ type ChessHub struct {
 	spareRooms []room.RoomInfo // 有空位的房间id
 	mu         sync.Mutex
 	pool       *utils.WorkerPool
-	matchPool  [](*Client)
+	matchmaker *matchmaker // 随机匹配队列
 	// 记录断开后的延迟删除定时器，以支持短时重连
 	disconnectTimers map[int]*time.Timer
+	draining         atomic.Bool   // 停机中：不再接受匹配与开局
+	tournamentBusy   atomic.Bool   // 正在进行比赛编排，避免重复判定同一对阵
+	done             chan struct{} // 命令循环退出后关闭
 }
 
 // DefaultHub 可供其他包调用（例如在消息保存后推送到在线用户）
@@ -63,7 +73,9 @@ func NewChessHub() *ChessHub {
 		spareRooms:       make([]room.RoomInfo, 0),
 		mu:               sync.Mutex{},
 		pool:             pool,
+		matchmaker:       newMatchmaker(),
 		disconnectTimers: make(map[int]*time.Timer),
+		done:             make(chan struct{}),
 	}
 	pool.Start()
 
@@ -73,15 +85,24 @@ func NewChessHub() *ChessHub {
 	return hub
 }
 
-// SendToUser 将消息直接发送到指定用户（如果在线）
+// SendToUser 将消息直接发送到指定用户（如果在线），集群模式下可推送给连接在其他实例上的用户
 func (ch *ChessHub) SendToUser(userID int, message interface{}) error {
 	ch.mu.Lock()
 	client, ok := ch.Clients[userID]
 	ch.mu.Unlock()
-	if !ok || client == nil {
-		return fmt.Errorf("user %d not online", userID)
+	if ok && client != nil && client.connNode == "" {
+		return client.sendMessage(message)
 	}
-	return client.sendMessage(message)
+	if node, found := presenceNode(userID); found && node != InstanceId {
+		return deliverToNode(node, userID, message)
+	}
+	return fmt.Errorf("user %d not online", userID)
+}
+
+// addRoom 登记新房间，集群模式下同时记录房间由本实例处理，调用方需持有 ch.mu
+func (ch *ChessHub) addRoom(r *ChessRoom) {
+	ch.Rooms[r.Id] = r
+	setRoomOwner(r.Id)
 }
 
 func (ch *ChessHub) Run() {
@@ -90,7 +111,40 @@ func (ch *ChessHub) Run() {
 			log.Printf("Worker pool error: %v\n", err)
 		}
 	}()
+	// 重建服务重启前进行中的对局，玩家通过重连流程接回
+	ch.restoreRooms()
+	if ClusterMode {
+		ch.runCluster()
+	}
+	// 匹配窗口随等待时间放宽，需要定期为仍在队列中的玩家重新配对
+	go func() {
+		ticker := time.NewTicker(MatchSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ch.done:
+				return
+			}
+			ch.mu.Lock()
+			waiting := ch.matchmaker.pending()
+			ch.mu.Unlock()
+			if waiting {
+				select {
+				case ch.commands <- hubCommand{commandType: commandMatchSweep}:
+				case <-ch.done:
+					return
+				}
+			}
+		}
+	}()
+	// 比赛的自动开赛、轮次推进与对阵房间的创建
+	go ch.runTournamentTicker()
 	for cmd := range ch.commands {
+		if cmd.commandType == commandShutdown {
+			close(ch.done)
+			return
+		}
 		ch.pool.Process(context.Background(), func() error {
 			switch cmd.commandType {
 			case commandRegister:
@@ -99,7 +153,7 @@ func (ch *ChessHub) Run() {
 				ch.Clients[client.Id] = client
 				ch.mu.Unlock()
 				// 在线用户
-				database.SetValue(fmt.Sprint(client.Id), "a", 0)
+				setPresence(client.Id)
 				// 将在线状态写入 MySQL
 				if err := database.GetMysqlDb().Model(&modeluser.User{}).Where("id = ?", client.Id).Update("online", true).Error; err != nil {
 					// 不阻塞主流程，记录或忽略错误
@@ -109,6 +163,11 @@ func (ch *ChessHub) Run() {
 				roomId := client.RoomId
 				ch.mu.Lock()
 				room, ok := ch.Rooms[roomId]
+				if ok && room.tournament != nil && room.StartTime.IsZero() {
+					// 尚未开局的比赛房间保留到入场截止，棋手重新上线后可以再次入场
+					room.vacate(client)
+					ok = false
+				}
 				ch.mu.Unlock()
 				if ok {
 					var target *Client
@@ -140,10 +199,12 @@ func (ch *ChessHub) Run() {
 					// 如果游戏正在进行中，发送游戏结束消息
 					if target != nil && room.Current != nil && room.Next != nil {
 						// 游戏已开始且正在进行
-						ch.sendMessage(target, endMessage{
+						endMsg := endMessage{
 							BaseMessage: BaseMessage{Type: messageEnd},
 							Winner:      winner,
-						})
+						}
+						ch.sendMessage(target, endMsg)
+						room.broadcastToSpectators(endMsg)
 					} else if target != nil {
 						// 游戏未开始，只告知对方连接断开
 						ch.sendMessage(target, NormalMessage{
@@ -163,9 +224,12 @@ func (ch *ChessHub) Run() {
 					}
 					ch.mu.Unlock()
 				}
+				// 正在观战则退出观战
+				ch.stopWatching(client)
 				ch.mu.Lock()
-				// 从 Clients 中移除
-				if _, ok := ch.Clients[client.Id]; ok {
+				// 从 Clients 中移除；代理客户端的在线状态由连接所在实例维护
+				proxy := client.connNode != ""
+				if _, ok := ch.Clients[client.Id]; ok && !proxy {
 					// 更新在线状态为 false
 					if err := database.GetMysqlDb().Model(&modeluser.User{}).Where("id = ?", client.Id).Update("online", false).Error; err != nil {
 						// 忽略错误
@@ -174,67 +238,52 @@ func (ch *ChessHub) Run() {
 					if client.Conn != nil {
 						client.Conn.Close()
 					}
+				} else if ok {
+					delete(ch.Clients, client.Id)
 				}
-				// 从匹配池中移除任何残留的该客户端引用，避免再次被匹配
-				for i := len(ch.matchPool) - 1; i >= 0; i-- {
-					if ch.matchPool[i].Id == client.Id {
-						ch.matchPool = append(ch.matchPool[:i], ch.matchPool[i+1:]...)
-					}
-				}
+				// 从匹配队列中移除该客户端，避免再次被匹配
+				ch.matchmaker.remove(client.Id)
+				ch.matchmaker.forget(client.Id)
 				ch.mu.Unlock()
-				database.DeleteValue(fmt.Sprint(client.Id))
-				// 清理与该用户相关的挑战记录
-				_ = service.NewFriendChallengeService().DeleteAllByUser(uint(client.Id))
+				if !proxy {
+					clearPresence(client.Id)
+					// 清理与该用户相关的挑战记录
+					_ = service.NewFriendChallengeService().DeleteAllByUser(uint(client.Id))
+				}
 			case commandMatch:
 				client := cmd.client
+				// 按等级分配对，查询放在加锁之前
+				rating := service.NewRatingService().DisplayRating(uint(client.Id))
+				now := time.Now()
 				ch.mu.Lock()
-				// 防止重复加入匹配池
-				already := false
-				for _, c := range ch.matchPool {
-					if c.Id == client.Id {
-						already = true
-						break
-					}
-				}
-				if !already {
-					ch.matchPool = append(ch.matchPool, client)
+				// 重复加入时保留原有的排队时间
+				entry := ch.matchmaker.add(client, client.matchQueue, rating, now)
+				pairs := ch.matchmaker.pairAll(now)
+				ch.startMatchedRooms(pairs)
+				queued := ch.matchmaker.contains(client.Id)
+				var wait time.Duration
+				if queued {
+					wait = ch.matchmaker.estimateWait(entry, now)
 				}
-				fmt.Println(ch.matchPool)
-				if len(ch.matchPool) < 2 {
-					client.sendMessage(NormalMessage{
-						BaseMessage: BaseMessage{Type: messageNormal},
-						Message:     "正在匹配，请稍等",
+				ch.mu.Unlock()
+				if queued {
+					seconds := int(wait.Round(time.Second) / time.Second)
+					client.sendMessage(matchingMessage{
+						BaseMessage:   BaseMessage{Type: messageNormal},
+						Message:       fmt.Sprintf("正在匹配，请稍等，预计等待约 %d 秒", seconds),
+						EstimatedWait: seconds,
 					})
-					ch.mu.Unlock()
-					return nil
 				}
-				// 匹配成功，创建房间
-				room := NewChessRoom()
-				room.join(ch.matchPool[0])
-				room.join(ch.matchPool[1])
-				ch.matchPool = ch.matchPool[2:]
-				ch.Rooms[room.Id] = room
+			case commandMatchSweep:
+				ch.mu.Lock()
+				ch.startMatchedRooms(ch.matchmaker.pairAll(time.Now()))
 				ch.mu.Unlock()
-				// 发送消息给两个客户端，通知他们开始游戏
-				go func() {
-					ch.commands <- hubCommand{
-						commandType: commandStart,
-						client:      client,
-					}
-				}()
 			case commandCancelMatch:
 				// 【问题2修复】处理取消匹配命令
 				client := cmd.client
 				ch.mu.Lock()
-				// 从匹配池中移除该客户端
-				removed := false
-				for i := len(ch.matchPool) - 1; i >= 0; i-- {
-					if ch.matchPool[i].Id == client.Id {
-						ch.matchPool = append(ch.matchPool[:i], ch.matchPool[i+1:]...)
-						removed = true
-						break
-					}
-				}
+				// 从匹配队列中移除该客户端
+				removed := ch.matchmaker.remove(client.Id)
 				ch.mu.Unlock()
 
 				// 更新客户端状态为在线
@@ -254,6 +303,10 @@ func (ch *ChessHub) Run() {
 				}
 			case commandMove:
 				req := cmd.payload.(moveRequest)
+				// 搜索期间玩家悔棋，AI 的结果已不适用于当前局面
+				if req.from.ai != nil && req.seq != req.from.ai.seq.Load() {
+					return nil
+				}
 				room := ch.Rooms[req.from.RoomId]
 				if room == nil {
 					req.from.sendMessage(NormalMessage{
@@ -282,16 +335,72 @@ func (ch *ChessHub) Run() {
 
 				target := room.Next
 
-				target.sendMessage(req.move)
+				// 计时对局：走子到达时若已超时（定时器尚未触发），按超时判负，不再执行这步棋
+				now := time.Now()
+				if room.clock != nil && room.clock.flagIfExpired(req.from.Role, now) {
+					ch.endByTimeout(room, req.from.Role)
+					return nil
+				}
 
-				// 将此次走棋记录追加到房间历史（按顺序保存 from, to）
+				// 服务端校验走子合法性，非法走子不转发、不记录，并让发起方回滚到服务端局面
 				room.mu.Lock()
-				room.History = append(room.History, req.move.From)
-				room.History = append(room.History, req.move.To)
+				err := room.playMove(req.move.From, req.move.To, req.from.Role)
+				if err == nil {
+					// 将此次走棋记录追加到房间历史（按顺序保存 from, to）
+					room.History = append(room.History, req.move.From)
+					room.History = append(room.History, req.move.To)
+				}
 				room.mu.Unlock()
+				if err != nil {
+					req.from.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageError},
+						Message:     "非法走子：" + err.Error(),
+					})
+					req.from.sendMessage(room.syncMessage(req.from.Role))
+					return nil
+				}
+
+				// 按钟并随转发的棋步附上双方剩余时间
+				if room.clock != nil {
+					room.clock.punch(req.from.Role, now)
+					req.move.Clock = room.clock.snapshot(now)
+				}
+				target.sendMessage(req.move)
+				room.broadcastToSpectators(spectatorMove(req.move, req.from.Role))
 
 				// 交换当前玩家和下一个玩家
 				room.exchange()
+				// 每步棋后保存快照，服务重启后可恢复对局
+				room.saveSnapshot()
+
+				// 由服务端判定胜负：将死、困毙以及循环局面的长将长捉裁决，残局挑战还需检查限定步数
+				winner, reason, over := room.adjudicate()
+				if !over {
+					winner, reason, over = room.endgameVerdict(req.from.Role)
+				}
+				if over {
+					go ch.enqueue(hubCommand{
+						commandType: commandEnd,
+						client:      req.from,
+						payload:     endRequest{winner: winner, reason: reason},
+					})
+				} else {
+					if room.takeAutoDrawOffer() {
+						// 自然限着：连续未吃子达到提议步数，系统向双方提议和棋
+						offer := NormalMessage{
+							BaseMessage: BaseMessage{Type: messageDrawRequest},
+							Message:     fmt.Sprintf("双方已连续%d步未吃子，系统提议和棋", room.NoCaptureCount),
+						}
+						req.from.sendMessage(offer)
+						target.sendMessage(offer)
+						// AI 总是同意系统和棋提议
+						if bot := room.aiClient(); bot != nil {
+							room.acceptAutoDraw(bot.Role, true)
+						}
+					}
+					// 人机对局中轮到 AI 时开始思考
+					ch.scheduleAIMove(room)
+				}
 			case commandSendMessage:
 				req := cmd.payload.(sendMessageRequest)
 				err := req.target.sendMessage(req.message)
@@ -317,6 +426,11 @@ func (ch *ChessHub) Run() {
 					return nil
 				}
 
+				// 比赛对局的先后手由编排决定，与入场顺序无关
+				if room.tournament != nil && room.Current.Id != room.tournament.red {
+					room.exchange()
+				}
+
 				// 获取用户信息
 				var currentUser, nextUser modeluser.User
 				database.GetMysqlDb().First(&currentUser, room.Current.Id)
@@ -324,13 +438,18 @@ func (ch *ChessHub) Run() {
 
 				room.Current.startPlay(roleRed)
 				room.Next.startPlay(roleBlack)
+				// 开始下棋的玩家不再观战其他对局
+				ch.stopWatching(room.Current)
+				ch.stopWatching(room.Next)
 
+				ratingSvc := service.NewRatingService()
 				curOpponent := OpponentInfo{
 					Name:       nextUser.Name,
 					Avatar:     nextUser.Avatar,
 					Exp:        nextUser.Exp,
 					TotalGames: nextUser.TotalGames,
 					WinRate:    nextUser.WinRate,
+					Rating:     ratingSvc.DisplayRating(nextUser.ID),
 				}
 
 				nextOpponent := OpponentInfo{
@@ -339,14 +458,31 @@ func (ch *ChessHub) Run() {
 					Exp:        currentUser.Exp,
 					TotalGames: currentUser.TotalGames,
 					WinRate:    currentUser.WinRate,
+					Rating:     ratingSvc.DisplayRating(currentUser.ID),
 				}
 
 				cur := startMessage{BaseMessage: BaseMessage{Type: messageStart}, Role: "red", Opponent: curOpponent}
 				next := startMessage{BaseMessage: BaseMessage{Type: messageStart}, Role: "black", Opponent: nextOpponent}
+				if room.TimeControl.Enabled() {
+					tc := room.TimeControl
+					cur.TimeControl = &tc
+					next.TimeControl = &tc
+				}
+				if room.tournament != nil {
+					cur.TournamentId, cur.Round = room.tournament.tournamentID, room.tournament.round
+					next.TournamentId, next.Round = room.tournament.tournamentID, room.tournament.round
+				}
 				room.Current.sendMessage(cur)
 				room.Next.sendMessage(next)
-				// 记录对局开始时间
+				// 记录对局开始时间，计时对局从此刻开始为红方计时
 				room.StartTime = time.Now()
+				room.startClock(func(loser clientRole) {
+					ch.endByTimeout(room, loser)
+				})
+				room.saveSnapshot()
+				if room.tournament != nil {
+					room.tournamentStarted()
+				}
 				// 移除空余房间
 				ch.mu.Lock()
 				for i, r := range ch.spareRooms {
@@ -357,41 +493,82 @@ func (ch *ChessHub) Run() {
 				}
 				ch.mu.Unlock()
 			case commandEnd:
-				var winner clientRole
+				req := cmd.payload.(endRequest)
 
 				room := ch.Rooms[cmd.client.RoomId]
 				if room == nil {
-					// 房间不存在：仍然尝试告知当前客户端比赛结束并返回胜者信息（避免显示“房间不存在”）
-					if cmd.payload == nil {
-						winner = cmd.client.Role
-					} else {
-						// payload 已经是客户端传来的 winner（clientRole），直接使用
-						winner = cmd.payload.(clientRole)
+					// 客户端上报的结束到达时房间已被服务端结算，结果早已下发，无需重复通知
+					if req.reason == "" {
+						return nil
 					}
+					// 房间不存在：仍然尝试告知当前客户端比赛结束并返回胜者信息（避免显示“房间不存在”）
 					endMsg := endMessage{
 						BaseMessage: BaseMessage{Type: messageEnd},
-						Winner:      winner,
+						Winner:      req.winner,
+						Reason:      req.reason,
 					}
 					cmd.client.sendMessage(endMsg)
 					return nil
 				}
-				if cmd.payload == nil {
-					winner = cmd.client.Role
+				if req.reason == "" {
+					// 客户端上报的结束不可信，以服务端棋盘为准
+					winner, reason, over := room.adjudicate()
+					if !over {
+						cmd.client.sendMessage(NormalMessage{
+							BaseMessage: BaseMessage{Type: messageError},
+							Message:     "对局尚未分出胜负",
+						})
+						return nil
+					}
+					req.winner, req.reason = winner, reason
+				}
+				// 循环裁决等需要向双方说明判罚
+				if text := endReasonText(req.winner, req.reason); text != "" {
+					ruling := NormalMessage{BaseMessage: BaseMessage{Type: messageNormal}, Message: text}
+					room.Current.sendMessage(ruling)
+					room.Next.sendMessage(ruling)
+					room.broadcastToSpectators(ruling)
+				}
+				// 保存对局记录到数据库（在清理房间前保存），按实际赢家记录；计分对局同时结算等级分
+				// 残局挑战不保存对局记录，改为记录残局进度
+				var ratingChanges map[clientRole]*ratingDto.RatingChange
+				var endgameResult *endgameDto.EndgameResult
+				if room.endgame != nil {
+					endgameResult = saveEndgameResult(room, req.winner, req.reason)
 				} else {
-					// payload 已经是客户端传来的 winner（clientRole），直接使用
-					winner = cmd.payload.(clientRole)
+					ratingChanges = saveGameRecord(room, req.winner, req.reason)
+				}
+				// 发送消息给两个客户端，通知他们结束游戏，各自附上本方的等级分变化
+				for _, player := range []*Client{room.Current, room.Next} {
+					player.sendMessage(endMessage{
+						BaseMessage: BaseMessage{Type: messageEnd},
+						Winner:      req.winner,
+						Reason:      req.reason,
+						Rating:      ratingChanges[player.Role],
+						Endgame:     endgameResult,
+					})
 				}
-				// 发送消息给两个客户端，通知他们结束游戏
-				endMsg := endMessage{
+				room.broadcastToSpectators(endMessage{
 					BaseMessage: BaseMessage{Type: messageEnd},
-					Winner:      winner,
+					Winner:      req.winner,
+					Reason:      req.reason,
+				})
+				var players [2]int
+				for i, player := range []*Client{room.Current, room.Next} {
+					if player != nil {
+						players[i] = player.Id
+					}
 				}
-				room.Current.sendMessage(endMsg)
-				room.Next.sendMessage(endMsg)
-				// 保存对局记录到数据库（在清理房间前保存），按实际赢家记录
-				saveGameRecord(room, winner)
 				room.clear()
-				delete(ch.Rooms, cmd.client.RoomId)
+				// clear 已重置玩家的 RoomId，按房间自身的ID移除
+				ch.mu.Lock()
+				delete(ch.Rooms, room.Id)
+				ch.matchmaker.gameEnded(players[0], players[1], time.Now())
+				ch.mu.Unlock()
+				// 比赛对局结束后检查本轮是否全部结束，以便立即编排下一轮
+				if room.tournament != nil {
+					ch.scheduleTournamentTick()
+				}
 			case commandHeartbeat:
 				// 更新客户端的最后一次心跳时间
 				client := cmd.client
@@ -400,6 +577,8 @@ func (ch *ChessHub) Run() {
 			case commandDisconnect:
 				// 当检测到连接断开时，设置一个定时器等待客户端重连，超时后再真正注销
 				client := cmd.client
+				// 玩家在其他实例的房间中时，由房间所在实例判定断线
+				client.notifyRoomNode(clusterDisconnect)
 				ch.mu.Lock()
 				// 如果已有定时器则先停止
 				if t, ok := ch.disconnectTimers[client.Id]; ok {
@@ -408,12 +587,8 @@ func (ch *ChessHub) Run() {
 				// 标记连接为空
 				if existing, ok := ch.Clients[client.Id]; ok {
 					existing.Conn = nil
-					// 如果该玩家在匹配池中，立即移除，避免在短暂断线时被匹配
-					for i := len(ch.matchPool) - 1; i >= 0; i-- {
-						if ch.matchPool[i].Id == existing.Id {
-							ch.matchPool = append(ch.matchPool[:i], ch.matchPool[i+1:]...)
-						}
-					}
+					// 如果该玩家在匹配队列中，立即移除，避免在短暂断线时被匹配
+					ch.matchmaker.remove(existing.Id)
 				}
 				// 通知对手：对方断线，正在等待重连
 				room := ch.Rooms[client.RoomId]
@@ -429,49 +604,40 @@ func (ch *ChessHub) Run() {
 					}
 				}
 
-				// 启动定时器，宽限期到达则根据当时状态决定处理方式
-				t := time.AfterFunc(ReconnectGrace, func() {
-					ch.mu.Lock()
-					currentClient, exists := ch.Clients[client.Id]
-					ch.mu.Unlock()
-					if !exists {
-						return // 玩家已被清理
-					}
-
-					// 若玩家仍在游戏中，则判对手胜
-					if currentClient.Status == userPlaying {
-						var winner clientRole
-						if currentClient.Role == roleRed {
-							winner = roleBlack
-						} else if currentClient.Role == roleBlack {
-							winner = roleRed
-						} else {
-							winner = roleNone
-						}
-						ch.commands <- hubCommand{
-							commandType: commandEnd,
-							client:      currentClient,
-							payload:     winner,
-						}
-					} else {
-						// 若玩家不在游戏中（已离开或空闲），则直接注销
-						ch.commands <- hubCommand{
-							commandType: commandUnregister,
-							client:      currentClient,
-						}
-					}
-					// 清理定时器记录
-					ch.mu.Lock()
-					delete(ch.disconnectTimers, client.Id)
-					ch.mu.Unlock()
-				})
-				ch.disconnectTimers[client.Id] = t
+				ch.startDisconnectTimer(client, ReconnectGrace)
 				ch.mu.Unlock()
 			case commandJoin:
 				joinMsg := cmd.payload.(joinMessage)
+				roomId, err := inviteRoomId(joinMsg.RoomId, joinMsg.Invite)
+				if err != nil {
+					cmd.client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageError},
+						Message:     err.Error(),
+					})
+					return nil
+				}
 				ch.mu.Lock()
-				room := ch.Rooms[joinMsg.RoomId]
+				room := ch.Rooms[roomId]
+				ch.mu.Unlock()
 				if room == nil {
+					ch.sendMessage(cmd.client, NormalMessage{
+						BaseMessage: BaseMessage{Type: messageNormal},
+						Message:     "房间不存在",
+					})
+					return nil
+				}
+				// 校验密码较慢，不在持有 ch.mu 时进行；比赛房间不设密码，只允许对阵双方入场
+				if room.tournament == nil {
+					if err := room.checkAccess(joinMsg.Password, joinMsg.Invite); err != nil {
+						cmd.client.sendMessage(NormalMessage{
+							BaseMessage: BaseMessage{Type: messageError},
+							Message:     err.Error(),
+						})
+						return nil
+					}
+				}
+				ch.mu.Lock()
+				if ch.Rooms[roomId] != room {
 					ch.sendMessage(cmd.client, NormalMessage{
 						BaseMessage: BaseMessage{Type: messageNormal},
 						Message:     "房间不存在",
@@ -479,7 +645,11 @@ func (ch *ChessHub) Run() {
 					ch.mu.Unlock()
 					return nil
 				}
-				err := room.join(cmd.client)
+				if room.tournament != nil && !ch.joinTournamentRoom(room, cmd.client) {
+					ch.mu.Unlock()
+					return nil
+				}
+				err = room.join(cmd.client)
 				if err != nil {
 					cmd.client.sendMessage(NormalMessage{
 						BaseMessage: BaseMessage{Type: messageNormal},
@@ -488,34 +658,57 @@ func (ch *ChessHub) Run() {
 					ch.mu.Unlock()
 					return nil
 				}
+				waiting := room.tournament != nil && !room.isFull()
 				ch.mu.Unlock()
+				// 比赛房间由服务端创建，先到的棋手等待对手入场后再开局
+				if waiting {
+					cmd.client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageNormal},
+						Message:     "已进入比赛房间，等待对手入场",
+					})
+					return nil
+				}
 				// 发送消息给两个客户端，通知他们开始游戏
-				go func() {
-					ch.commands <- hubCommand{
-						commandType: commandStart,
-						client:      cmd.client,
-					}
-				}()
+				go ch.enqueue(hubCommand{
+					commandType: commandStart,
+					client:      cmd.client,
+				})
 			case commandCreate:
 				// 创建房间
 				client := cmd.client
+				createMsg := cmd.payload.(createMessage)
 				r := NewChessRoom()
-				r.join(client)
-				ch.Rooms[r.Id] = r
-				roomInfo := room.RoomInfo{
-					Id: client.RoomId,
-					Current: dtouser.UserInfo{
-						ID: uint(client.Id),
-					},
+				r.TimeControl = createMsg.TimeControl
+				if err := r.setAccess(createMsg.Private, createMsg.Password); err != nil {
+					return fmt.Errorf("设置房间权限失败: %v", err)
 				}
+				created, err := r.inviteMessage()
+				if err != nil {
+					return fmt.Errorf("生成邀请链接失败: %v", err)
+				}
+				r.join(client)
 				ch.mu.Lock()
-				ch.spareRooms = append(ch.spareRooms, roomInfo)
+				ch.addRoom(r)
+				// 私密房间不在大厅展示
+				if !r.Private {
+					ch.spareRooms = append(ch.spareRooms, room.RoomInfo{
+						Id: client.RoomId,
+						Current: dtouser.UserInfo{
+							ID: uint(client.Id),
+						},
+						Locked: r.locked(),
+					})
+				}
 				ch.mu.Unlock()
-				// 发送消息给客户端，通知他们创建房间成功
-				ch.sendMessage(client, NormalMessage{
-					BaseMessage: BaseMessage{Type: messageCreate},
-				})
+				// 发送消息给客户端，通知他们创建房间成功，并附上邀请链接
+				ch.sendMessage(client, created)
 				return nil
+			case commandCreateAI:
+				ch.startAIGame(cmd.client, cmd.payload.(createAIMessage))
+			case commandCreateEndgame:
+				ch.startEndgame(cmd.client, cmd.payload.(*endgameModel.Scenario))
+			case commandTournamentTick:
+				ch.tickTournaments()
 			case commandFriendChallengeInvite:
 				// payload: map[string]any{"receiverId":uint, "relationId":uint}
 				p := cmd.payload.(map[string]any)
@@ -524,8 +717,17 @@ func (ch *ChessHub) Run() {
 				// 创建房间并标记为好友对战
 				r := NewChessRoom()
 				r.GameType = 2
+				// 邀请方提议计分，接受方同意后才计入等级分
+				r.ratedOffer, _ = p["rated"].(bool)
+				var timeControl *TimeControl
+				if tc, ok := p["timeControl"].(TimeControl); ok && tc.Enabled() {
+					r.TimeControl = tc
+					timeControl = &tc
+				}
 				r.join(cmd.client)
-				ch.Rooms[r.Id] = r
+				ch.mu.Lock()
+				ch.addRoom(r)
+				ch.mu.Unlock()
 				// 插入挑战记录（带房间ID）
 				fcSvc := service.NewFriendChallengeService()
 				rec, _ := fcSvc.Create(relationId, uint(cmd.client.Id), uint(receiverId), r.Id)
@@ -545,6 +747,8 @@ func (ch *ChessHub) Run() {
 					ReceiverId:  uint(receiverId),
 					SenderName:  senderName,
 					RoomId:      r.Id,
+					TimeControl: timeControl,
+					Rated:       r.ratedOffer,
 				})
 				// 给发送方一个回执
 				ch.sendMessage(cmd.client, &FriendChallengeMessage{
@@ -601,20 +805,25 @@ func (ch *ChessHub) Run() {
 				senderId := int(p["senderId"].(uint))
 				roomId := int(p["roomId"].(int))
 				// 校验对方仍在线且挑战存在
-				ch.mu.Lock()
-				_, ok := ch.Clients[senderId]
-				ch.mu.Unlock()
+				ok := ch.isOnline(senderId)
 				exists, _ := service.NewFriendChallengeService().Exists(challengeId)
 				if !ok || !exists {
 					ch.sendMessage(cmd.client, NormalMessage{BaseMessage: BaseMessage{Type: messageNormal}, Message: "对方已离开或邀请已撤销"})
 					return nil
 				}
+				// 双方都同意才计入等级分
+				accepterRated, _ := p["rated"].(bool)
+				ch.mu.Lock()
+				if r := ch.Rooms[roomId]; r != nil {
+					r.Rated = r.ratedOffer && accepterRated
+				}
+				ch.mu.Unlock()
 				// 删除挑战记录
 				_ = service.NewFriendChallengeService().DeleteByID(challengeId)
 				// 通知发送方：已接受
 				_ = ch.SendToUser(senderId, &FriendChallengeMessage{BaseMessage: BaseMessage{Type: messageFriendChallengeAccept}, ChallengeId: challengeId, ReceiverId: uint(cmd.client.Id)})
 				// 让接收方加入房间（通过内部命令）
-				ch.commands <- hubCommand{commandType: commandJoin, client: cmd.client, payload: joinMessage{BaseMessage: BaseMessage{Type: messageJoin}, RoomId: roomId}}
+				go ch.enqueue(hubCommand{commandType: commandJoin, client: cmd.client, payload: joinMessage{BaseMessage: BaseMessage{Type: messageJoin}, RoomId: roomId}})
 				return nil
 			case commandFriendChallengeReject:
 				p := cmd.payload.(map[string]any)
@@ -675,13 +884,107 @@ func (ch *ChessHub) Run() {
 					})
 					return nil
 				}
-				// 获取对手
+				// 获取对手，对手屏蔽聊天时不再转发
 				target := room.Next
 				if client == room.Next {
 					target = room.Current
 				}
 				if target != nil {
-					target.sendMessage(chatMsg)
+					if room.isChatMuted(target.Role) {
+						client.sendMessage(NormalMessage{
+							BaseMessage: BaseMessage{Type: messageNormal},
+							Message:     "对方已屏蔽本局聊天",
+						})
+					} else {
+						target.sendMessage(chatMsg)
+					}
+				}
+				room.broadcastToSpectators(chatMsg)
+			case commandSpectatorChat:
+				client := cmd.client
+				chatMsg := cmd.payload.(*ChatMessage)
+				ch.mu.Lock()
+				room := ch.Rooms[client.watchRoomId]
+				ch.mu.Unlock()
+				if room == nil || !room.isPlaying() {
+					client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageError},
+						Message:     "对局不存在或已结束",
+					})
+					return nil
+				}
+				// 观战区聊天只发给其他观战者，对局中的棋手看不到
+				room.broadcastToSpectatorsExcept(chatMsg, client)
+			case commandChatMute:
+				client := cmd.client
+				muted := cmd.payload.(bool)
+				room := ch.Rooms[client.RoomId]
+				if room == nil {
+					client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageError},
+						Message:     "房间不存在",
+					})
+					return nil
+				}
+				room.setChatMuted(client.Role, muted)
+				text := "已恢复接收对方的聊天消息"
+				if muted {
+					text = "已屏蔽对方的聊天消息"
+				}
+				client.sendMessage(NormalMessage{
+					BaseMessage: BaseMessage{Type: messageNormal},
+					Message:     text,
+				})
+			case commandWatch:
+				client := cmd.client
+				watchMsg := cmd.payload.(watchMessage)
+				roomId, err := inviteRoomId(watchMsg.RoomId, watchMsg.Invite)
+				if err != nil {
+					client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageError},
+						Message:     err.Error(),
+					})
+					return nil
+				}
+				ch.mu.Lock()
+				room := ch.Rooms[roomId]
+				ch.mu.Unlock()
+				if room == nil || !room.isPlaying() {
+					client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageError},
+						Message:     "对局不存在或已结束",
+					})
+					return nil
+				}
+				if client.RoomId == roomId {
+					client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageError},
+						Message:     "您正在该对局中下棋",
+					})
+					return nil
+				}
+				if client.watchRoomId != roomId {
+					if err := room.checkAccess(watchMsg.Password, watchMsg.Invite); err != nil {
+						client.sendMessage(NormalMessage{
+							BaseMessage: BaseMessage{Type: messageError},
+							Message:     err.Error(),
+						})
+						return nil
+					}
+				}
+				// 同一时间只能观战一个对局
+				if client.watchRoomId != roomId {
+					ch.stopWatching(client)
+				}
+				room.addSpectator(client)
+				client.sendMessage(room.spectatorSync())
+			case commandUnwatch:
+				client := cmd.client
+				if ch.stopWatching(client) {
+					client.sendMessage(NormalMessage{
+						BaseMessage: BaseMessage{Type: messageNormal},
+						Message:     "已退出观战",
+					})
 				}
 			}
 			return nil
@@ -689,6 +992,62 @@ func (ch *ChessHub) Run() {
 	}
 }
 
+// startDisconnectTimer 启动断线定时器，宽限期到达则根据当时状态决定处理方式，调用方需持有 ch.mu
+func (ch *ChessHub) startDisconnectTimer(client *Client, grace time.Duration) {
+	t := time.AfterFunc(grace, func() {
+		ch.mu.Lock()
+		currentClient, exists := ch.Clients[client.Id]
+		ch.mu.Unlock()
+		if !exists {
+			return // 玩家已被清理
+		}
+
+		// 若玩家仍在游戏中，则判对手胜
+		if currentClient.Status == userPlaying {
+			var winner clientRole
+			if currentClient.Role == roleRed {
+				winner = roleBlack
+			} else if currentClient.Role == roleBlack {
+				winner = roleRed
+			} else {
+				winner = roleNone
+			}
+			ch.enqueue(hubCommand{
+				commandType: commandEnd,
+				client:      currentClient,
+				payload:     endRequest{winner: winner, reason: recordModel.EndReasonDisconnect},
+			})
+		} else {
+			// 若玩家不在游戏中（已离开或空闲），则直接注销
+			ch.enqueue(hubCommand{
+				commandType: commandUnregister,
+				client:      currentClient,
+			})
+		}
+		// 清理定时器记录
+		ch.mu.Lock()
+		delete(ch.disconnectTimers, client.Id)
+		ch.mu.Unlock()
+	})
+	ch.disconnectTimers[client.Id] = t
+}
+
+// endByTimeout 一方超时，交由 commandEnd 判对方获胜
+func (ch *ChessHub) endByTimeout(room *ChessRoom, loser clientRole) {
+	client := room.Current
+	if client == nil {
+		client = room.Next
+	}
+	if client == nil {
+		return
+	}
+	go ch.enqueue(hubCommand{
+		commandType: commandEnd,
+		client:      client,
+		payload:     endRequest{winner: opponentRole(loser), reason: recordModel.EndReasonTimeout},
+	})
+}
+
 func (ch *ChessHub) HandleConnection(c *gin.Context) {
 	userId, exists := c.Get("userId")
 	if !exists {
@@ -737,59 +1096,27 @@ func (ch *ChessHub) HandleConnection(c *gin.Context) {
 			// 忽略错误
 		}
 
-		// 关键：检查房间是否还存在
-		// 若房间已被清理（定时器到期导致的结果），则重置玩家状态为"在线"
-		_, roomExists := ch.Rooms[client.RoomId]
-		if !roomExists || client.RoomId == -1 {
-			// 房间已不存在或玩家未在房间中，重置状态以允许重新匹配
-			existing.Status = userOnline
-			existing.RoomId = -1
-			existing.Role = roleNone
-		}
+		// 此前经其他实例参与本实例对局的玩家，现在直接连接到本实例
+		existing.connNode = ""
 		ch.mu.Unlock()
+		setPresence(id)
 
 		// 通知客户端重连成功
 		client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageNormal}, Message: "重连成功"})
 
-		// 如果房间已被清理（例如对局已结束），发送同步消息以清理客户端的本地对局状态
-		if !roomExists || client.RoomId == -1 {
-			client.sendMessage(SyncMessage{BaseMessage: BaseMessage{Type: messageSync}, History: []Position{}, Role: "", CurrentTurn: ""})
-		}
-
-		// 若房间仍存在且玩家在其中，发送房间当前状态（同步棋步、角色与当前轮次）
-		if roomExists && client.RoomId != -1 {
-			ch.mu.Lock()
-			room := ch.Rooms[client.RoomId]
-			if room != nil {
-				history := make([]Position, len(room.History))
-				copy(history, room.History)
-				var roleStr string
-				if client.Role == roleRed {
-					roleStr = "red"
-				} else if client.Role == roleBlack {
-					roleStr = "black"
-				}
-				var currentTurn string
-				if room.Current != nil {
-					if room.Current.Role == roleRed {
-						currentTurn = "red"
-					} else if room.Current.Role == roleBlack {
-						currentTurn = "black"
-					}
-				}
-				ch.mu.Unlock()
-				client.sendMessage(SyncMessage{BaseMessage: BaseMessage{Type: messageSync}, History: history, Role: roleStr, CurrentTurn: currentTurn})
-			} else {
-				ch.mu.Unlock()
-			}
+		if client.roomNode != "" {
+			// 玩家在其他实例的房间中，由该实例下发同步消息
+			client.notifyRoomNode(clusterReconnect)
+		} else {
+			ch.resync(client)
 		}
 	} else {
 		ch.mu.Unlock()
 		client = NewClient(conn, id, user.Name)
-		ch.commands <- hubCommand{
+		ch.enqueue(hubCommand{
 			commandType: commandRegister,
 			client:      client,
-		}
+		})
 	}
 
 	conn.SetReadLimit(1024 * 1024)
@@ -819,12 +1146,8 @@ func (ch *ChessHub) HandleConnection(c *gin.Context) {
 	}()
 
 	// 断开时不立即注销，而是进入断线等待流程，使用 commandDisconnect 启动定时器
-	defer func() {
-		ch.commands <- hubCommand{
-			commandType: commandDisconnect,
-			client:      client,
-		}
-	}()
+	// 已停机时不再处理断线
+	defer ch.enqueue(hubCommand{commandType: commandDisconnect, client: client})
 
 	ch.sendMessage(client, NormalMessage{
 		BaseMessage: BaseMessage{Type: messageNormal},
@@ -847,11 +1170,76 @@ func (ch *ChessHub) HandleConnection(c *gin.Context) {
 	fmt.Println("客户端断开连接")
 }
 
+// resync 玩家重连后下发当前局面：仍在对局中则同步棋步、角色与当前轮次，观战中则同步观战局面，
+// 否则重置状态并发送空的同步消息以清理客户端的本地对局状态
+func (ch *ChessHub) resync(client *Client) {
+	ch.mu.Lock()
+	// 关键：检查房间是否还存在
+	// 若房间已被清理（定时器到期导致的结果），则重置玩家状态为"在线"
+	_, roomExists := ch.Rooms[client.RoomId]
+	if !roomExists || client.RoomId == -1 {
+		// 房间已不存在或玩家未在房间中，重置状态以允许重新匹配
+		client.Status = userOnline
+		client.RoomId = -1
+		client.Role = roleNone
+	}
+	ch.mu.Unlock()
+
+	// 如果房间已被清理（例如对局已结束），发送同步消息以清理客户端的本地对局状态
+	if !roomExists || client.RoomId == -1 {
+		ch.mu.Lock()
+		watched := ch.Rooms[client.watchRoomId]
+		ch.mu.Unlock()
+		if watched != nil && client.watchRoomId != 0 {
+			// 观战者重连：重新下发所观战对局的局面
+			client.sendMessage(watched.spectatorSync())
+		} else {
+			client.sendMessage(SyncMessage{BaseMessage: BaseMessage{Type: messageSync}, History: []Position{}, Role: "", CurrentTurn: ""})
+		}
+	}
+
+	// 若房间仍存在且玩家在其中，发送房间当前状态（同步棋步、角色与当前轮次）
+	if roomExists && client.RoomId != -1 {
+		ch.mu.Lock()
+		room := ch.Rooms[client.RoomId]
+		if room != nil {
+			// 与开局、重连时的同步一致，带上起始局面，残局与自定义局面的对局才能正确还原
+			msg := room.syncMessage(client.Role)
+			ch.mu.Unlock()
+			client.sendMessage(msg)
+		} else {
+			ch.mu.Unlock()
+		}
+	}
+}
+
+// startMatchedRooms 为配对成功的玩家创建房间并通知开始游戏，调用方需持有 ch.mu
+func (ch *ChessHub) startMatchedRooms(pairs []matchPair) {
+	for _, p := range pairs {
+		room := NewChessRoom()
+		room.TimeControl = p.queue.TimeControl
+		room.Rated = p.queue.Rated
+		room.join(p.red)
+		room.join(p.black)
+		ch.addRoom(room)
+		// 发送消息给两个客户端，通知他们开始游戏
+		go ch.enqueue(hubCommand{
+			commandType: commandStart,
+			client:      p.black,
+		})
+	}
+}
+
 func (ch *ChessHub) GetSpareRooms(c *gin.Context) {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	c.Set("rooms", ch.spareRooms)
+	// 等待加入的房间在前，随后是可观战的进行中对局
+	rooms := make([]room.RoomInfo, 0, len(ch.spareRooms))
+	rooms = append(rooms, ch.spareRooms...)
+	rooms = append(rooms, ch.watchableRooms()...)
+	c.Set("rooms", rooms)
+	c.Set("queues", ch.matchmaker.sizes())
 	c.Next()
 }
 
@@ -861,16 +1249,38 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 	if err != nil {
 		return fmt.Errorf("解析消息失败: %v", err)
 	}
+	// 集群模式下由房间所在实例处理的消息直接转发
+	if forwarded, err := ch.forwardToRoomNode(client, base.Type, rawMessage); forwarded {
+		return err
+	}
+	if ch.draining.Load() {
+		switch base.Type {
+		case messageMatch, messageCreate, messageCreateAI, messageCreateEndgame, messageJoin, messageFriendChallengeInvite, messageFriendChallengeAccept:
+			return client.sendMessage(NormalMessage{
+				BaseMessage: BaseMessage{Type: messageError},
+				Message:     "服务器即将维护，暂停匹配与开局",
+			})
+		}
+	}
 
 	switch base.Type {
 	case messageMatch:
 		switch client.Status {
 		case userOnline:
+			var matchMsg matchMessage
+			if err := json.Unmarshal(rawMessage, &matchMsg); err != nil {
+				return fmt.Errorf("解析匹配消息失败: %v", err)
+			}
+			queue, err := parseMatchQueue(matchMsg)
+			if err != nil {
+				return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: err.Error()})
+			}
+			client.matchQueue = queue
 			client.Status = userMatching
-			ch.commands <- hubCommand{
+			ch.enqueue(hubCommand{
 				commandType: commandMatch,
 				client:      client,
-			}
+			})
 		case userMatching:
 			msg := NormalMessage{
 				BaseMessage: BaseMessage{Type: messageNormal},
@@ -887,10 +1297,10 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 	case messageCancelMatch:
 		// 【问题2修复】处理取消匹配消息
 		if client.Status == userMatching {
-			ch.commands <- hubCommand{
+			ch.enqueue(hubCommand{
 				commandType: commandCancelMatch,
 				client:      client,
-			}
+			})
 		} else {
 			msg := NormalMessage{
 				BaseMessage: BaseMessage{Type: messageNormal},
@@ -907,33 +1317,25 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 				return err
 			}
 
-			ch.commands <- hubCommand{
+			ch.enqueue(hubCommand{
 				commandType: commandMove,
 				client:      client,
 				payload: moveRequest{
 					from: client,
 					move: moveMsg,
 				},
-			}
+			})
 		} else {
 			return fmt.Errorf("玩家不在游戏中")
 		}
 	case messageEnd:
 		if client.Status == userPlaying {
-			// 尝试解析消息体中的 winner 字段（可选）并作为 payload 传递到命令队列
-			var em endMessage
-			if err := json.Unmarshal(rawMessage, &em); err == nil {
-				ch.commands <- hubCommand{
-					commandType: commandEnd,
-					client:      client,
-					payload:     em.Winner,
-				}
-			} else {
-				ch.commands <- hubCommand{
-					commandType: commandEnd,
-					client:      client,
-				}
-			}
+			// 客户端声称的胜方不再采信，由 commandEnd 根据服务端棋盘裁定
+			ch.enqueue(hubCommand{
+				commandType: commandEnd,
+				client:      client,
+				payload:     endRequest{},
+			})
 		}
 	case messageJoin:
 		// 用户加入房间
@@ -952,11 +1354,32 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 			fmt.Printf("解析加入房间消息失败: %v\n", err)
 			return nil
 		}
-		ch.commands <- hubCommand{
+		ch.enqueue(hubCommand{
 			commandType: commandJoin,
 			client:      client,
 			payload:     joinMsg,
+		})
+	case messageWatch:
+		if client.Status == userPlaying {
+			return client.sendMessage(NormalMessage{
+				BaseMessage: BaseMessage{Type: messageNormal},
+				Message:     "您已在游戏中",
+			})
 		}
+		var watchMsg watchMessage
+		if err := json.Unmarshal(rawMessage, &watchMsg); err != nil {
+			return fmt.Errorf("解析观战消息失败: %v", err)
+		}
+		ch.enqueue(hubCommand{
+			commandType: commandWatch,
+			client:      client,
+			payload:     watchMsg,
+		})
+	case messageUnwatch:
+		ch.enqueue(hubCommand{
+			commandType: commandUnwatch,
+			client:      client,
+		})
 	case messageCreate:
 		// 用户创建房间
 		if client.Status == userPlaying {
@@ -968,10 +1391,68 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 			ch.sendMessage(client, msg)
 			return nil
 		}
-		ch.commands <- hubCommand{
+		var createMsg createMessage
+		if err := json.Unmarshal(rawMessage, &createMsg); err != nil {
+			return fmt.Errorf("解析创建房间消息失败: %v", err)
+		}
+		if err := createMsg.TimeControl.Validate(); err != nil {
+			return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: err.Error()})
+		}
+		if err := validateRoomPassword(createMsg.Password); err != nil {
+			return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: err.Error()})
+		}
+		ch.enqueue(hubCommand{
 			commandType: commandCreate,
 			client:      client,
+			payload:     createMsg,
+		})
+	case messageCreateAI:
+		// 人机对局：服务端 AI 执另一方，结果由服务端记录
+		if client.Status != userOnline || client.RoomId != -1 {
+			return client.sendMessage(NormalMessage{
+				BaseMessage: BaseMessage{Type: messageNormal},
+				Message:     "您已在房间中或正在匹配",
+			})
 		}
+		var createMsg createAIMessage
+		if err := json.Unmarshal(rawMessage, &createMsg); err != nil {
+			return fmt.Errorf("解析创建人机对局消息失败: %v", err)
+		}
+		if createMsg.Engine && !engine.Enabled() {
+			return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: engine.ErrDisabled.Error()})
+		}
+		if !createMsg.Engine && !ai.ValidLevel(createMsg.Level) {
+			return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: "无效的AI难度值"})
+		}
+		if createMsg.Color != "" && createMsg.Color != "red" && createMsg.Color != "black" {
+			return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: "无效的执子颜色"})
+		}
+		ch.enqueue(hubCommand{
+			commandType: commandCreateAI,
+			client:      client,
+			payload:     createMsg,
+		})
+	case messageCreateEndgame:
+		// 残局挑战：服务端 AI 执另一方，胜负、步数与经验由服务端裁定
+		if client.Status != userOnline || client.RoomId != -1 {
+			return client.sendMessage(NormalMessage{
+				BaseMessage: BaseMessage{Type: messageNormal},
+				Message:     "您已在房间中或正在匹配",
+			})
+		}
+		var createMsg createEndgameMessage
+		if err := json.Unmarshal(rawMessage, &createMsg); err != nil {
+			return fmt.Errorf("解析残局挑战消息失败: %v", err)
+		}
+		sc, err := service.GetEndgameScenario(createMsg.ScenarioId)
+		if err != nil {
+			return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: err.Error()})
+		}
+		ch.enqueue(hubCommand{
+			commandType: commandCreateEndgame,
+			client:      client,
+			payload:     sc,
+		})
 	case messageGiveUp:
 		if client.Status == userPlaying {
 			// 将认输请求转换为结束命令，payload 传递为对手角色（认输方的对手为胜者）
@@ -983,11 +1464,11 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 			} else {
 				winner = roleNone
 			}
-			ch.commands <- hubCommand{
+			ch.enqueue(hubCommand{
 				commandType: commandEnd,
 				client:      client,
-				payload:     winner,
-			}
+				payload:     endRequest{winner: winner, reason: recordModel.EndReasonResign},
+			})
 		}
 	// 新增：处理悔棋请求
 	case messageRegretRequest:
@@ -998,13 +1479,13 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 			})
 		}
 		// 发送内部命令到命令队列
-		ch.commands <- hubCommand{
+		ch.enqueue(hubCommand{
 			commandType: commandRegretRequest,
 			client:      client,
 			payload: regretRequestPayload{
 				from: client,
 			},
-		}
+		})
 
 	// 新增：处理前端悔棋响应消息，转为内部命令
 	case messageRegretResponse:
@@ -1019,37 +1500,61 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 			return fmt.Errorf("解析悔棋响应失败: %v", err)
 		}
 		// 发送内部命令到命令队列
-		ch.commands <- hubCommand{
+		ch.enqueue(hubCommand{
 			commandType: commandRegretResponse,
 			client:      client,
 			payload: regretResponsePayload{
 				from:     client,
 				accepted: resp.Accepted,
 			},
-		}
+		})
 	case messageChatMessage:
+		// 棋手发送对局聊天，观战者发送观战区聊天
+		commandType := commandChatMessage
+		channel := ""
 		if client.Status != userPlaying || client.RoomId == -1 {
-			return client.sendMessage(NormalMessage{
-				BaseMessage: BaseMessage{Type: messageError},
-				Message:     "不在游戏中，无法发送消息",
-			})
+			if client.watchRoomId == 0 {
+				return client.sendMessage(NormalMessage{
+					BaseMessage: BaseMessage{Type: messageError},
+					Message:     "不在游戏中，无法发送消息",
+				})
+			}
+			commandType = commandSpectatorChat
+			channel = "spectator"
 		}
 		var chatMsg ChatMessage
 		if err := json.Unmarshal(rawMessage, &chatMsg); err != nil {
 			return fmt.Errorf("解析聊天消息失败: %v", err)
 		}
 
-		ch.commands <- hubCommand{
-			commandType: commandChatMessage,
+		ch.enqueue(hubCommand{
+			commandType: commandType,
 			client:      client,
 			payload: &ChatMessage{
 				BaseMessage: BaseMessage{Type: messageChatMessage},
-				Content:     chatMsg.Content,
+				Content:     utils.FilterBannedWords(chatMsg.Content),
 				Sender:      client.Username,
+				Channel:     channel,
 				SenderId:    uint(client.Id),
 				CreatedAt:   time.Now().Unix(),
 			},
+		})
+	case messageChatMute:
+		if client.Status != userPlaying || client.RoomId == -1 {
+			return client.sendMessage(NormalMessage{
+				BaseMessage: BaseMessage{Type: messageError},
+				Message:     "不在游戏中，无法设置聊天",
+			})
+		}
+		var muteMsg chatMuteMessage
+		if err := json.Unmarshal(rawMessage, &muteMsg); err != nil {
+			return fmt.Errorf("解析聊天设置失败: %v", err)
 		}
+		ch.enqueue(hubCommand{
+			commandType: commandChatMute,
+			client:      client,
+			payload:     muteMsg.Muted,
+		})
 
 	case messageFriendRequest:
 		// 解析请求并保存到数据库
@@ -1086,11 +1591,11 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 				Message:     "不在游戏中，无法请求和棋",
 			})
 		}
-		ch.commands <- hubCommand{
+		ch.enqueue(hubCommand{
 			commandType: commandDrawRequest,
 			client:      client,
 			payload:     drawRequestPayload{from: client},
-		}
+		})
 
 	case messageDrawResponse:
 		if client.Status != userPlaying || client.RoomId == -1 {
@@ -1103,14 +1608,14 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 		if err := json.Unmarshal(rawMessage, &resp); err != nil {
 			return fmt.Errorf("解析和棋响应失败: %v", err)
 		}
-		ch.commands <- hubCommand{
+		ch.enqueue(hubCommand{
 			commandType: commandDrawResponse,
 			client:      client,
 			payload: drawResponsePayload{
 				from:     client,
 				accepted: resp.Accepted,
 			},
-		}
+		})
 	case messageFriendChallengeInvite:
 		// 期待前端发送 { receiverId, relationId }
 		var m FriendChallengeMessage
@@ -1118,31 +1623,35 @@ func (ch *ChessHub) handleMessage(client *Client, rawMessage []byte) error {
 			return fmt.Errorf("解析挑战邀请失败: %v", err)
 		}
 		// 对方不在线直接提示（前端也会判断，但这里兜底）
-		ch.mu.Lock()
-		_, ok := ch.Clients[int(m.ReceiverId)]
-		ch.mu.Unlock()
-		if !ok {
+		if !ch.isOnline(int(m.ReceiverId)) {
 			return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageNormal}, Message: "对方不在线，需等待对方在线才可对战"})
 		}
-		ch.commands <- hubCommand{commandType: commandFriendChallengeInvite, client: client, payload: map[string]any{"receiverId": m.ReceiverId, "relationId": m.RelationId}}
+		var timeControl TimeControl
+		if m.TimeControl != nil {
+			if err := m.TimeControl.Validate(); err != nil {
+				return client.sendMessage(NormalMessage{BaseMessage: BaseMessage{Type: messageError}, Message: err.Error()})
+			}
+			timeControl = *m.TimeControl
+		}
+		ch.enqueue(hubCommand{commandType: commandFriendChallengeInvite, client: client, payload: map[string]any{"receiverId": m.ReceiverId, "relationId": m.RelationId, "timeControl": timeControl, "rated": m.Rated}})
 	case messageFriendChallengeCancel:
 		var m FriendChallengeMessage
 		if err := json.Unmarshal(rawMessage, &m); err != nil {
 			return fmt.Errorf("解析挑战撤销失败: %v", err)
 		}
-		ch.commands <- hubCommand{commandType: commandFriendChallengeCancel, client: client, payload: map[string]any{"challengeId": m.ChallengeId, "receiverId": m.ReceiverId}}
+		ch.enqueue(hubCommand{commandType: commandFriendChallengeCancel, client: client, payload: map[string]any{"challengeId": m.ChallengeId, "receiverId": m.ReceiverId}})
 	case messageFriendChallengeAccept:
 		var m FriendChallengeMessage
 		if err := json.Unmarshal(rawMessage, &m); err != nil {
 			return fmt.Errorf("解析挑战接受失败: %v", err)
 		}
-		ch.commands <- hubCommand{commandType: commandFriendChallengeAccept, client: client, payload: map[string]any{"challengeId": m.ChallengeId, "senderId": m.SenderId, "roomId": m.RoomId}}
+		ch.enqueue(hubCommand{commandType: commandFriendChallengeAccept, client: client, payload: map[string]any{"challengeId": m.ChallengeId, "senderId": m.SenderId, "roomId": m.RoomId, "rated": m.Rated}})
 	case messageFriendChallengeReject:
 		var m FriendChallengeMessage
 		if err := json.Unmarshal(rawMessage, &m); err != nil {
 			return fmt.Errorf("解析挑战拒绝失败: %v", err)
 		}
-		ch.commands <- hubCommand{commandType: commandFriendChallengeReject, client: client, payload: map[string]any{"challengeId": m.ChallengeId, "senderId": m.SenderId}}
+		ch.enqueue(hubCommand{commandType: commandFriendChallengeReject, client: client, payload: map[string]any{"challengeId": m.ChallengeId, "senderId": m.SenderId}})
 	}
 	return nil
 }
@@ -0,0 +1,162 @@
+package xiangqi
+
+import "errors"
+
+var (
+	ErrOutOfBoard   = errors.New("坐标超出棋盘")
+	ErrNoPiece      = errors.New("起点没有棋子")
+	ErrNotYourTurn  = errors.New("不能移动对方的棋子")
+	ErrIllegalMove  = errors.New("走法不符合规则")
+	ErrSelfCheck    = errors.New("不能送将")
+	ErrSameLocation = errors.New("起点与终点相同")
+)
+
+// Board 棋盘局面，cells 按 [y][x] 存储
+type Board struct {
+	cells         [10][9]Piece
+	Turn          Color // 当前轮到行棋的一方
+	HalfMoveClock int   // 自上次吃子以来的步数（半回合）
+	FullMove      int   // 回合数，从 1 开始，黑方走完后加一
+}
+
+// NewBoard 返回标准开局局面，红方先行
+func NewBoard() *Board {
+	b := &Board{Turn: Red, FullMove: 1}
+	backRank := []Kind{Chariot, Horse, Elephant, Advisor, General, Advisor, Elephant, Horse, Chariot}
+	for x, k := range backRank {
+		b.cells[0][x] = Piece{Kind: k, Color: Black}
+		b.cells[9][x] = Piece{Kind: k, Color: Red}
+	}
+	for _, x := range []int{1, 7} {
+		b.cells[2][x] = Piece{Kind: Cannon, Color: Black}
+		b.cells[7][x] = Piece{Kind: Cannon, Color: Red}
+	}
+	for x := 0; x <= 8; x += 2 {
+		b.cells[3][x] = Piece{Kind: Soldier, Color: Black}
+		b.cells[6][x] = Piece{Kind: Soldier, Color: Red}
+	}
+	return b
+}
+
+// EmptyBoard 返回一个没有任何棋子的棋盘，用于摆放残局等自定义局面
+func EmptyBoard(turn Color) *Board {
+	return &Board{Turn: turn, FullMove: 1}
+}
+
+// At 返回指定坐标上的棋子，越界时返回空位
+func (b *Board) At(p Pos) Piece {
+	if !p.Valid() {
+		return Piece{}
+	}
+	return b.cells[p.Y][p.X]
+}
+
+// Set 在指定坐标放置棋子（放置零值 Piece 即清空）
+func (b *Board) Set(p Pos, piece Piece) {
+	if !p.Valid() {
+		return
+	}
+	b.cells[p.Y][p.X] = piece
+}
+
+// Clone 深拷贝棋盘
+func (b *Board) Clone() *Board {
+	nb := *b
+	return &nb
+}
+
+// General 返回指定颜色将帅的位置，找不到时第二个返回值为 false
+func (b *Board) General(c Color) (Pos, bool) {
+	for y := 0; y <= 9; y++ {
+		for x := 3; x <= 5; x++ {
+			p := b.cells[y][x]
+			if p.Kind == General && p.Color == c {
+				return Pos{X: x, Y: y}, true
+			}
+		}
+	}
+	return Pos{}, false
+}
+
+// apply 直接执行走法并交换行棋方，不做任何合法性校验，返回被吃掉的棋子
+func (b *Board) apply(m Move) Piece {
+	captured := b.cells[m.To.Y][m.To.X]
+	b.cells[m.To.Y][m.To.X] = b.cells[m.From.Y][m.From.X]
+	b.cells[m.From.Y][m.From.X] = Piece{}
+	b.Turn = b.Turn.Opponent()
+	return captured
+}
+
+// undo 撤销 apply 执行的走法
+func (b *Board) undo(m Move, captured Piece) {
+	b.cells[m.From.Y][m.From.X] = b.cells[m.To.Y][m.To.X]
+	b.cells[m.To.Y][m.To.X] = captured
+	b.Turn = b.Turn.Opponent()
+}
+
+// Play 执行一步已知合法（或伪合法）的走法，不做校验也不更新计数，供搜索使用，须与 Unplay 成对调用
+func (b *Board) Play(m Move) Piece {
+	return b.apply(m)
+}
+
+// Unplay 撤销 Play 执行的走法
+func (b *Board) Unplay(m Move, captured Piece) {
+	b.undo(m, captured)
+}
+
+// Validate 校验当前行棋方的一步棋是否合法，不修改棋盘
+func (b *Board) Validate(m Move) error {
+	if !m.From.Valid() || !m.To.Valid() {
+		return ErrOutOfBoard
+	}
+	if m.From == m.To {
+		return ErrSameLocation
+	}
+	piece := b.At(m.From)
+	if piece.IsEmpty() {
+		return ErrNoPiece
+	}
+	if piece.Color != b.Turn {
+		return ErrNotYourTurn
+	}
+	found := false
+	for _, to := range b.pseudoTargets(m.From) {
+		if to == m.To {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrIllegalMove
+	}
+	if b.leavesInCheck(m) {
+		return ErrSelfCheck
+	}
+	return nil
+}
+
+// MakeMove 校验并执行一步棋，成功时返回被吃掉的棋子（未吃子时为空位）
+func (b *Board) MakeMove(m Move) (Piece, error) {
+	if err := b.Validate(m); err != nil {
+		return Piece{}, err
+	}
+	if b.Turn == Black {
+		b.FullMove++
+	}
+	captured := b.apply(m)
+	if captured.IsEmpty() {
+		b.HalfMoveClock++
+	} else {
+		b.HalfMoveClock = 0
+	}
+	return captured, nil
+}
+
+// leavesInCheck 判断走完这步棋后己方是否处于被将军（含将帅照面）状态
+func (b *Board) leavesInCheck(m Move) bool {
+	mover := b.At(m.From).Color
+	captured := b.apply(m)
+	inCheck := b.InCheck(mover)
+	b.undo(m, captured)
+	return inCheck
+}
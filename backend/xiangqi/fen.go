@@ -0,0 +1,242 @@
+package xiangqi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InitialFEN 标准开局局面的 FEN
+const InitialFEN = "rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9/RNBAKABNR w - - 0 1"
+
+// fenLetters 棋子种类对应的 FEN 字母（红方大写、黑方小写）
+var fenLetters = map[Kind]byte{
+	General:  'k',
+	Advisor:  'a',
+	Elephant: 'b',
+	Horse:    'n',
+	Chariot:  'r',
+	Cannon:   'c',
+	Soldier:  'p',
+}
+
+// fenKinds FEN 字母到棋子种类，兼容部分软件使用的 e(象)、h(马)
+var fenKinds = map[byte]Kind{
+	'k': General,
+	'a': Advisor,
+	'b': Elephant,
+	'e': Elephant,
+	'n': Horse,
+	'h': Horse,
+	'r': Chariot,
+	'c': Cannon,
+	'p': Soldier,
+}
+
+// ParseFEN 解析 WXF/UCCI 格式的 FEN，只校验格式；棋子摆放是否合理请调用 CheckPosition
+// 行序与 Board 一致：第一段为 y=0（黑方底线），最后一段为 y=9（红方底线）
+func ParseFEN(fen string) (*Board, error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return nil, errors.New("FEN 不能为空")
+	}
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 10 {
+		return nil, fmt.Errorf("FEN 应包含 10 行，实际为 %d 行", len(ranks))
+	}
+
+	b := EmptyBoard(Red)
+	for y, rank := range ranks {
+		x := 0
+		for i := 0; i < len(rank); i++ {
+			ch := rank[i]
+			if ch >= '1' && ch <= '9' {
+				x += int(ch - '0')
+				continue
+			}
+			lower := ch | 0x20
+			kind, ok := fenKinds[lower]
+			if !ok {
+				return nil, fmt.Errorf("第 %d 行包含无法识别的字符 %q", y+1, ch)
+			}
+			if x > 8 {
+				return nil, fmt.Errorf("第 %d 行超过 9 列", y+1)
+			}
+			color := Black
+			if ch != lower {
+				color = Red
+			}
+			b.cells[y][x] = Piece{Kind: kind, Color: color}
+			x++
+		}
+		if x != 9 {
+			return nil, fmt.Errorf("第 %d 行应为 9 列，实际为 %d 列", y+1, x)
+		}
+	}
+
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "w", "r":
+			b.Turn = Red
+		case "b":
+			b.Turn = Black
+		default:
+			return nil, fmt.Errorf("无法识别的行棋方 %q", fields[1])
+		}
+	}
+	// 第 3、4 段在象棋中固定为 "-"，直接忽略
+	if len(fields) > 4 {
+		n, err := strconv.Atoi(fields[4])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("无效的半回合计数 %q", fields[4])
+		}
+		b.HalfMoveClock = n
+	}
+	if len(fields) > 5 {
+		n, err := strconv.Atoi(fields[5])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("无效的回合数 %q", fields[5])
+		}
+		b.FullMove = n
+	}
+	return b, nil
+}
+
+// FEN 将局面序列化为 FEN
+func (b *Board) FEN() string {
+	var sb strings.Builder
+	for y := 0; y <= 9; y++ {
+		if y > 0 {
+			sb.WriteByte('/')
+		}
+		empty := 0
+		for x := 0; x <= 8; x++ {
+			p := b.cells[y][x]
+			if p.IsEmpty() {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			ch := fenLetters[p.Kind]
+			if p.Color == Red {
+				ch -= 'a' - 'A'
+			}
+			sb.WriteByte(ch)
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+	}
+	turn := "w"
+	if b.Turn == Black {
+		turn = "b"
+	}
+	fmt.Fprintf(&sb, " %s - - %d %d", turn, b.HalfMoveClock, b.FullMove)
+	return sb.String()
+}
+
+// pieceLimits 每方各类棋子的最大数量
+var pieceLimits = map[Kind]int{
+	General:  1,
+	Advisor:  2,
+	Elephant: 2,
+	Horse:    2,
+	Chariot:  2,
+	Cannon:   2,
+	Soldier:  5,
+}
+
+// CheckPosition 检查局面是否可能出现在实战中，返回全部问题（为空表示局面合法）
+// 检查项：棋子数量、将帅/仕/相的可达位置、兵卒不能退回己方兵线之后、将帅不能照面、非行棋方不能正被将军
+func (b *Board) CheckPosition() []error {
+	var errs []error
+	counts := map[Color]map[Kind]int{Red: {}, Black: {}}
+	for y := 0; y <= 9; y++ {
+		for x := 0; x <= 8; x++ {
+			p := b.cells[y][x]
+			if p.IsEmpty() {
+				continue
+			}
+			counts[p.Color][p.Kind]++
+			pos := Pos{X: x, Y: y}
+			if !reachable(p, pos) {
+				errs = append(errs, fmt.Errorf("%s%s不能位于 (%d,%d)", colorName(p.Color), kindName(p.Kind), x, y))
+			}
+		}
+	}
+	for _, c := range []Color{Red, Black} {
+		if counts[c][General] == 0 {
+			errs = append(errs, fmt.Errorf("缺少%s%s", colorName(c), kindName(General)))
+		}
+		for _, k := range []Kind{General, Advisor, Elephant, Horse, Chariot, Cannon, Soldier} {
+			if counts[c][k] > pieceLimits[k] {
+				errs = append(errs, fmt.Errorf("%s%s数量超过 %d 个", colorName(c), kindName(k), pieceLimits[k]))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if b.GeneralsFacing() {
+		errs = append(errs, errors.New("将帅不能照面"))
+	} else if b.InCheck(b.Turn.Opponent()) {
+		errs = append(errs, fmt.Errorf("轮到%s走棋时%s不能正被将军", colorName(b.Turn), colorName(b.Turn.Opponent())))
+	}
+	return errs
+}
+
+// reachable 判断棋子是否可能出现在该位置
+func reachable(p Piece, pos Pos) bool {
+	// 以红方视角判断，黑方棋子先旋转到红方一侧
+	rp := pos
+	if p.Color == Black {
+		rp = pos.Flip()
+	}
+	switch p.Kind {
+	case General:
+		return inPalace(rp, Red)
+	case Advisor:
+		return inPalace(rp, Red) && (rp.X+rp.Y)%2 == 0
+	case Elephant:
+		switch rp {
+		case Pos{X: 2, Y: 9}, Pos{X: 6, Y: 9}, Pos{X: 0, Y: 7}, Pos{X: 4, Y: 7}, Pos{X: 8, Y: 7}, Pos{X: 2, Y: 5}, Pos{X: 6, Y: 5}:
+			return true
+		}
+		return false
+	case Soldier:
+		if rp.Y > 6 {
+			return false
+		}
+		// 未过河的兵只能在原来的五个兵位上
+		if rp.Y >= 5 {
+			return rp.X%2 == 0
+		}
+		return true
+	}
+	return true
+}
+
+func colorName(c Color) string {
+	if c == Red {
+		return "红方"
+	}
+	return "黑方"
+}
+
+var kindNames = map[Kind]string{
+	General:  "将帅",
+	Advisor:  "仕士",
+	Elephant: "相象",
+	Horse:    "马",
+	Chariot:  "车",
+	Cannon:   "炮",
+	Soldier:  "兵卒",
+}
+
+func kindName(k Kind) string {
+	return kindNames[k]
+}
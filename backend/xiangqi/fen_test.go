@@ -0,0 +1,85 @@
+package xiangqi
+
+import "testing"
+
+func TestFENRoundTrip(t *testing.T) {
+	if got := NewBoard().FEN(); got != InitialFEN {
+		t.Errorf("NewBoard().FEN() = %s, want %s", got, InitialFEN)
+	}
+	fens := []string{
+		InitialFEN,
+		"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C2C4/9/RNBAKABNR b - - 1 1",
+		"r1bakab1r/9/1cn4cn/p1p1p1p1p/9/9/P1P1P1P1P/1C2C1N2/9/RNBAKAB1R w - - 4 3",
+		"3k5/4a4/9/9/9/9/9/9/4A4/3AK4 b - - 38 60",
+	}
+	for _, fen := range fens {
+		b, err := ParseFEN(fen)
+		if err != nil {
+			t.Errorf("ParseFEN(%q): %v", fen, err)
+			continue
+		}
+		if got := b.FEN(); got != fen {
+			t.Errorf("round trip of %q = %q", fen, got)
+		}
+	}
+}
+
+func TestFENAfterMoves(t *testing.T) {
+	b := NewBoard()
+	// 炮二平五 马８进７
+	for _, m := range []Move{
+		{From: Pos{X: 7, Y: 7}, To: Pos{X: 4, Y: 7}},
+		{From: Pos{X: 7, Y: 0}, To: Pos{X: 6, Y: 2}},
+	} {
+		if _, err := b.MakeMove(m); err != nil {
+			t.Fatalf("MakeMove(%+v): %v", m, err)
+		}
+	}
+	want := "rnbakab1r/9/1c4nc1/p1p1p1p1p/9/9/P1P1P1P1P/1C2C4/9/RNBAKABNR w - - 2 2"
+	if got := b.FEN(); got != want {
+		t.Errorf("FEN() = %s, want %s", got, want)
+	}
+	parsed, err := ParseFEN(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Hash() != b.Hash() {
+		t.Error("parsed position hashes differently from played position")
+	}
+}
+
+func TestParseFENErrors(t *testing.T) {
+	for _, fen := range []string{
+		"",
+		"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9 w - - 0 1",
+		"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9/RNBAKABNRR w - - 0 1",
+		"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9/RNBAKAXNR w - - 0 1",
+		"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9/RNBAKABNR x - - 0 1",
+		"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9/RNBAKABNR w - - -1 1",
+		"rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C5C1/9/RNBAKABNR w - - 0 0",
+	} {
+		if _, err := ParseFEN(fen); err == nil {
+			t.Errorf("ParseFEN(%q) succeeded, want error", fen)
+		}
+	}
+}
+
+func TestCheckPosition(t *testing.T) {
+	if errs := NewBoard().CheckPosition(); len(errs) != 0 {
+		t.Errorf("initial position reported %v", errs)
+	}
+	for _, fen := range []string{
+		"9/9/9/9/9/9/9/9/9/4K4 w - - 0 1",       // 缺少黑将
+		"4k4/9/9/9/9/9/9/9/9/4K4 w - - 0 1",     // 将帅照面
+		"3k5/9/9/9/9/9/9/9/4A4/3AKA3 w - - 0 1", // 三个仕
+		"3k5/9/9/9/9/9/9/9/P8/4K4 w - - 0 1",    // 兵在己方底线以下
+	} {
+		b, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatalf("ParseFEN(%q): %v", fen, err)
+		}
+		if errs := b.CheckPosition(); len(errs) == 0 {
+			t.Errorf("CheckPosition(%q) reported no problem", fen)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package xiangqi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseHistory 解析 GameRecord.History 的紧凑格式：每步 4 位数字 fromX fromY toX toY（红方视角）
+func ParseHistory(s string) ([]Move, error) {
+	s = strings.TrimSpace(s)
+	if len(s)%4 != 0 {
+		return nil, fmt.Errorf("棋谱长度 %d 不是 4 的倍数", len(s))
+	}
+	moves := make([]Move, 0, len(s)/4)
+	for i := 0; i < len(s); i += 4 {
+		var d [4]int
+		for j := 0; j < 4; j++ {
+			ch := s[i+j]
+			if ch < '0' || ch > '9' {
+				return nil, fmt.Errorf("第 %d 步包含非法字符 %q", i/4+1, ch)
+			}
+			d[j] = int(ch - '0')
+		}
+		m := Move{From: Pos{X: d[0], Y: d[1]}, To: Pos{X: d[2], Y: d[3]}}
+		if !m.From.Valid() || !m.To.Valid() {
+			return nil, fmt.Errorf("第 %d 步坐标超出棋盘", i/4+1)
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// FormatHistory 将走法序列编码为 GameRecord.History 的紧凑格式
+func FormatHistory(moves []Move) string {
+	var sb strings.Builder
+	for _, m := range moves {
+		fmt.Fprintf(&sb, "%d%d%d%d", m.From.X, m.From.Y, m.To.X, m.To.Y)
+	}
+	return sb.String()
+}
+
+// Replay 从 start 局面依次校验并执行走法，返回每一步之后的局面（下标 0 为起始局面的拷贝）
+func Replay(start *Board, moves []Move) ([]*Board, error) {
+	boards := make([]*Board, 0, len(moves)+1)
+	b := start.Clone()
+	boards = append(boards, b.Clone())
+	for i, m := range moves {
+		if _, err := b.MakeMove(m); err != nil {
+			return boards, fmt.Errorf("第 %d 步 %s 不合法：%w", i+1, FormatHistory([]Move{m}), err)
+		}
+		boards = append(boards, b.Clone())
+	}
+	return boards, nil
+}
@@ -0,0 +1,251 @@
+package xiangqi
+
+var (
+	orthogonal = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	diagonal   = [4][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	// 马的八个落点及对应的马腿偏移
+	horseSteps = [8]struct{ dx, dy, legX, legY int }{
+		{1, 2, 0, 1}, {-1, 2, 0, 1}, {1, -2, 0, -1}, {-1, -2, 0, -1},
+		{2, 1, 1, 0}, {2, -1, 1, 0}, {-2, 1, -1, 0}, {-2, -1, -1, 0},
+	}
+)
+
+// pseudoTargets 生成指定棋子的所有伪合法落点（符合棋子走法，但不检查走后是否被将军）
+func (b *Board) pseudoTargets(from Pos) []Pos {
+	piece := b.At(from)
+	if piece.IsEmpty() {
+		return nil
+	}
+	targets := make([]Pos, 0, 17)
+	add := func(to Pos) {
+		if !to.Valid() {
+			return
+		}
+		if t := b.At(to); !t.IsEmpty() && t.Color == piece.Color {
+			return
+		}
+		targets = append(targets, to)
+	}
+
+	switch piece.Kind {
+	case General:
+		for _, d := range orthogonal {
+			to := Pos{X: from.X + d[0], Y: from.Y + d[1]}
+			if inPalace(to, piece.Color) {
+				add(to)
+			}
+		}
+	case Advisor:
+		for _, d := range diagonal {
+			to := Pos{X: from.X + d[0], Y: from.Y + d[1]}
+			if inPalace(to, piece.Color) {
+				add(to)
+			}
+		}
+	case Elephant:
+		for _, d := range diagonal {
+			eye := Pos{X: from.X + d[0], Y: from.Y + d[1]}
+			to := Pos{X: from.X + 2*d[0], Y: from.Y + 2*d[1]}
+			// 象眼被塞或过河都不能走
+			if to.Valid() && ownSide(to, piece.Color) && b.At(eye).IsEmpty() {
+				add(to)
+			}
+		}
+	case Horse:
+		for _, s := range horseSteps {
+			leg := Pos{X: from.X + s.legX, Y: from.Y + s.legY}
+			to := Pos{X: from.X + s.dx, Y: from.Y + s.dy}
+			// 蹩马腿
+			if to.Valid() && b.At(leg).IsEmpty() {
+				add(to)
+			}
+		}
+	case Chariot:
+		for _, d := range orthogonal {
+			for to := (Pos{X: from.X + d[0], Y: from.Y + d[1]}); to.Valid(); to = (Pos{X: to.X + d[0], Y: to.Y + d[1]}) {
+				add(to)
+				if !b.At(to).IsEmpty() {
+					break
+				}
+			}
+		}
+	case Cannon:
+		for _, d := range orthogonal {
+			screened := false
+			for to := (Pos{X: from.X + d[0], Y: from.Y + d[1]}); to.Valid(); to = (Pos{X: to.X + d[0], Y: to.Y + d[1]}) {
+				t := b.At(to)
+				if !screened {
+					if t.IsEmpty() {
+						add(to)
+					} else {
+						// 遇到炮架，之后只能隔子吃
+						screened = true
+					}
+					continue
+				}
+				if !t.IsEmpty() {
+					if t.Color != piece.Color {
+						add(to)
+					}
+					break
+				}
+			}
+		}
+	case Soldier:
+		add(Pos{X: from.X, Y: from.Y + forward(piece.Color)})
+		// 过河后可以横走
+		if !ownSide(from, piece.Color) {
+			add(Pos{X: from.X - 1, Y: from.Y})
+			add(Pos{X: from.X + 1, Y: from.Y})
+		}
+	}
+	return targets
+}
+
+// PseudoMoves 返回指定颜色所有棋子的伪合法走法（不过滤送将）
+func (b *Board) PseudoMoves(c Color) []Move {
+	moves := make([]Move, 0, 64)
+	for y := 0; y <= 9; y++ {
+		for x := 0; x <= 8; x++ {
+			if p := b.cells[y][x]; p.IsEmpty() || p.Color != c {
+				continue
+			}
+			from := Pos{X: x, Y: y}
+			for _, to := range b.pseudoTargets(from) {
+				moves = append(moves, Move{From: from, To: to})
+			}
+		}
+	}
+	return moves
+}
+
+// LegalMoves 返回当前行棋方的全部合法走法
+func (b *Board) LegalMoves() []Move {
+	pseudo := b.PseudoMoves(b.Turn)
+	legal := pseudo[:0]
+	for _, m := range pseudo {
+		if !b.leavesInCheck(m) {
+			legal = append(legal, m)
+		}
+	}
+	return legal
+}
+
+// LegalMovesFrom 返回当前行棋方某个棋子的全部合法走法
+func (b *Board) LegalMovesFrom(from Pos) []Move {
+	piece := b.At(from)
+	if piece.IsEmpty() || piece.Color != b.Turn {
+		return nil
+	}
+	moves := make([]Move, 0, 17)
+	for _, to := range b.pseudoTargets(from) {
+		m := Move{From: from, To: to}
+		if !b.leavesInCheck(m) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// GeneralsFacing 判断将帅是否在同一列且中间无子（飞将/照面）
+func (b *Board) GeneralsFacing() bool {
+	red, ok1 := b.General(Red)
+	black, ok2 := b.General(Black)
+	if !ok1 || !ok2 || red.X != black.X {
+		return false
+	}
+	for y := black.Y + 1; y < red.Y; y++ {
+		if !b.cells[y][red.X].IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// InCheck 判断指定颜色是否正被将军（将帅照面同样视为被将军）
+func (b *Board) InCheck(c Color) bool {
+	g, ok := b.General(c)
+	if !ok {
+		// 没有将帅的局面视为已被将死，避免继续行棋
+		return true
+	}
+	return b.GeneralsFacing() || b.IsAttacked(g, c.Opponent())
+}
+
+// IsAttacked 判断坐标 target 是否被 by 方的任一棋子攻击（不含将帅照面）
+func (b *Board) IsAttacked(target Pos, by Color) bool {
+	return len(b.Attackers(target, by)) > 0
+}
+
+// Attackers 返回 by 方所有能吃到 target 位置的棋子坐标
+func (b *Board) Attackers(target Pos, by Color) []Pos {
+	var result []Pos
+	is := func(p Pos, k Kind) bool {
+		piece := b.At(p)
+		return p.Valid() && piece.Kind == k && piece.Color == by
+	}
+
+	// 车、炮：沿四个方向查找第一个与第二个棋子
+	for _, d := range orthogonal {
+		seen := 0
+		for p := (Pos{X: target.X + d[0], Y: target.Y + d[1]}); p.Valid(); p = (Pos{X: p.X + d[0], Y: p.Y + d[1]}) {
+			if b.At(p).IsEmpty() {
+				continue
+			}
+			seen++
+			if seen == 1 && is(p, Chariot) {
+				result = append(result, p)
+			}
+			if seen == 2 {
+				if is(p, Cannon) {
+					result = append(result, p)
+				}
+				break
+			}
+		}
+	}
+
+	// 马：反向推算马的位置，马腿位于马的一侧
+	for _, s := range horseSteps {
+		h := Pos{X: target.X - s.dx, Y: target.Y - s.dy}
+		leg := Pos{X: h.X + s.legX, Y: h.Y + s.legY}
+		if is(h, Horse) && b.At(leg).IsEmpty() {
+			result = append(result, h)
+		}
+	}
+
+	// 兵卒：正前方一格，或过河后的左右一格
+	if p := (Pos{X: target.X, Y: target.Y - forward(by)}); is(p, Soldier) {
+		result = append(result, p)
+	}
+	for _, dx := range []int{-1, 1} {
+		p := Pos{X: target.X + dx, Y: target.Y}
+		if is(p, Soldier) && !ownSide(p, by) {
+			result = append(result, p)
+		}
+	}
+
+	// 仕、相、将只在己方区域内活动
+	if inPalace(target, by) {
+		for _, d := range diagonal {
+			if p := (Pos{X: target.X + d[0], Y: target.Y + d[1]}); is(p, Advisor) {
+				result = append(result, p)
+			}
+		}
+		for _, d := range orthogonal {
+			if p := (Pos{X: target.X + d[0], Y: target.Y + d[1]}); is(p, General) {
+				result = append(result, p)
+			}
+		}
+	}
+	if ownSide(target, by) {
+		for _, d := range diagonal {
+			p := Pos{X: target.X + 2*d[0], Y: target.Y + 2*d[1]}
+			eye := Pos{X: target.X + d[0], Y: target.Y + d[1]}
+			if is(p, Elephant) && b.At(eye).IsEmpty() {
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package xiangqi
+
+import "testing"
+
+// perft 统计从当前局面出发 depth 层内的叶子节点数
+func perft(b *Board, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	moves := b.LegalMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, m := range moves {
+		captured := b.Play(m)
+		nodes += perft(b, depth-1)
+		b.Unplay(m, captured)
+	}
+	return nodes
+}
+
+func TestPerftInitial(t *testing.T) {
+	want := []int{1, 44, 1920, 79666}
+	for depth := 1; depth < len(want); depth++ {
+		b := NewBoard()
+		if got := perft(b, depth); got != want[depth] {
+			t.Errorf("perft(%d) = %d, want %d", depth, got, want[depth])
+		}
+		if b.FEN() != InitialFEN {
+			t.Fatalf("perft(%d) left board at %s", depth, b.FEN())
+		}
+	}
+}
+
+func TestFlyingGeneral(t *testing.T) {
+	// 车挡在将帅之间，只能沿中路移动
+	b, err := ParseFEN("4k4/9/9/9/9/9/9/9/4R4/4K4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rook := Pos{X: 4, Y: 8}
+	for _, m := range b.LegalMovesFrom(rook) {
+		if m.To.X != 4 {
+			t.Errorf("rook pinned by facing generals moved sideways: %+v", m)
+		}
+	}
+	if err := b.Validate(Move{From: rook, To: Pos{X: 0, Y: 8}}); err != ErrSelfCheck {
+		t.Errorf("Validate(rook leaves file) = %v, want %v", err, ErrSelfCheck)
+	}
+	if err := b.Validate(Move{From: rook, To: Pos{X: 4, Y: 1}}); err != nil {
+		t.Errorf("Validate(rook along file) = %v, want nil", err)
+	}
+
+	// 帅不能走到与将照面的位置
+	b, err = ParseFEN("3k5/9/9/9/9/9/9/9/9/4K4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Validate(Move{From: Pos{X: 4, Y: 9}, To: Pos{X: 3, Y: 9}}); err != ErrSelfCheck {
+		t.Errorf("Validate(general faces general) = %v, want %v", err, ErrSelfCheck)
+	}
+	b.Set(Pos{X: 4, Y: 9}, Piece{})
+	b.Set(Pos{X: 3, Y: 9}, Piece{Kind: General, Color: Red})
+	if !b.GeneralsFacing() || !b.InCheck(Red) || !b.InCheck(Black) {
+		t.Error("facing generals should count as check for both sides")
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	b := NewBoard()
+	tests := []struct {
+		name string
+		move Move
+		want error
+	}{
+		{"out of board", Move{From: Pos{X: 0, Y: 9}, To: Pos{X: 0, Y: 10}}, ErrOutOfBoard},
+		{"same location", Move{From: Pos{X: 0, Y: 9}, To: Pos{X: 0, Y: 9}}, ErrSameLocation},
+		{"no piece", Move{From: Pos{X: 0, Y: 5}, To: Pos{X: 0, Y: 4}}, ErrNoPiece},
+		{"not your turn", Move{From: Pos{X: 0, Y: 3}, To: Pos{X: 0, Y: 4}}, ErrNotYourTurn},
+		{"horse", Move{From: Pos{X: 1, Y: 9}, To: Pos{X: 0, Y: 7}}, nil},
+		{"blocked horse leg", Move{From: Pos{X: 1, Y: 9}, To: Pos{X: 3, Y: 8}}, ErrIllegalMove},
+		{"elephant", Move{From: Pos{X: 2, Y: 9}, To: Pos{X: 4, Y: 7}}, nil},
+		{"soldier sideways before river", Move{From: Pos{X: 0, Y: 6}, To: Pos{X: 1, Y: 6}}, ErrIllegalMove},
+		{"cannon jumps to capture", Move{From: Pos{X: 1, Y: 7}, To: Pos{X: 1, Y: 0}}, nil},
+		{"cannon captures without screen", Move{From: Pos{X: 1, Y: 7}, To: Pos{X: 1, Y: 2}}, ErrIllegalMove},
+	}
+	for _, tt := range tests {
+		if err := b.Validate(tt.move); err != tt.want {
+			t.Errorf("%s: Validate(%+v) = %v, want %v", tt.name, tt.move, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package xiangqi
+
+// Color 棋子颜色（与 websocket 中的 clientRole 取值保持一致：1=红，2=黑）
+type Color int
+
+const (
+	NoColor Color = iota
+	Red
+	Black
+)
+
+// Opponent 返回对方颜色
+func (c Color) Opponent() Color {
+	switch c {
+	case Red:
+		return Black
+	case Black:
+		return Red
+	}
+	return NoColor
+}
+
+func (c Color) String() string {
+	switch c {
+	case Red:
+		return "red"
+	case Black:
+		return "black"
+	}
+	return ""
+}
+
+// Kind 棋子种类
+type Kind int
+
+const (
+	Empty    Kind = iota
+	General       // 帅/将
+	Advisor       // 仕/士
+	Elephant      // 相/象
+	Horse         // 马
+	Chariot       // 车
+	Cannon        // 炮
+	Soldier       // 兵/卒
+)
+
+// Piece 棋盘上的一枚棋子，零值表示空位
+type Piece struct {
+	Kind  Kind
+	Color Color
+}
+
+func (p Piece) IsEmpty() bool {
+	return p.Kind == Empty
+}
+
+// Pos 棋盘坐标，统一使用红方视角：x 为 0-8 从左到右，y 为 0-9 从上到下，红方在下（y=9 为红方底线）
+type Pos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (p Pos) Valid() bool {
+	return p.X >= 0 && p.X <= 8 && p.Y >= 0 && p.Y <= 9
+}
+
+// Flip 返回旋转 180 度后的坐标（红黑视角互换）
+func (p Pos) Flip() Pos {
+	return Pos{X: 8 - p.X, Y: 9 - p.Y}
+}
+
+// Move 一步棋
+type Move struct {
+	From Pos `json:"from"`
+	To   Pos `json:"to"`
+}
+
+// Flip 返回旋转 180 度后的走法
+func (m Move) Flip() Move {
+	return Move{From: m.From.Flip(), To: m.To.Flip()}
+}
+
+// inPalace 判断坐标是否在指定颜色的九宫内
+func inPalace(p Pos, c Color) bool {
+	if p.X < 3 || p.X > 5 {
+		return false
+	}
+	if c == Red {
+		return p.Y >= 7 && p.Y <= 9
+	}
+	return p.Y >= 0 && p.Y <= 2
+}
+
+// ownSide 判断坐标是否在指定颜色一侧（未过河）
+func ownSide(p Pos, c Color) bool {
+	if c == Red {
+		return p.Y >= 5
+	}
+	return p.Y <= 4
+}
+
+// forward 返回指定颜色兵卒前进方向的 y 增量
+func forward(c Color) int {
+	if c == Red {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,186 @@
+package xiangqi
+
+// RepetitionLimit 同一局面（含行棋方）出现的次数达到该值时进行循环裁决
+var RepetitionLimit = 3
+
+// MoveClass 循环裁决中对一步棋的分类
+type MoveClass int
+
+const (
+	Idle  MoveClass = iota // 闲着
+	Check                  // 将军
+	Chase                  // 捉子
+)
+
+// pieceValue 判断“捉”时使用的子力价值
+var pieceValue = map[Kind]int{
+	General:  100,
+	Chariot:  9,
+	Horse:    4,
+	Cannon:   4,
+	Advisor:  2,
+	Elephant: 2,
+	Soldier:  1,
+}
+
+// chasedTargets 返回 c 方在当前局面下“捉”住的对方棋子位置
+// 按中国象棋协会规则：将帅、兵卒捉子不算捉；被攻击的将帅属于将军；未过河的兵卒被攻击不算捉；
+// 攻击必须是真实可吃的（吃子后不致己方被将军），且目标无根，或目标价值高于攻击子（如马炮捉车）
+func (b *Board) chasedTargets(c Color) map[Pos]bool {
+	targets := make(map[Pos]bool)
+	for y := 0; y <= 9; y++ {
+		for x := 0; x <= 8; x++ {
+			target := b.cells[y][x]
+			if target.IsEmpty() || target.Color == c || target.Kind == General {
+				continue
+			}
+			tp := Pos{X: x, Y: y}
+			if target.Kind == Soldier && ownSide(tp, target.Color) {
+				continue
+			}
+			for _, from := range b.Attackers(tp, c) {
+				attacker := b.At(from)
+				if attacker.Kind == General || attacker.Kind == Soldier {
+					continue
+				}
+				capture := Move{From: from, To: tp}
+				saved := b.Turn
+				b.Turn = c
+				captured := b.apply(capture)
+				real := !b.InCheck(c)
+				protected := b.IsAttacked(tp, target.Color)
+				b.undo(capture, captured)
+				b.Turn = saved
+				if real && (!protected || pieceValue[attacker.Kind] < pieceValue[target.Kind]) {
+					targets[tp] = true
+					break
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// ClassifyMove 在走子前判断这步棋属于将军、捉子还是闲着，不修改棋盘
+func (b *Board) ClassifyMove(m Move) MoveClass {
+	mover := b.At(m.From).Color
+	before := b.chasedTargets(mover)
+	nb := b.Clone()
+	nb.apply(m)
+	if nb.InCheck(mover.Opponent()) {
+		return Check
+	}
+	for p := range nb.chasedTargets(mover) {
+		if !before[p] {
+			return Chase
+		}
+	}
+	return Idle
+}
+
+// Verdict 循环局面的裁决结果
+type Verdict struct {
+	Loser Color     // 判负方，NoColor 表示判和
+	Kind  MoveClass // 判负原因：Check=长将，Chase=长捉；判和时为 Idle
+}
+
+type plyRecord struct {
+	hash  uint64
+	mover Color
+	class MoveClass
+}
+
+// RepetitionTracker 记录每步棋后的局面哈希与着法分类，用于检测循环并按长将、长捉规则裁决
+type RepetitionTracker struct {
+	start uint64
+	plies []plyRecord
+}
+
+// NewRepetitionTracker 以 b 作为起始局面创建记录器
+func NewRepetitionTracker(b *Board) *RepetitionTracker {
+	return &RepetitionTracker{start: b.Hash()}
+}
+
+// Push 记录一步棋：after 为走子后的局面，class 为走子前由 ClassifyMove 得到的分类
+func (t *RepetitionTracker) Push(after *Board, mover Color, class MoveClass) {
+	t.plies = append(t.plies, plyRecord{hash: after.Hash(), mover: mover, class: class})
+}
+
+// Hashes 返回起始局面与此后每步棋后的局面哈希，按出现顺序排列
+func (t *RepetitionTracker) Hashes() []uint64 {
+	hashes := make([]uint64, 0, len(t.plies)+1)
+	hashes = append(hashes, t.start)
+	for _, p := range t.plies {
+		hashes = append(hashes, p.hash)
+	}
+	return hashes
+}
+
+// Judge 若最新局面已重复达到 RepetitionLimit 次，则对循环内双方的着法进行裁决
+// 规则：单方长将或长捉（含一将一捉）判负；一方长将、另一方长捉时长将方判负；双方同类违例或均为闲着判和
+func (t *RepetitionTracker) Judge() (Verdict, bool) {
+	n := len(t.plies)
+	if n == 0 {
+		return Verdict{}, false
+	}
+	cur := t.plies[n-1].hash
+	// 从后往前找到足够多的相同局面，first 为循环窗口起点局面的下标（-1 表示起始局面）
+	count := 1
+	first := n - 1
+	for i := n - 2; i >= -1 && count < RepetitionLimit; i-- {
+		h := t.start
+		if i >= 0 {
+			h = t.plies[i].hash
+		}
+		if h == cur {
+			count++
+			first = i
+		}
+	}
+	if count < RepetitionLimit {
+		return Verdict{}, false
+	}
+
+	// 统计循环窗口内双方着法
+	type summary struct {
+		moves, checks, chases int
+	}
+	stats := map[Color]*summary{Red: {}, Black: {}}
+	for _, p := range t.plies[first+1:] {
+		s := stats[p.mover]
+		s.moves++
+		switch p.class {
+		case Check:
+			s.checks++
+		case Chase:
+			s.chases++
+		}
+	}
+	violation := func(c Color) MoveClass {
+		s := stats[c]
+		if s.moves == 0 {
+			return Idle
+		}
+		if s.checks == s.moves {
+			return Check
+		}
+		if s.checks+s.chases == s.moves {
+			return Chase
+		}
+		return Idle
+	}
+
+	red, black := violation(Red), violation(Black)
+	switch {
+	case red == black:
+		return Verdict{}, true
+	case red == Check:
+		return Verdict{Loser: Red, Kind: Check}, true
+	case black == Check:
+		return Verdict{Loser: Black, Kind: Check}, true
+	case red == Chase:
+		return Verdict{Loser: Red, Kind: Chase}, true
+	default:
+		return Verdict{Loser: Black, Kind: Chase}, true
+	}
+}
@@ -0,0 +1,106 @@
+package xiangqi
+
+import "testing"
+
+// playCycle 从 fen 开始循环走 cycle 中的着法 rounds 次，每步按对局中的方式分类并记录；
+// 返回每步之后 Judge 是否触发，以及最后一步之后的裁决
+func playCycle(t *testing.T, fen string, cycle []Move, rounds int) ([]bool, Verdict) {
+	t.Helper()
+	b, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewRepetitionTracker(b)
+	var judged []bool
+	for r := 0; r < rounds; r++ {
+		for _, m := range cycle {
+			mover := b.Turn
+			class := b.ClassifyMove(m)
+			if _, err := b.MakeMove(m); err != nil {
+				t.Fatalf("MakeMove(%+v): %v", m, err)
+			}
+			tracker.Push(b, mover, class)
+			_, ok := tracker.Judge()
+			judged = append(judged, ok)
+		}
+	}
+	verdict, _ := tracker.Judge()
+	return judged, verdict
+}
+
+func TestJudgePerpetualCheck(t *testing.T) {
+	// 红车在 a 线上下将军，黑将在 e 线上下躲避
+	cycle := []Move{
+		{From: Pos{X: 0, Y: 1}, To: Pos{X: 0, Y: 0}},
+		{From: Pos{X: 4, Y: 0}, To: Pos{X: 4, Y: 1}},
+		{From: Pos{X: 0, Y: 0}, To: Pos{X: 0, Y: 1}},
+		{From: Pos{X: 4, Y: 1}, To: Pos{X: 4, Y: 0}},
+	}
+	judged, verdict := playCycle(t, "4k4/R8/9/9/9/9/9/9/9/5K3 w - - 0 1", cycle, 2)
+	for i, ok := range judged[:len(judged)-1] {
+		if ok {
+			t.Fatalf("Judge() triggered after ply %d, before the position repeated %d times", i+1, RepetitionLimit)
+		}
+	}
+	if !judged[len(judged)-1] {
+		t.Fatal("Judge() did not trigger on the third occurrence")
+	}
+	if want := (Verdict{Loser: Red, Kind: Check}); verdict != want {
+		t.Errorf("verdict = %+v, want %+v", verdict, want)
+	}
+}
+
+func TestJudgeIdleRepetitionIsDraw(t *testing.T) {
+	// 双方来回走车，均为闲着
+	cycle := []Move{
+		{From: Pos{X: 0, Y: 9}, To: Pos{X: 0, Y: 8}},
+		{From: Pos{X: 8, Y: 0}, To: Pos{X: 8, Y: 1}},
+		{From: Pos{X: 0, Y: 8}, To: Pos{X: 0, Y: 9}},
+		{From: Pos{X: 8, Y: 1}, To: Pos{X: 8, Y: 0}},
+	}
+	judged, verdict := playCycle(t, "3k4r/9/9/9/9/9/9/9/9/R3K4 w - - 0 1", cycle, 2)
+	if !judged[len(judged)-1] {
+		t.Fatal("Judge() did not trigger on the third occurrence")
+	}
+	if verdict != (Verdict{}) {
+		t.Errorf("verdict = %+v, want draw", verdict)
+	}
+}
+
+func TestJudgePerpetualChase(t *testing.T) {
+	// 黑车来回捉无根的红马，红马躲闪为闲着
+	cycle := []Move{
+		{From: Pos{X: 6, Y: 4}, To: Pos{X: 7, Y: 6}},
+		{From: Pos{X: 6, Y: 0}, To: Pos{X: 7, Y: 0}},
+		{From: Pos{X: 7, Y: 6}, To: Pos{X: 6, Y: 4}},
+		{From: Pos{X: 7, Y: 0}, To: Pos{X: 6, Y: 0}},
+	}
+	judged, verdict := playCycle(t, "3k2r2/9/9/9/6N2/9/9/9/9/5K3 w - - 0 1", cycle, 2)
+	if !judged[len(judged)-1] {
+		t.Fatal("Judge() did not trigger on the third occurrence")
+	}
+	if want := (Verdict{Loser: Black, Kind: Chase}); verdict != want {
+		t.Errorf("verdict = %+v, want %+v", verdict, want)
+	}
+}
+
+func TestClassifyMove(t *testing.T) {
+	b, err := ParseFEN("3k5/9/8r/9/9/9/9/9/9/R3K4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		move Move
+		want MoveClass
+	}{
+		{"check", Move{From: Pos{X: 0, Y: 9}, To: Pos{X: 3, Y: 9}}, Check},
+		{"idle", Move{From: Pos{X: 0, Y: 9}, To: Pos{X: 0, Y: 5}}, Idle},
+		{"chase unprotected rook", Move{From: Pos{X: 0, Y: 9}, To: Pos{X: 0, Y: 2}}, Chase},
+	}
+	for _, tt := range tests {
+		if got := b.ClassifyMove(tt.move); got != tt.want {
+			t.Errorf("%s: ClassifyMove(%+v) = %d, want %d", tt.name, tt.move, got, tt.want)
+		}
+	}
+}
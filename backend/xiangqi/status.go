@@ -0,0 +1,47 @@
+package xiangqi
+
+// Status 局面状态
+type Status int
+
+const (
+	Ongoing   Status = iota // 对局继续
+	Checkmate               // 将死：被将军且无合法着法
+	Stalemate               // 困毙：未被将军但无合法着法（象棋规则中同样判负）
+)
+
+// HasLegalMove 判断当前行棋方是否至少有一步合法着法
+func (b *Board) HasLegalMove() bool {
+	for y := 0; y <= 9; y++ {
+		for x := 0; x <= 8; x++ {
+			if p := b.cells[y][x]; p.IsEmpty() || p.Color != b.Turn {
+				continue
+			}
+			from := Pos{X: x, Y: y}
+			for _, to := range b.pseudoTargets(from) {
+				if !b.leavesInCheck(Move{From: from, To: to}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Status 判断当前行棋方的局面状态
+func (b *Board) Status() Status {
+	if b.HasLegalMove() {
+		return Ongoing
+	}
+	if b.InCheck(b.Turn) {
+		return Checkmate
+	}
+	return Stalemate
+}
+
+// Winner 返回终局时的胜方：无子可走的一方（当前行棋方）判负；对局未结束时返回 NoColor
+func (b *Board) Winner() Color {
+	if b.Status() == Ongoing {
+		return NoColor
+	}
+	return b.Turn.Opponent()
+}
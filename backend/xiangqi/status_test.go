@@ -0,0 +1,37 @@
+package xiangqi
+
+import "testing"
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		status Status
+		winner Color
+	}{
+		{"initial", InitialFEN, Ongoing, NoColor},
+		// 车将军，将不能躲到与帅照面的 e 线
+		{"rook mate with facing generals", "3k5/9/9/9/9/9/9/9/9/3RK4 b - - 0 1", Checkmate, Red},
+		// 双车错杀
+		{"double rook mate", "R2k5/R8/9/9/9/9/9/9/9/4K4 b - - 0 1", Checkmate, Red},
+		// 未被将军但无子可走，象棋中同样判负
+		{"stalemate", "3k5/R8/9/9/9/9/9/9/9/4K4 b - - 0 1", Stalemate, Red},
+		{"red stalemated", "4k4/9/9/9/9/9/9/9/r8/3K5 w - - 0 1", Stalemate, Black},
+		{"check with escape", "3k5/9/9/9/9/9/9/9/9/3R1K3 b - - 0 1", Ongoing, NoColor},
+	}
+	for _, tt := range tests {
+		b, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: ParseFEN: %v", tt.name, err)
+		}
+		if got := b.Status(); got != tt.status {
+			t.Errorf("%s: Status() = %d, want %d", tt.name, got, tt.status)
+		}
+		if got := b.Winner(); got != tt.winner {
+			t.Errorf("%s: Winner() = %v, want %v", tt.name, got, tt.winner)
+		}
+		if got := b.HasLegalMove(); got != (tt.status == Ongoing) {
+			t.Errorf("%s: HasLegalMove() = %v", tt.name, got)
+		}
+	}
+}
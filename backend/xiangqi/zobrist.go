@@ -0,0 +1,53 @@
+package xiangqi
+
+import "math/rand/v2"
+
+var (
+	// zobristPieces 按 [颜色][棋子种类][y][x] 存放随机数
+	zobristPieces [3][8][10][9]uint64
+	zobristBlack  uint64 // 轮到黑方走棋时异或
+)
+
+func init() {
+	// 固定种子，保证不同进程、重启前后得到相同的局面哈希
+	r := rand.New(rand.NewPCG(0x5869616e67716921, 0x4368657373486173))
+	for c := range zobristPieces {
+		for k := range zobristPieces[c] {
+			for y := range zobristPieces[c][k] {
+				for x := range zobristPieces[c][k][y] {
+					zobristPieces[c][k][y][x] = r.Uint64()
+				}
+			}
+		}
+	}
+	zobristBlack = r.Uint64()
+}
+
+// Hash 返回局面的 Zobrist 哈希（包含行棋方），相同局面得到相同哈希
+func (b *Board) Hash() uint64 {
+	var h uint64
+	for y := 0; y <= 9; y++ {
+		for x := 0; x <= 8; x++ {
+			if p := b.cells[y][x]; !p.IsEmpty() {
+				h ^= zobristPieces[p.Color][p.Kind][y][x]
+			}
+		}
+	}
+	if b.Turn == Black {
+		h ^= zobristBlack
+	}
+	return h
+}
+
+// PieceKey 返回棋子位于指定坐标时参与 Zobrist 哈希的随机数，供增量更新哈希使用
+func PieceKey(p Piece, pos Pos) uint64 {
+	if p.IsEmpty() || !pos.Valid() {
+		return 0
+	}
+	return zobristPieces[p.Color][p.Kind][pos.Y][pos.X]
+}
+
+// TurnKey 返回行棋方切换时需要异或的随机数
+func TurnKey() uint64 {
+	return zobristBlack
+}
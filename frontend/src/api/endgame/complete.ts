@@ -12,6 +12,7 @@ export interface EndgameCompleteResponse {
 }
 
 // 残局挑战结算：仅在首次通关按难度奖励经验（中级50/高级100）
+/** @deprecated 残局挑战改为通过 websocket 与服务端 AI 对弈，通关奖励由服务端结算；该接口现在总是返回错误 */
 export function reportEndgameComplete(payload: EndgameCompleteRequest) {
   return Request.post<EndgameCompleteResponse>('/user/endgame/complete', payload)
 }
@@ -17,6 +17,7 @@ export function getEndgameProgress() {
 }
 
 // 保存单个残局关卡的一次挑战结果
+/** @deprecated 残局进度由服务端按对局结果记录；该接口现在总是返回错误 */
 export function saveEndgameProgress(params: { scenarioId: string; result: 'win' | 'lose'; steps?: number }) {
   const payload: any = {
     scenario_id: params.scenarioId,
@@ -39,6 +39,7 @@ export interface SaveGameRecordRequest {
 
 /**
  * 保存人机对战记录
+ * @deprecated 人机对局改为通过 websocket 与服务端 AI 对弈，对局记录由服务端保存；该接口现在总是返回错误
  * @param data SaveGameRecordRequest
  * @returns Promise
  */